@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+const abVariantPropertyKey = "abVariant"
+
+// ABTestPlugin assigns users to variants with an external experimentation framework, such as
+// LaunchDarkly, Split or Optimizely.
+type ABTestPlugin interface {
+	GetVariant(ctx context.Context, experimentKey string, userKey string) (string, error)
+}
+
+// abTestAssignment sends each user's variant of an external experiment in the abVariant request
+// property.
+type abTestAssignment struct {
+	plugin        ABTestPlugin
+	experimentKey string
+}
+
+// middleware sends the request without a variant if the plugin fails, so delivery doesn't depend on
+// the experimentation framework being up.
+func (a *abTestAssignment) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		variant, err := a.plugin.GetVariant(ctx, a.experimentKey, requestUserID(req.Request))
+		if err != nil {
+			log.Printf("WARN: error getting variant of experiment %s: %v\n", a.experimentKey, err)
+			return next(ctx, req)
+		}
+		if variant == "" {
+			return next(ctx, req)
+		}
+
+		req = cloneRequest(req)
+		if err := setProperty(&req.Request.Properties, abVariantPropertyKey, variant); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
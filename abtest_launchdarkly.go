@@ -0,0 +1,25 @@
+//go:build launchdarkly
+
+package main
+
+import (
+	"context"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	ldclient "github.com/launchdarkly/go-server-sdk/v6"
+)
+
+// launchDarklyPlugin evaluates experiments as LaunchDarkly string flags.
+type launchDarklyPlugin struct {
+	client *ldclient.LDClient
+}
+
+// LaunchDarklyPlugin adapts a LaunchDarkly client to ABTestPlugin, evaluating each experiment key as
+// a string flag for a context keyed by the user. Build with the launchdarkly tag to use it.
+func LaunchDarklyPlugin(client *ldclient.LDClient) ABTestPlugin {
+	return &launchDarklyPlugin{client: client}
+}
+
+func (p *launchDarklyPlugin) GetVariant(_ context.Context, experimentKey string, userKey string) (string, error) {
+	return p.client.StringVariation(experimentKey, ldcontext.New(userKey), "")
+}
@@ -0,0 +1,38 @@
+//go:build launchdarkly
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	ldclient "github.com/launchdarkly/go-server-sdk/v6"
+	"github.com/launchdarkly/go-server-sdk/v6/ldcomponents"
+	"github.com/launchdarkly/go-server-sdk/v6/testhelpers/ldtestdata"
+)
+
+func TestLaunchDarklyPluginEvaluatesStringFlag(t *testing.T) {
+	td := ldtestdata.DataSource()
+	td.Update(td.Flag("ranking-v2").
+		Variations(ldvalue.String("control"), ldvalue.String("treatment")).
+		FallthroughVariationIndex(0).
+		VariationIndexForUser("user-2", 1))
+	ld, err := ldclient.MakeCustomClient("sdk-key", ldclient.Config{DataSource: td, Events: ldcomponents.NoEvents()}, time.Second)
+	if err != nil {
+		t.Fatalf("error creating LaunchDarkly client: %v", err)
+	}
+	t.Cleanup(func() { ld.Close() })
+
+	plugin := LaunchDarklyPlugin(ld)
+	for userKey, want := range map[string]string{"user-1": "control", "user-2": "treatment"} {
+		got, err := plugin.GetVariant(context.Background(), "ranking-v2", userKey)
+		if err != nil || got != want {
+			t.Errorf("got variant %q (%v) for %s, want %q", got, err, userKey, want)
+		}
+	}
+	if _, err := plugin.GetVariant(context.Background(), "missing", "user-1"); err == nil {
+		t.Error("got no error for a missing flag")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// fakeABTestPlugin assigns users to fixed variants, or fails with err, and records the experiments
+// it was asked about.
+type fakeABTestPlugin struct {
+	variants map[string]string
+	err      error
+
+	mu          sync.Mutex
+	experiments []string
+}
+
+func (p *fakeABTestPlugin) GetVariant(_ context.Context, experimentKey string, userKey string) (string, error) {
+	p.mu.Lock()
+	p.experiments = append(p.experiments, experimentKey)
+	p.mu.Unlock()
+	if p.err != nil {
+		return "", p.err
+	}
+	return p.variants[userKey], nil
+}
+
+func TestABTestVariantIsSentPerUser(t *testing.T) {
+	api := newFakeAPI(t)
+	plugin := &fakeABTestPlugin{variants: map[string]string{"user-1": "control", "user-2": "treatment"}}
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithABTestPlugin(plugin, "ranking-v2"))
+
+	for _, tc := range []struct{ userID, want string }{
+		{"user-1", "control"},
+		{"user-2", "treatment"},
+		{"user-3", ""},
+	} {
+		if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, tc.userID, "a")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+		if got := getProperty(api.lastRequest(t).GetProperties(), abVariantPropertyKey).GetStringValue(); got != tc.want {
+			t.Errorf("%s: got variant %q, want %q", tc.userID, got, tc.want)
+		}
+	}
+	for _, experiment := range plugin.experiments {
+		if experiment != "ranking-v2" {
+			t.Errorf("asked for the variant of experiment %q, want ranking-v2", experiment)
+		}
+	}
+}
+
+func TestABTestPluginFailureSendsNoVariant(t *testing.T) {
+	api := newFakeAPI(t)
+	plugin := &fakeABTestPlugin{err: errors.New("unavailable")}
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithABTestPlugin(plugin, "ranking-v2"))
+
+	resp, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "a")
+	if v := getProperty(api.lastRequest(t).GetProperties(), abVariantPropertyKey); v != nil {
+		t.Errorf("sent variant %v after the plugin failed", v)
+	}
+}
+
+func TestABTestPluginRequiresExperimentKey(t *testing.T) {
+	if _, err := newTestClientBuilder(t, newFakeAPI(t)).WithABTestPlugin(&fakeABTestPlugin{}, "").Build(); err == nil {
+		t.Error("Build succeeded without an experiment key")
+	}
+}
+
+func TestABTestSkipsOptedOutUsers(t *testing.T) {
+	api := newFakeAPI(t)
+	plugin := &fakeABTestPlugin{variants: map[string]string{"user-1": "treatment"}}
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithABTestPlugin(plugin, "ranking-v2").
+		WithGDPROptOut(optedOutFromContext))
+
+	ctx := context.WithValue(context.Background(), optedOutKey{}, true)
+	if _, err := c.Deliver(ctx, newUserDeliveryRequest(t, "user-1", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if len(plugin.experiments) != 0 {
+		t.Errorf("asked the plugin for %d variants of an opted-out user, want none", len(plugin.experiments))
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultAdaptiveTimeoutAlpha = 0.2
+
+// adaptiveTimeout tracks an EWMA of Delivery API latency and sets the timeout to twice that,
+// clamped to [floor, ceiling], so a slow server is given up on sooner.
+type adaptiveTimeout struct {
+	floor   time.Duration
+	ceiling time.Duration
+	alpha   float64
+
+	mu      sync.Mutex
+	ewma    float64
+	current time.Duration
+}
+
+// newAdaptiveTimeout starts at target until latencies are observed.
+func newAdaptiveTimeout(target, floor, ceiling time.Duration, alpha float64) *adaptiveTimeout {
+	return &adaptiveTimeout{
+		floor:   floor,
+		ceiling: ceiling,
+		alpha:   alpha,
+		current: target,
+	}
+}
+
+// timeout returns the timeout for the next call.
+func (a *adaptiveTimeout) timeout() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.current
+}
+
+// observe folds a call's latency into the EWMA and recomputes the timeout.
+func (a *adaptiveTimeout) observe(latency time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ewma == 0 {
+		a.ewma = float64(latency)
+	} else {
+		a.ewma = a.alpha*float64(latency) + (1-a.alpha)*a.ewma
+	}
+	a.current = min(max(a.floor, time.Duration(2*a.ewma)), a.ceiling)
+}
+
+// CurrentAdaptiveTimeout returns the timeout the next Delivery API call will use, or the fixed
+// delivery timeout if adaptive timeouts are off.
+func (c *DeliveryClient) CurrentAdaptiveTimeout() time.Duration {
+	if c.adaptiveTimeout == nil {
+		return c.deliveryAPI.timeoutDuration
+	}
+	return c.adaptiveTimeout.timeout()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestAdaptiveTimeoutConvergesToTwiceLatency(t *testing.T) {
+	a := newAdaptiveTimeout(time.Second, 10*time.Millisecond, 5*time.Second, 0.5)
+	for i := 0; i < 20; i++ {
+		a.observe(900 * time.Millisecond)
+	}
+	for i := 0; i < 20; i++ {
+		a.observe(200 * time.Millisecond)
+	}
+	if got := a.timeout(); got < 399*time.Millisecond || got > 401*time.Millisecond {
+		t.Errorf("got timeout %v, want about 400ms", got)
+	}
+}
+
+func TestAdaptiveTimeoutIsClamped(t *testing.T) {
+	a := newAdaptiveTimeout(time.Second, 100*time.Millisecond, 2*time.Second, 1)
+	a.observe(time.Millisecond)
+	if got := a.timeout(); got != 100*time.Millisecond {
+		t.Errorf("got timeout %v for a fast server, want the 100ms floor", got)
+	}
+	a.observe(10 * time.Second)
+	if got := a.timeout(); got != 2*time.Second {
+		t.Errorf("got timeout %v for a slow server, want the 2s ceiling", got)
+	}
+}
+
+func TestAdaptiveTimeoutTracksServerLatency(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		time.Sleep(50 * time.Millisecond)
+		writeDeliveryResponse(t, w, echoResponse(req))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithAdaptiveTimeout(time.Second, 10*time.Millisecond, 5*time.Second).
+		WithAdaptiveTimeoutAlpha(0.5))
+
+	if got := c.CurrentAdaptiveTimeout(); got != time.Second {
+		t.Errorf("got initial timeout %v, want the 1s target", got)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if got := c.CurrentAdaptiveTimeout(); got < 100*time.Millisecond || got > 300*time.Millisecond {
+		t.Errorf("got timeout %v after 50ms responses, want about 100ms", got)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// apiVersionHeader carries the API version in both directions.
+const apiVersionHeader = "X-Promoted-API-Version"
+
+// APIVersionMismatchError is returned when the server's API version is older than required.
+type APIVersionMismatchError struct {
+	RequiredVersion string
+	ServerVersion   string
+}
+
+func (e *APIVersionMismatchError) Error() string {
+	if e.ServerVersion == "" {
+		return fmt.Sprintf("server did not report an API version; required=%s", e.RequiredVersion)
+	}
+	return fmt.Sprintf("server API version %s is older than required %s", e.ServerVersion, e.RequiredVersion)
+}
+
+// apiVersionChecker compares server API versions with the required one until a check succeeds.
+type apiVersionChecker struct {
+	requiredVersion string
+
+	mu sync.Mutex
+	// serverVersion is cached after the first successful check.
+	serverVersion string
+}
+
+// checked reports whether a compatible server version has already been seen.
+func (v *apiVersionChecker) checked() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.serverVersion != ""
+}
+
+// check validates the server version in the response headers.
+func (v *apiVersionChecker) check(header http.Header) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.serverVersion != "" {
+		return nil
+	}
+
+	serverVersion := header.Get(apiVersionHeader)
+	mismatch := &APIVersionMismatchError{RequiredVersion: v.requiredVersion, ServerVersion: serverVersion}
+	if serverVersion == "" {
+		return mismatch
+	}
+	cmp, err := compareAPIVersions(serverVersion, v.requiredVersion)
+	if err != nil {
+		return err
+	}
+	if cmp < 0 {
+		return mismatch
+	}
+	v.serverVersion = serverVersion
+	return nil
+}
+
+// CheckAPIVersion eagerly validates the server's API version, e.g. at startup. It is a no-op when no
+// version is required or a compatible version has already been seen.
+func (c *DeliveryClient) CheckAPIVersion(ctx context.Context) error {
+	if c.apiVersion == nil || c.apiVersion.checked() {
+		return nil
+	}
+	header, err := c.deliveryAPI.runHealthCheck(ctx)
+	if err != nil {
+		return err
+	}
+	return c.apiVersion.check(header)
+}
+
+// compareAPIVersions compares dotted numeric versions like "1.2" or "v1.2.3", returning -1, 0 or 1.
+func compareAPIVersions(a, b string) (int, error) {
+	aParts, err := parseAPIVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	bParts, err := parseAPIVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < max(len(aParts), len(bParts)); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if aPart != bPart {
+			if aPart < bPart {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseAPIVersion(version string) ([]int, error) {
+	fields := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	parts := make([]int, 0, len(fields))
+	for _, field := range fields {
+		part, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API version %q", version)
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
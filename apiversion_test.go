@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCompareAPIVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2", "1.2", 0},
+		{"v1.2", "1.2.0", 0},
+		{"1.10", "1.9", 1},
+		{"1.2", "1.2.1", -1},
+		{"2", "1.99", 1},
+	}
+	for _, tt := range tests {
+		got, err := compareAPIVersions(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("compareAPIVersions(%q, %q) failed: %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("compareAPIVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if _, err := compareAPIVersions("1.x", "1.2"); err == nil {
+		t.Error("compareAPIVersions accepted an invalid version")
+	}
+}
+
+func TestDeliverFailsOnOlderServerAPIVersion(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setResponseHeader(apiVersionHeader, "1.1")
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRequiredAPIVersion("1.2"))
+
+	_, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	var mismatch *APIVersionMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got error %v, want an APIVersionMismatchError", err)
+	}
+	if mismatch.RequiredVersion != "1.2" || mismatch.ServerVersion != "1.1" {
+		t.Errorf("got versions required=%s server=%s, want 1.2 and 1.1", mismatch.RequiredVersion, mismatch.ServerVersion)
+	}
+	if got := api.lastHeader(t).Get(apiVersionHeader); got != "1.2" {
+		t.Errorf("got %s request header %q, want 1.2", apiVersionHeader, got)
+	}
+}
+
+func TestDeliverCachesCompatibleServerAPIVersion(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setResponseHeader(apiVersionHeader, "1.3")
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRequiredAPIVersion("1.2"))
+
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	// After a successful check, later responses aren't checked again.
+	api.setResponseHeader(apiVersionHeader, "1.0")
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Errorf("Deliver after a successful check failed: %v", err)
+	}
+}
+
+func TestCheckAPIVersion(t *testing.T) {
+	for serverVersion, wantErr := range map[string]bool{"": true, "1.1": true, "1.2": false, "2.0": false} {
+		api := newFakeAPI(t)
+		if serverVersion != "" {
+			api.setResponseHeader(apiVersionHeader, serverVersion)
+		}
+		c := buildTestClient(t, newTestClientBuilder(t, api).WithRequiredAPIVersion("1.2"))
+
+		err := c.CheckAPIVersion(context.Background())
+		if (err != nil) != wantErr {
+			t.Errorf("CheckAPIVersion with server version %q returned %v, want error=%v", serverVersion, err, wantErr)
+		}
+		if len(api.otherCalls()) != 1 || api.otherCalls()[0] != healthEndpointSuffix {
+			t.Errorf("CheckAPIVersion called %v, want the health endpoint", api.otherCalls())
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// banditExplorer replaces epsilon-greedy exploration with a scoring strategy.
+type banditExplorer interface {
+	// rank reorders insertions in place.
+	rank(insertions []*delivery.Insertion)
+	// record feeds back an observed reward.
+	record(contentID string, reward float64)
+}
+
+// banditStats are the observed outcomes for one content ID.
+type banditStats struct {
+	impressions uint64
+	reward      float64
+}
+
+// BanditDeliveryClient adds epsilon-greedy exploration on top of another client: with probability
+// epsilon the ranked response is replaced by a random shuffle so long-tail content gets shown.
+// Setting an explorer replaces epsilon-greedy with that strategy for every call.
+type BanditDeliveryClient struct {
+	client   DeliveryClientInterface
+	explorer banditExplorer
+
+	mu             sync.Mutex
+	rand           *rand.Rand
+	initialEpsilon float64
+	decay          float64
+	calls          uint64
+	explorations   uint64
+	stats          map[string]*banditStats
+}
+
+// NewBanditDeliveryClient wraps c. Epsilon decays as epsilon/(1+decay*calls); a decay of 0 keeps
+// it constant.
+func NewBanditDeliveryClient(c DeliveryClientInterface, epsilon, decay float64) *BanditDeliveryClient {
+	return &BanditDeliveryClient{
+		client:         c,
+		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		initialEpsilon: epsilon,
+		decay:          decay,
+		stats:          map[string]*banditStats{},
+	}
+}
+
+// WithThompsonSamplingExplorer ranks every response by Thompson sampling instead of epsilon-greedy.
+func (b *BanditDeliveryClient) WithThompsonSamplingExplorer(explorer *ThompsonSamplingExplorer) *BanditDeliveryClient {
+	b.explorer = explorer
+	return b
+}
+
+// WithUCBExplorer ranks every response by UCB1 score instead of epsilon-greedy.
+func (b *BanditDeliveryClient) WithUCBExplorer(e *UCBExplorer) *BanditDeliveryClient {
+	b.explorer = e
+	return b
+}
+
+// Deliver delivers through the wrapped client, exploring with probability epsilon.
+func (b *BanditDeliveryClient) Deliver(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+	resp, err := b.client.Deliver(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.explorer != nil {
+		resp = cloneResponse(resp)
+		b.explorer.rank(resp.Response.Insertion)
+		renumberPositions(resp.Response.Insertion)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	explore := b.explorer == nil && b.rand.Float64() < b.epsilonLocked()
+	b.calls++
+	if explore {
+		b.explorations++
+		resp = cloneResponse(resp)
+		insertions := resp.Response.Insertion
+		b.rand.Shuffle(len(insertions), func(i, j int) {
+			insertions[i], insertions[j] = insertions[j], insertions[i]
+		})
+		renumberPositions(insertions)
+	}
+
+	for _, ins := range resp.Response.GetInsertion() {
+		b.statsLocked(ins.GetContentId()).impressions++
+	}
+	return resp, nil
+}
+
+// RecordOutcome records the reward (e.g. 1 for a click) for content that was shown.
+func (b *BanditDeliveryClient) RecordOutcome(contentID string, reward float64) {
+	if b.explorer != nil {
+		b.explorer.record(contentID, reward)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.statsLocked(contentID).reward += reward
+}
+
+// ExplorationStats returns the estimated CTR (reward per impression) for each content ID.
+func (b *BanditDeliveryClient) ExplorationStats() map[string]float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ctrs := make(map[string]float64, len(b.stats))
+	for contentID, s := range b.stats {
+		if s.impressions > 0 {
+			ctrs[contentID] = s.reward / float64(s.impressions)
+		} else {
+			ctrs[contentID] = 0
+		}
+	}
+	return ctrs
+}
+
+// Epsilon returns the current exploration probability.
+func (b *BanditDeliveryClient) Epsilon() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.epsilonLocked()
+}
+
+// ExplorationFraction returns the fraction of calls so far that explored.
+func (b *BanditDeliveryClient) ExplorationFraction() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.calls == 0 {
+		return 0
+	}
+	return float64(b.explorations) / float64(b.calls)
+}
+
+func (b *BanditDeliveryClient) epsilonLocked() float64 {
+	return b.initialEpsilon / (1 + b.decay*float64(b.calls))
+}
+
+func (b *BanditDeliveryClient) statsLocked(contentID string) *banditStats {
+	s, ok := b.stats[contentID]
+	if !ok {
+		s = &banditStats{}
+		b.stats[contentID] = s
+	}
+	return s
+}
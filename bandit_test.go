@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestBanditExplorationFractionConvergesToEpsilon(t *testing.T) {
+	bandit := NewBanditDeliveryClient(&fakeDeliveryClient{name: "ranked"}, 0.2, 0)
+	bandit.rand = rand.New(rand.NewSource(1))
+
+	req := newTestDeliveryRequest(t, "a", "b", "c", "d")
+	for i := 0; i < 10000; i++ {
+		if _, err := bandit.Deliver(context.Background(), req); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if got := bandit.ExplorationFraction(); math.Abs(got-0.2) > 0.02 {
+		t.Errorf("got exploration fraction %.3f, want about 0.2", got)
+	}
+}
+
+func TestBanditEpsilonDecays(t *testing.T) {
+	bandit := NewBanditDeliveryClient(&fakeDeliveryClient{name: "ranked"}, 0.5, 1)
+	req := newTestDeliveryRequest(t, "a")
+	for i := 0; i < 9; i++ {
+		if _, err := bandit.Deliver(context.Background(), req); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if got := bandit.Epsilon(); math.Abs(got-0.05) > 1e-9 {
+		t.Errorf("got epsilon %v after 9 calls, want 0.05", got)
+	}
+}
+
+func TestBanditExplorationKeepsContentAndRenumbers(t *testing.T) {
+	bandit := NewBanditDeliveryClient(&fakeDeliveryClient{name: "ranked"}, 1, 0)
+	resp, err := bandit.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	seen := map[string]bool{}
+	for i, ins := range resp.Response.GetInsertion() {
+		seen[ins.GetContentId()] = true
+		if ins.GetPosition() != uint64(i) {
+			t.Errorf("insertion %d has position %d", i, ins.GetPosition())
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("exploration changed the content to %v", seen)
+	}
+}
+
+func TestBanditExplorationStats(t *testing.T) {
+	bandit := NewBanditDeliveryClient(&fakeDeliveryClient{name: "ranked"}, 0, 0)
+	req := newTestDeliveryRequest(t, "a", "b")
+	for i := 0; i < 4; i++ {
+		if _, err := bandit.Deliver(context.Background(), req); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	bandit.RecordOutcome("a", 1)
+
+	stats := bandit.ExplorationStats()
+	if stats["a"] != 0.25 || stats["b"] != 0 {
+		t.Errorf("got CTRs %v, want a=0.25 and b=0", stats)
+	}
+}
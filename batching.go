@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const defaultBatchMaxSize = 100
+
+// BatchDeliveryAPI sends several delivery requests in one call. The Delivery API schema has no
+// batch message, so callers provide the transport for their deployment.
+type BatchDeliveryAPI interface {
+	// RunBatchDelivery returns one response per request, in request order. headers[i] are the
+	// per-call HTTP headers of requests[i], e.g. the correlation ID and request-option headers.
+	RunBatchDelivery(ctx context.Context, requests []*client.DeliveryRequest, headers []http.Header) ([]*delivery.Response, error)
+}
+
+// batchResult is the outcome for a single call in a batch.
+type batchResult struct {
+	response *delivery.Response
+	err      error
+}
+
+// batchCall is a Deliver call waiting for its batch to be sent.
+type batchCall struct {
+	request *client.DeliveryRequest
+	header  http.Header
+	done    chan batchResult
+}
+
+// requestBatcher groups Delivery API calls arriving within a window into one batch call.
+type requestBatcher struct {
+	api             BatchDeliveryAPI
+	window          time.Duration
+	maxSize         int
+	timeoutDuration time.Duration
+
+	mu      sync.Mutex
+	pending []*batchCall
+	timer   *time.Timer
+}
+
+// runDelivery queues the request and blocks until its batch returns or ctx is done.
+func (b *requestBatcher) runDelivery(ctx context.Context, req *client.DeliveryRequest, header http.Header) (*delivery.Response, error) {
+	call := &batchCall{request: req, header: header, done: make(chan batchResult, 1)}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, call)
+	if len(b.pending) >= b.maxSize {
+		batch := b.takeLocked()
+		b.mu.Unlock()
+		go b.send(batch)
+	} else {
+		// The window starts with the first call of a batch so no call waits longer than it.
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.window, b.flush)
+		}
+		b.mu.Unlock()
+	}
+
+	select {
+	case result := <-call.done:
+		return result.response, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flush sends whatever is pending when the window closes.
+func (b *requestBatcher) flush() {
+	b.mu.Lock()
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	if len(batch) > 0 {
+		b.send(batch)
+	}
+}
+
+func (b *requestBatcher) takeLocked() []*batchCall {
+	batch := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// send makes the batch call and hands each caller its own response.
+func (b *requestBatcher) send(batch []*batchCall) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeoutDuration)
+	defer cancel()
+
+	requests := make([]*client.DeliveryRequest, len(batch))
+	headers := make([]http.Header, len(batch))
+	for i, call := range batch {
+		requests[i] = call.request
+		headers[i] = call.header
+	}
+	responses, err := b.api.RunBatchDelivery(ctx, requests, headers)
+	if err == nil && len(responses) != len(batch) {
+		err = fmt.Errorf("batch delivery returned %d responses for %d requests", len(responses), len(batch))
+	}
+	for i, call := range batch {
+		if err != nil {
+			call.done <- batchResult{err: err}
+		} else {
+			call.done <- batchResult{response: responses[i]}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// fakeBatchAPI echoes each request's insertions and records the size and headers of every batch.
+type fakeBatchAPI struct {
+	mu      sync.Mutex
+	batches []int
+	headers []http.Header
+}
+
+func (f *fakeBatchAPI) RunBatchDelivery(_ context.Context, requests []*client.DeliveryRequest, headers []http.Header) ([]*delivery.Response, error) {
+	f.mu.Lock()
+	f.batches = append(f.batches, len(requests))
+	f.headers = append(f.headers, headers...)
+	f.mu.Unlock()
+	responses := make([]*delivery.Response, len(requests))
+	for i, req := range requests {
+		responses[i] = echoResponse(req.Request)
+	}
+	return responses, nil
+}
+
+func (f *fakeBatchAPI) sentHeaders() []http.Header {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]http.Header(nil), f.headers...)
+}
+
+func (f *fakeBatchAPI) batchSizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.batches...)
+}
+
+// deliverConcurrently delivers one request per content ID at the same time, returning the first
+// content ID of each response.
+func deliverConcurrently(t *testing.T, c *DeliveryClient, contentIDs ...string) []string {
+	t.Helper()
+	got := make([]string, len(contentIDs))
+	var wg sync.WaitGroup
+	for i, id := range contentIDs {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, id))
+			if err != nil {
+				t.Errorf("Deliver failed: %v", err)
+				return
+			}
+			got[i] = resp.Response.GetInsertion()[0].GetContentId()
+		}(i, id)
+	}
+	wg.Wait()
+	return got
+}
+
+func TestSimultaneousCallsAreBatched(t *testing.T) {
+	api := newFakeAPI(t)
+	batchAPI := &fakeBatchAPI{}
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithRequestBatchingWindow(50*time.Millisecond).
+		WithBatchDeliveryAPI(batchAPI))
+
+	got := deliverConcurrently(t, c, "a", "b")
+	if got[0] != "a" || got[1] != "b" {
+		t.Errorf("got responses for %v, want each caller's own response", got)
+	}
+	if sizes := batchAPI.batchSizes(); len(sizes) != 1 || sizes[0] != 2 {
+		t.Errorf("got batches of sizes %v, want one batch of 2", sizes)
+	}
+	if api.calls() != 0 {
+		t.Errorf("got %d unbatched Delivery API calls, want 0", api.calls())
+	}
+}
+
+func TestFullBatchIsSentWithoutWaiting(t *testing.T) {
+	batchAPI := &fakeBatchAPI{}
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).
+		WithRequestBatchingWindow(time.Hour).
+		WithBatchMaxSize(2).
+		WithBatchDeliveryAPI(batchAPI))
+
+	done := make(chan struct{})
+	go func() {
+		deliverConcurrently(t, c, "a", "b")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a full batch waited for the batching window")
+	}
+	if sizes := batchAPI.batchSizes(); len(sizes) != 1 || sizes[0] != 2 {
+		t.Errorf("got batches of sizes %v, want one batch of 2", sizes)
+	}
+}
+
+func TestBatchingRequiresBatchDeliveryAPI(t *testing.T) {
+	if _, err := newTestClientBuilder(t, newFakeAPI(t)).WithRequestBatchingWindow(time.Millisecond).Build(); err == nil {
+		t.Error("Build succeeded with batching enabled and no batch transport")
+	}
+}
+
+func TestBatchedCallsKeepTheirHeaders(t *testing.T) {
+	batchAPI := &fakeBatchAPI{}
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).
+		WithRequestBatchingWindow(time.Millisecond).
+		WithBatchDeliveryAPI(batchAPI))
+
+	req := newTestDeliveryRequest(t, "a")
+	req.Headers = http.Header{"X-Tenant": []string{"acme"}}
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	headers := batchAPI.sentHeaders()
+	if len(headers) != 1 || headers[0].Get("X-Tenant") != "acme" {
+		t.Errorf("got batch headers %v, want the call's X-Tenant header", headers)
+	}
+}
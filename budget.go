@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// FallbackReasonBudgetExhausted marks responses served by SDK delivery because the daily call
+// budget was used up.
+const FallbackReasonBudgetExhausted = "BUDGET_EXHAUSTED"
+
+// BudgetTracker caps the Delivery API calls made per UTC day, for billing models that charge per
+// call. A zero budget is unlimited.
+type BudgetTracker struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	budget int64
+	used   int64
+	day    time.Time
+}
+
+// NewBudgetTracker is a factory method for BudgetTracker.
+func NewBudgetTracker() *BudgetTracker {
+	return &BudgetTracker{now: time.Now}
+}
+
+// WithDailyCallBudget allows n calls per UTC day.
+func (t *BudgetTracker) WithDailyCallBudget(n int64) *BudgetTracker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.budget = n
+	return t
+}
+
+// ConsumeCall reports whether another call fits in today's budget, counting it if so.
+func (t *BudgetTracker) ConsumeCall() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resetLocked()
+	if t.budget > 0 && t.used >= t.budget {
+		return false
+	}
+	t.used++
+	return true
+}
+
+// RemainingBudget is the number of calls left today, or -1 if the budget is unlimited.
+func (t *BudgetTracker) RemainingBudget() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resetLocked()
+	if t.budget <= 0 {
+		return -1
+	}
+	return t.budget - t.used
+}
+
+// resetLocked starts a new count at midnight UTC.
+func (t *BudgetTracker) resetLocked() {
+	day := t.now().UTC().Truncate(24 * time.Hour)
+	if !day.Equal(t.day) {
+		t.day = day
+		t.used = 0
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestBudgetBlocksCallAfterBudget(t *testing.T) {
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	tracker := NewBudgetTracker().WithDailyCallBudget(3)
+	tracker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !tracker.ConsumeCall() {
+			t.Fatalf("call %d was blocked within the budget", i+1)
+		}
+	}
+	if tracker.ConsumeCall() {
+		t.Error("call 4 was allowed past a budget of 3")
+	}
+	if got := tracker.RemainingBudget(); got != 0 {
+		t.Errorf("got remaining budget %d, want 0", got)
+	}
+
+	// Not yet midnight UTC.
+	now = now.Add(59 * time.Minute)
+	if tracker.ConsumeCall() {
+		t.Error("budget reset before midnight UTC")
+	}
+	now = now.Add(time.Minute)
+	if got := tracker.RemainingBudget(); got != 3 {
+		t.Errorf("got remaining budget %d after midnight UTC, want 3", got)
+	}
+	if !tracker.ConsumeCall() {
+		t.Error("call was blocked after the budget reset")
+	}
+}
+
+func TestUnlimitedBudget(t *testing.T) {
+	tracker := NewBudgetTracker()
+	for i := 0; i < 100; i++ {
+		if !tracker.ConsumeCall() {
+			t.Fatal("call was blocked without a budget")
+		}
+	}
+	if got := tracker.RemainingBudget(); got != -1 {
+		t.Errorf("got remaining budget %d, want -1 for unlimited", got)
+	}
+}
+
+func TestExhaustedBudgetServesInputOrderWithoutCalling(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		writeDeliveryResponse(t, w, rankedResponse("b", "a", "c"))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithBudgetTracker(NewBudgetTracker().WithDailyCallBudget(1)))
+
+	first, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "c", "a", "b"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, first, "b", "a", "c")
+
+	second, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "c", "a", "b"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, second, "c", "a", "b")
+	if second.FallbackReason != FallbackReasonBudgetExhausted || second.ExecutionServer != delivery.ExecutionServer_SDK {
+		t.Errorf("got fallback reason %q from %v, want %s from the SDK", second.FallbackReason, second.ExecutionServer, FallbackReasonBudgetExhausted)
+	}
+	if api.calls() != 1 {
+		t.Errorf("got %d Delivery API calls, want only the budgeted one", api.calls())
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// LoadInsertionsFromCSV reads insertions from a CSV export with a header row. Each row's content ID
+// comes from contentIDColumn, and priceColumn and extraColumns become numeric properties named after
+// their columns. Empty extra cells are left unset. Rows with an empty content ID or a non-numeric
+// value are logged and skipped.
+func LoadInsertionsFromCSV(r io.Reader, contentIDColumn string, priceColumn string, extraColumns ...string) ([]*delivery.Insertion, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("insertions CSV has no header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading insertions CSV header: %v", err)
+	}
+	indices := make(map[string]int, len(header))
+	for i, column := range header {
+		indices[strings.TrimSpace(column)] = i
+	}
+	columnIndex := func(column string) (int, error) {
+		i, ok := indices[column]
+		if !ok {
+			return 0, fmt.Errorf("insertions CSV has no %s column", column)
+		}
+		return i, nil
+	}
+	contentIDIndex, err := columnIndex(contentIDColumn)
+	if err != nil {
+		return nil, err
+	}
+	priceIndex, err := columnIndex(priceColumn)
+	if err != nil {
+		return nil, err
+	}
+	extraIndices := make([]int, len(extraColumns))
+	for i, column := range extraColumns {
+		if extraIndices[i], err = columnIndex(column); err != nil {
+			return nil, err
+		}
+	}
+
+	var insertions []*delivery.Insertion
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return insertions, nil
+		}
+		if errors.Is(err, csv.ErrFieldCount) {
+			log.Printf("WARN: skipping insertions CSV line %d: %v\n", line, err)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading insertions CSV: %v", err)
+		}
+
+		contentID := strings.TrimSpace(record[contentIDIndex])
+		if contentID == "" {
+			log.Printf("WARN: skipping insertions CSV line %d: empty %s\n", line, contentIDColumn)
+			continue
+		}
+		ins := &delivery.Insertion{ContentId: contentID}
+		price, err := strconv.ParseFloat(strings.TrimSpace(record[priceIndex]), 64)
+		if err != nil {
+			log.Printf("WARN: skipping insertions CSV line %d: non-numeric %s\n", line, priceColumn)
+			continue
+		}
+		if err := setProperty(&ins.Properties, priceColumn, price); err != nil {
+			return nil, err
+		}
+		valid := true
+		for i, column := range extraColumns {
+			cell := strings.TrimSpace(record[extraIndices[i]])
+			if cell == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				log.Printf("WARN: skipping insertions CSV line %d: non-numeric %s\n", line, column)
+				valid = false
+				break
+			}
+			if err := setProperty(&ins.Properties, column, value); err != nil {
+				return nil, err
+			}
+		}
+		if valid {
+			insertions = append(insertions, ins)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testInsertionsCSV returns a 100-row CSV export along with the content IDs of its valid rows and
+// the number of invalid ones.
+func testInsertionsCSV() (csv string, valid []string, invalid int) {
+	var b strings.Builder
+	b.WriteString("sku, price, rating, stock\n")
+	for i := 0; i < 100; i++ {
+		sku, price, rating := fmt.Sprintf("sku-%d", i), fmt.Sprintf("%d.99", i), "4.5"
+		row := ""
+		switch {
+		case i%10 == 3:
+			price = "n/a"
+		case i%10 == 7:
+			sku = " "
+		case i%20 == 5:
+			rating = "five"
+		case i%20 == 15:
+			// An empty extra cell is left unset rather than invalid.
+			rating = ""
+		case i == 99:
+			row = sku + "," + price + "\n"
+		}
+		if row == "" {
+			row = fmt.Sprintf("%s,%s,%s,%d\n", sku, price, rating, i)
+		}
+		b.WriteString(row)
+		if i%10 == 3 || i%10 == 7 || i%20 == 5 || i == 99 {
+			invalid++
+		} else {
+			valid = append(valid, sku)
+		}
+	}
+	return b.String(), valid, invalid
+}
+
+func TestLoadInsertionsFromCSVSkipsInvalidRows(t *testing.T) {
+	logs := captureLog(t)
+	csv, valid, invalid := testInsertionsCSV()
+
+	insertions, err := LoadInsertionsFromCSV(strings.NewReader(csv), "sku", "price", "rating", "stock")
+	if err != nil {
+		t.Fatalf("LoadInsertionsFromCSV failed: %v", err)
+	}
+	if got := insertionContentIDsOf(insertions); strings.Join(got, " ") != strings.Join(valid, " ") {
+		t.Fatalf("got %d insertions %v, want %d %v", len(got), got, len(valid), valid)
+	}
+	if got := strings.Count(logs.String(), "WARN: skipping insertions CSV line"); got != invalid {
+		t.Errorf("got %d skipped row warnings, want %d:\n%s", got, invalid, logs.String())
+	}
+	for _, want := range []string{"line 5: non-numeric price", "line 9: empty sku", "line 7: non-numeric rating", "line 101: record on line 101: wrong number of fields"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs are missing %q", want)
+		}
+	}
+
+	first := insertions[0]
+	if got := getProperty(first.GetProperties(), "price").GetNumberValue(); got != 0.99 {
+		t.Errorf("got price %v, want 0.99", got)
+	}
+	if got := getProperty(first.GetProperties(), "rating").GetNumberValue(); got != 4.5 {
+		t.Errorf("got rating %v, want 4.5", got)
+	}
+	for _, ins := range insertions {
+		if ins.GetContentId() == "sku-15" && getProperty(ins.GetProperties(), "rating") != nil {
+			t.Error("an empty rating was set")
+		}
+	}
+}
+
+func TestLoadInsertionsFromCSVRequiresColumns(t *testing.T) {
+	for name, tc := range map[string]struct {
+		csv, contentIDColumn, priceColumn string
+		extraColumns                      []string
+	}{
+		"empty":               {"", "sku", "price", nil},
+		"missing content ID":  {"id,price\na,1\n", "sku", "price", nil},
+		"missing price":       {"sku,cost\na,1\n", "sku", "price", nil},
+		"missing extra":       {"sku,price\na,1\n", "sku", "price", []string{"rating"}},
+		"malformed quotation": {"sku,price\n\"a,1\n", "sku", "price", nil},
+	} {
+		if _, err := LoadInsertionsFromCSV(strings.NewReader(tc.csv), tc.contentIDColumn, tc.priceColumn, tc.extraColumns...); err == nil {
+			t.Errorf("%s: LoadInsertionsFromCSV succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const bundlePropertyKey = "bundle"
+
+// BundleInsertion is a product sold as a bundle, ranked by its primary content ID.
+type BundleInsertion struct {
+	PrimaryContentID  string   `json:"primaryContentId"`
+	BundledContentIDs []string `json:"bundledContentIds"`
+	BundlePrice       float64  `json:"bundlePrice"`
+}
+
+// addBundleInsertions sets the bundle property of each bundle's insertion, adding the insertion if
+// the request doesn't have it yet.
+func addBundleInsertions(req *delivery.Request, bundles []BundleInsertion) error {
+	byContentID := map[string]*delivery.Insertion{}
+	for _, ins := range req.Insertion {
+		byContentID[ins.GetContentId()] = ins
+	}
+	for _, bundle := range bundles {
+		ins, ok := byContentID[bundle.PrimaryContentID]
+		if !ok {
+			ins = &delivery.Insertion{ContentId: bundle.PrimaryContentID}
+			req.Insertion = append(req.Insertion, ins)
+			byContentID[bundle.PrimaryContentID] = ins
+		}
+		if err := setJSONProperty(&ins.Properties, bundlePropertyKey, bundle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtractBundles returns the bundles among the response's insertions, keyed by primary content ID.
+func ExtractBundles(resp *DeliveryResponse) map[string]*BundleInsertion {
+	bundles := map[string]*BundleInsertion{}
+	for _, ins := range resp.Response.GetInsertion() {
+		if getProperty(ins.Properties, bundlePropertyKey) == nil {
+			continue
+		}
+		var bundle BundleInsertion
+		if err := getJSONProperty(ins.Properties, bundlePropertyKey, &bundle); err != nil {
+			log.Printf("Error reading bundle of content %s: %v\n", ins.GetContentId(), err)
+			continue
+		}
+		bundles[ins.GetContentId()] = &bundle
+	}
+	return bundles
+}
+
+// attachBundles copies the bundle property from the request to the response insertions, which
+// don't carry properties.
+func attachBundles(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		if !req.hasBundles {
+			return next(ctx, req)
+		}
+		requested := requestInsertionsByContentID(req)
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp = cloneResponse(resp)
+		for _, ins := range resp.Response.GetInsertion() {
+			bundle := getProperty(requested[ins.GetContentId()].GetProperties(), bundlePropertyKey)
+			if bundle == nil || getProperty(ins.Properties, bundlePropertyKey) != nil {
+				continue
+			}
+			if err := setProperty(&ins.Properties, bundlePropertyKey, bundle.AsInterface()); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestBundlesAreRankedByPrimaryContentID(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		writeDeliveryResponse(t, w, rankedResponse("kit", "a"))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+	bundle := BundleInsertion{PrimaryContentID: "kit", BundledContentIDs: []string{"brush", "paint"}, BundlePrice: 24.5}
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithBundleInsertions(bundle))
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "kit", "a")
+	if got := insertionContentIDsOf(api.lastRequest(t).GetInsertion()); !reflect.DeepEqual(got, []string{"a", "kit"}) {
+		t.Errorf("sent insertions %v, want the bundle's primary content ID added", got)
+	}
+
+	bundles := ExtractBundles(resp)
+	if len(bundles) != 1 || bundles["kit"] == nil {
+		t.Fatalf("got bundles %v, want only kit", bundles)
+	}
+	if !reflect.DeepEqual(*bundles["kit"], bundle) {
+		t.Errorf("got bundle %+v, want %+v", *bundles["kit"], bundle)
+	}
+}
+
+func TestBundlesSurviveSDKFallback(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+	bundle := BundleInsertion{PrimaryContentID: "b", BundledContentIDs: []string{"c"}, BundlePrice: 10}
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a", "b").Request).WithBundleInsertions(bundle))
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if resp.ExecutionServer != delivery.ExecutionServer_SDK {
+		t.Fatalf("got execution server %v, want SDK", resp.ExecutionServer)
+	}
+	assertContentIDs(t, resp, "a", "b")
+	if got := ExtractBundles(resp)["b"]; got == nil || got.BundlePrice != 10 {
+		t.Errorf("got bundle %+v, want b's bundle", got)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const defaultWarmUpConcurrency = 4
+
+// WarmUpReport summarizes a WarmCache call.
+type WarmUpReport struct {
+	// Warmed is how many requests now have a cached response.
+	Warmed int
+
+	// Errors are the delivery errors of the requests that couldn't be warmed.
+	Errors []error
+}
+
+// WarmCache delivers each request so its response is cached ahead of traffic, e.g. before a flash
+// sale. Requests that are already cached are served from the cache, so warming twice is harmless.
+// It requires WithStaleWhileRevalidate.
+func (c *DeliveryClient) WarmCache(ctx context.Context, requests []*DeliveryRequest) (*WarmUpReport, error) {
+	if c.staleWhileRevalidate == nil {
+		return nil, errors.New("WarmCache requires a cache; see WithStaleWhileRevalidate")
+	}
+
+	report := &WarmUpReport{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.warmUpConcurrency)
+	for _, req := range requests {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return report, ctx.Err()
+		}
+		wg.Add(1)
+		go func(req *DeliveryRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp, err := c.Deliver(ctx, req)
+			if err == nil && !cacheable(resp) {
+				// Fallback responses aren't cached, so the request isn't warm.
+				err = resp.APIError
+				if err == nil {
+					err = fmt.Errorf("delivery fell back: %s", resp.FallbackReason)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Errors = append(report.Errors, err)
+			} else {
+				report.Warmed++
+			}
+		}(req)
+	}
+	wg.Wait()
+	return report, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestWarmCachePopulatesCache(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithStaleWhileRevalidate(time.Minute, time.Second).
+		WithWarmUpConcurrency(2))
+	requests := []*DeliveryRequest{
+		newTestDeliveryRequest(t, "a", "b"),
+		newTestDeliveryRequest(t, "c"),
+		newTestDeliveryRequest(t, "d"),
+	}
+
+	report, err := c.WarmCache(context.Background(), requests)
+	if err != nil {
+		t.Fatalf("WarmCache failed: %v", err)
+	}
+	if report.Warmed != 3 || len(report.Errors) != 0 {
+		t.Errorf("got %d warmed and errors %v, want 3 warmed", report.Warmed, report.Errors)
+	}
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if api.calls() != 3 {
+		t.Errorf("got %d Delivery API calls, want the warmed request served from the cache", api.calls())
+	}
+
+	// Warming again is served from the cache.
+	if _, err := c.WarmCache(context.Background(), requests); err != nil {
+		t.Fatalf("WarmCache failed: %v", err)
+	}
+	if api.calls() != 3 {
+		t.Errorf("got %d Delivery API calls after warming twice, want 3", api.calls())
+	}
+}
+
+func TestWarmCacheReportsFallbacks(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		if len(req.GetInsertion()) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeDeliveryResponse(t, w, echoResponse(req))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithStaleWhileRevalidate(time.Minute, time.Second))
+
+	report, err := c.WarmCache(context.Background(), []*DeliveryRequest{
+		newTestDeliveryRequest(t, "a", "b"),
+		newTestDeliveryRequest(t, "c"),
+	})
+	if err != nil {
+		t.Fatalf("WarmCache failed: %v", err)
+	}
+	if report.Warmed != 1 || len(report.Errors) != 1 {
+		t.Errorf("got %d warmed and %d errors, want 1 of each", report.Warmed, len(report.Errors))
+	}
+}
+
+func TestWarmCacheRequiresCache(t *testing.T) {
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)))
+	if _, err := c.WarmCache(context.Background(), nil); err == nil {
+		t.Error("WarmCache succeeded without a cache")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const capabilitiesEndpointSuffix = "/capabilities"
+
+const defaultCapabilityRefreshInterval = 5 * time.Minute
+
+// ServerCapabilities are the features a Delivery API deployment advertises.
+type ServerCapabilities struct {
+	SupportsBatch     bool
+	SupportsGzip      bool
+	SupportedUseCases []delivery.UseCase
+	APIVersion        string
+}
+
+// serverCapabilitiesJSON is the wire format of ServerCapabilities, with use cases by name.
+type serverCapabilitiesJSON struct {
+	SupportsBatch     bool     `json:"supportsBatch"`
+	SupportsGzip      bool     `json:"supportsGzip"`
+	SupportedUseCases []string `json:"supportedUseCases"`
+	APIVersion        string   `json:"apiVersion"`
+}
+
+// capabilityCache holds the last discovered capabilities until they are refreshInterval old.
+type capabilityCache struct {
+	refreshInterval time.Duration
+
+	mu           sync.Mutex
+	capabilities *ServerCapabilities
+	fetchedAt    time.Time
+}
+
+// DiscoverCapabilities returns the Delivery API's advertised capabilities, fetching them if they
+// are not cached or older than the refresh interval.
+func (c *DeliveryClient) DiscoverCapabilities(ctx context.Context) (*ServerCapabilities, error) {
+	c.capabilities.mu.Lock()
+	defer c.capabilities.mu.Unlock()
+	if c.capabilities.capabilities != nil && time.Since(c.capabilities.fetchedAt) < c.capabilities.refreshInterval {
+		return c.capabilities.capabilities, nil
+	}
+	capabilities, err := c.deliveryAPI.runCapabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.capabilities.capabilities = capabilities
+	c.capabilities.fetchedAt = time.Now()
+	return capabilities, nil
+}
+
+// runCapabilities fetches the capabilities endpoint. Unknown use cases are skipped so older clients
+// keep working against newer servers.
+func (d *deliveryAPI) runCapabilities(ctx context.Context) (*ServerCapabilities, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeoutDuration)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.capabilitiesHTTPEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating HTTP request: %v", err)
+	}
+	d.setHeaders(req, nil)
+
+	respHTTP, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making HTTP request: %v", err)
+	}
+	defer respHTTP.Body.Close()
+
+	if respHTTP.StatusCode < 200 || respHTTP.StatusCode >= 300 {
+		return nil, fmt.Errorf("failure calling capabilities endpoint; statusCode=%d", respHTTP.StatusCode)
+	}
+
+	var wire serverCapabilitiesJSON
+	if err := json.NewDecoder(respHTTP.Body).Decode(&wire); err != nil {
+		return nil, fmt.Errorf("error unmarshaling capabilities: %v", err)
+	}
+	capabilities := &ServerCapabilities{
+		SupportsBatch: wire.SupportsBatch,
+		SupportsGzip:  wire.SupportsGzip,
+		APIVersion:    wire.APIVersion,
+	}
+	for _, name := range wire.SupportedUseCases {
+		useCase, err := ParseUseCase(name)
+		if err != nil {
+			continue
+		}
+		capabilities.SupportedUseCases = append(capabilities.SupportedUseCases, useCase)
+	}
+	return capabilities, nil
+}
+
+// warnOnCapabilityConflicts logs a warning for each builder option the server doesn't support.
+func (b *DeliveryClientBuilder) warnOnCapabilityConflicts(capabilities *ServerCapabilities) {
+	if b.batchingWindow > 0 && !capabilities.SupportsBatch {
+		log.Printf("WARN: request batching is enabled but the Delivery API doesn't support batch calls\n")
+	}
+	if b.acceptsGzip && !capabilities.SupportsGzip {
+		log.Printf("WARN: gzip is accepted but the Delivery API doesn't support it\n")
+	}
+	if b.requiredAPIVersion != "" && capabilities.APIVersion != "" {
+		if cmp, err := compareAPIVersions(capabilities.APIVersion, b.requiredAPIVersion); err == nil && cmp < 0 {
+			log.Printf("WARN: the Delivery API version %s is older than the required %s\n", capabilities.APIVersion, b.requiredAPIVersion)
+		}
+	}
+}
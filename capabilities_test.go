@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// newCapabilitiesServer serves body from the capabilities endpoint, counting the calls.
+func newCapabilitiesServer(t *testing.T, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != capabilitiesEndpointSuffix {
+			t.Errorf("got %s %s, want GET %s", r.Method, r.URL.Path, capabilitiesEndpointSuffix)
+		}
+		calls.Add(1)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestDiscoverCapabilities(t *testing.T) {
+	srv, calls := newCapabilitiesServer(t, `{"supportsBatch": true, "supportedUseCases": ["SEARCH", "feed", "FUTURE_USE_CASE"], "apiVersion": "v2.1"}`)
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithDeliveryEndpoint(srv.URL))
+
+	capabilities, err := c.DiscoverCapabilities(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverCapabilities failed: %v", err)
+	}
+	// Unknown use cases are skipped.
+	want := &ServerCapabilities{
+		SupportsBatch:     true,
+		SupportedUseCases: []delivery.UseCase{delivery.UseCase_SEARCH, delivery.UseCase_FEED},
+		APIVersion:        "v2.1",
+	}
+	if !reflect.DeepEqual(capabilities, want) {
+		t.Errorf("got %+v, want %+v", capabilities, want)
+	}
+
+	if _, err := c.DiscoverCapabilities(context.Background()); err != nil {
+		t.Fatalf("DiscoverCapabilities failed: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("got %d capabilities calls, want the second one cached", calls.Load())
+	}
+}
+
+func TestCapabilitiesAreRefreshed(t *testing.T) {
+	srv, calls := newCapabilitiesServer(t, `{"apiVersion": "v2"}`)
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).
+		WithDeliveryEndpoint(srv.URL).
+		WithCapabilityRefreshInterval(20*time.Millisecond))
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.DiscoverCapabilities(context.Background()); err != nil {
+			t.Fatalf("DiscoverCapabilities failed: %v", err)
+		}
+		time.Sleep(30 * time.Millisecond)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("got %d capabilities calls, want a refetch after the refresh interval", calls.Load())
+	}
+}
+
+func TestDiscoverCapabilitiesErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithDeliveryEndpoint(srv.URL))
+	if _, err := c.DiscoverCapabilities(context.Background()); err == nil {
+		t.Error("DiscoverCapabilities succeeded on a 404")
+	}
+
+	invalid, _ := newCapabilitiesServer(t, `not json`)
+	c = buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithDeliveryEndpoint(invalid.URL))
+	if _, err := c.DiscoverCapabilities(context.Background()); err == nil {
+		t.Error("DiscoverCapabilities succeeded on an invalid body")
+	}
+}
+
+func TestCapabilityCheckOnBuildWarnsOnConflicts(t *testing.T) {
+	logs := captureLog(t)
+	srv, _ := newCapabilitiesServer(t, `{"supportsBatch": false, "supportsGzip": false, "apiVersion": "v1.0"}`)
+	buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).
+		WithDeliveryEndpoint(srv.URL).
+		WithRequestBatchingWindow(time.Millisecond).
+		WithBatchDeliveryAPI(&fakeBatchAPI{}).
+		WithAcceptsGzip(true).
+		WithCapabilityCheckOnBuild(true))
+
+	for _, want := range []string{"batching", "gzip"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("got logs %q, want a %s warning", logs.String(), want)
+		}
+	}
+}
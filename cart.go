@@ -0,0 +1,48 @@
+package main
+
+import "github.com/promotedai/schema/generated/go/proto/delivery"
+
+const defaultCartBuryMultiplier = 0.1
+
+// CartContext lists the content already in the user's cart, which is wasted in ranked positions.
+type CartContext struct {
+	InCartContentIDs []string
+}
+
+// CartFilterMode controls what happens to insertions already in the cart.
+type CartFilterMode int
+
+const (
+	// CartFilterModeBury keeps in-cart insertions but buries them by the cart bury multiplier, so
+	// they rank well below where they otherwise would.
+	CartFilterModeBury CartFilterMode = iota
+	// CartFilterModeExclude removes in-cart insertions from the request.
+	CartFilterModeExclude
+)
+
+// applyCart buries or removes the request's in-cart insertions depending on mode.
+func applyCart(req *delivery.Request, cc CartContext, mode CartFilterMode, buryMultiplier float64) error {
+	inCart := make(map[string]bool, len(cc.InCartContentIDs))
+	for _, id := range cc.InCartContentIDs {
+		inCart[id] = true
+	}
+	if mode == CartFilterModeExclude {
+		kept := req.Insertion[:0]
+		for _, ins := range req.Insertion {
+			if !inCart[ins.GetContentId()] {
+				kept = append(kept, ins)
+			}
+		}
+		req.Insertion = kept
+		return nil
+	}
+	for _, ins := range req.Insertion {
+		if !inCart[ins.GetContentId()] {
+			continue
+		}
+		if err := multiplyBoost(ins, buryMultiplier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
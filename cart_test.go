@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func deliverWithCart(t *testing.T, api *fakeAPI, b *DeliveryRequestBuilder) *DeliveryResponse {
+	t.Helper()
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+	resp, err := c.Deliver(context.Background(), buildTestRequest(t, b))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	return resp
+}
+
+func TestCartItemsAreBuried(t *testing.T) {
+	api := newFakeAPI(t)
+	resp := deliverWithCart(t, api, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a", "b", "c").Request).
+		WithCartContext(CartContext{InCartContentIDs: []string{"a"}}))
+
+	assertContentIDs(t, resp, "b", "c", "a")
+	if got := sentProperty(t, api, "a", boostMultiplierPropertyKey); got != 0.1 {
+		t.Errorf("sent bury multiplier %v, want the default 0.1", got)
+	}
+}
+
+func TestCartBuryMultiplier(t *testing.T) {
+	api := newFakeAPI(t)
+	deliverWithCart(t, api, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a", "b").Request).
+		WithCartContext(CartContext{InCartContentIDs: []string{"b"}}).
+		WithCartBuryMultiplier(0.25))
+
+	if got := sentProperty(t, api, "b", boostMultiplierPropertyKey); got != 0.25 {
+		t.Errorf("sent bury multiplier %v, want 0.25", got)
+	}
+}
+
+func TestCartItemsAreExcluded(t *testing.T) {
+	api := newFakeAPI(t)
+	resp := deliverWithCart(t, api, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a", "b", "c").Request).
+		WithCartContext(CartContext{InCartContentIDs: []string{"a", "c"}}).
+		WithCartFilterMode(CartFilterModeExclude))
+
+	assertContentIDs(t, resp, "b")
+	if got := insertionContentIDsOf(api.lastRequest(t).GetInsertion()); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("sent insertions %v, want cart items removed", got)
+	}
+}
+
+func TestBuildLeavesTheCallersRequestAlone(t *testing.T) {
+	original := newTestDeliveryRequest(t, "a", "b", "c", "d").Request
+	caller := proto.Clone(original)
+	b := NewDeliveryRequestBuilder(original).
+		WithCartContext(CartContext{InCartContentIDs: []string{"a"}}).
+		WithCartFilterMode(CartFilterModeExclude).
+		WithWishlistContext(WishlistContext{WishlistedContentIDs: []string{"c"}}).
+		WithSubscriptionContext(SubscriptionContext{IsSubscriber: true, EligibleContentIDs: []string{"b"}})
+
+	first := buildTestRequest(t, b)
+	second := buildTestRequest(t, b)
+	if !proto.Equal(original, caller) {
+		t.Errorf("Build changed the caller's request to %v", original)
+	}
+	if !proto.Equal(first.Request, second.Request) {
+		t.Errorf("building again got %v, want %v", second.Request, first.Request)
+	}
+	if got := insertionContentIDsOf(first.Request.GetInsertion()); !reflect.DeepEqual(got, []string{"b", "c", "d"}) {
+		t.Errorf("got insertions %v, want the cart item excluded", got)
+	}
+}
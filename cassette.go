@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// cassetteVersion is the go-vcr v2 cassette format version. The cassette is written as JSON, which
+// go-vcr reads as YAML.
+const cassetteVersion = 2
+
+// credentialHeaders aren't saved to cassettes, which are meant to be committed as test fixtures.
+var credentialHeaders = []string{"x-api-key", "Authorization", "Cookie", "Set-Cookie"}
+
+// withoutCredentials returns a copy of header without credentialHeaders.
+func withoutCredentials(header http.Header) http.Header {
+	header = header.Clone()
+	for _, key := range credentialHeaders {
+		header.Del(key)
+	}
+	return header
+}
+
+// CassetteMode controls whether the recording transport records, replays or does neither.
+type CassetteMode string
+
+const (
+	// CassetteModeAuto replays if the cassette file exists and records otherwise.
+	CassetteModeAuto CassetteMode = ""
+	// CassetteModeRecord makes real calls and records them, overwriting the cassette.
+	CassetteModeRecord CassetteMode = "RECORD"
+	// CassetteModeReplay serves calls from the cassette and never calls the Delivery API.
+	CassetteModeReplay CassetteMode = "REPLAY"
+	// CassetteModePassthrough makes real calls without recording them.
+	CassetteModePassthrough CassetteMode = "PASSTHROUGH"
+)
+
+// cassette is the go-vcr v2 cassette layout.
+type cassette struct {
+	Version      int            `json:"version"`
+	Interactions []*interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Request  cassetteRequest  `json:"request"`
+	Response cassetteResponse `json:"response"`
+}
+
+type cassetteRequest struct {
+	Body    string      `json:"body"`
+	Form    url.Values  `json:"form"`
+	Headers http.Header `json:"headers"`
+	URL     string      `json:"url"`
+	Method  string      `json:"method"`
+}
+
+type cassetteResponse struct {
+	Body     string      `json:"body"`
+	Headers  http.Header `json:"headers"`
+	Status   string      `json:"status"`
+	Code     int         `json:"code"`
+	Duration string      `json:"duration"`
+}
+
+// cassetteTransport records Delivery API calls to a cassette file or replays them from it.
+// Recording reads whole response bodies, so disable gzip when recording to keep bodies readable.
+type cassetteTransport struct {
+	path string
+	mode CassetteMode
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	cassette *cassette
+	used     []bool
+}
+
+// newCassetteTransport resolves CassetteModeAuto and loads the cassette for replay.
+func newCassetteTransport(path string, mode CassetteMode, next http.RoundTripper) (*cassetteTransport, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if mode == CassetteModeAuto {
+		mode = CassetteModeRecord
+		if _, err := os.Stat(path); err == nil {
+			mode = CassetteModeReplay
+		}
+	}
+	t := &cassetteTransport{path: path, mode: mode, next: next, cassette: &cassette{Version: cassetteVersion}}
+	if mode == CassetteModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading cassette: %v", err)
+		}
+		if err := json.Unmarshal(data, t.cassette); err != nil {
+			return nil, fmt.Errorf("error parsing cassette %s: %v", path, err)
+		}
+		t.used = make([]bool, len(t.cassette.Interactions))
+	}
+	return t, nil
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch t.mode {
+	case CassetteModePassthrough:
+		return t.next.RoundTrip(req)
+	case CassetteModeReplay:
+		return t.replay(req)
+	default:
+		return t.record(req)
+	}
+}
+
+func (t *cassetteTransport) record(req *http.Request) (*http.Response, error) {
+	reqBody, err := readAndRestore(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readAndRestore(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, &interaction{
+		Request: cassetteRequest{
+			Body:    string(reqBody),
+			Headers: withoutCredentials(req.Header),
+			URL:     req.URL.String(),
+			Method:  req.Method,
+		},
+		Response: cassetteResponse{
+			Body:     string(respBody),
+			Headers:  withoutCredentials(resp.Header),
+			Status:   resp.Status,
+			Code:     resp.StatusCode,
+			Duration: time.Since(start).String(),
+		},
+	})
+	// Save after every call so the cassette is complete whenever the process stops.
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding cassette: %v", err)
+	}
+	if err := os.WriteFile(t.path, data, 0o600); err != nil {
+		return nil, fmt.Errorf("error writing cassette: %v", err)
+	}
+	return resp, nil
+}
+
+// replay serves the first unused interaction with the same method and URL, like go-vcr's default
+// matcher, falling back to reusing one if all matches were used. Bodies aren't matched because
+// protojson output isn't byte-stable.
+func (t *cassetteTransport) replay(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	match := -1
+	for i, in := range t.cassette.Interactions {
+		if in.Request.Method != req.Method || in.Request.URL != req.URL.String() {
+			continue
+		}
+		if !t.used[i] {
+			match = i
+			break
+		}
+		if match < 0 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("no cassette interaction for %s %s", req.Method, req.URL)
+	}
+	t.used[match] = true
+
+	recorded := t.cassette.Interactions[match].Response
+	return &http.Response{
+		Status:        recorded.Status,
+		StatusCode:    recorded.Code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Headers.Clone(),
+		Body:          io.NopCloser(bytes.NewBufferString(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// readAndRestore reads body fully and replaces it with an unread copy.
+func readAndRestore(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error reading body for cassette: %v", err)
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
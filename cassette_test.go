@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func newRecordingClient(t *testing.T, api *fakeAPI, path string, mode CassetteMode) *DeliveryClient {
+	t.Helper()
+	return buildTestClient(t, newTestClientBuilder(t, api).
+		WithTestMode(true).
+		WithTestRecording(path).
+		WithCassetteMode(mode))
+}
+
+func TestCassetteReplayMatchesRecording(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		writeDeliveryResponse(t, w, rankedResponse("c", "a", "b"))
+	})
+	path := filepath.Join(t.TempDir(), "delivery.json")
+
+	recorder := newRecordingClient(t, api, path, CassetteModeAuto)
+	recorded, err := recorder.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, recorded, "c", "a", "b")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading cassette: %v", err)
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		t.Fatalf("error parsing cassette: %v", err)
+	}
+	if c.Version != cassetteVersion || len(c.Interactions) != 1 || c.Interactions[0].Response.Code != http.StatusOK {
+		t.Fatalf("got cassette version %d with %d interactions, want one recorded 200", c.Version, len(c.Interactions))
+	}
+
+	// The cassette now exists, so the default mode replays it.
+	replayer := newRecordingClient(t, api, path, CassetteModeAuto)
+	replayed, err := replayer.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, replayed, "c", "a", "b")
+	if replayed.ExecutionServer != delivery.ExecutionServer_API {
+		t.Errorf("got execution server %v for a replayed response, want API", replayed.ExecutionServer)
+	}
+	if api.calls() != 1 {
+		t.Errorf("got %d Delivery API calls, want only the recorded one", api.calls())
+	}
+}
+
+func TestCassettePassthroughDoesNotRecord(t *testing.T) {
+	api := newFakeAPI(t)
+	path := filepath.Join(t.TempDir(), "delivery.json")
+	c := newRecordingClient(t, api, path, CassetteModePassthrough)
+
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if api.calls() != 1 {
+		t.Errorf("got %d Delivery API calls, want 1", api.calls())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("passthrough wrote a cassette: %v", err)
+	}
+}
+
+func TestCassetteReplayRequiresCassette(t *testing.T) {
+	_, err := newTestClientBuilder(t, newFakeAPI(t)).
+		WithTestMode(true).
+		WithTestRecording(filepath.Join(t.TempDir(), "missing.json")).
+		WithCassetteMode(CassetteModeReplay).
+		Build()
+	if err == nil {
+		t.Error("Build succeeded replaying a missing cassette")
+	}
+}
+
+func TestTestRecordingRequiresTestMode(t *testing.T) {
+	if _, err := newTestClientBuilder(t, newFakeAPI(t)).WithTestRecording(filepath.Join(t.TempDir(), "c.json")).Build(); err == nil {
+		t.Error("Build succeeded with test recording outside test mode")
+	}
+}
+
+func TestCassetteRecordingRedactsCredentials(t *testing.T) {
+	oidc, _ := newOIDCServer(t, 3600)
+	for name, withAuth := range map[string]func(b *DeliveryClientBuilder) *DeliveryClientBuilder{
+		"API key": func(b *DeliveryClientBuilder) *DeliveryClientBuilder { return b.WithDeliveryAPIKey("secret-key") },
+		"OIDC": func(b *DeliveryClientBuilder) *DeliveryClientBuilder {
+			return b.WithOIDCAuth(NewClientCredentialsProvider(oidc.URL, "client-1", "secret-1", []string{"delivery"}))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			api := newFakeAPI(t)
+			api.setResponseHeader("Set-Cookie", "session=secret-session")
+			path := filepath.Join(t.TempDir(), "delivery.json")
+			c := buildTestClient(t, withAuth(newTestClientBuilder(t, api).
+				WithTestMode(true).
+				WithTestRecording(path).
+				WithCassetteMode(CassetteModeRecord)))
+
+			if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+				t.Fatalf("Deliver failed: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("error reading cassette: %v", err)
+			}
+			for _, secret := range []string{"secret-key", "token-1", "secret-session"} {
+				if strings.Contains(string(data), secret) {
+					t.Errorf("cassette contains %s:\n%s", secret, data)
+				}
+			}
+			if !strings.Contains(string(data), "application/json") {
+				t.Error("cassette is missing the request's other headers")
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o600 {
+				t.Errorf("cassette has mode %o, want 600", perm)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ReplayReport summarizes replaying a cassette against a new endpoint.
+type ReplayReport struct {
+	// Entries is the number of cassette interactions replayed.
+	Entries int
+	// Errors counts replays that got no response.
+	Errors int
+	// StatusMismatches counts responses whose status code differs from the recorded one.
+	StatusMismatches int
+	// RankingDiffs counts Delivery API responses ranking insertions differently than recorded.
+	RankingDiffs int
+	// BodyDiffs counts other responses whose body differs from the recorded one.
+	BodyDiffs int
+
+	P50Ms float64
+	P95Ms float64
+	P99Ms float64
+}
+
+// CassetteReplayer replays cassettes recorded by WithTestRecording against another endpoint, e.g.
+// to load test or regression test a new model version.
+type CassetteReplayer struct {
+	httpClient *http.Client
+	apiKey     string
+}
+
+// NewCassetteReplayer is a factory method for CassetteReplayer. Each call times out after timeout.
+// Recorded credentials are never resent; calls authenticate with apiKey instead, if set.
+func NewCassetteReplayer(timeout time.Duration, apiKey string) *CassetteReplayer {
+	return &CassetteReplayer{httpClient: &http.Client{Timeout: timeout}, apiKey: apiKey}
+}
+
+// replayResult is the outcome of replaying one interaction.
+type replayResult struct {
+	latency time.Duration
+	err     error
+	code    int
+	body    []byte
+}
+
+// Replay sends every interaction of the cassette at cassettePath to targetURL, which replaces each
+// recorded URL's scheme and host, at up to rps calls per second. A non-positive rps is unlimited.
+func (r *CassetteReplayer) Replay(ctx context.Context, cassettePath string, targetURL string, rps float64) (*ReplayReport, error) {
+	data, err := os.ReadFile(cassettePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %v", err)
+	}
+	var recorded cassette
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("error parsing cassette %s: %v", cassettePath, err)
+	}
+	target, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target URL: %v", err)
+	}
+
+	limit := rate.Inf
+	if rps > 0 {
+		limit = rate.Limit(rps)
+	}
+	limiter := rate.NewLimiter(limit, 1)
+
+	results := make([]replayResult, len(recorded.Interactions))
+	var wg sync.WaitGroup
+	for i, in := range recorded.Interactions {
+		if err := limiter.Wait(ctx); err != nil {
+			wg.Wait()
+			return nil, err
+		}
+		wg.Add(1)
+		go func(i int, in *interaction) {
+			defer wg.Done()
+			results[i] = r.replay(ctx, in.Request, target)
+		}(i, in)
+	}
+	wg.Wait()
+
+	report := &ReplayReport{Entries: len(recorded.Interactions)}
+	var latencies []float64
+	for i, result := range results {
+		if result.err != nil {
+			report.Errors++
+			continue
+		}
+		latencies = append(latencies, float64(result.latency)/float64(time.Millisecond))
+		want := recorded.Interactions[i].Response
+		switch {
+		case result.code != want.Code:
+			report.StatusMismatches++
+		case isRankingDiff(want.Body, result.body):
+			report.RankingDiffs++
+		case !isDeliveryResponse(want.Body) && want.Body != string(result.body):
+			report.BodyDiffs++
+		}
+	}
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		report.P50Ms = percentile(latencies, 0.50)
+		report.P95Ms = percentile(latencies, 0.95)
+		report.P99Ms = percentile(latencies, 0.99)
+	}
+	return report, nil
+}
+
+// replay sends one recorded request to target.
+func (r *CassetteReplayer) replay(ctx context.Context, recorded cassetteRequest, target *url.URL) replayResult {
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return replayResult{err: fmt.Errorf("invalid recorded URL: %v", err)}
+	}
+	u.Scheme = target.Scheme
+	u.Host = target.Host
+
+	req, err := http.NewRequestWithContext(ctx, recorded.Method, u.String(), bytes.NewBufferString(recorded.Body))
+	if err != nil {
+		return replayResult{err: fmt.Errorf("error creating HTTP request: %v", err)}
+	}
+	req.Header = withoutCredentials(recorded.Headers)
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if r.apiKey != "" {
+		req.Header.Set("x-api-key", r.apiKey)
+	}
+	// The recorded body is uncompressed, so let the transport handle compression.
+	req.Header.Del("Accept-Encoding")
+
+	start := time.Now()
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return replayResult{err: fmt.Errorf("error making HTTP request: %v", err)}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return replayResult{err: fmt.Errorf("error reading response body: %v", err)}
+	}
+	return replayResult{latency: time.Since(start), code: resp.StatusCode, body: body}
+}
+
+// isDeliveryResponse reports whether body is a Delivery API response with insertions.
+func isDeliveryResponse(body string) bool {
+	_, ok := rankedContentIDs([]byte(body))
+	return ok
+}
+
+// isRankingDiff reports whether two Delivery API responses rank different content. Other fields,
+// such as request IDs, differ on every call so they aren't compared.
+func isRankingDiff(want string, got []byte) bool {
+	wantIDs, ok := rankedContentIDs([]byte(want))
+	if !ok {
+		return false
+	}
+	gotIDs, _ := rankedContentIDs(got)
+	return !slices.Equal(wantIDs, gotIDs)
+}
+
+// rankedContentIDs parses body as a Delivery API response, returning its content IDs in order.
+func rankedContentIDs(body []byte) ([]string, bool) {
+	var resp delivery.Response
+	if err := protojson.Unmarshal(body, &resp); err != nil || len(resp.GetInsertion()) == 0 {
+		return nil, false
+	}
+	ids := make([]string, len(resp.GetInsertion()))
+	for i, ins := range resp.GetInsertion() {
+		ids[i] = ins.GetContentId()
+	}
+	return ids, true
+}
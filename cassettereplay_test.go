@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// deliveryInteraction is a recorded Delivery API call for contentIDs whose response ranked them
+// as ranked.
+func deliveryInteraction(t *testing.T, code int, contentIDs []string, ranked ...string) *interaction {
+	t.Helper()
+	req := &delivery.Request{ClientRequestId: "recorded"}
+	for _, id := range contentIDs {
+		req.Insertion = append(req.Insertion, &delivery.Insertion{ContentId: id})
+	}
+	reqBody, err := protojson.Marshal(req)
+	if err != nil {
+		t.Fatalf("error marshaling delivery request: %v", err)
+	}
+	respBody, err := protojson.Marshal(rankedResponse(ranked...))
+	if err != nil {
+		t.Fatalf("error marshaling delivery response: %v", err)
+	}
+	return &interaction{
+		Request: cassetteRequest{
+			Method:  http.MethodPost,
+			URL:     "https://recorded.example" + deliveryEndpointSuffix,
+			Headers: http.Header{"Content-Type": {"application/json"}},
+			Body:    string(reqBody),
+		},
+		Response: cassetteResponse{Code: code, Body: string(respBody)},
+	}
+}
+
+func writeTestCassette(t *testing.T, interactions ...*interaction) string {
+	t.Helper()
+	data, err := json.Marshal(&cassette{Version: cassetteVersion, Interactions: interactions})
+	if err != nil {
+		t.Fatalf("error marshaling cassette: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("error writing cassette: %v", err)
+	}
+	return path
+}
+
+func TestReplayReportsEveryEntry(t *testing.T) {
+	// The new endpoint returns insertions in request order.
+	api := newFakeAPI(t)
+	ab := []string{"a", "b"}
+	path := writeTestCassette(t,
+		deliveryInteraction(t, http.StatusOK, ab, "a", "b"),
+		deliveryInteraction(t, http.StatusOK, ab, "b", "a"),
+		deliveryInteraction(t, http.StatusInternalServerError, ab, "a", "b"),
+		&interaction{
+			Request:  cassetteRequest{Method: http.MethodGet, URL: "https://recorded.example/healthz"},
+			Response: cassetteResponse{Code: http.StatusOK, Body: "ok"},
+		},
+	)
+
+	report, err := NewCassetteReplayer(time.Second, "").Replay(context.Background(), path, api.URL, 0)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if report.Entries != 4 || api.calls() != 3 || len(api.otherCalls()) != 1 {
+		t.Fatalf("got %d entries with %d delivery and %d other calls, want 4, 3 and 1",
+			report.Entries, api.calls(), len(api.otherCalls()))
+	}
+	if report.Errors != 0 || report.StatusMismatches != 1 || report.RankingDiffs != 1 || report.BodyDiffs != 1 {
+		t.Errorf("got report %+v, want one status mismatch, ranking diff and body diff", report)
+	}
+	if report.P50Ms <= 0 || report.P50Ms > report.P95Ms || report.P95Ms > report.P99Ms {
+		t.Errorf("got latency percentiles %v, %v, %v, want positive and nondecreasing", report.P50Ms, report.P95Ms, report.P99Ms)
+	}
+}
+
+func TestReplayCountsUnreachableTargetAsErrors(t *testing.T) {
+	path := writeTestCassette(t, deliveryInteraction(t, http.StatusOK, []string{"a"}, "a"))
+	api := newFakeAPI(t)
+	target := api.URL
+	api.Close()
+
+	report, err := NewCassetteReplayer(time.Second, "").Replay(context.Background(), path, target, 0)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if report.Entries != 1 || report.Errors != 1 || report.P50Ms != 0 {
+		t.Errorf("got report %+v, want one errored entry without latencies", report)
+	}
+}
+
+func TestReplayIsRateLimited(t *testing.T) {
+	var interactions []*interaction
+	for i := 0; i < 5; i++ {
+		interactions = append(interactions, deliveryInteraction(t, http.StatusOK, []string{"a"}, "a"))
+	}
+	path := writeTestCassette(t, interactions...)
+	api := newFakeAPI(t)
+
+	start := time.Now()
+	if _, err := NewCassetteReplayer(time.Second, "").Replay(context.Background(), path, api.URL, 20); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	// The first call goes out immediately and the other four wait 50ms each.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("replayed 5 entries at 20 rps in %v, want about 200ms", elapsed)
+	}
+}
+
+func TestReplayRejectsInvalidCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatalf("error writing cassette: %v", err)
+	}
+	replayer := NewCassetteReplayer(time.Second, "")
+	if _, err := replayer.Replay(context.Background(), path, "http://localhost", 0); err == nil {
+		t.Error("Replay succeeded with an invalid cassette")
+	}
+	if _, err := replayer.Replay(context.Background(), filepath.Join(t.TempDir(), "missing.json"), "http://localhost", 0); err == nil {
+		t.Error("Replay succeeded with a missing cassette")
+	}
+}
+
+func TestReplaySendsOnlyNonCredentialHeaders(t *testing.T) {
+	api := newFakeAPI(t)
+	in := deliveryInteraction(t, http.StatusOK, []string{"a"}, "a")
+	// Cassettes recorded before credentials were redacted still hold them.
+	in.Request.Headers.Set("x-api-key", "recorded-key")
+	in.Request.Headers.Set("Authorization", "Bearer recorded-token")
+	in.Request.Headers.Set("Cookie", "session=recorded")
+	in.Request.Headers.Set("X-Model-Version", "v2")
+	path := writeTestCassette(t, in)
+
+	if _, err := NewCassetteReplayer(time.Second, "current-key").Replay(context.Background(), path, api.URL, 0); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	header := api.lastHeader(t)
+	if got := header.Get("x-api-key"); got != "current-key" {
+		t.Errorf("got x-api-key %q, want current-key", got)
+	}
+	for _, key := range []string{"Authorization", "Cookie"} {
+		if got := header.Get(key); got != "" {
+			t.Errorf("resent recorded %s %q", key, got)
+		}
+	}
+	if got := header.Get("X-Model-Version"); got != "v2" {
+		t.Errorf("got X-Model-Version %q, want the recorded v2", got)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// categoryPropertyKey holds the category ID of an insertion.
+const categoryPropertyKey = "category"
+
+// CategoryTaxonomy maps each category to its direct subcategories.
+type CategoryTaxonomy struct {
+	mu       sync.RWMutex
+	children map[string][]string
+}
+
+// NewCategoryTaxonomy is a factory method for CategoryTaxonomy.
+func NewCategoryTaxonomy() *CategoryTaxonomy {
+	return &CategoryTaxonomy{children: map[string][]string{}}
+}
+
+// Load replaces the taxonomy with a JSON object of category to subcategories, e.g.
+// {"electronics": ["phones", "laptops"], "phones": ["smartphones"]}.
+func (t *CategoryTaxonomy) Load(reader io.Reader) error {
+	var children map[string][]string
+	if err := json.NewDecoder(reader).Decode(&children); err != nil {
+		return fmt.Errorf("error parsing category taxonomy: %v", err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.children = children
+	return nil
+}
+
+// Expand returns the category and all of its descendants.
+func (t *CategoryTaxonomy) Expand(category string) map[string]bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	expanded := map[string]bool{}
+	queue := []string{category}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if expanded[c] {
+			continue
+		}
+		expanded[c] = true
+		queue = append(queue, t.children[c]...)
+	}
+	return expanded
+}
+
+// categoryFilter is a request's category filter, set with WithCategoryFilter.
+type categoryFilter struct {
+	category  string
+	inclusive bool
+}
+
+// categoryFiltering drops insertions by category, including subcategories from the taxonomy.
+type categoryFiltering struct {
+	taxonomy *CategoryTaxonomy
+}
+
+func (f *categoryFiltering) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		if req.categoryFilter == nil {
+			return next(ctx, req)
+		}
+		categories := map[string]bool{req.categoryFilter.category: true}
+		if f.taxonomy != nil {
+			categories = f.taxonomy.Expand(req.categoryFilter.category)
+		}
+
+		req = cloneRequest(req)
+		kept := req.Request.Insertion[:0]
+		for _, ins := range req.Request.Insertion {
+			if categories[insertionCategory(ins)] == req.categoryFilter.inclusive {
+				kept = append(kept, ins)
+			}
+		}
+		req.Request.Insertion = kept
+		return next(ctx, req)
+	}
+}
+
+func insertionCategory(ins *delivery.Insertion) string {
+	return getProperty(ins.Properties, categoryPropertyKey).GetStringValue()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func newTestTaxonomy(t *testing.T) *CategoryTaxonomy {
+	t.Helper()
+	taxonomy := NewCategoryTaxonomy()
+	if err := taxonomy.Load(strings.NewReader(`{"electronics": ["phones", "laptops"], "phones": ["smartphones"]}`)); err != nil {
+		t.Fatalf("error loading taxonomy: %v", err)
+	}
+	return taxonomy
+}
+
+// newCategorizedRequest has one insertion per category, with the category as its content ID.
+func newCategorizedRequest(t *testing.T, category string, inclusive bool) *DeliveryRequest {
+	t.Helper()
+	insertions := testInsertions("electronics", "phones", "laptops", "smartphones", "books")
+	for _, ins := range insertions {
+		setProperty(&ins.Properties, categoryPropertyKey, ins.GetContentId())
+	}
+	return buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:  &common.UserInfo{AnonUserId: "anon-1"},
+		Insertion: insertions,
+	}).WithCategoryFilter(category, inclusive))
+}
+
+func TestCategoryFilterExcludesSubcategories(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithCategoryTaxonomy(newTestTaxonomy(t)))
+
+	resp, err := c.Deliver(context.Background(), newCategorizedRequest(t, "electronics", false))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "books")
+}
+
+func TestCategoryFilterIncludesSubcategories(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithCategoryTaxonomy(newTestTaxonomy(t)))
+
+	resp, err := c.Deliver(context.Background(), newCategorizedRequest(t, "phones", true))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "phones", "smartphones")
+}
+
+func TestCategoryFilterWithoutTaxonomyMatchesExactly(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	resp, err := c.Deliver(context.Background(), newCategorizedRequest(t, "electronics", false))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "phones", "laptops", "smartphones", "books")
+}
+
+func TestCategoryTaxonomyLoadRejectsInvalidJSON(t *testing.T) {
+	if err := NewCategoryTaxonomy().Load(strings.NewReader(`["electronics"]`)); err == nil {
+		t.Error("Load accepted a taxonomy that isn't an object")
+	}
+}
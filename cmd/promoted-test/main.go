@@ -0,0 +1,296 @@
+// Command promoted-test sends test requests to the Delivery API from the terminal, so a
+// configuration can be checked without writing Go code.
+//
+// The example's Config and NewPromotedDeliveryClient live in package main, which Go can't import,
+// so this binary mirrors Config and builds the Promoted SDK client the same way.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const usage = `Usage: promoted-test <command> [flags]
+
+Commands:
+  deliver   sends a test request and prints the ranked content IDs
+  validate  validates a test request with the dry-run endpoint
+  warmup    verifies connectivity to the Delivery API
+
+Run a command with -h for its flags. Flags default to the environment variables.
+`
+
+const timeoutMillis = 1000
+
+const deliveryEndpointSuffix = "/deliver"
+const healthEndpointSuffix = "/healthz"
+
+// Config mirrors the example's Config.
+type Config struct {
+	MetricsApiEndpointUrl     string
+	MetricsApiKey             string
+	DeliveryApiEndpointUrl    string
+	DeliveryApiKey            string
+	OnlyLog                   bool
+	ShadowTrafficDeliveryRate float64
+	BlockingShadowTraffic     bool
+}
+
+// validationResult is the dry-run endpoint's response.
+type validationResult struct {
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the sub-command in args, returning the process exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	switch args[0] {
+	case "deliver", "validate", "warmup":
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	config := configFlags(flags)
+	insertions := flags.String("insertions", "", "comma-separated content IDs to rank")
+	useCase := flags.String("use-case", "SEARCH", "use case of the request")
+	searchQuery := flags.String("search-query", "", "search query of the request")
+	userID := flags.String("user-id", "", "user ID of the request")
+	anonUserID := flags.String("anon-user-id", "testAnonUserId1", "anonymous user ID of the request")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if err := validateConfig(*config); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+	if args[0] == "warmup" {
+		if err := warmup(*config); err != nil {
+			fmt.Fprintf(stderr, "Warmup failed: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "OK")
+		return 0
+	}
+
+	parsedUseCase, ok := delivery.UseCase_value[strings.ToUpper(*useCase)]
+	if !ok {
+		fmt.Fprintf(stderr, "Error: unknown use case %q\n", *useCase)
+		return 2
+	}
+	var requestInsertions []*delivery.Insertion
+	for _, contentID := range strings.Split(*insertions, ",") {
+		if contentID = strings.TrimSpace(contentID); contentID != "" {
+			requestInsertions = append(requestInsertions, &delivery.Insertion{ContentId: contentID})
+		}
+	}
+	if len(requestInsertions) == 0 {
+		fmt.Fprintln(stderr, "Error: --insertions needs to be specified")
+		return 2
+	}
+	request := &delivery.Request{
+		UserInfo:    &common.UserInfo{UserId: *userID, AnonUserId: *anonUserID},
+		UseCase:     delivery.UseCase(parsedUseCase),
+		SearchQuery: *searchQuery,
+		Insertion:   requestInsertions,
+	}
+
+	if args[0] == "validate" {
+		result, err := validate(*config, request)
+		if err != nil {
+			fmt.Fprintf(stderr, "Validation failed: %v\n", err)
+			return 1
+		}
+		for _, msg := range result.Errors {
+			fmt.Fprintf(stdout, "ERROR: %s\n", msg)
+		}
+		for _, msg := range result.Warnings {
+			fmt.Fprintf(stdout, "WARN: %s\n", msg)
+		}
+		if !result.Valid {
+			fmt.Fprintln(stdout, "INVALID")
+			return 1
+		}
+		fmt.Fprintln(stdout, "VALID")
+		return 0
+	}
+
+	promoted, err := newPromotedDeliveryClient(*config)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error initializing PromotedDeliveryClient: %v\n", err)
+		return 1
+	}
+	resp, err := promoted.Deliver(client.NewDeliveryRequest(request, nil, config.OnlyLog, 0, nil))
+	if err != nil {
+		fmt.Fprintf(stderr, "Delivery failed: %v\n", err)
+		return 1
+	}
+	for _, ins := range resp.Response.GetInsertion() {
+		fmt.Fprintln(stdout, ins.GetContentId())
+	}
+	return 0
+}
+
+// newPromotedDeliveryClient builds the SDK client with the example's settings.
+func newPromotedDeliveryClient(config Config) (*client.PromotedDeliveryClient, error) {
+	return client.NewPromotedDeliveryClientBuilder().
+		WithDeliveryEndpoint(config.DeliveryApiEndpointUrl).
+		WithDeliveryAPIKey(config.DeliveryApiKey).
+		WithDeliveryTimeoutMillis(timeoutMillis).
+		WithMetricsEndpoint(config.MetricsApiEndpointUrl).
+		WithMetricsAPIKey(config.MetricsApiKey).
+		WithMetricsTimeoutMillis(timeoutMillis).
+		WithShadowTrafficDeliveryRate(float32(config.ShadowTrafficDeliveryRate)).
+		WithBlockingShadowTraffic(config.BlockingShadowTraffic).
+		WithAcceptsGzip(true).
+		WithAPIFactory(&client.DefaultAPIFactory{}).
+		Build()
+}
+
+// validate posts request to the dry-run endpoint. A 400 response still carries a result.
+func validate(config Config, request *delivery.Request) (*validationResult, error) {
+	requestBody, err := protojson.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling delivery request: %v", err)
+	}
+	respHTTP, err := post(config, deliveryEndpointSuffix+"?dry_run=true", requestBody)
+	if err != nil {
+		return nil, err
+	}
+	defer respHTTP.Body.Close()
+
+	if (respHTTP.StatusCode < 200 || respHTTP.StatusCode >= 300) && respHTTP.StatusCode != http.StatusBadRequest {
+		return nil, fmt.Errorf("failure calling dry-run endpoint; statusCode=%d", respHTTP.StatusCode)
+	}
+	var result validationResult
+	if err := json.NewDecoder(respHTTP.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error unmarshaling validation result: %v", err)
+	}
+	return &result, nil
+}
+
+// warmup posts a minimal request, with no insertions or user data, to the health endpoint.
+func warmup(config Config) error {
+	requestBody, err := protojson.Marshal(&delivery.Request{
+		ClientInfo: &common.ClientInfo{
+			ClientType:  common.ClientInfo_PLATFORM_SERVER,
+			TrafficType: common.ClientInfo_PRODUCTION,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling warmup request: %v", err)
+	}
+	respHTTP, err := post(config, healthEndpointSuffix, requestBody)
+	if err != nil {
+		return err
+	}
+	defer respHTTP.Body.Close()
+
+	if respHTTP.StatusCode < 200 || respHTTP.StatusCode >= 300 {
+		return fmt.Errorf("failure calling health endpoint; statusCode=%d", respHTTP.StatusCode)
+	}
+	return nil
+}
+
+// post sends body to the path on the Delivery API's host.
+func post(config Config, path string, body []byte) (*http.Response, error) {
+	uri, err := url.Parse(config.DeliveryApiEndpointUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delivery endpoint: %v", err)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, uri.Scheme+"://"+uri.Host+path, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating HTTP request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", config.DeliveryApiKey)
+
+	httpClient := &http.Client{Timeout: timeoutMillis * time.Millisecond}
+	respHTTP, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error making HTTP request: %v", err)
+	}
+	return respHTTP, nil
+}
+
+// configFlags defines a flag for each Config field, defaulting to its environment variable.
+func configFlags(flags *flag.FlagSet) *Config {
+	config := &Config{}
+	flags.StringVar(&config.MetricsApiEndpointUrl, "metrics-api-endpoint-url", os.Getenv("METRICS_API_ENDPOINT_URL"), "Metrics API endpoint")
+	flags.StringVar(&config.MetricsApiKey, "metrics-api-key", os.Getenv("METRICS_API_KEY"), "Metrics API key")
+	flags.StringVar(&config.DeliveryApiEndpointUrl, "delivery-api-endpoint-url", os.Getenv("DELIVERY_API_ENDPOINT_URL"), "Delivery API endpoint")
+	flags.StringVar(&config.DeliveryApiKey, "delivery-api-key", os.Getenv("DELIVERY_API_KEY"), "Delivery API key")
+	flags.BoolVar(&config.OnlyLog, "only-log", parseBoolEnv("ONLY_LOG", false), "log without calling the Delivery API")
+	flags.Float64Var(&config.ShadowTrafficDeliveryRate, "shadow-traffic-delivery-rate", parseFloatEnv("SHADOW_TRAFFIC_DELIVERY_RATE", 0.0), "rate of shadow traffic")
+	flags.BoolVar(&config.BlockingShadowTraffic, "blocking-shadow-traffic", parseBoolEnv("BLOCKING_SHADOW_TRAFFIC", false), "send shadow traffic synchronously")
+	return config
+}
+
+func validateConfig(config Config) error {
+	if config.MetricsApiEndpointUrl == "" {
+		return errors.New("metricsApiEndpointUrl needs to be specified")
+	}
+	if config.MetricsApiKey == "" {
+		return errors.New("metricsApiKey needs to be specified")
+	}
+	if config.DeliveryApiEndpointUrl == "" {
+		return errors.New("deliveryApiEndpointUrl needs to be specified")
+	}
+	if config.DeliveryApiKey == "" {
+		return errors.New("deliveryApiKey needs to be specified")
+	}
+	return nil
+}
+
+func parseBoolEnv(key string, defaultValue bool) bool {
+	val, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(val)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+func parseFloatEnv(key string, defaultValue float64) float64 {
+	val, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// binary is the promoted-test binary built for the tests.
+var binary string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "promoted-test")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating build directory: %v\n", err)
+		os.Exit(1)
+	}
+	binary = filepath.Join(dir, "promoted-test")
+	if out, err := exec.Command("go", "build", "-o", binary, ".").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "error building promoted-test: %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestServer is a Delivery API that ranks insertions in reverse, validates dry runs unless they
+// include content "bad", and is healthy if healthy is set. Metrics API calls get an empty 200.
+func newTestServer(t *testing.T, healthy bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case healthEndpointSuffix:
+			if !healthy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		case deliveryEndpointSuffix:
+		default:
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req delivery.Request
+		if err := protojson.Unmarshal(body, &req); err != nil {
+			t.Errorf("error unmarshaling delivery request: %v", err)
+			return
+		}
+		var ids []string
+		for _, ins := range req.GetInsertion() {
+			ids = append(ids, ins.GetContentId())
+		}
+		if r.URL.Query().Get("dry_run") == "true" {
+			if slices.Contains(ids, "bad") {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(validationResult{Errors: []string{"content bad is unknown"}})
+				return
+			}
+			json.NewEncoder(w).Encode(validationResult{Valid: true, Warnings: []string{"no search query"}})
+			return
+		}
+		slices.Reverse(ids)
+		resp := &delivery.Response{RequestId: "request-1"}
+		for i, id := range ids {
+			position := uint64(i)
+			resp.Insertion = append(resp.Insertion, &delivery.Insertion{ContentId: id, Position: &position})
+		}
+		data, err := protojson.Marshal(resp)
+		if err != nil {
+			t.Errorf("error marshaling delivery response: %v", err)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// runBinary runs promoted-test with args and flags for server, returning its stdout and exit code.
+func runBinary(t *testing.T, server *httptest.Server, args ...string) (string, int) {
+	t.Helper()
+	args = append(args,
+		"--delivery-api-endpoint-url", server.URL+deliveryEndpointSuffix,
+		"--delivery-api-key", "delivery-key",
+		"--metrics-api-endpoint-url", server.URL+"/log",
+		"--metrics-api-key", "metrics-key")
+	cmd := exec.Command(binary, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("error running promoted-test: %v", err)
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("%s stderr: %s", args[0], stderr.String())
+		}
+	})
+	return stdout.String(), cmd.ProcessState.ExitCode()
+}
+
+func TestDeliverPrintsRankedOrder(t *testing.T) {
+	out, code := runBinary(t, newTestServer(t, true), "deliver", "--insertions", "1, 2,3")
+	if code != 0 {
+		t.Fatalf("deliver exited with %d", code)
+	}
+	if got := strings.Fields(out); !reflect.DeepEqual(got, []string{"3", "2", "1"}) {
+		t.Errorf("deliver printed %q, want the ranked 3 2 1", out)
+	}
+}
+
+func TestDeliverRequiresInsertions(t *testing.T) {
+	if _, code := runBinary(t, newTestServer(t, true), "deliver"); code != 2 {
+		t.Errorf("deliver without insertions exited with %d, want 2", code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	server := newTestServer(t, true)
+
+	out, code := runBinary(t, server, "validate", "--insertions", "1,2")
+	if code != 0 || out != "WARN: no search query\nVALID\n" {
+		t.Errorf("validate printed %q and exited with %d, want a warning and VALID", out, code)
+	}
+	out, code = runBinary(t, server, "validate", "--insertions", "1,bad")
+	if code != 1 || out != "ERROR: content bad is unknown\nINVALID\n" {
+		t.Errorf("validate printed %q and exited with %d, want an error and INVALID", out, code)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	if out, code := runBinary(t, newTestServer(t, true), "warmup"); code != 0 || out != "OK\n" {
+		t.Errorf("warmup printed %q and exited with %d, want OK", out, code)
+	}
+	if _, code := runBinary(t, newTestServer(t, false), "warmup"); code != 1 {
+		t.Errorf("warmup of an unhealthy server exited with %d, want 1", code)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if _, code := runBinary(t, newTestServer(t, true), "rank"); code != 2 {
+		t.Errorf("unknown command exited with %d, want 2", code)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const defaultComplementaryCount = 5
+
+// ComplementaryRecommender suggests items bought together with the ranked ones, e.g. for a
+// "frequently bought together" section.
+type ComplementaryRecommender struct {
+	mu sync.RWMutex
+	// coPurchases counts how often each pair of content IDs was bought together.
+	coPurchases map[string]map[string]int
+}
+
+// NewComplementaryRecommender is a factory method for ComplementaryRecommender.
+func NewComplementaryRecommender() *ComplementaryRecommender {
+	return &ComplementaryRecommender{coPurchases: map[string]map[string]int{}}
+}
+
+// Load adds co-purchase data, mapping each content ID to the content IDs bought with it. An ID may
+// appear several times in a list to weight it by purchase count.
+func (r *ComplementaryRecommender) Load(coOccurrenceMap map[string][]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for contentID, coPurchased := range coOccurrenceMap {
+		if contentID == "" {
+			return errors.New("co-purchase content IDs must not be empty")
+		}
+		counts := r.coPurchases[contentID]
+		if counts == nil {
+			counts = map[string]int{}
+			r.coPurchases[contentID] = counts
+		}
+		for _, other := range coPurchased {
+			if other != "" && other != contentID {
+				counts[other]++
+			}
+		}
+	}
+	return nil
+}
+
+// Recommend returns up to k items most often bought with any of contentIDs, leaving out
+// contentIDs themselves. Ties are broken by content ID.
+func (r *ComplementaryRecommender) Recommend(contentIDs []string, k int) []*delivery.Insertion {
+	input := make(map[string]bool, len(contentIDs))
+	for _, id := range contentIDs {
+		input[id] = true
+	}
+
+	r.mu.RLock()
+	totals := map[string]int{}
+	for _, id := range contentIDs {
+		for other, count := range r.coPurchases[id] {
+			if !input[other] {
+				totals[other] += count
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	candidates := make([]string, 0, len(totals))
+	for id := range totals {
+		candidates = append(candidates, id)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if totals[candidates[i]] != totals[candidates[j]] {
+			return totals[candidates[i]] > totals[candidates[j]]
+		}
+		return candidates[i] < candidates[j]
+	})
+	if k < len(candidates) {
+		candidates = candidates[:max(k, 0)]
+	}
+
+	insertions := make([]*delivery.Insertion, len(candidates))
+	for i, id := range candidates {
+		insertions[i] = &delivery.Insertion{ContentId: id}
+	}
+	return insertions
+}
+
+// complementaryRecommendations attaches complementary items to each response.
+type complementaryRecommendations struct {
+	recommender *ComplementaryRecommender
+	k           int
+}
+
+func (c *complementaryRecommendations) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		contentIDs := make([]string, 0, len(resp.Response.GetInsertion()))
+		for _, ins := range resp.Response.GetInsertion() {
+			contentIDs = append(contentIDs, ins.GetContentId())
+		}
+		resp = cloneResponse(resp)
+		resp.Complementary = c.recommender.Recommend(contentIDs, c.k)
+		return resp, nil
+	}
+}
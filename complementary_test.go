@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func newTestComplementaryRecommender(t *testing.T) *ComplementaryRecommender {
+	t.Helper()
+	r := NewComplementaryRecommender()
+	err := r.Load(map[string][]string{
+		"shoes": {"socks", "socks", "socks", "laces", "shirt"},
+		"shirt": {"socks", "tie", "tie", "shoes"},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return r
+}
+
+func TestComplementaryRecommendations(t *testing.T) {
+	r := newTestComplementaryRecommender(t)
+	tests := []struct {
+		name       string
+		contentIDs []string
+		k          int
+		want       []string
+	}{
+		{"single item", []string{"shoes"}, 5, []string{"socks", "laces", "shirt"}},
+		// Input items are excluded even when they're bought with each other.
+		{"excludes input", []string{"shoes", "shirt"}, 5, []string{"socks", "tie", "laces"}},
+		{"capped at k", []string{"shoes", "shirt"}, 2, []string{"socks", "tie"}},
+		{"zero k", []string{"shoes"}, 0, []string{}},
+		{"unknown item", []string{"hat"}, 5, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := insertionContentIDsOf(r.Recommend(tt.contentIDs, tt.k)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComplementaryLoadRejectsEmptyContentID(t *testing.T) {
+	if err := NewComplementaryRecommender().Load(map[string][]string{"": {"a"}}); err == nil {
+		t.Error("Load succeeded with an empty content ID")
+	}
+}
+
+func TestComplementaryRecommenderAugmentsResponses(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithComplementaryRecommender(newTestComplementaryRecommender(t)).
+		WithComplementaryCount(1))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "shoes", "shirt"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "shoes", "shirt")
+	if got := insertionContentIDsOf(resp.Complementary); !reflect.DeepEqual(got, []string{"socks"}) {
+		t.Errorf("got complementary items %v, want [socks]", got)
+	}
+}
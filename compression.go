@@ -0,0 +1,34 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncodings are the response compressions the Delivery API may use.
+const acceptEncodings = "gzip, zstd"
+
+// decompressedBody returns a reader of the response's decompressed body, per its Content-Encoding.
+// Uncompressed bodies are returned as is. Callers must close the returned reader.
+func decompressedBody(resp *http.Response) (io.ReadCloser, error) {
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error creating gzip reader: %v", err)
+		}
+		return reader, nil
+	case "zstd":
+		decoder, err := zstd.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error creating zstd reader: %v", err)
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(resp.Body), nil
+	}
+}
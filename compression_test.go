@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func gzipCompress(t *testing.T, body []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdCompress(t *testing.T, body []byte) []byte {
+	t.Helper()
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encoder.Close()
+	return encoder.EncodeAll(body, nil)
+}
+
+func TestDeliverDecompressesResponses(t *testing.T) {
+	for _, tc := range []struct {
+		encoding string
+		compress func(t *testing.T, body []byte) []byte
+	}{
+		{"gzip", gzipCompress},
+		{"zstd", zstdCompress},
+		{"", func(t *testing.T, body []byte) []byte { return body }},
+	} {
+		t.Run("encoding "+tc.encoding, func(t *testing.T) {
+			api := newFakeAPI(t)
+			api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+				body, err := protojson.Marshal(rankedResponse("c", "b", "a"))
+				if err != nil {
+					t.Errorf("error marshaling delivery response: %v", err)
+					return
+				}
+				if tc.encoding != "" {
+					w.Header().Set("Content-Encoding", tc.encoding)
+				}
+				w.Write(tc.compress(t, body))
+			})
+			c := buildTestClient(t, newTestClientBuilder(t, api).WithAcceptsGzip(true))
+
+			resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c"))
+			if err != nil {
+				t.Fatalf("Deliver failed: %v", err)
+			}
+			if resp.ExecutionServer != delivery.ExecutionServer_API {
+				t.Fatalf("got execution server %v, want API: %v", resp.ExecutionServer, resp.APIError)
+			}
+			assertContentIDs(t, resp, "c", "b", "a")
+			if got := api.lastHeader(t).Get("Accept-Encoding"); got != acceptEncodings {
+				t.Errorf("got Accept-Encoding %q, want %q", got, acceptEncodings)
+			}
+		})
+	}
+}
+
+func TestDeliverFallsBackOnCorruptCompressedResponse(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte("not gzip"))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithAcceptsGzip(true))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if resp.ExecutionServer != delivery.ExecutionServer_SDK {
+		t.Errorf("got execution server %v, want SDK fallback", resp.ExecutionServer)
+	}
+	assertContentIDs(t, resp, "a", "b", "c")
+}
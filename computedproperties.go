@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// ComputedProperty is an insertion property derived from others, e.g.
+// discountPct = (originalPrice - price) / originalPrice. DependsOn lists the keys Compute reads
+// that are themselves computed, so they are evaluated first.
+type ComputedProperty struct {
+	Key       string
+	DependsOn []string
+	Compute   func(props map[string]any) (any, error)
+}
+
+// sortComputedProperties orders props so each comes after the computed properties it depends on,
+// keeping the given order otherwise.
+func sortComputedProperties(props []ComputedProperty) ([]ComputedProperty, error) {
+	byKey := make(map[string]int, len(props))
+	for i, prop := range props {
+		if _, ok := byKey[prop.Key]; ok {
+			return nil, fmt.Errorf("computed property %s is defined twice", prop.Key)
+		}
+		byKey[prop.Key] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(props))
+	sorted := make([]ComputedProperty, 0, len(props))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("computed property %s is part of a dependency cycle", props[i].Key)
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		for _, dep := range props[i].DependsOn {
+			// Dependencies on sent or lazy properties need no ordering.
+			if j, ok := byKey[dep]; ok {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = done
+		sorted = append(sorted, props[i])
+		return nil
+	}
+	for i := range props {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// applyComputedProperties evaluates props, already sorted, on each of req's insertions. Failures
+// are logged and their keys left unset, like lazy properties.
+func applyComputedProperties(req *DeliveryRequest) error {
+	for _, ins := range req.Request.GetInsertion() {
+		values := ins.GetProperties().GetStruct().AsMap()
+		for _, prop := range req.computedProperties {
+			value, err := prop.Compute(values)
+			if err != nil {
+				log.Printf("WARN: Omitting computed property %s of content %s: %v\n", prop.Key, ins.GetContentId(), err)
+				continue
+			}
+			if err := setProperty(&ins.Properties, prop.Key, value); err != nil {
+				return err
+			}
+			values[prop.Key] = getProperty(ins.Properties, prop.Key).AsInterface()
+		}
+	}
+	return nil
+}
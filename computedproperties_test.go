@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestComputedPropertiesFollowDependencies(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).
+		WithLazyProperties(LazyProperty{Key: "listPrice", Resolver: func(ctx context.Context, contentID string) (any, error) {
+			return 80.0, nil
+		}}).
+		// Listed before the property it depends on, so only sorting evaluates it after price.
+		WithComputedProperties(
+			ComputedProperty{Key: "discountPct", DependsOn: []string{"price", "originalPrice"}, Compute: func(props map[string]any) (any, error) {
+				originalPrice := props["originalPrice"].(float64)
+				return (originalPrice - props["price"].(float64)) / originalPrice, nil
+			}},
+			ComputedProperty{Key: "price", DependsOn: []string{"listPrice"}, Compute: func(props map[string]any) (any, error) {
+				return props["listPrice"].(float64) * 0.75, nil
+			}},
+			ComputedProperty{Key: "originalPrice", DependsOn: []string{"listPrice"}, Compute: func(props map[string]any) (any, error) {
+				return props["listPrice"].(float64), nil
+			}},
+		).
+		WithLazyPropertyTimeout(time.Second))
+
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := sentProperty(t, api, "a", "price"); got != 60.0 {
+		t.Errorf("got price %v, want 60", got)
+	}
+	if got, ok := sentProperty(t, api, "a", "discountPct").(float64); !ok || math.Abs(got-0.25) > 1e-9 {
+		t.Errorf("got discountPct %v, want 0.25", got)
+	}
+}
+
+func TestComputedPropertyCyclesAreRejected(t *testing.T) {
+	compute := func(props map[string]any) (any, error) { return 1.0, nil }
+	_, err := NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).
+		WithComputedProperties(
+			ComputedProperty{Key: "x", DependsOn: []string{"y"}, Compute: compute},
+			ComputedProperty{Key: "y", DependsOn: []string{"x"}, Compute: compute},
+		).
+		Build()
+	if err == nil {
+		t.Error("Build succeeded with a dependency cycle")
+	}
+}
+
+func TestDuplicateComputedPropertiesAreRejected(t *testing.T) {
+	compute := func(props map[string]any) (any, error) { return 1.0, nil }
+	_, err := NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).
+		WithComputedProperties(ComputedProperty{Key: "x", Compute: compute}, ComputedProperty{Key: "x", Compute: compute}).
+		Build()
+	if err == nil {
+		t.Error("Build succeeded with a computed property defined twice")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const confidenceScorePropertyKey = "confidenceScore"
+const modelScorePropertyKey = "modelScore"
+const retrievalScorePropertyKey = "retrievalScore"
+
+// FallbackReasonLowConfidence marks responses put back in request order because the model's mean
+// confidence was below the threshold.
+const FallbackReasonLowConfidence = "LOW_CONFIDENCE"
+
+// InsertionDebugInfo is the model's scoring of a ranked insertion. Scores the Delivery API didn't
+// return are 0.
+type InsertionDebugInfo struct {
+	ConfidenceScore float64
+	ModelScore      float64
+	RetrievalScore  float64
+}
+
+// GetInsertionDebugInfo reads the scores from the insertion's properties. The retrieval score falls
+// back to the insertion's RetrievalScore field.
+func GetInsertionDebugInfo(insertion *delivery.Insertion) (*InsertionDebugInfo, error) {
+	info := &InsertionDebugInfo{RetrievalScore: float64(insertion.GetRetrievalScore())}
+	for key, score := range map[string]*float64{
+		confidenceScorePropertyKey: &info.ConfidenceScore,
+		modelScorePropertyKey:      &info.ModelScore,
+		retrievalScorePropertyKey:  &info.RetrievalScore,
+	} {
+		value := getProperty(insertion.GetProperties(), key)
+		if value == nil {
+			continue
+		}
+		number, ok := value.GetKind().(*structpb.Value_NumberValue)
+		if !ok {
+			return nil, fmt.Errorf("property %s of content %s is not a number", key, insertion.GetContentId())
+		}
+		*score = number.NumberValue
+	}
+	return info, nil
+}
+
+// ResponseConfidence is the mean confidence score of the response's insertions, or 0 if none has
+// one.
+func ResponseConfidence(resp *DeliveryResponse) float64 {
+	mean, _ := meanConfidence(resp)
+	return mean
+}
+
+// meanConfidence also reports whether any insertion had a confidence score. Unreadable scores are
+// skipped.
+func meanConfidence(resp *DeliveryResponse) (float64, bool) {
+	var sum float64
+	var count int
+	for _, ins := range resp.Response.GetInsertion() {
+		if getProperty(ins.GetProperties(), confidenceScorePropertyKey) == nil {
+			continue
+		}
+		info, err := GetInsertionDebugInfo(ins)
+		if err != nil {
+			continue
+		}
+		sum += info.ConfidenceScore
+		count++
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// confidenceFallback puts responses whose mean confidence is below threshold back in request order.
+// Responses without confidence scores are left as ranked.
+type confidenceFallback struct {
+	threshold float64
+}
+
+func (f *confidenceFallback) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if confidence, ok := meanConfidence(resp); !ok || confidence >= f.threshold {
+			return resp, nil
+		}
+
+		inputPositions := map[string]int{}
+		for i, ins := range req.Request.GetInsertion() {
+			inputPositions[ins.GetContentId()] = i
+		}
+		resp = cloneResponse(resp)
+		insertions := resp.Response.GetInsertion()
+		sort.SliceStable(insertions, func(i, j int) bool {
+			a, aOK := inputPositions[insertions[i].GetContentId()]
+			b, bOK := inputPositions[insertions[j].GetContentId()]
+			if aOK != bOK {
+				return aOK
+			}
+			return a < b
+		})
+		renumberPositions(insertions)
+		resp.FallbackReason = FallbackReasonLowConfidence
+		return resp, nil
+	}
+}
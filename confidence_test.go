@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// newConfidentAPI ranks a, b, c as c, b, a with the given confidence scores.
+func newConfidentAPI(t *testing.T, confidences ...float64) *fakeAPI {
+	t.Helper()
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		resp := rankedResponse("c", "b", "a")
+		for i, confidence := range confidences {
+			if err := setProperty(&resp.Insertion[i].Properties, confidenceScorePropertyKey, confidence); err != nil {
+				t.Error(err)
+			}
+		}
+		writeDeliveryResponse(t, w, resp)
+	})
+	return api
+}
+
+func TestGetInsertionDebugInfo(t *testing.T) {
+	retrievalScore := float32(0.5)
+	ins := &delivery.Insertion{ContentId: "a", RetrievalScore: &retrievalScore}
+	if err := setProperty(&ins.Properties, confidenceScorePropertyKey, 0.9); err != nil {
+		t.Fatal(err)
+	}
+	if err := setProperty(&ins.Properties, modelScorePropertyKey, 0.7); err != nil {
+		t.Fatal(err)
+	}
+	info, err := GetInsertionDebugInfo(ins)
+	if err != nil {
+		t.Fatalf("GetInsertionDebugInfo failed: %v", err)
+	}
+	if *info != (InsertionDebugInfo{ConfidenceScore: 0.9, ModelScore: 0.7, RetrievalScore: 0.5}) {
+		t.Errorf("got %+v", *info)
+	}
+
+	if err := setProperty(&ins.Properties, modelScorePropertyKey, "high"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetInsertionDebugInfo(ins); err == nil {
+		t.Error("expected an error for a non-number score")
+	}
+}
+
+func TestResponseConfidence(t *testing.T) {
+	resp := newResponseWithInsertions(testInsertions("a", "b", "c"))
+	if got := ResponseConfidence(resp); got != 0 {
+		t.Errorf("got confidence %v without scores, want 0", got)
+	}
+	for i, confidence := range []float64{0.2, 0.6} {
+		if err := setProperty(&resp.Response.Insertion[i].Properties, confidenceScorePropertyKey, confidence); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The unscored insertion doesn't count toward the mean.
+	if got := ResponseConfidence(resp); got < 0.399 || got > 0.401 {
+		t.Errorf("got confidence %v, want 0.4", got)
+	}
+}
+
+func TestConfidenceThresholdFallback(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		confidences  []float64
+		want         []string
+		wantFallback bool
+	}{
+		{"below threshold", []float64{0.1, 0.3, 0.2}, []string{"a", "b", "c"}, true},
+		{"at threshold", []float64{0.5, 0.5, 0.5}, []string{"c", "b", "a"}, false},
+		{"above threshold", []float64{0.9, 0.8, 0.7}, []string{"c", "b", "a"}, false},
+		{"without scores", nil, []string{"c", "b", "a"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := newConfidentAPI(t, tc.confidences...)
+			c := buildTestClient(t, newTestClientBuilder(t, api).WithConfidenceThreshold(0.5))
+
+			resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c"))
+			if err != nil {
+				t.Fatalf("Deliver failed: %v", err)
+			}
+			assertContentIDs(t, resp, tc.want...)
+			if got := resp.FallbackReason == FallbackReasonLowConfidence; got != tc.wantFallback {
+				t.Errorf("got fallback reason %q, want low confidence fallback %v", resp.FallbackReason, tc.wantFallback)
+			}
+			for i, ins := range resp.Response.GetInsertion() {
+				if ins.GetPosition() != uint64(i) {
+					t.Errorf("insertion %s has position %d, want %d", ins.GetContentId(), ins.GetPosition(), i)
+				}
+			}
+		})
+	}
+}
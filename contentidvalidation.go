@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// DefaultContentIDValidator accepts content IDs of 1 to 255 characters that start with a non-space
+// character and have no control characters, so blank IDs are rejected.
+var DefaultContentIDValidator = regexp.MustCompile(`^[^\p{Z}\p{Cc}][^\p{Cc}]{0,254}$`)
+
+// ValidationMode controls what happens to insertions with invalid content IDs.
+type ValidationMode int
+
+const (
+	// ValidationModeReject fails the Deliver call.
+	ValidationModeReject ValidationMode = iota
+	// ValidationModeFilter drops the invalid insertions and logs a warning.
+	ValidationModeFilter
+)
+
+// contentIDValidator checks every insertion's content ID before the request is sent.
+type contentIDValidator struct {
+	pattern *regexp.Regexp
+	mode    ValidationMode
+}
+
+// middleware validates content IDs, rejecting or filtering the request depending on the mode.
+func (v *contentIDValidator) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		invalid := 0
+		for _, ins := range req.Request.GetInsertion() {
+			if v.pattern.MatchString(ins.GetContentId()) {
+				continue
+			}
+			if v.mode == ValidationModeReject {
+				return nil, fmt.Errorf("invalid content ID %q", ins.GetContentId())
+			}
+			log.Printf("Invalid content ID %q, removing insertion\n", ins.GetContentId())
+			invalid++
+		}
+		if invalid == 0 {
+			return next(ctx, req)
+		}
+
+		req = cloneRequest(req)
+		valid := make([]*delivery.Insertion, 0, len(req.Request.GetInsertion())-invalid)
+		for _, ins := range req.Request.GetInsertion() {
+			if v.pattern.MatchString(ins.GetContentId()) {
+				valid = append(valid, ins)
+			}
+		}
+		req.Request.Insertion = valid
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDefaultContentIDValidator(t *testing.T) {
+	tests := map[string]bool{
+		"":                            false,
+		strings.Repeat("x", 256):      false,
+		strings.Repeat("x", 255):      true,
+		"product-123":                 true,
+		"   ":                         false,
+		"\u00a0":                      false,
+		"a\nb":                        false,
+		"a b ":                        true,
+		"1'; DROP TABLE products; --": true,
+	}
+	for id, want := range tests {
+		if got := DefaultContentIDValidator.MatchString(id); got != want {
+			t.Errorf("DefaultContentIDValidator.MatchString(%.20q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+// strictContentIDs only allows word characters and dashes.
+var strictContentIDs = regexp.MustCompile(`^[\w-]{1,255}$`)
+
+func TestInvalidContentIDsAreRejected(t *testing.T) {
+	for _, id := range []string{"", "   ", strings.Repeat("x", 256), "1'; DROP TABLE products; --"} {
+		api := newFakeAPI(t)
+		c := buildTestClient(t, newTestClientBuilder(t, api).WithContentIDValidator(strictContentIDs))
+
+		if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "valid-1", id)); err == nil {
+			t.Errorf("Deliver with content ID %.20q succeeded, want an error", id)
+		}
+		if api.calls() != 0 {
+			t.Errorf("Deliver with content ID %.20q called the Delivery API", id)
+		}
+	}
+}
+
+func TestInvalidContentIDsAreFiltered(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithContentIDValidator(strictContentIDs).
+		WithContentIDValidationMode(ValidationModeFilter))
+
+	req := newTestDeliveryRequest(t, "valid-1", "", "   ", "valid-2", "1'; DROP TABLE products; --")
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "valid-1", "valid-2")
+	if got := len(req.Request.GetInsertion()); got != 5 {
+		t.Errorf("the caller's request has %d insertions after filtering, want 5", got)
+	}
+}
+
+func TestValidContentIDsPassThrough(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithContentIDValidator(DefaultContentIDValidator))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "a", "b")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+const localScorePropertyKey = "localScore"
+const defaultScoringConcurrency = 8
+
+// ContentScorer coarsely scores insertions locally, e.g. with an in-process model, before the
+// Delivery API fine-ranks them.
+type ContentScorer interface {
+	Score(ctx context.Context, contentID string, props map[string]any) (float64, error)
+}
+
+// contentScoring sets each insertion's localScore property before sending.
+type contentScoring struct {
+	scorer      ContentScorer
+	concurrency int
+}
+
+// middleware scores up to concurrency insertions at a time. Failed scores are logged and sent as 0
+// rather than failing the request.
+func (s *contentScoring) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		if len(req.Request.GetInsertion()) == 0 {
+			return next(ctx, req)
+		}
+		req = cloneRequest(req)
+		insertions := req.Request.GetInsertion()
+		scores := make([]float64, len(insertions))
+		sem := make(chan struct{}, s.concurrency)
+		var wg sync.WaitGroup
+		for i, ins := range insertions {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, contentID string, props map[string]any) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				score, err := s.scorer.Score(ctx, contentID, props)
+				if err != nil {
+					log.Printf("WARN: Scoring content %s as 0: %v\n", contentID, err)
+					score = 0
+				}
+				scores[i] = score
+			}(i, ins.GetContentId(), ins.GetProperties().GetStruct().AsMap())
+		}
+		wg.Wait()
+
+		for i, ins := range insertions {
+			if err := setProperty(&ins.Properties, localScorePropertyKey, scores[i]); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeContentScorer scores content by its price property, taking delay per call, and fails for
+// content in failing. It tracks the most calls in flight at once.
+type fakeContentScorer struct {
+	delay   time.Duration
+	failing map[string]bool
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (s *fakeContentScorer) Score(_ context.Context, contentID string, props map[string]any) (float64, error) {
+	s.mu.Lock()
+	s.inFlight++
+	s.maxInFlight = max(s.maxInFlight, s.inFlight)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	time.Sleep(s.delay)
+	if s.failing[contentID] {
+		return 0, errors.New("model unavailable")
+	}
+	price, _ := props["price"].(float64)
+	return price / 100, nil
+}
+
+func TestContentScoresAreSent(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithContentScorer(&fakeContentScorer{}))
+
+	req := newTestDeliveryRequest(t, "a", "b")
+	setProperty(&req.Request.Insertion[0].Properties, "price", 50)
+	setProperty(&req.Request.Insertion[1].Properties, "price", 20)
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	for contentID, want := range map[string]float64{"a": 0.5, "b": 0.2} {
+		if got := sentProperty(t, api, contentID, localScorePropertyKey); got != want {
+			t.Errorf("sent local score %v for %s, want %v", got, contentID, want)
+		}
+	}
+	if getProperty(req.Request.Insertion[0].GetProperties(), localScorePropertyKey) != nil {
+		t.Error("scoring modified the caller's request")
+	}
+}
+
+func TestContentScoringIsParallel(t *testing.T) {
+	api := newFakeAPI(t)
+	scorer := &fakeContentScorer{delay: 50 * time.Millisecond}
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithContentScorer(scorer).WithScoringConcurrency(3))
+
+	start := time.Now()
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c", "d", "e", "f")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	// Six 50ms scores three at a time take two rounds, not six.
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("scoring took %v, want about 100ms", elapsed)
+	}
+	if scorer.maxInFlight != 3 {
+		t.Errorf("scored up to %d insertions at once, want 3", scorer.maxInFlight)
+	}
+}
+
+func TestFailedScoreIsSentAsZero(t *testing.T) {
+	logs := captureLog(t)
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithContentScorer(&fakeContentScorer{failing: map[string]bool{"b": true}}))
+
+	req := newTestDeliveryRequest(t, "a", "b")
+	setProperty(&req.Request.Insertion[0].Properties, "price", 50)
+	setProperty(&req.Request.Insertion[1].Properties, "price", 20)
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "a", "b")
+	if got := sentProperty(t, api, "b", localScorePropertyKey); got != 0.0 {
+		t.Errorf("sent local score %v for failed content, want 0", got)
+	}
+	if got := sentProperty(t, api, "a", localScorePropertyKey); got != 0.5 {
+		t.Errorf("sent local score %v, want 0.5", got)
+	}
+	if !strings.Contains(logs.String(), "Scoring content b as 0") {
+		t.Errorf("logged %q, want a warning about content b", logs.String())
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const defaultContentStoreConcurrency = 8
+
+// ContentStore looks up display properties, e.g. imageURL, that are left out of delivery requests
+// to keep them small.
+type ContentStore interface {
+	GetProperties(contentID string) (map[string]any, error)
+}
+
+// mapContentStore is a ContentStore backed by a map.
+type mapContentStore map[string]map[string]any
+
+// MapContentStore returns a ContentStore serving properties from data, keyed by content ID.
+func MapContentStore(data map[string]map[string]any) ContentStore {
+	return mapContentStore(data)
+}
+
+func (m mapContentStore) GetProperties(contentID string) (map[string]any, error) {
+	return m[contentID], nil
+}
+
+// EnrichResponse returns a copy of resp with each insertion's properties filled in from store.
+// Properties already set on an insertion are kept.
+func EnrichResponse(resp *DeliveryResponse, store ContentStore) (*DeliveryResponse, error) {
+	return enrichResponse(resp, store, defaultContentStoreConcurrency)
+}
+
+// enrichResponse is EnrichResponse with at most concurrency lookups in flight.
+func enrichResponse(resp *DeliveryResponse, store ContentStore, concurrency int) (*DeliveryResponse, error) {
+	resp = cloneResponse(resp)
+	insertions := resp.Response.GetInsertion()
+	errs := make([]error, len(insertions))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, ins := range insertions {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, contentID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			props, err := store.GetProperties(contentID)
+			if err != nil {
+				errs[i] = fmt.Errorf("error getting properties of content %s: %v", contentID, err)
+				return
+			}
+			// Each goroutine only touches its own insertion.
+			ins := insertions[i]
+			for key, value := range props {
+				if getProperty(ins.Properties, key) != nil {
+					continue
+				}
+				if err := setProperty(&ins.Properties, key, value); err != nil {
+					errs[i] = err
+					return
+				}
+			}
+		}(i, ins.GetContentId())
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// contentStoreEnrichment fills in response insertion properties from a ContentStore.
+type contentStoreEnrichment struct {
+	store       ContentStore
+	concurrency int
+}
+
+func (e *contentStoreEnrichment) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return enrichResponse(resp, e.store, e.concurrency)
+	}
+}
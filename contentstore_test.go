@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// slowContentStore serves one property per content ID after a delay, tracking the most lookups in
+// flight at once.
+type slowContentStore struct {
+	delay time.Duration
+
+	inFlight    atomic.Int32
+	mu          sync.Mutex
+	maxInFlight int32
+}
+
+func (s *slowContentStore) GetProperties(contentID string) (map[string]any, error) {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	s.mu.Lock()
+	s.maxInFlight = max(s.maxInFlight, n)
+	s.mu.Unlock()
+	time.Sleep(s.delay)
+	return map[string]any{"imageURL": "https://example.com/" + contentID + ".png"}, nil
+}
+
+type failingContentStore struct{}
+
+func (failingContentStore) GetProperties(contentID string) (map[string]any, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func newResponseWithInsertions(insertions []*delivery.Insertion) *DeliveryResponse {
+	return &DeliveryResponse{DeliveryResponse: &client.DeliveryResponse{
+		Response: &delivery.Response{Insertion: insertions},
+	}}
+}
+
+func TestEnrichResponseMergesMissingProperties(t *testing.T) {
+	insertions := testInsertions("a", "b")
+	if err := setProperty(&insertions[0].Properties, "averageRating", 4.0); err != nil {
+		t.Fatalf("error setting property: %v", err)
+	}
+	resp := newResponseWithInsertions(insertions)
+	store := MapContentStore(map[string]map[string]any{
+		"a": {"imageURL": "a.png", "averageRating": 1.0},
+		"b": {"imageURL": "b.png"},
+	})
+
+	enriched, err := EnrichResponse(resp, store)
+	if err != nil {
+		t.Fatalf("EnrichResponse failed: %v", err)
+	}
+	a, b := enriched.Response.GetInsertion()[0], enriched.Response.GetInsertion()[1]
+	if got := getProperty(a.Properties, "imageURL").GetStringValue(); got != "a.png" {
+		t.Errorf("got imageURL %q, want a.png", got)
+	}
+	if got := getProperty(a.Properties, "averageRating").GetNumberValue(); got != 4 {
+		t.Errorf("got averageRating %v, want the insertion's own 4 kept", got)
+	}
+	if got := getProperty(b.Properties, "imageURL").GetStringValue(); got != "b.png" {
+		t.Errorf("got imageURL %q, want b.png", got)
+	}
+	if getProperty(resp.Response.GetInsertion()[1].Properties, "imageURL") != nil {
+		t.Error("EnrichResponse modified the original response")
+	}
+}
+
+func TestEnrichResponseReturnsStoreErrors(t *testing.T) {
+	if _, err := EnrichResponse(newResponseWithInsertions(testInsertions("a")), failingContentStore{}); err == nil {
+		t.Error("EnrichResponse succeeded with a failing store")
+	}
+}
+
+func TestContentStoreEnrichmentLimitsConcurrency(t *testing.T) {
+	api := newFakeAPI(t)
+	store := &slowContentStore{delay: 20 * time.Millisecond}
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithContentStoreEnrichment(store).
+		WithContentStoreConcurrency(2))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c", "d", "e"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	for _, ins := range resp.Response.GetInsertion() {
+		if getProperty(ins.Properties, "imageURL") == nil {
+			t.Errorf("insertion %s wasn't enriched", ins.GetContentId())
+		}
+	}
+	if store.maxInFlight != 2 {
+		t.Errorf("got at most %d lookups at once, want 2", store.maxInFlight)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import "context"
+
+// correlationIDHeader carries the caller's correlation ID to the Delivery API.
+const correlationIDHeader = "X-Request-ID"
+
+// correlationIDKey is the context key for correlation IDs.
+type correlationIDKey struct{}
+
+// ContextWithCorrelationID returns a copy of ctx carrying the correlation ID, typically the
+// incoming request's X-Request-ID.
+func ContextWithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationIDFromContext is the built-in correlation ID extractor for IDs stored with
+// ContextWithCorrelationID.
+func CorrelationIDFromContext(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDKey{}).(string)
+	return correlationID
+}
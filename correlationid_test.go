@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCorrelationIDIsPropagated(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithCorrelationIDExtractor(CorrelationIDFromContext))
+
+	ctx := ContextWithCorrelationID(context.Background(), "corr-123")
+	resp, err := c.Deliver(ctx, newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.lastHeader(t).Get(correlationIDHeader); got != "corr-123" {
+		t.Errorf("got %s header %q, want corr-123", correlationIDHeader, got)
+	}
+	if resp.CorrelationID != "corr-123" {
+		t.Errorf("got response correlation ID %q, want corr-123", resp.CorrelationID)
+	}
+}
+
+func TestEmptyCorrelationIDIsNotSent(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithCorrelationIDExtractor(CorrelationIDFromContext))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.lastHeader(t).Get(correlationIDHeader); got != "" {
+		t.Errorf("got %s header %q, want none", correlationIDHeader, got)
+	}
+	if resp.CorrelationID != "" {
+		t.Errorf("got response correlation ID %q, want none", resp.CorrelationID)
+	}
+}
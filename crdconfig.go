@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	crscheme "sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+// PromotedGroupVersion is the API group and version of the PromotedClientConfig CRD.
+var PromotedGroupVersion = schema.GroupVersion{Group: "promoted.ai", Version: "v1"}
+
+// AddPromotedToScheme registers the PromotedClientConfig types with a scheme.
+var AddPromotedToScheme = (&crscheme.Builder{GroupVersion: PromotedGroupVersion}).
+	Register(&PromotedClientConfig{}, &PromotedClientConfigList{}).
+	AddToScheme
+
+// PromotedClientConfigSpec mirrors Config. API keys aren't kept in the resource; they're referenced
+// from Secrets in its namespace.
+type PromotedClientConfigSpec struct {
+	MetricsApiEndpointUrl     string                   `json:"metricsApiEndpointUrl"`
+	MetricsApiKeySecretRef    corev1.SecretKeySelector `json:"metricsApiKeySecretRef"`
+	DeliveryApiEndpointUrl    string                   `json:"deliveryApiEndpointUrl"`
+	DeliveryApiKeySecretRef   corev1.SecretKeySelector `json:"deliveryApiKeySecretRef"`
+	OnlyLog                   bool                     `json:"onlyLog,omitempty"`
+	ShadowTrafficDeliveryRate float64                  `json:"shadowTrafficDeliveryRate,omitempty"`
+	BlockingShadowTraffic     bool                     `json:"blockingShadowTraffic,omitempty"`
+}
+
+func (in *PromotedClientConfigSpec) DeepCopyInto(out *PromotedClientConfigSpec) {
+	*out = *in
+	in.MetricsApiKeySecretRef.DeepCopyInto(&out.MetricsApiKeySecretRef)
+	in.DeliveryApiKeySecretRef.DeepCopyInto(&out.DeliveryApiKeySecretRef)
+}
+
+// PromotedClientConfig is the promoted.ai/v1 custom resource configuring a delivery client.
+type PromotedClientConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PromotedClientConfigSpec `json:"spec"`
+}
+
+// PromotedClientConfigList is a list of PromotedClientConfig.
+type PromotedClientConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []PromotedClientConfig `json:"items"`
+}
+
+func (in *PromotedClientConfig) DeepCopyInto(out *PromotedClientConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+func (in *PromotedClientConfig) DeepCopy() *PromotedClientConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PromotedClientConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *PromotedClientConfig) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+func (in *PromotedClientConfigList) DeepCopyInto(out *PromotedClientConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]PromotedClientConfig, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+func (in *PromotedClientConfigList) DeepCopy() *PromotedClientConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(PromotedClientConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *PromotedClientConfigList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+// CRDConfigClient is a delivery client configured by one PromotedClientConfig resource. Each
+// reconcile rebuilds the client from the resource's spec and the Secrets it references and swaps it
+// in, so calls in flight finish on the previous client, which is then closed. With OnlyLog set,
+// every request is only logged.
+type CRDConfigClient struct {
+	scheme     *runtime.Scheme
+	name       types.NamespacedName
+	kubeClient client.Client
+
+	config atomic.Pointer[Config]
+	client atomic.Pointer[DeliveryClientInterface]
+}
+
+// NewCRDConfigClient registers the PromotedClientConfig and core types with scheme, which should be
+// the manager's, and serves calls with c until the first reconcile of the resource set with
+// WithConfigName. Other PromotedClientConfig resources are ignored.
+func NewCRDConfigClient(scheme *runtime.Scheme, c DeliveryClientInterface) *CRDConfigClient {
+	// Registering only fails for conflicting types, which is a programming error.
+	utilruntime.Must(AddPromotedToScheme(scheme))
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	crdClient := &CRDConfigClient{scheme: scheme}
+	crdClient.client.Store(&c)
+	return crdClient
+}
+
+// WithConfigName sets the PromotedClientConfig resource that configures the client.
+func (c *CRDConfigClient) WithConfigName(name types.NamespacedName) *CRDConfigClient {
+	c.name = name
+	return c
+}
+
+// SetupWithManager watches the configured PromotedClientConfig resource, and the Secrets in its
+// namespace so rotated API keys are picked up, with the manager.
+func (c *CRDConfigClient) SetupWithManager(mgr ctrl.Manager) error {
+	if c.name.Name == "" {
+		return errors.New("PromotedClientConfig name needs to be set with WithConfigName")
+	}
+	c.kubeClient = mgr.GetClient()
+	isConfigured := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return client.ObjectKeyFromObject(obj) == c.name
+	})
+	inNamespace := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == c.name.Namespace
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&PromotedClientConfig{}, builder.WithPredicates(isConfigured)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+			return []reconcile.Request{{NamespacedName: c.name}}
+		}), builder.WithPredicates(inNamespace)).
+		Complete(c)
+}
+
+// Reconcile applies the latest spec of the configured resource. Deleted resources leave the current
+// client in place.
+func (c *CRDConfigClient) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if req.NamespacedName != c.name {
+		return ctrl.Result{}, nil
+	}
+	var resource PromotedClientConfig
+	if err := c.kubeClient.Get(ctx, req.NamespacedName, &resource); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	config, err := c.configFor(ctx, &resource)
+	if err != nil {
+		// The Secret may not have been created yet, so retry.
+		return ctrl.Result{}, fmt.Errorf("error reading API keys for %s: %v", req.NamespacedName, err)
+	}
+	if err := validateConfig(config); err != nil {
+		// Retrying won't fix an invalid spec; wait for the next update.
+		log.Printf("Invalid PromotedClientConfig %s: %v\n", req.NamespacedName, err)
+		return ctrl.Result{}, nil
+	}
+	deliveryClient, err := newCRDDeliveryClient(config)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error building client for %s: %v", req.NamespacedName, err)
+	}
+	var next DeliveryClientInterface = deliveryClient
+	previous := c.client.Swap(&next)
+	c.config.Store(&config)
+	// Closing only flushes the previous client's telemetry and usage report, so calls still in
+	// flight on it finish normally.
+	if closer, ok := (*previous).(interface{ Close() error }); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Error closing previous client for %s: %v\n", req.NamespacedName, err)
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// newCRDDeliveryClient builds a client like NewPromotedDeliveryClient, also applying the shadow
+// traffic settings. OnlyLog is applied to each request by Deliver.
+func newCRDDeliveryClient(config Config) (*DeliveryClient, error) {
+	return NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(config.DeliveryApiEndpointUrl).
+		WithDeliveryAPIKey(config.DeliveryApiKey).
+		WithDeliveryTimeoutMillis(1000).
+		WithMetricsEndpoint(config.MetricsApiEndpointUrl).
+		WithMetricsAPIKey(config.MetricsApiKey).
+		WithMetricsTimeoutMillis(1000).
+		WithShadowTrafficDeliveryRate(float32(config.ShadowTrafficDeliveryRate)).
+		WithBlockingShadowTraffic(config.BlockingShadowTraffic).
+		WithAcceptsGzip(true).
+		Build()
+}
+
+// configFor converts the resource's spec to a Config, reading API keys from the Secrets it references.
+func (c *CRDConfigClient) configFor(ctx context.Context, resource *PromotedClientConfig) (Config, error) {
+	spec := resource.Spec
+	metricsAPIKey, err := c.secretValue(ctx, resource.Namespace, spec.MetricsApiKeySecretRef)
+	if err != nil {
+		return Config{}, err
+	}
+	deliveryAPIKey, err := c.secretValue(ctx, resource.Namespace, spec.DeliveryApiKeySecretRef)
+	if err != nil {
+		return Config{}, err
+	}
+	return Config{
+		MetricsApiEndpointUrl:     spec.MetricsApiEndpointUrl,
+		MetricsApiKey:             metricsAPIKey,
+		DeliveryApiEndpointUrl:    spec.DeliveryApiEndpointUrl,
+		DeliveryApiKey:            deliveryAPIKey,
+		OnlyLog:                   spec.OnlyLog,
+		ShadowTrafficDeliveryRate: spec.ShadowTrafficDeliveryRate,
+		BlockingShadowTraffic:     spec.BlockingShadowTraffic,
+	}, nil
+}
+
+// secretValue returns the value ref selects from a Secret in namespace. An unset ref returns an empty
+// value, which validation rejects.
+func (c *CRDConfigClient) secretValue(ctx context.Context, namespace string, ref corev1.SecretKeySelector) (string, error) {
+	if ref.Name == "" {
+		return "", nil
+	}
+	var secret corev1.Secret
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s has no key %q", namespace, ref.Name, ref.Key)
+	}
+	return string(value), nil
+}
+
+// Config returns the config applied by the last reconcile, or nil before the first one.
+func (c *CRDConfigClient) Config() *Config {
+	return c.config.Load()
+}
+
+// Deliver serves the request with the current client, only logging it if the config says so.
+func (c *CRDConfigClient) Deliver(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+	if config := c.config.Load(); config != nil && config.OnlyLog && !req.OnlyLog {
+		req = cloneRequest(req)
+		req.OnlyLog = true
+	}
+	return (*c.client.Load()).Deliver(ctx, req)
+}
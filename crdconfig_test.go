@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// closingDeliveryClient records whether it was closed.
+type closingDeliveryClient struct {
+	fakeDeliveryClient
+	mu     sync.Mutex
+	closed bool
+}
+
+func (c *closingDeliveryClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *closingDeliveryClient) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+var testConfigName = types.NamespacedName{Name: "promoted", Namespace: "default"}
+
+const testAPIKeySecret = "promoted-api-keys"
+
+func newTestClientConfig(name types.NamespacedName, api *fakeAPI) *PromotedClientConfig {
+	return &PromotedClientConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+		Spec: PromotedClientConfigSpec{
+			MetricsApiEndpointUrl: api.URL,
+			MetricsApiKeySecretRef: corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: testAPIKeySecret},
+				Key:                  "metrics",
+			},
+			DeliveryApiEndpointUrl: api.URL,
+			DeliveryApiKeySecretRef: corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: testAPIKeySecret},
+				Key:                  "delivery",
+			},
+		},
+	}
+}
+
+func newTestAPIKeySecret(deliveryAPIKey string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testAPIKeySecret, Namespace: testConfigName.Namespace},
+		Data: map[string][]byte{
+			"metrics":  []byte("metrics-key"),
+			"delivery": []byte(deliveryAPIKey),
+		},
+	}
+}
+
+// newTestCRDConfigClient serves with initial and reads resources from a fake cluster holding objs.
+func newTestCRDConfigClient(t *testing.T, initial DeliveryClientInterface, objs ...client.Object) *CRDConfigClient {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	crd := NewCRDConfigClient(scheme, initial).WithConfigName(testConfigName)
+	crd.kubeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	return crd
+}
+
+func reconcileTestConfig(t *testing.T, crd *CRDConfigClient, name types.NamespacedName) {
+	t.Helper()
+	if _, err := crd.Reconcile(context.Background(), ctrl.Request{NamespacedName: name}); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+}
+
+// deliveryAPIKeyOf returns the API key crd's current client sends to api.
+func deliveryAPIKeyOf(t *testing.T, crd *CRDConfigClient, api *fakeAPI) string {
+	t.Helper()
+	if _, err := crd.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	return api.lastHeader(t).Get("x-api-key")
+}
+
+// TestCRDConfigChangesPropagateThroughEnvtest runs the controller against a real API server. It is
+// opt-in: plain go test skips it, since it needs the envtest binaries, e.g.
+// KUBEBUILDER_ASSETS=$(setup-envtest use -p path) go test -run Envtest.
+func TestCRDConfigChangesPropagateThroughEnvtest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("opt-in: set KUBEBUILDER_ASSETS to run against envtest")
+	}
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("config", "crd")},
+		ErrorIfCRDPathMissing: true,
+	}
+	restConfig, err := env.Start()
+	if err != nil {
+		t.Fatalf("error starting envtest: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Errorf("error stopping envtest: %v", err)
+		}
+	})
+
+	api := newFakeAPI(t)
+	initial := &closingDeliveryClient{fakeDeliveryClient: fakeDeliveryClient{name: "initial"}}
+	scheme := runtime.NewScheme()
+	crd := NewCRDConfigClient(scheme, initial).WithConfigName(testConfigName)
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{Scheme: scheme, Metrics: metricsserver.Options{BindAddress: "0"}})
+	if err != nil {
+		t.Fatalf("error creating manager: %v", err)
+	}
+	if err := crd.SetupWithManager(mgr); err != nil {
+		t.Fatalf("SetupWithManager failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			t.Errorf("manager stopped: %v", err)
+		}
+	}()
+
+	kubeClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	secret := newTestAPIKeySecret("key-1")
+	resource := newTestClientConfig(testConfigName, api)
+	for _, obj := range []client.Object{secret, resource} {
+		if err := kubeClient.Create(ctx, obj); err != nil {
+			t.Fatalf("error creating %T: %v", obj, err)
+		}
+	}
+	waitFor(t, "the resource to be applied", func() bool {
+		config := crd.Config()
+		return config != nil && config.DeliveryApiKey == "key-1"
+	})
+	if !initial.isClosed() {
+		t.Error("the replaced client wasn't closed")
+	}
+	if got := deliveryAPIKeyOf(t, crd, api); got != "key-1" {
+		t.Errorf("got API key %q, want key-1", got)
+	}
+
+	secret.Data["delivery"] = []byte("key-2")
+	if err := kubeClient.Update(ctx, secret); err != nil {
+		t.Fatalf("error rotating the API key: %v", err)
+	}
+	waitFor(t, "the rotated API key to be applied", func() bool {
+		return crd.Config().DeliveryApiKey == "key-2"
+	})
+	if got := deliveryAPIKeyOf(t, crd, api); got != "key-2" {
+		t.Errorf("got API key %q after the rotation, want key-2", got)
+	}
+}
+
+func TestCRDConfigReadsAPIKeysFromSecrets(t *testing.T) {
+	api := newFakeAPI(t)
+	initial := &closingDeliveryClient{fakeDeliveryClient: fakeDeliveryClient{name: "initial"}}
+	secret := newTestAPIKeySecret("key-1")
+	crd := newTestCRDConfigClient(t, initial, secret, newTestClientConfig(testConfigName, api))
+
+	reconcileTestConfig(t, crd, testConfigName)
+	if !initial.isClosed() {
+		t.Error("the replaced client wasn't closed")
+	}
+	if got := deliveryAPIKeyOf(t, crd, api); got != "key-1" {
+		t.Errorf("got API key %q, want key-1", got)
+	}
+
+	secret.Data["delivery"] = []byte("key-2")
+	if err := crd.kubeClient.Update(context.Background(), secret); err != nil {
+		t.Fatalf("error rotating the API key: %v", err)
+	}
+	reconcileTestConfig(t, crd, testConfigName)
+	if got := deliveryAPIKeyOf(t, crd, api); got != "key-2" {
+		t.Errorf("got API key %q after the rotation, want key-2", got)
+	}
+	if got := crd.Config().MetricsApiKey; got != "metrics-key" {
+		t.Errorf("got Metrics API key %q, want metrics-key", got)
+	}
+}
+
+func TestCRDConfigRetriesWhileTheSecretIsMissing(t *testing.T) {
+	crd := newTestCRDConfigClient(t, &fakeDeliveryClient{name: "initial"}, newTestClientConfig(testConfigName, newFakeAPI(t)))
+	if _, err := crd.Reconcile(context.Background(), ctrl.Request{NamespacedName: testConfigName}); err == nil {
+		t.Error("Reconcile succeeded without the API key Secret")
+	}
+	if crd.Config() != nil {
+		t.Errorf("got config %+v, want none applied", crd.Config())
+	}
+}
+
+func TestCRDConfigIgnoresOtherResources(t *testing.T) {
+	other := types.NamespacedName{Name: "other-service", Namespace: testConfigName.Namespace}
+	crd := newTestCRDConfigClient(t, &fakeDeliveryClient{name: "initial"},
+		newTestAPIKeySecret("key-1"), newTestClientConfig(other, newFakeAPI(t)))
+	reconcileTestConfig(t, crd, other)
+
+	resp, err := crd.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil || resp.Response.GetRequestId() != "initial" {
+		t.Errorf("got %v, %v, want the initial client's response", resp, err)
+	}
+	if crd.Config() != nil {
+		t.Errorf("got config %+v from another resource, want none applied", crd.Config())
+	}
+}
+
+func TestCRDConfigKeepsClientForDeletedOrInvalidResources(t *testing.T) {
+	initial := &fakeDeliveryClient{name: "initial"}
+	crd := newTestCRDConfigClient(t, initial)
+	reconcileTestConfig(t, crd, testConfigName)
+
+	invalid := newTestCRDConfigClient(t, initial, &PromotedClientConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: testConfigName.Name, Namespace: testConfigName.Namespace},
+	})
+	reconcileTestConfig(t, invalid, testConfigName)
+
+	for _, c := range []*CRDConfigClient{crd, invalid} {
+		resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+		if err != nil || resp.Response.GetRequestId() != "initial" {
+			t.Errorf("got %v, %v, want the initial client's response", resp, err)
+		}
+		if c.Config() != nil {
+			t.Errorf("got config %+v, want none applied", c.Config())
+		}
+	}
+}
+
+func TestCRDConfigAppliesDeliverySettings(t *testing.T) {
+	api := newFakeAPI(t)
+	resource := newTestClientConfig(testConfigName, api)
+	resource.Spec.OnlyLog = true
+	resource.Spec.ShadowTrafficDeliveryRate = 1
+	resource.Spec.BlockingShadowTraffic = true
+	crd := newTestCRDConfigClient(t, &fakeDeliveryClient{name: "initial"}, newTestAPIKeySecret("key-1"), resource)
+	reconcileTestConfig(t, crd, testConfigName)
+
+	req := newTestDeliveryRequest(t, "a")
+	resp, err := crd.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if resp.ExecutionServer != delivery.ExecutionServer_SDK {
+		t.Errorf("got execution server %v, want SDK for only-log calls", resp.ExecutionServer)
+	}
+	if req.OnlyLog {
+		t.Error("the caller's request was marked only-log")
+	}
+	// Blocking shadow traffic is sent before Deliver returns.
+	if api.calls() != 1 || api.lastRequest(t).GetClientInfo().GetTrafficType() != common.ClientInfo_SHADOW {
+		t.Errorf("got %d Delivery API calls, want one shadow call", api.calls())
+	}
+}
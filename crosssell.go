@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// CrossSellHook recommends items to buy alongside some content, e.g. "Customers also bought".
+type CrossSellHook interface {
+	GetRecommendations(ctx context.Context, contentID string) ([]*delivery.Insertion, error)
+}
+
+// UpSellHook recommends pricier alternatives to some content, e.g. "Upgrade to premium".
+type UpSellHook interface {
+	GetRecommendations(ctx context.Context, contentID string) ([]*delivery.Insertion, error)
+}
+
+// salesHooks attaches cross-sell and up-sell recommendations for the top-ranked insertion.
+type salesHooks struct {
+	crossSell CrossSellHook
+	upSell    UpSellHook
+}
+
+// middleware calls both hooks in parallel. A failing hook is logged and leaves its field empty, so
+// the ranking is still returned.
+func (s *salesHooks) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		insertions := resp.Response.GetInsertion()
+		if len(insertions) == 0 {
+			return resp, nil
+		}
+		top := insertions[0].GetContentId()
+
+		resp = cloneResponse(resp)
+		var wg sync.WaitGroup
+		if s.crossSell != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp.CrossSell = runSalesHook(ctx, "cross-sell", s.crossSell.GetRecommendations, top)
+			}()
+		}
+		if s.upSell != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp.UpSell = runSalesHook(ctx, "up-sell", s.upSell.GetRecommendations, top)
+			}()
+		}
+		wg.Wait()
+		return resp, nil
+	}
+}
+
+func runSalesHook(ctx context.Context, name string, hook func(ctx context.Context, contentID string) ([]*delivery.Insertion, error), contentID string) []*delivery.Insertion {
+	recommendations, err := hook(ctx, contentID)
+	if err != nil {
+		log.Printf("Error getting %s recommendations for %s: %v\n", name, contentID, err)
+		return nil
+	}
+	return recommendations
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// fakeSalesHook recommends fixed content IDs after a delay, recording the content IDs it's called
+// for.
+type fakeSalesHook struct {
+	delay time.Duration
+	ids   []string
+	err   error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (h *fakeSalesHook) GetRecommendations(ctx context.Context, contentID string) ([]*delivery.Insertion, error) {
+	h.mu.Lock()
+	h.calls = append(h.calls, contentID)
+	h.mu.Unlock()
+	time.Sleep(h.delay)
+	if h.err != nil {
+		return nil, h.err
+	}
+	return testInsertions(h.ids...), nil
+}
+
+func (h *fakeSalesHook) contentIDs() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.calls...)
+}
+
+func TestSalesHooksRunInParallelForTopInsertion(t *testing.T) {
+	api := newFakeAPI(t)
+	crossSell := &fakeSalesHook{delay: 100 * time.Millisecond, ids: []string{"socks"}}
+	upSell := &fakeSalesHook{delay: 100 * time.Millisecond, ids: []string{"premium-shoes"}}
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithCrossSellHook(crossSell).WithUpSellHook(upSell))
+
+	start := time.Now()
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "shoes", "shirt"))
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if elapsed >= 190*time.Millisecond {
+		t.Errorf("Deliver took %v, want the 100ms hooks to run in parallel", elapsed)
+	}
+	if got := insertionContentIDsOf(resp.CrossSell); !reflect.DeepEqual(got, []string{"socks"}) {
+		t.Errorf("got cross-sell %v, want [socks]", got)
+	}
+	if got := insertionContentIDsOf(resp.UpSell); !reflect.DeepEqual(got, []string{"premium-shoes"}) {
+		t.Errorf("got up-sell %v, want [premium-shoes]", got)
+	}
+	for _, hook := range []*fakeSalesHook{crossSell, upSell} {
+		if got := hook.contentIDs(); !reflect.DeepEqual(got, []string{"shoes"}) {
+			t.Errorf("hook called for %v, want only the top insertion", got)
+		}
+	}
+}
+
+func TestFailingSalesHookKeepsRanking(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithCrossSellHook(&fakeSalesHook{err: errors.New("recommendations unavailable")}).
+		WithUpSellHook(&fakeSalesHook{ids: []string{"premium-shoes"}}))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "shoes", "shirt"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "shoes", "shirt")
+	if resp.CrossSell != nil {
+		t.Errorf("got cross-sell %v from a failing hook, want none", insertionContentIDsOf(resp.CrossSell))
+	}
+	if len(resp.UpSell) != 1 {
+		t.Errorf("got %d up-sell items, want 1", len(resp.UpSell))
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// currencyPropertyKey holds the ISO 4217 code of an insertion's price. Prices without it are in USD.
+const currencyPropertyKey = "currency"
+
+const originalPricePropertyKey = "originalPrice"
+const originalCurrencyPropertyKey = "originalCurrency"
+
+// CurrencyNormalizer converts prices to USD so the ranking model can compare them.
+type CurrencyNormalizer struct {
+	// usdRates is the USD value of one unit of each currency, keyed by upper-case code.
+	usdRates map[string]float64
+}
+
+// NewCurrencyNormalizer is a factory method for CurrencyNormalizer. usdRates gives the USD value
+// of one unit of each currency, e.g. {"EUR": 1.08}.
+func NewCurrencyNormalizer(usdRates map[string]float64) CurrencyNormalizer {
+	rates := make(map[string]float64, len(usdRates)+1)
+	rates["USD"] = 1
+	for currency, rate := range usdRates {
+		rates[strings.ToUpper(currency)] = rate
+	}
+	return CurrencyNormalizer{usdRates: rates}
+}
+
+// NormalizeToUSD converts amount from currency to USD.
+func (n CurrencyNormalizer) NormalizeToUSD(amount float64, currency string) (float64, error) {
+	rate, ok := n.usdRates[strings.ToUpper(currency)]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for currency %q", currency)
+	}
+	return amount * rate, nil
+}
+
+// currencyNormalization rewrites an insertion price property to USD before sending.
+type currencyNormalization struct {
+	normalizer CurrencyNormalizer
+	priceKey   string
+}
+
+func (c *currencyNormalization) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		// Normalize a copy so the caller's request keeps its original prices.
+		req = cloneRequest(req)
+		for _, ins := range req.Request.GetInsertion() {
+			if err := c.normalize(ins); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+func (c *currencyNormalization) normalize(ins *delivery.Insertion) error {
+	price := getProperty(ins.Properties, c.priceKey)
+	if price == nil {
+		return nil
+	}
+	currency := "USD"
+	if v := getProperty(ins.Properties, currencyPropertyKey); v != nil {
+		currency = v.GetStringValue()
+	}
+	amount := price.GetNumberValue()
+	usd, err := c.normalizer.NormalizeToUSD(amount, currency)
+	if err != nil {
+		return fmt.Errorf("error normalizing price of content %s: %v", ins.GetContentId(), err)
+	}
+	if err := setProperty(&ins.Properties, originalPricePropertyKey, amount); err != nil {
+		return err
+	}
+	if err := setProperty(&ins.Properties, originalCurrencyPropertyKey, currency); err != nil {
+		return err
+	}
+	return setProperty(&ins.Properties, c.priceKey, usd)
+}
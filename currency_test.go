@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestNormalizeToUSD(t *testing.T) {
+	n := NewCurrencyNormalizer(map[string]float64{"eur": 1.08})
+	got, err := n.NormalizeToUSD(100, "EUR")
+	if err != nil {
+		t.Fatalf("NormalizeToUSD failed: %v", err)
+	}
+	if math.Abs(got-108) > 1e-9 {
+		t.Errorf("got %v USD for 100 EUR, want 108", got)
+	}
+	if got, _ := n.NormalizeToUSD(5, "usd"); got != 5 {
+		t.Errorf("got %v USD for 5 USD, want 5", got)
+	}
+	if _, err := n.NormalizeToUSD(1, "XYZ"); err == nil {
+		t.Error("NormalizeToUSD succeeded for an unknown currency")
+	}
+}
+
+func TestCurrencyNormalizationRewritesPrices(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithCurrencyNormalization(NewCurrencyNormalizer(map[string]float64{"EUR": 1.08}), "price"))
+
+	req := newTestDeliveryRequest(t, "eur", "usd", "unpriced")
+	setProperty(&req.Request.Insertion[0].Properties, "price", 100)
+	setProperty(&req.Request.Insertion[0].Properties, currencyPropertyKey, "EUR")
+	setProperty(&req.Request.Insertion[1].Properties, "price", 20)
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+
+	sent := api.lastRequest(t).GetInsertion()
+	eur := sent[0].GetProperties()
+	if got := getProperty(eur, "price").GetNumberValue(); math.Abs(got-108) > 1e-9 {
+		t.Errorf("got normalized price %v, want 108", got)
+	}
+	if getProperty(eur, originalPricePropertyKey).GetNumberValue() != 100 || getProperty(eur, originalCurrencyPropertyKey).GetStringValue() != "EUR" {
+		t.Errorf("got original price %v %s, want 100 EUR", getProperty(eur, originalPricePropertyKey), getProperty(eur, originalCurrencyPropertyKey))
+	}
+	if got := getProperty(sent[1].GetProperties(), "price").GetNumberValue(); got != 20 {
+		t.Errorf("got normalized USD price %v, want 20", got)
+	}
+	if getProperty(sent[2].GetProperties(), "price") != nil {
+		t.Error("an unpriced insertion got a price")
+	}
+	if got := getProperty(req.Request.GetInsertion()[0].GetProperties(), "price").GetNumberValue(); got != 100 {
+		t.Errorf("the caller's price became %v", got)
+	}
+}
+
+func TestCurrencyNormalizationFailsForUnknownCurrency(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithCurrencyNormalization(NewCurrencyNormalizer(nil), "price"))
+
+	req := newTestDeliveryRequest(t, "a")
+	setProperty(&req.Request.Insertion[0].Properties, "price", 100)
+	setProperty(&req.Request.Insertion[0].Properties, currencyPropertyKey, "XYZ")
+	if _, err := c.Deliver(context.Background(), req); err == nil {
+		t.Error("Deliver succeeded with an unknown currency")
+	}
+	if api.calls() != 0 {
+		t.Errorf("got %d Delivery API calls, want 0", api.calls())
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+)
+
+// regionEndpoints are the Delivery and Metrics API endpoints serving one region.
+type regionEndpoints struct {
+	deliveryEndpoint string
+	metricsEndpoint  string
+}
+
+// DataResidencyRouter maps user regions to endpoints that keep their data in that region.
+type DataResidencyRouter struct {
+	regions map[string]regionEndpoints
+}
+
+// NewDataResidencyRouter is a factory method for DataResidencyRouter.
+func NewDataResidencyRouter() *DataResidencyRouter {
+	return &DataResidencyRouter{regions: map[string]regionEndpoints{}}
+}
+
+// WithRegion sends requests from region to the given Delivery and Metrics API endpoints.
+func (r *DataResidencyRouter) WithRegion(region string, endpoint string, metricsEndpoint string) *DataResidencyRouter {
+	r.regions[region] = regionEndpoints{deliveryEndpoint: endpoint, metricsEndpoint: metricsEndpoint}
+	return r
+}
+
+// deliveryBackend is the SDK client and Delivery API that serve a request.
+type deliveryBackend struct {
+	promoted    *client.PromotedDeliveryClient
+	deliveryAPI *deliveryAPI
+}
+
+// backendFor returns the backend for the context's region, or the default endpoints if the region
+// has none.
+func (c *DeliveryClient) backendFor(ctx context.Context) *deliveryBackend {
+	if c.regionExtractor != nil {
+		if backend, ok := c.regions[c.regionExtractor(ctx)]; ok {
+			return backend
+		}
+	}
+	return &deliveryBackend{promoted: c.promoted, deliveryAPI: c.deliveryAPI}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+type regionKey struct{}
+
+func regionFromContext(ctx context.Context) string {
+	region, _ := ctx.Value(regionKey{}).(string)
+	return region
+}
+
+func TestDataResidencyRoutesByRegion(t *testing.T) {
+	defaultAPI, euAPI, usAPI := newFakeAPI(t), newFakeAPI(t), newFakeAPI(t)
+	euMetrics, euLogged := newRecordingServer(t)
+	usMetrics, usLogged := newRecordingServer(t)
+	router := NewDataResidencyRouter().
+		WithRegion("eu", euAPI.URL, euMetrics.URL).
+		WithRegion("us", usAPI.URL, usMetrics.URL)
+	c := buildTestClient(t, newTestClientBuilder(t, defaultAPI).
+		WithDataResidencyRouter(router).
+		WithRegionExtractor(regionFromContext))
+
+	deliver := func(region string, onlyLog bool) {
+		t.Helper()
+		ctx := context.WithValue(context.Background(), regionKey{}, region)
+		req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithOnlyLog(onlyLog))
+		if _, err := c.Deliver(ctx, req); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	deliver("eu", false)
+	deliver("eu", false)
+	deliver("us", false)
+	deliver("apac", false)
+	deliver("eu", true)
+
+	if euAPI.calls() != 2 || usAPI.calls() != 1 || defaultAPI.calls() != 1 {
+		t.Errorf("got %d EU, %d US and %d default Delivery API calls, want 2, 1 and 1", euAPI.calls(), usAPI.calls(), defaultAPI.calls())
+	}
+	waitFor(t, "the EU log request", func() bool { return len(euLogged()) > 0 })
+	if len(usLogged()) != 0 {
+		t.Errorf("got %d US Metrics API calls for an EU request, want 0", len(usLogged()))
+	}
+}
+
+func TestDataResidencyRequiresRegionExtractor(t *testing.T) {
+	router := NewDataResidencyRouter().WithRegion("eu", "http://eu.example.com", "http://eu-metrics.example.com")
+	if _, err := newTestClientBuilder(t, newFakeAPI(t)).WithDataResidencyRouter(router).Build(); err == nil {
+		t.Error("Build succeeded with a data residency router and no region extractor")
+	}
+}
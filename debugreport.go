@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ReportOptions selects the sections of a debug report. The zero value includes all of them.
+type ReportOptions struct {
+	// Title defaults to "Delivery report".
+	Title string
+
+	OmitRequest       bool
+	OmitInsertions    bool
+	OmitPositionChart bool
+	OmitMetadata      bool
+}
+
+// reportRow is one ranked insertion in the report.
+type reportRow struct {
+	Position       int
+	ContentID      string
+	InsertionID    string
+	InputPosition  string
+	Change         int
+	RetrievalScore string
+	LocalScore     string
+}
+
+// reportBar is one insertion's bar in the position change chart.
+type reportBar struct {
+	Label  string
+	Change int
+	X      int
+	Y      int
+	Width  int
+	Up     bool
+}
+
+// reportMetadataItem is one row of the metadata section.
+type reportMetadataItem struct {
+	Name  string
+	Value string
+}
+
+const (
+	reportChartWidth = 600
+	reportBarHeight  = 18
+	reportLabelWidth = 120
+)
+
+var debugReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+pre { background: #f6f6f6; padding: 1em; overflow: auto; }
+.up { fill: #2e7d32; }
+.down { fill: #c62828; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Metadata}}<h2>Metadata</h2>
+<table id="metadata">
+{{range .Metadata}}<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Rows}}<h2>Ranked insertions</h2>
+<table id="insertions">
+<thead><tr><th>Position</th><th>Content ID</th><th>Insertion ID</th><th>Input position</th><th>Change</th><th>Retrieval score</th><th>Local score</th></tr></thead>
+<tbody>
+{{range .Rows}}<tr><td>{{.Position}}</td><td>{{.ContentID}}</td><td>{{.InsertionID}}</td><td>{{.InputPosition}}</td><td>{{.Change}}</td><td>{{.RetrievalScore}}</td><td>{{.LocalScore}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}{{if .Bars}}<h2>Position changes vs. input order</h2>
+<svg xmlns="http://www.w3.org/2000/svg" width="{{.ChartWidth}}" height="{{.ChartHeight}}">
+<line x1="{{.ChartAxis}}" y1="0" x2="{{.ChartAxis}}" y2="{{.ChartHeight}}" stroke="#999"/>
+{{range .Bars}}<text x="0" y="{{.Y}}" dy="13" font-size="12">{{.Label}}</text>
+<rect class="{{if .Up}}up{{else}}down{{end}}" x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="14"><title>{{.Change}}</title></rect>
+{{end}}</svg>
+{{end}}{{if .Request}}<h2>Request</h2>
+<pre id="request">{{.Request}}</pre>
+{{end}}</body>
+</html>
+`))
+
+// GenerateDebugReport renders req and resp as a self-contained HTML page, e.g. to share a debugging
+// session. Position changes are positive for insertions ranked above their input position.
+func GenerateDebugReport(req *DeliveryRequest, resp *DeliveryResponse, opts ReportOptions) ([]byte, error) {
+	data := struct {
+		Title       string
+		Request     string
+		Rows        []reportRow
+		Bars        []reportBar
+		Metadata    []reportMetadataItem
+		ChartWidth  int
+		ChartHeight int
+		ChartAxis   int
+	}{Title: opts.Title, ChartWidth: reportChartWidth}
+	if data.Title == "" {
+		data.Title = "Delivery report"
+	}
+
+	if !opts.OmitRequest {
+		requestJSON, err := protojson.MarshalOptions{Multiline: true}.Marshal(req.Request)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling delivery request: %v", err)
+		}
+		data.Request = string(requestJSON)
+	}
+	rows := reportRows(req, resp)
+	if !opts.OmitInsertions {
+		data.Rows = rows
+	}
+	if !opts.OmitPositionChart {
+		data.Bars = reportBars(rows)
+		data.ChartHeight = len(data.Bars) * (reportBarHeight + 2)
+		data.ChartAxis = reportLabelWidth + (reportChartWidth-reportLabelWidth)/2
+	}
+	if !opts.OmitMetadata {
+		data.Metadata = reportMetadata(resp)
+	}
+
+	var buf bytes.Buffer
+	if err := debugReportTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error rendering debug report: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func reportRows(req *DeliveryRequest, resp *DeliveryResponse) []reportRow {
+	inputPositions := map[string]int{}
+	for i, ins := range req.Request.GetInsertion() {
+		inputPositions[ins.GetContentId()] = i
+	}
+	requested := requestInsertionsByContentID(req)
+	insertions := resp.Response.GetInsertion()
+	rows := make([]reportRow, len(insertions))
+	for i, ins := range insertions {
+		row := reportRow{Position: i, ContentID: ins.GetContentId(), InsertionID: ins.GetInsertionId()}
+		if input, ok := inputPositions[ins.GetContentId()]; ok {
+			row.InputPosition = strconv.Itoa(input)
+			row.Change = input - i
+		}
+		if ins.RetrievalScore != nil {
+			row.RetrievalScore = strconv.FormatFloat(float64(ins.GetRetrievalScore()), 'g', 4, 32)
+		}
+		if score := getProperty(requested[ins.GetContentId()].GetProperties(), localScorePropertyKey); score != nil {
+			row.LocalScore = strconv.FormatFloat(score.GetNumberValue(), 'g', 4, 64)
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// reportBars lays out a bar per row around a center axis, scaled to the largest change.
+func reportBars(rows []reportRow) []reportBar {
+	maxChange := 1
+	for _, row := range rows {
+		maxChange = max(maxChange, row.Change, -row.Change)
+	}
+	halfWidth := (reportChartWidth - reportLabelWidth) / 2
+	axis := reportLabelWidth + halfWidth
+	bars := make([]reportBar, len(rows))
+	for i, row := range rows {
+		width := halfWidth * max(row.Change, -row.Change) / maxChange
+		bar := reportBar{Label: row.ContentID, Change: row.Change, Y: i * (reportBarHeight + 2), Width: width, X: axis, Up: row.Change >= 0}
+		if !bar.Up {
+			bar.X = axis - width
+		}
+		bars[i] = bar
+	}
+	return bars
+}
+
+func reportMetadata(resp *DeliveryResponse) []reportMetadataItem {
+	items := []reportMetadataItem{
+		{Name: "Execution server", Value: resp.ExecutionServer.String()},
+		{Name: "Client request ID", Value: resp.ClientRequestID},
+		{Name: "Request ID", Value: resp.Response.GetRequestId()},
+	}
+	add := func(name, value string) {
+		if value != "" {
+			items = append(items, reportMetadataItem{Name: name, Value: value})
+		}
+	}
+	add("Correlation ID", resp.CorrelationID)
+	add("Fallback reason", resp.FallbackReason)
+	if resp.APIError != nil {
+		add("API error", resp.APIError.Error())
+	}
+	if m := resp.Metadata; m != nil {
+		if m.TotalCount > 0 {
+			add("Total count", strconv.FormatInt(m.TotalCount, 10))
+		}
+		add("Has more", strconv.FormatBool(m.HasMore))
+		add("Next page token", m.NextPageToken)
+		add("Previous page token", m.PrevPageToken)
+	}
+	if resp.ModelVersionMismatch != nil {
+		add("Model version mismatch", resp.ModelVersionMismatch.Error())
+	}
+	if p := resp.Profile; p != nil {
+		add("Total ms", strconv.FormatInt(p.TotalMs, 10))
+		add("Network ms", strconv.FormatInt(p.NetworkMs, 10))
+	}
+	return items
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"golang.org/x/net/html"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// findElement returns the first element matching tag and, if set, id, in document order.
+func findElement(n *html.Node, tag, id string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag && (id == "" || attr(n, "id") == id) {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, tag, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findElements returns every element matching tag under n.
+func findElements(n *html.Node, tag string) []*html.Node {
+	var found []*html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == tag {
+			found = append(found, child)
+		}
+		found = append(found, findElements(child, tag)...)
+	}
+	return found
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// deliverForReport ranks a, b, c as c, a, b through a client.
+func deliverForReport(t *testing.T) (*DeliveryRequest, *DeliveryResponse) {
+	t.Helper()
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		ranked := rankedResponse("c", "a", "b")
+		score := float32(0.25)
+		ranked.Insertion[0].RetrievalScore = &score
+		writeDeliveryResponse(t, w, ranked)
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+	req := newTestDeliveryRequest(t, "a", "b", "c")
+	if err := setProperty(&req.Request.Insertion[0].Properties, localScorePropertyKey, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	return req, resp
+}
+
+func parseReport(t *testing.T, req *DeliveryRequest, resp *DeliveryResponse, opts ReportOptions) *html.Node {
+	t.Helper()
+	report, err := GenerateDebugReport(req, resp, opts)
+	if err != nil {
+		t.Fatalf("GenerateDebugReport failed: %v", err)
+	}
+	doc, err := html.Parse(bytes.NewReader(report))
+	if err != nil {
+		t.Fatalf("error parsing report: %v", err)
+	}
+	return doc
+}
+
+func TestDebugReportInsertionTableMatchesResponse(t *testing.T) {
+	req, resp := deliverForReport(t)
+	doc := parseReport(t, req, resp, ReportOptions{})
+
+	table := findElement(doc, "table", "insertions")
+	if table == nil {
+		t.Fatal("report has no insertion table")
+	}
+	rows := findElements(findElement(table, "tbody", ""), "tr")
+	if len(rows) != len(resp.Response.GetInsertion()) {
+		t.Fatalf("got %d table rows, want one per response insertion", len(rows))
+	}
+	// Position, content ID, insertion ID, input position, change, retrieval score and local score.
+	wantCells := [][]string{
+		{"0", "c", "", "2", "2", "0.25", ""},
+		{"1", "a", "", "0", "-1", "", "0.5"},
+		{"2", "b", "", "1", "-1", "", ""},
+	}
+	for i, row := range rows {
+		var cells []string
+		for _, cell := range findElements(row, "td") {
+			cells = append(cells, textOf(cell))
+		}
+		want := wantCells[i]
+		want[2] = resp.Response.GetInsertion()[i].GetInsertionId()
+		if !reflect.DeepEqual(cells, want) {
+			t.Errorf("row %d has cells %q, want %q", i, cells, want)
+		}
+	}
+}
+
+func TestDebugReportSections(t *testing.T) {
+	req, resp := deliverForReport(t)
+
+	doc := parseReport(t, req, resp, ReportOptions{Title: "Refresh bug"})
+	if got := textOf(findElement(doc, "title", "")); got != "Refresh bug" {
+		t.Errorf("got title %q, want Refresh bug", got)
+	}
+	if bars := findElements(findElement(doc, "svg", ""), "rect"); len(bars) != 3 || attr(bars[0], "class") != "up" || attr(bars[1], "class") != "down" {
+		t.Errorf("got %d chart bars, want c up and a and b down", len(bars))
+	}
+	// protojson randomizes its whitespace, so the request is parsed back rather than matched.
+	var sent delivery.Request
+	if pre := findElement(doc, "pre", "request"); pre == nil {
+		t.Error("report is missing the request JSON")
+	} else if err := protojson.Unmarshal([]byte(textOf(pre)), &sent); err != nil || sent.GetUserInfo().GetAnonUserId() != "anon-1" {
+		t.Errorf("report has request JSON %q, want anon-1's request", textOf(pre))
+	}
+	if metadata := findElement(doc, "table", "metadata"); metadata == nil || !strings.Contains(textOf(metadata), resp.ClientRequestID) {
+		t.Error("report metadata is missing the client request ID")
+	}
+
+	doc = parseReport(t, req, resp, ReportOptions{OmitRequest: true, OmitInsertions: true, OmitPositionChart: true, OmitMetadata: true})
+	for _, section := range []struct{ tag, id string }{{"pre", "request"}, {"table", "insertions"}, {"svg", ""}, {"table", "metadata"}} {
+		if findElement(doc, section.tag, section.id) != nil {
+			t.Errorf("report has omitted %s %s", section.tag, section.id)
+		}
+	}
+}
+
+func TestDebugReportEscapesContent(t *testing.T) {
+	req := newTestDeliveryRequest(t, "<script>alert(1)</script>")
+	resp := newResponseWithInsertions(testInsertions("<script>alert(1)</script>"))
+	report, err := GenerateDebugReport(req, resp, ReportOptions{})
+	if err != nil {
+		t.Fatalf("GenerateDebugReport failed: %v", err)
+	}
+	if bytes.Contains(report, []byte("<script>")) {
+		t.Error("report contains unescaped content")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dedupEntry is a response remembered for the deduplication window.
+type dedupEntry struct {
+	response  *DeliveryResponse
+	expiresAt time.Time
+}
+
+// requestDeduplicator serves identical requests arriving within a short window from the first response.
+type requestDeduplicator struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]dedupEntry
+	lastSweep time.Time
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// newRequestDeduplicator is a factory method for requestDeduplicator.
+func newRequestDeduplicator(window time.Duration) *requestDeduplicator {
+	return &requestDeduplicator{
+		window:  window,
+		entries: map[string]dedupEntry{},
+	}
+}
+
+// middleware returns the remembered response for a duplicate request, or delivers and remembers it.
+func (d *requestDeduplicator) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		fingerprint := RequestFingerprint(req)
+		if resp, ok := d.get(fingerprint); ok {
+			d.hits.Add(1)
+			return resp, nil
+		}
+		d.misses.Add(1)
+
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if cacheable(resp) {
+			d.put(fingerprint, resp)
+		}
+		return resp, nil
+	}
+}
+
+func (d *requestDeduplicator) get(fingerprint string) (*DeliveryResponse, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, ok := d.entries[fingerprint]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.response, true
+}
+
+func (d *requestDeduplicator) put(fingerprint string, resp *DeliveryResponse) {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Drop expired entries at most once per window to keep memory bounded.
+	if now.Sub(d.lastSweep) > d.window {
+		for key, entry := range d.entries {
+			if now.After(entry.expiresAt) {
+				delete(d.entries, key)
+			}
+		}
+		d.lastSweep = now
+	}
+	d.entries[fingerprint] = dedupEntry{response: resp, expiresAt: now.Add(d.window)}
+}
+
+// DeduplicationHits returns how many calls were served from the deduplication window.
+func (c *DeliveryClient) DeduplicationHits() uint64 {
+	if c.deduplicator == nil {
+		return 0
+	}
+	return c.deduplicator.hits.Load()
+}
+
+// DeduplicationMisses returns how many calls were not duplicates and went through delivery.
+func (c *DeliveryClient) DeduplicationMisses() uint64 {
+	if c.deduplicator == nil {
+		return 0
+	}
+	return c.deduplicator.misses.Load()
+}
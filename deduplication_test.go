@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestIdenticalRequestsWithinWindowMakeOneCall(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRequestDeduplicationWindow(time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if api.calls() != 1 {
+		t.Errorf("got %d HTTP calls, want 1", api.calls())
+	}
+	if c.DeduplicationHits() != 1 || c.DeduplicationMisses() != 1 {
+		t.Errorf("got %d hits and %d misses, want 1 each", c.DeduplicationHits(), c.DeduplicationMisses())
+	}
+}
+
+func TestRequestsOutsideWindowMakeTwoCalls(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRequestDeduplicationWindow(20*time.Millisecond))
+
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if api.calls() != 2 {
+		t.Errorf("got %d HTTP calls, want 2", api.calls())
+	}
+}
+
+func TestDifferentRequestsAreNotDeduplicated(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRequestDeduplicationWindow(time.Minute))
+
+	for _, id := range []string{"a", "b"} {
+		if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, id)); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if api.calls() != 2 {
+		t.Errorf("got %d HTTP calls, want 2", api.calls())
+	}
+}
+
+func TestFallbackResponsesAreNotDeduplicated(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRequestDeduplicationWindow(time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if api.calls() != 2 {
+		t.Errorf("got %d HTTP calls, want 2 since the fallback response shouldn't be reused", api.calls())
+	}
+}
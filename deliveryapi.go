@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const deliveryEndpointSuffix = "/deliver"
+const healthEndpointSuffix = "/healthz"
+
+// deliveryAPI is a context-aware HTTP client for the Delivery API. It is installed into the SDK
+// through apiFactory so that shadow traffic goes through the same HTTP path.
+type deliveryAPI struct {
+	// deliveryHTTPEndpoint is the Delivery API endpoint.
+	deliveryHTTPEndpoint string
+
+	// healthHTTPEndpoint is the API endpoint for healthchecks.
+	healthHTTPEndpoint string
+
+	// dryRunHTTPEndpoint is the API endpoint for validating requests without ranking them.
+	dryRunHTTPEndpoint string
+
+	// sseHTTPEndpoint is the API endpoint streaming re-ranked responses as server-sent events.
+	sseHTTPEndpoint string
+
+	// capabilitiesHTTPEndpoint is the API endpoint advertising the server's capabilities.
+	capabilitiesHTTPEndpoint string
+
+	// explainHTTPEndpoint is the API endpoint explaining an item's rank for debugging.
+	explainHTTPEndpoint string
+
+	// explainTimeout bounds each call to the explain endpoint.
+	explainTimeout time.Duration
+
+	// apiKey required for access to Delivery API.
+	apiKey string
+
+	// httpClient for the remote call.
+	httpClient *http.Client
+
+	// timeoutDuration bounds each call to the Delivery API.
+	timeoutDuration time.Duration
+
+	// maxRequestInsertions is the maximum number of request insertions passed to the delivery API.
+	maxRequestInsertions int
+
+	// acceptGzip asks for gzip or zstd compressed responses. Compressed responses are decompressed
+	// either way.
+	acceptGzip bool
+
+	// headers are sent on every request.
+	headers http.Header
+
+	// dynamicHeaders, if set, returns more headers for each request from its context. They
+	// override headers on key collision.
+	dynamicHeaders func(ctx context.Context) map[string]string
+
+	// fieldMask, if set, limits the request fields sent.
+	fieldMask *fieldmaskpb.FieldMask
+
+	// autoFieldMask sends only each request's non-default fields.
+	autoFieldMask bool
+
+	// backoff, if set, waits between up to maxRetries retries of retryable failures.
+	backoff    *RetryAfterAwareBackoff
+	maxRetries int
+
+	// eventBus, if set, is told about shadow traffic.
+	eventBus EventBus
+}
+
+// newDeliveryAPI instantiates a new Delivery API client.
+func newDeliveryAPI(endpoint, apiKey string, timeoutMillis int64, maxRequestInsertions int, acceptGzip bool) (*deliveryAPI, error) {
+	uri, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delivery endpoint: %v", err)
+	}
+	timeout := time.Duration(timeoutMillis) * time.Millisecond
+	return &deliveryAPI{
+		deliveryHTTPEndpoint:     uri.Scheme + "://" + uri.Host + deliveryEndpointSuffix,
+		healthHTTPEndpoint:       uri.Scheme + "://" + uri.Host + healthEndpointSuffix,
+		dryRunHTTPEndpoint:       uri.Scheme + "://" + uri.Host + deliveryEndpointSuffix + dryRunQuery,
+		sseHTTPEndpoint:          uri.Scheme + "://" + uri.Host + sseEndpointSuffix,
+		capabilitiesHTTPEndpoint: uri.Scheme + "://" + uri.Host + capabilitiesEndpointSuffix,
+		explainHTTPEndpoint:      uri.Scheme + "://" + uri.Host + explainEndpointSuffix,
+		explainTimeout:           defaultExplainTimeout,
+		apiKey:                   apiKey,
+		httpClient:               &http.Client{Timeout: timeout},
+		timeoutDuration:          timeout,
+		maxRequestInsertions:     maxRequestInsertions,
+		acceptGzip:               acceptGzip,
+		headers:                  http.Header{},
+	}, nil
+}
+
+// RunDelivery implements client.DeliveryAPI. The SDK only calls it for shadow traffic.
+func (d *deliveryAPI) RunDelivery(deliveryRequest *client.DeliveryRequest) (*delivery.Response, error) {
+	if d.eventBus != nil {
+		publishEvent(d.eventBus, EventTypeShadowSent, deliveryRequest.Request.GetClientRequestId(), proto.Clone(deliveryRequest.Request))
+	}
+	resp, _, err := d.runDelivery(context.Background(), deliveryRequest, nil)
+	return resp, err
+}
+
+// runDelivery calls the Delivery API, adding header on top of the API's own headers. It also
+// returns the response headers. Overloaded or unavailable responses are retried with the backoff,
+// if one is set.
+func (d *deliveryAPI) runDelivery(ctx context.Context, deliveryRequest *client.DeliveryRequest, header http.Header) (*delivery.Response, http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		resp, respHeader, err := d.runDeliveryOnce(ctx, deliveryRequest, header)
+		var deliveryErr *DeliveryError
+		if d.backoff == nil || attempt >= d.maxRetries || !errors.As(err, &deliveryErr) || !retryableStatusCodes[deliveryErr.StatusCode] {
+			return resp, respHeader, err
+		}
+		if err := d.backoff.Wait(ctx, attempt, deliveryErr.header); err != nil {
+			return nil, nil, newDeliveryError(deliveryRequest.Request.GetClientRequestId(), 0, nil, "error waiting to retry", err)
+		}
+	}
+}
+
+// runDeliveryOnce makes one Delivery API call, bounded by the API's timeout. Errors are
+// *DeliveryError.
+func (d *deliveryAPI) runDeliveryOnce(ctx context.Context, deliveryRequest *client.DeliveryRequest, header http.Header) (*delivery.Response, http.Header, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeoutDuration)
+	defer cancel()
+
+	request := deliveryRequest.Request
+	clientRequestID := request.GetClientRequestId()
+	if len(request.Insertion) > d.maxRequestInsertions {
+		// Only clone if we need to trim insertions.
+		request = deliveryRequest.Clone(d.maxRequestInsertions).Request
+	}
+	request = applyFieldMasks(request, d.fieldMask, d.autoFieldMask)
+
+	profiler := profilerFromContext(ctx)
+	start := time.Now()
+	requestBody, err := protojson.Marshal(request)
+	if err != nil {
+		return nil, nil, newDeliveryError(clientRequestID, 0, nil, "error marshaling delivery request", err)
+	}
+	profiler.observe(phaseSerialization, start)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.deliveryHTTPEndpoint, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, nil, newDeliveryError(clientRequestID, 0, nil, "error creating HTTP request", err)
+	}
+
+	d.setHeaders(req, header)
+	req.Header.Set("Content-Type", "application/json")
+	if d.acceptGzip {
+		req.Header.Set("Accept-Encoding", acceptEncodings)
+	}
+
+	start = time.Now()
+	respHTTP, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, newDeliveryError(clientRequestID, 0, nil, "error making HTTP request", err)
+	}
+	defer respHTTP.Body.Close()
+
+	if respHTTP.StatusCode < 200 || respHTTP.StatusCode >= 300 {
+		return nil, nil, newDeliveryError(clientRequestID, respHTTP.StatusCode, respHTTP.Header, "failure calling Delivery API", nil)
+	}
+
+	body, err := decompressedBody(respHTTP)
+	if err != nil {
+		return nil, nil, newDeliveryError(clientRequestID, respHTTP.StatusCode, respHTTP.Header, "error decompressing delivery response", err)
+	}
+	defer body.Close()
+
+	resp, err := readDeliveryResponse(body, profiler, start)
+	if err != nil {
+		return nil, nil, newDeliveryError(clientRequestID, respHTTP.StatusCode, respHTTP.Header, "error reading delivery response", err)
+	}
+	if resp.RequestId == "" {
+		return nil, nil, newDeliveryError(clientRequestID, respHTTP.StatusCode, respHTTP.Header, "delivery response should contain a requestId", nil)
+	}
+	return resp, respHTTP.Header, nil
+}
+
+// runHealthCheck calls the health endpoint and returns the response headers.
+func (d *deliveryAPI) runHealthCheck(ctx context.Context) (http.Header, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeoutDuration)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.healthHTTPEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating HTTP request: %v", err)
+	}
+	d.setHeaders(req, nil)
+
+	respHTTP, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making HTTP request: %v", err)
+	}
+	defer respHTTP.Body.Close()
+
+	if respHTTP.StatusCode < 200 || respHTTP.StatusCode >= 300 {
+		return nil, fmt.Errorf("failure calling health endpoint; statusCode=%d", respHTTP.StatusCode)
+	}
+	return respHTTP.Header, nil
+}
+
+// runWarmup posts request to the health endpoint, expecting a 2xx within the timeout.
+func (d *deliveryAPI) runWarmup(ctx context.Context, request *delivery.Request) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeoutDuration)
+	defer cancel()
+
+	requestBody, err := protojson.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("error marshaling warmup request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.healthHTTPEndpoint, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return fmt.Errorf("error creating HTTP request: %v", err)
+	}
+	d.setHeaders(req, nil)
+	req.Header.Set("Content-Type", "application/json")
+
+	respHTTP, err := d.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making HTTP request: %v", err)
+	}
+	defer respHTTP.Body.Close()
+
+	if respHTTP.StatusCode < 200 || respHTTP.StatusCode >= 300 {
+		return fmt.Errorf("failure calling health endpoint; statusCode=%d", respHTTP.StatusCode)
+	}
+	return nil
+}
+
+// setHeaders applies the API's headers, then its dynamic headers, then header, then the API key.
+func (d *deliveryAPI) setHeaders(req *http.Request, header http.Header) {
+	for key, values := range d.headers {
+		req.Header[key] = values
+	}
+	if d.dynamicHeaders != nil {
+		for key, value := range d.dynamicHeaders(req.Context()) {
+			req.Header.Set(key, value)
+		}
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("x-api-key", d.apiKey)
+}
+
+// readDeliveryResponse reads and unmarshals a JSON delivery response. The profiler, if any, counts
+// the network time since sent and the unmarshaling time.
+func readDeliveryResponse(body io.Reader, profiler *deliveryProfiler, sent time.Time) (*delivery.Response, error) {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, body); err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+	profiler.observe(phaseNetwork, sent)
+
+	start := time.Now()
+	var resp delivery.Response
+	if err := protojson.Unmarshal(buf.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("error unmarshaling JSON response: %v", err)
+	}
+	profiler.observe(phaseDeserialization, start)
+	return &resp, nil
+}
+
+// apiFactory hands the SDK this example's delivery API and defaults for everything else.
+type apiFactory struct {
+	client.DefaultAPIFactory
+	deliveryAPI *deliveryAPI
+}
+
+// CreateDeliveryAPI returns the pre-built delivery API, ignoring the SDK's settings.
+func (f *apiFactory) CreateDeliveryAPI(string, string, int64, int, bool, bool) client.DeliveryAPI {
+	return f.deliveryAPI
+}
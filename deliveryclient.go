@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/proto"
+)
+
+// DeliveryClientInterface is implemented by anything that can serve delivery requests.
+type DeliveryClientInterface interface {
+	Deliver(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error)
+}
+
+// DeliveryRequest wraps the SDK delivery request with options used by this example.
+type DeliveryRequest struct {
+	*client.DeliveryRequest
+
+	// Headers are extra HTTP headers sent to the Delivery API for this request only.
+	Headers http.Header
+
+	categoryFilter      *categoryFilter
+	lazyProperties      []LazyProperty
+	lazyPropertyTimeout time.Duration
+	computedProperties  []ComputedProperty
+	hasBundles          bool
+	hasInsertionGroups  bool
+	importance          ImportanceLevel
+}
+
+// DeliveryResponse wraps the SDK delivery response with fields added by this example.
+type DeliveryResponse struct {
+	*client.DeliveryResponse
+
+	// CorrelationID is the correlation ID sent with the request, if any.
+	CorrelationID string
+
+	// FallbackReason says why the Delivery API was skipped in favor of SDK delivery, if it was
+	// skipped on purpose.
+	FallbackReason string
+
+	// Metadata is the pagination metadata returned by the Delivery API. It is nil for SDK
+	// responses.
+	Metadata *ResponseMetadata
+
+	// ModelVersionMismatch is set when the Delivery API didn't use the pinned model version.
+	ModelVersionMismatch *ModelVersionMismatchWarning
+
+	// Complementary are items often bought with the ranked ones, if a complementary recommender
+	// is configured.
+	Complementary []*delivery.Insertion
+
+	// CrossSell and UpSell are the recommendations of the configured hooks for the top-ranked
+	// insertion.
+	CrossSell []*delivery.Insertion
+	UpSell    []*delivery.Insertion
+
+	// APIError is why the Delivery API call failed, if SDK delivery was served in its place. It
+	// is a *DeliveryError unless the call never started, e.g. due to rate limiting.
+	APIError error
+
+	// Profile breaks down where the call spent its time, if profiling is enabled.
+	Profile *DeliveryProfile
+}
+
+// deliverFunc performs a single delivery call.
+type deliverFunc func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error)
+
+// deliveryMiddleware wraps a deliverFunc with extra behavior, such as serving from a cache.
+type deliveryMiddleware func(next deliverFunc) deliverFunc
+
+// DeliveryClient is a context-aware wrapper around PromotedDeliveryClient.
+type DeliveryClient struct {
+	// deliver is deliverPromoted wrapped in the configured middlewares.
+	deliver deliverFunc
+
+	promoted               *client.PromotedDeliveryClient
+	deliveryAPI            *deliveryAPI
+	organizationID         string
+	correlationIDExtractor func(ctx context.Context) string
+	deduplicator           *requestDeduplicator
+	staleWhileRevalidate   *staleWhileRevalidateCache
+	telemetry              *telemetryReporter
+	apiVersion             *apiVersionChecker
+	batcher                *requestBatcher
+	adaptiveTimeout        *adaptiveTimeout
+	rateLimiter            *outboundRateLimiter
+	perUserRateLimiter     *PerUserRateLimiter
+	userIDAnonymizer       UserIDAnonymizer
+	regions                map[string]*deliveryBackend
+	regionExtractor        func(ctx context.Context) string
+	sseMaxReconnectDelay   time.Duration
+	requestIDs             *deterministicIDGenerator
+	warmUpConcurrency      int
+	temporalBoosts         *temporalBoosts
+	inventoryFilter        *inventoryFilter
+	performance            *latencyWindow
+	capabilities           *capabilityCache
+	usage                  *UsageTracker
+	budgetTracker          *BudgetTracker
+	eventBus               EventBus
+	priorityQueue          *priorityQueue
+	optedOut               func(ctx context.Context) bool
+	propertyEncryption     *propertyEncryption
+	propertyFilter         *propertyKeyFilter
+}
+
+// Deliver sends a delivery request and returns the response.
+func (c *DeliveryClient) Deliver(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+	return c.deliver(ctx, req)
+}
+
+// Close stops the client's background work, flushing pending telemetry and logging the usage report.
+func (c *DeliveryClient) Close() error {
+	c.logUsageReport()
+	if c.telemetry != nil {
+		c.telemetry.close()
+	}
+	return nil
+}
+
+// use wraps the client's delivery path in middlewares, the first being outermost.
+func (c *DeliveryClient) use(middlewares ...deliveryMiddleware) {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		c.deliver = middlewares[i](c.deliver)
+	}
+}
+
+// deliverPromoted runs the SDK delivery workflow, calling the Delivery API with this example's options.
+func (c *DeliveryClient) deliverPromoted(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+	if c.organizationID != "" && getProperty(req.Request.GetProperties(), organizationIDPropertyKey) == nil {
+		req = cloneRequest(req)
+		if err := applyOrganizationID(req.Request, c.organizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	header := req.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	var correlationID string
+	if c.correlationIDExtractor != nil {
+		correlationID = c.correlationIDExtractor(ctx)
+		if correlationID != "" {
+			header.Set(correlationIDHeader, correlationID)
+		}
+	}
+
+	backend := c.backendFor(ctx)
+	plan := backend.promoted.Plan(req.OnlyLog, req.Experiment)
+	if c.requestIDs != nil {
+		plan.ClientRequestID = c.requestIDs.next()
+	}
+	backend.promoted.PrepareRequest(req.DeliveryRequest, plan)
+
+	var apiResponse *delivery.Response
+	var fallbackReason string
+	var metadata *ResponseMetadata
+	var modelVersionMismatch *ModelVersionMismatchWarning
+	var apiErr error
+	critical := req.importance == ImportanceCritical
+	if plan.UseAPIResponse && !critical && c.perUserRateLimiter != nil && !c.perUserRateLimiter.Allow(requestUserID(req.Request)) {
+		fallbackReason = FallbackReasonRateLimited
+	} else if plan.UseAPIResponse && c.budgetTracker != nil && !c.budgetTracker.ConsumeCall() {
+		fallbackReason = FallbackReasonBudgetExhausted
+	} else if plan.UseAPIResponse {
+		var respHeader http.Header
+		var err error
+		apiResponse, respHeader, err = c.callDeliveryAPI(ctx, backend.deliveryAPI, req, header)
+		var waitErr *RateLimitWaitError
+		if errors.As(err, &waitErr) {
+			return nil, err
+		}
+		if err != nil {
+			log.Printf("Error calling Delivery API, falling back: %v\n", err)
+			apiErr = err
+		} else {
+			if c.apiVersion != nil {
+				if err := c.apiVersion.check(respHeader); err != nil {
+					return nil, err
+				}
+			}
+			metadata = extractMetadata(respHeader, apiResponse)
+			modelVersionMismatch = checkModelVersion(backend.deliveryAPI, header, respHeader)
+			if modelVersionMismatch != nil {
+				log.Printf("Warning: %v\n", modelVersionMismatch)
+			}
+		}
+	}
+
+	// The Delivery API needs the raw IDs to personalize, but everything logged gets anonymized ones.
+	// They go on a copy so the caller's request keeps its raw IDs.
+	logReq := req
+	if c.userIDAnonymizer != nil {
+		logReq = cloneRequest(req)
+		anonymizeUserInfo(logReq.Request, c.userIDAnonymizer)
+	}
+
+	// Note this returns a delivery response based on this apiResponse if it's set, and creates
+	// an SDK response otherwise.
+	resp, err := backend.promoted.HandleSDKAndLog(logReq.DeliveryRequest, plan, apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	return &DeliveryResponse{
+		DeliveryResponse:     resp,
+		CorrelationID:        correlationID,
+		FallbackReason:       fallbackReason,
+		Metadata:             metadata,
+		ModelVersionMismatch: modelVersionMismatch,
+		APIError:             apiErr,
+	}, nil
+}
+
+// prepareDirectCall readies req for the calls that skip the middlewares, e.g. Explain and DeliverSSE,
+// returning the backend to call and the request to send. Like Deliver, they refuse to send
+// opted-out users, encrypt and filter insertion properties, and follow data residency.
+func (c *DeliveryClient) prepareDirectCall(ctx context.Context, req *DeliveryRequest) (*deliveryAPI, *DeliveryRequest, error) {
+	if c.optedOut != nil && c.optedOut(ctx) {
+		return nil, nil, errOptedOut
+	}
+	req = cloneRequest(req)
+	if c.propertyEncryption != nil {
+		for _, ins := range req.Request.GetInsertion() {
+			if err := c.propertyEncryption.encrypt(ins); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if c.propertyFilter != nil {
+		c.propertyFilter.strip(req)
+	}
+	return c.backendFor(ctx).deliveryAPI, req, nil
+}
+
+// callDeliveryAPI calls the Delivery API, publishing the call's events. Payloads are copies, so
+// subscribers don't race with the rest of the call, and requests carry anonymized user IDs if an
+// anonymizer is configured.
+func (c *DeliveryClient) callDeliveryAPI(ctx context.Context, api *deliveryAPI, req *DeliveryRequest, header http.Header) (*delivery.Response, http.Header, error) {
+	clientRequestID := req.Request.GetClientRequestId()
+	if c.eventBus != nil {
+		sent := proto.Clone(req.Request).(*delivery.Request)
+		if c.userIDAnonymizer != nil {
+			anonymizeUserInfo(sent, c.userIDAnonymizer)
+		}
+		publishEvent(c.eventBus, EventTypeRequestSent, clientRequestID, sent)
+	}
+	resp, respHeader, err := c.sendDeliveryRequest(ctx, api, req, header)
+	if err != nil {
+		publishEvent(c.eventBus, EventTypeError, clientRequestID, err)
+	} else if c.eventBus != nil {
+		publishEvent(c.eventBus, EventTypeResponseReceived, clientRequestID, proto.Clone(resp))
+	}
+	return resp, respHeader, err
+}
+
+// sendDeliveryRequest calls the Delivery API directly or through the batcher, once the priority queue
+// and rate limiter let it. Batched calls pass their headers to the batch API and return no response
+// headers.
+func (c *DeliveryClient) sendDeliveryRequest(ctx context.Context, api *deliveryAPI, req *DeliveryRequest, header http.Header) (*delivery.Response, http.Header, error) {
+	if c.priorityQueue != nil {
+		if err := c.priorityQueue.acquire(ctx, req.importance); err != nil {
+			return nil, nil, err
+		}
+		defer c.priorityQueue.release()
+	}
+	if c.rateLimiter != nil && req.importance != ImportanceCritical {
+		if err := c.rateLimiter.acquire(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.adaptiveTimeout != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.adaptiveTimeout.timeout())
+		defer cancel()
+		start := time.Now()
+		defer func() {
+			c.adaptiveTimeout.observe(time.Since(start))
+		}()
+	}
+	if c.batcher != nil {
+		resp, err := c.batcher.runDelivery(ctx, req.DeliveryRequest, header)
+		if err != nil {
+			var deliveryErr *DeliveryError
+			if !errors.As(err, &deliveryErr) {
+				err = newDeliveryError(req.Request.GetClientRequestId(), 0, nil, "error calling batch Delivery API", err)
+			}
+		}
+		return resp, nil, err
+	}
+	return api.runDelivery(ctx, req.DeliveryRequest, header)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// fakeAPI is a Delivery API that records each delivery call and, unless respond is set, returns the
+// request's insertions in order. Calls to other paths, like the health endpoint, get an empty 200.
+type fakeAPI struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	requests       []*delivery.Request
+	headers        []http.Header
+	otherPaths     []string
+	respond        func(w http.ResponseWriter, req *delivery.Request)
+	responseHeader http.Header
+}
+
+func newFakeAPI(t testing.TB) *fakeAPI {
+	t.Helper()
+	api := &fakeAPI{responseHeader: http.Header{}}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		for key, values := range api.responseHeader {
+			w.Header()[key] = values
+		}
+		if r.URL.Path != deliveryEndpointSuffix {
+			api.otherPaths = append(api.otherPaths, r.URL.Path)
+			api.mu.Unlock()
+			return
+		}
+		api.mu.Unlock()
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("error reading request body: %v", err)
+			return
+		}
+		var req delivery.Request
+		if err := protojson.Unmarshal(body, &req); err != nil {
+			t.Errorf("error unmarshaling delivery request: %v", err)
+			return
+		}
+		api.mu.Lock()
+		api.requests = append(api.requests, &req)
+		api.headers = append(api.headers, r.Header.Clone())
+		respond := api.respond
+		api.mu.Unlock()
+		if respond != nil {
+			respond(w, &req)
+			return
+		}
+		writeDeliveryResponse(t, w, echoResponse(&req))
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// setRespond replaces the echo response.
+func (a *fakeAPI) setRespond(respond func(w http.ResponseWriter, req *delivery.Request)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.respond = respond
+}
+
+// setResponseHeader sets a header on every response.
+func (a *fakeAPI) setResponseHeader(key, value string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.responseHeader.Set(key, value)
+}
+
+// otherCalls returns the paths of calls other than delivery calls.
+func (a *fakeAPI) otherCalls() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.otherPaths...)
+}
+
+func (a *fakeAPI) calls() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.requests)
+}
+
+func (a *fakeAPI) lastRequest(t *testing.T) *delivery.Request {
+	t.Helper()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.requests) == 0 {
+		t.Fatal("the Delivery API wasn't called")
+	}
+	return a.requests[len(a.requests)-1]
+}
+
+func (a *fakeAPI) lastHeader(t *testing.T) http.Header {
+	t.Helper()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.headers) == 0 {
+		t.Fatal("the Delivery API wasn't called")
+	}
+	return a.headers[len(a.headers)-1]
+}
+
+// echoResponse returns the request's insertions in order.
+func echoResponse(req *delivery.Request) *delivery.Response {
+	resp := &delivery.Response{RequestId: "request-" + req.GetClientRequestId()}
+	for i, ins := range req.GetInsertion() {
+		position := uint64(i)
+		resp.Insertion = append(resp.Insertion, &delivery.Insertion{ContentId: ins.GetContentId(), Position: &position})
+	}
+	return resp
+}
+
+// rankedResponse returns the content IDs in the given order.
+func rankedResponse(contentIDs ...string) *delivery.Response {
+	resp := &delivery.Response{RequestId: "request-ranked"}
+	for i, id := range contentIDs {
+		position := uint64(i)
+		resp.Insertion = append(resp.Insertion, &delivery.Insertion{ContentId: id, Position: &position})
+	}
+	return resp
+}
+
+func writeDeliveryResponse(t testing.TB, w http.ResponseWriter, resp *delivery.Response) {
+	body, err := protojson.Marshal(resp)
+	if err != nil {
+		t.Errorf("error marshaling delivery response: %v", err)
+		return
+	}
+	w.Write(body)
+}
+
+// newRecordingServer records the bodies of requests posted to it, e.g. by the Metrics API client.
+func newRecordingServer(t testing.TB) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), bodies...)
+	}
+}
+
+// waitFor polls until cond holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newTestClientBuilder returns a builder for a client calling api, logging to a throwaway Metrics API.
+func newTestClientBuilder(t testing.TB, api *fakeAPI) *DeliveryClientBuilder {
+	t.Helper()
+	metrics, _ := newRecordingServer(t)
+	return NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(api.URL).
+		WithMetricsEndpoint(metrics.URL).
+		WithDisableUsageTracking(true)
+}
+
+func buildTestClient(t testing.TB, b *DeliveryClientBuilder) *DeliveryClient {
+	t.Helper()
+	c, err := b.Build()
+	if err != nil {
+		t.Fatalf("error building client: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// newTestDeliveryRequest builds a request for an anonymous user with insertions of contentIDs.
+func newTestDeliveryRequest(t *testing.T, contentIDs ...string) *DeliveryRequest {
+	t.Helper()
+	return buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:  &common.UserInfo{AnonUserId: "anon-1"},
+		Insertion: testInsertions(contentIDs...),
+	}))
+}
+
+func buildTestRequest(t *testing.T, b *DeliveryRequestBuilder) *DeliveryRequest {
+	t.Helper()
+	req, err := b.Build()
+	if err != nil {
+		t.Fatalf("error building request: %v", err)
+	}
+	return req
+}
+
+func testInsertions(contentIDs ...string) []*delivery.Insertion {
+	insertions := make([]*delivery.Insertion, len(contentIDs))
+	for i, id := range contentIDs {
+		insertions[i] = &delivery.Insertion{ContentId: id}
+	}
+	return insertions
+}
+
+func insertionContentIDsOf(insertions []*delivery.Insertion) []string {
+	ids := make([]string, len(insertions))
+	for i, ins := range insertions {
+		ids[i] = ins.GetContentId()
+	}
+	return ids
+}
+
+func assertContentIDs(t *testing.T, resp *DeliveryResponse, want ...string) {
+	t.Helper()
+	if got := insertionContentIDsOf(resp.Response.GetInsertion()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got content IDs %v, want %v", got, want)
+	}
+}
+
+// fakeDeliveryClient serves the request's insertions in order, or err, and records each call.
+type fakeDeliveryClient struct {
+	name string
+	err  error
+
+	mu       sync.Mutex
+	requests []*DeliveryRequest
+}
+
+func (f *fakeDeliveryClient) Deliver(_ context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := echoResponse(req.Request)
+	resp.RequestId = f.name
+	return &DeliveryResponse{DeliveryResponse: &client.DeliveryResponse{
+		Response:        resp,
+		ClientRequestID: req.Request.GetClientRequestId(),
+		ExecutionServer: delivery.ExecutionServer_API,
+	}}, nil
+}
+
+func (f *fakeDeliveryClient) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+func TestDeliverReturnsAPIRanking(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		writeDeliveryResponse(t, w, rankedResponse("c", "a", "b"))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "c", "a", "b")
+	if resp.ExecutionServer != delivery.ExecutionServer_API || resp.APIError != nil {
+		t.Errorf("got execution server %v and API error %v, want API and none", resp.ExecutionServer, resp.APIError)
+	}
+}
+
+func TestDeliverFallsBackToSDKOnAPIError(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "a", "b", "c")
+	if resp.ExecutionServer != delivery.ExecutionServer_SDK || resp.APIError == nil {
+		t.Errorf("got execution server %v and API error %v, want SDK and an error", resp.ExecutionServer, resp.APIError)
+	}
+}
@@ -0,0 +1,1398 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const defaultDeliveryTimeoutMillis = 250
+const defaultMetricsTimeoutMillis = 3000
+const defaultMaxRequestInsertions = 1000
+
+// DeliveryClientBuilder builds a DeliveryClient along with its underlying PromotedDeliveryClient.
+type DeliveryClientBuilder struct {
+	deliveryEndpoint          string
+	deliveryAPIKey            string
+	deliveryTimeoutMillis     int64
+	metricsEndpoint           string
+	metricsAPIKey             string
+	metricsTimeoutMillis      int64
+	maxRequestInsertions      int
+	applyTreatmentChecker     client.ApplyTreatmentChecker
+	sampler                   client.Sampler
+	shadowTrafficDeliveryRate float32
+	performChecks             bool
+	blockingShadowTraffic     bool
+	acceptsGzip               bool
+	organizationID            string
+	correlationIDExtractor    func(ctx context.Context) string
+	deduplicationWindow       time.Duration
+	contentIDValidator        *regexp.Regexp
+	contentIDValidationMode   ValidationMode
+	telemetry                 bool
+	telemetryEndpoint         string
+	requiredAPIVersion        string
+	batchingWindow            time.Duration
+	batchMaxSize              int
+	batchDeliveryAPI          BatchDeliveryAPI
+	adaptiveTimeoutTarget     time.Duration
+	adaptiveTimeoutFloor      time.Duration
+	adaptiveTimeoutCeiling    time.Duration
+	adaptiveTimeoutAlpha      float64
+	rateLimitRPS              float64
+	rateLimitBurst            int
+	rateLimitDrop             bool
+	perUserRateLimitRPS       float64
+	perUserRateLimitBurst     int
+	perUserRateLimitMaxUsers  int
+	propertyEncryptor         InsertionPropertyEncryptor
+	encryptedPropertyKeys     []string
+	userIDAnonymizer          UserIDAnonymizer
+	userIDAnonymizationPepper []byte
+	gdprOptOut                func(ctx context.Context) bool
+	dataResidencyRouter       *DataResidencyRouter
+	regionExtractor           func(ctx context.Context) string
+	currencyNormalizer        *CurrencyNormalizer
+	priceKey                  string
+	categoryTaxonomy          *CategoryTaxonomy
+	synonymExpander           *SynonymExpander
+	spellCorrector            *SpellCorrector
+	dryRunEndpoint            string
+	explainEndpoint           string
+	explainTimeout            time.Duration
+	requestLinter             *RequestLinter
+	modelVersion              string
+	sseMaxReconnectDelay      time.Duration
+	deterministicIDs          bool
+	requestIDSeed             int64
+	testMode                  bool
+	cassettePath              string
+	cassetteMode              CassetteMode
+	cassette                  *cassetteTransport
+	offlineRankings           map[string][]*delivery.Insertion
+	offlineRankingsPath       string
+	staleTTL                  time.Duration
+	bgRefreshTimeout          time.Duration
+	staleMaxAge               time.Duration
+	staleMaxEntries           int
+	warmUpConcurrency         int
+	recencyBooster            *RecencyBooster
+	complementaryRecommender  *ComplementaryRecommender
+	complementaryCount        int
+	crossSellHook             CrossSellHook
+	upSellHook                UpSellHook
+	contentStore              ContentStore
+	contentStoreConcurrency   int
+	temporalBoostRules        []TemporalBoostRule
+	popularityProvider        PopularityProvider
+	popularityWeight          float64
+	popularityFetchTimeout    time.Duration
+	diversityFilter           *DiversityFilter
+	diversityPropKey          string
+	fairnessConstraint        *FairnessConstraint
+	inventoryChecker          InventoryChecker
+	priceTierKey              string
+	priceTiersKey             string
+	priceTierBreakpoints      []float64
+	priceTierLabels           []string
+	priceTierComputer         *PriceTierComputer
+	discountProperties        *discountProperties
+	impressionTracker         *ImpressionTracker
+	queryExpander             *QueryExpander
+	queryExpansionMaxTerms    int
+	stopWordRemover           *StopWordRemover
+	profiling                 bool
+	warmupOnBuild             bool
+	performanceWindowSize     int
+	staticHeaders             map[string]string
+	dynamicHeaders            func(ctx context.Context) map[string]string
+	capabilityRefreshInterval time.Duration
+	capabilityCheckOnBuild    bool
+	usage                     *UsageTracker
+	disableUsageTracking      bool
+	localReRanker             LocalReRanker
+	rolloutGate               RolloutGate
+	rolloutPercents           map[string]float64
+	abTestPlugin              ABTestPlugin
+	abTestExperimentKey       string
+	fieldMaskPaths            []string
+	fieldMask                 *fieldmaskpb.FieldMask
+	autoFieldMask             bool
+	retryBackoff              *RetryAfterAwareBackoff
+	maxRetries                int
+	oidcProvider              OIDCTokenProvider
+	queryLogger               QueryLogger
+	budgetTracker             *BudgetTracker
+	eventBus                  EventBus
+	priorityQueueSize         int
+	contentScorer             ContentScorer
+	scoringConcurrency        int
+	stabilizationThreshold    float64
+	stabilizationTTL          time.Duration
+	confidenceThreshold       float64
+	featureStore              *LocalFeatureStore
+	allowedPropertyKeys       []string
+	deniedPropertyKeys        []string
+	useCaseValidation         bool
+}
+
+// NewDeliveryClientBuilder implements a builder interface for DeliveryClient.
+func NewDeliveryClientBuilder() *DeliveryClientBuilder {
+	return &DeliveryClientBuilder{
+		deliveryTimeoutMillis:     defaultDeliveryTimeoutMillis,
+		metricsTimeoutMillis:      defaultMetricsTimeoutMillis,
+		maxRequestInsertions:      defaultMaxRequestInsertions,
+		adaptiveTimeoutAlpha:      defaultAdaptiveTimeoutAlpha,
+		performanceWindowSize:     defaultPerformanceWindowSize,
+		capabilityRefreshInterval: defaultCapabilityRefreshInterval,
+		usage:                     &UsageTracker{},
+	}
+}
+
+func (b *DeliveryClientBuilder) WithDeliveryEndpoint(deliveryEndpoint string) *DeliveryClientBuilder {
+	b.usage.record("WithDeliveryEndpoint")
+	b.deliveryEndpoint = deliveryEndpoint
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithDeliveryAPIKey(deliveryAPIKey string) *DeliveryClientBuilder {
+	b.usage.record("WithDeliveryAPIKey")
+	b.deliveryAPIKey = deliveryAPIKey
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithMetricsEndpoint(metricsEndpoint string) *DeliveryClientBuilder {
+	b.usage.record("WithMetricsEndpoint")
+	b.metricsEndpoint = metricsEndpoint
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithMetricsAPIKey(metricsAPIKey string) *DeliveryClientBuilder {
+	b.usage.record("WithMetricsAPIKey")
+	b.metricsAPIKey = metricsAPIKey
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithDeliveryTimeoutMillis(deliveryTimeoutMillis int64) *DeliveryClientBuilder {
+	b.usage.record("WithDeliveryTimeoutMillis")
+	b.deliveryTimeoutMillis = deliveryTimeoutMillis
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithMetricsTimeoutMillis(metricsTimeoutMillis int64) *DeliveryClientBuilder {
+	b.usage.record("WithMetricsTimeoutMillis")
+	b.metricsTimeoutMillis = metricsTimeoutMillis
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithMaxRequestInsertions(maxRequestInsertions int) *DeliveryClientBuilder {
+	b.usage.record("WithMaxRequestInsertions")
+	b.maxRequestInsertions = maxRequestInsertions
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithApplyTreatmentChecker(applyTreatmentChecker client.ApplyTreatmentChecker) *DeliveryClientBuilder {
+	b.usage.record("WithApplyTreatmentChecker")
+	b.applyTreatmentChecker = applyTreatmentChecker
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithSampler(sampler client.Sampler) *DeliveryClientBuilder {
+	b.usage.record("WithSampler")
+	b.sampler = sampler
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithShadowTrafficDeliveryRate(shadowTrafficDeliveryRate float32) *DeliveryClientBuilder {
+	b.usage.record("WithShadowTrafficDeliveryRate")
+	b.shadowTrafficDeliveryRate = shadowTrafficDeliveryRate
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithPerformChecks(performChecks bool) *DeliveryClientBuilder {
+	b.usage.record("WithPerformChecks")
+	b.performChecks = performChecks
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithBlockingShadowTraffic(blockingShadowTraffic bool) *DeliveryClientBuilder {
+	b.usage.record("WithBlockingShadowTraffic")
+	b.blockingShadowTraffic = blockingShadowTraffic
+	return b
+}
+
+func (b *DeliveryClientBuilder) WithAcceptsGzip(acceptsGzip bool) *DeliveryClientBuilder {
+	b.usage.record("WithAcceptsGzip")
+	b.acceptsGzip = acceptsGzip
+	return b
+}
+
+// WithOrganizationID tags every request with the org ID so the Delivery API can pick the org's model.
+func (b *DeliveryClientBuilder) WithOrganizationID(orgID string) *DeliveryClientBuilder {
+	b.usage.record("WithOrganizationID")
+	b.organizationID = orgID
+	return b
+}
+
+// WithCorrelationIDExtractor sets how to find the caller's correlation ID, e.g. CorrelationIDFromContext.
+func (b *DeliveryClientBuilder) WithCorrelationIDExtractor(fn func(ctx context.Context) string) *DeliveryClientBuilder {
+	b.usage.record("WithCorrelationIDExtractor")
+	b.correlationIDExtractor = fn
+	return b
+}
+
+// WithRequestDeduplicationWindow serves repeated identical requests within d from the first response.
+func (b *DeliveryClientBuilder) WithRequestDeduplicationWindow(d time.Duration) *DeliveryClientBuilder {
+	b.usage.record("WithRequestDeduplicationWindow")
+	b.deduplicationWindow = d
+	return b
+}
+
+// WithContentIDValidator checks every insertion's content ID against pattern before sending.
+func (b *DeliveryClientBuilder) WithContentIDValidator(pattern *regexp.Regexp) *DeliveryClientBuilder {
+	b.usage.record("WithContentIDValidator")
+	b.contentIDValidator = pattern
+	return b
+}
+
+// WithContentIDValidationMode sets whether invalid content IDs fail the call or are filtered out.
+func (b *DeliveryClientBuilder) WithContentIDValidationMode(mode ValidationMode) *DeliveryClientBuilder {
+	b.usage.record("WithContentIDValidationMode")
+	b.contentIDValidationMode = mode
+	return b
+}
+
+// WithTelemetry enables anonymized usage stats, flushed daily and on Close. Defaults to false.
+func (b *DeliveryClientBuilder) WithTelemetry(enabled bool) *DeliveryClientBuilder {
+	b.usage.record("WithTelemetry")
+	b.telemetry = enabled
+	return b
+}
+
+// WithTelemetryEndpoint sets where usage stats are posted; required when telemetry is enabled.
+func (b *DeliveryClientBuilder) WithTelemetryEndpoint(telemetryEndpoint string) *DeliveryClientBuilder {
+	b.usage.record("WithTelemetryEndpoint")
+	b.telemetryEndpoint = telemetryEndpoint
+	return b
+}
+
+// WithRequiredAPIVersion fails delivery with APIVersionMismatchError if the server's API version is older.
+func (b *DeliveryClientBuilder) WithRequiredAPIVersion(version string) *DeliveryClientBuilder {
+	b.usage.record("WithRequiredAPIVersion")
+	b.requiredAPIVersion = version
+	return b
+}
+
+// WithRequestBatchingWindow groups Delivery API calls arriving within d into one batch call.
+// Requires WithBatchDeliveryAPI.
+func (b *DeliveryClientBuilder) WithRequestBatchingWindow(d time.Duration) *DeliveryClientBuilder {
+	b.usage.record("WithRequestBatchingWindow")
+	b.batchingWindow = d
+	return b
+}
+
+// WithBatchMaxSize sends a batch as soon as it has n calls. Defaults to 100.
+func (b *DeliveryClientBuilder) WithBatchMaxSize(n int) *DeliveryClientBuilder {
+	b.usage.record("WithBatchMaxSize")
+	b.batchMaxSize = n
+	return b
+}
+
+// WithBatchDeliveryAPI sets the transport used for batched calls.
+func (b *DeliveryClientBuilder) WithBatchDeliveryAPI(api BatchDeliveryAPI) *DeliveryClientBuilder {
+	b.usage.record("WithBatchDeliveryAPI")
+	b.batchDeliveryAPI = api
+	return b
+}
+
+// WithAdaptiveTimeout derives the Delivery API timeout from recent latency, starting at target and
+// staying within [floor, ceiling]. The ceiling replaces the delivery timeout.
+func (b *DeliveryClientBuilder) WithAdaptiveTimeout(target, floor, ceiling time.Duration) *DeliveryClientBuilder {
+	b.usage.record("WithAdaptiveTimeout")
+	b.adaptiveTimeoutTarget = target
+	b.adaptiveTimeoutFloor = floor
+	b.adaptiveTimeoutCeiling = ceiling
+	return b
+}
+
+// WithAdaptiveTimeoutAlpha sets the EWMA weight of the newest latency. Defaults to 0.2.
+func (b *DeliveryClientBuilder) WithAdaptiveTimeoutAlpha(a float64) *DeliveryClientBuilder {
+	b.usage.record("WithAdaptiveTimeoutAlpha")
+	b.adaptiveTimeoutAlpha = a
+	return b
+}
+
+// WithOutboundRateLimit keeps Delivery API calls within rps, allowing bursts of up to burst calls.
+func (b *DeliveryClientBuilder) WithOutboundRateLimit(rps float64, burst int) *DeliveryClientBuilder {
+	b.usage.record("WithOutboundRateLimit")
+	b.rateLimitRPS = rps
+	b.rateLimitBurst = burst
+	return b
+}
+
+// WithRateLimitDropBehavior falls back to SDK delivery instead of waiting when the rate limit is hit.
+func (b *DeliveryClientBuilder) WithRateLimitDropBehavior(drop bool) *DeliveryClientBuilder {
+	b.usage.record("WithRateLimitDropBehavior")
+	b.rateLimitDrop = drop
+	return b
+}
+
+// WithPerUserRateLimit gives each user their own rate limit, tracking up to maxUsers users.
+func (b *DeliveryClientBuilder) WithPerUserRateLimit(rps float64, burst int, maxUsers int) *DeliveryClientBuilder {
+	b.usage.record("WithPerUserRateLimit")
+	b.perUserRateLimitRPS = rps
+	b.perUserRateLimitBurst = burst
+	b.perUserRateLimitMaxUsers = maxUsers
+	return b
+}
+
+// WithUseCaseValidation rejects requests missing the fields their use case requires, e.g. a
+// SEARCH without a SearchQuery, and logs other use case mistakes. See UseCaseValidator.
+func (b *DeliveryClientBuilder) WithUseCaseValidation(enabled bool) *DeliveryClientBuilder {
+	b.usage.record("WithUseCaseValidation")
+	b.useCaseValidation = enabled
+	return b
+}
+
+// WithAllowedPropertyKeys strips every insertion property key but keys before sending, logging a
+// warning for each key stripped. Properties added by other features, e.g. lazy or computed ones,
+// are filtered too, except the client's own bookkeeping keys such as bundle and groupId.
+func (b *DeliveryClientBuilder) WithAllowedPropertyKeys(keys ...string) *DeliveryClientBuilder {
+	b.usage.record("WithAllowedPropertyKeys")
+	b.allowedPropertyKeys = keys
+	return b
+}
+
+// WithDeniedPropertyKeys strips keys from insertion properties before sending, logging a warning
+// for each key stripped.
+func (b *DeliveryClientBuilder) WithDeniedPropertyKeys(keys ...string) *DeliveryClientBuilder {
+	b.usage.record("WithDeniedPropertyKeys")
+	b.deniedPropertyKeys = keys
+	return b
+}
+
+// WithLocalFeatureStore assigns requests without an experiment to the user's cohort in fs.
+func (b *DeliveryClientBuilder) WithLocalFeatureStore(fs *LocalFeatureStore) *DeliveryClientBuilder {
+	b.usage.record("WithLocalFeatureStore")
+	b.featureStore = fs
+	return b
+}
+
+// WithConfidenceThreshold puts responses back in request order when the mean confidence score of
+// their insertions is below threshold.
+func (b *DeliveryClientBuilder) WithConfidenceThreshold(threshold float64) *DeliveryClientBuilder {
+	b.usage.record("WithConfidenceThreshold")
+	b.confidenceThreshold = threshold
+	return b
+}
+
+// WithPositionStabilization puts responses back in the order last served to the user for the same
+// request, for up to ttl, when their top insertions have a Jaccard similarity to it above
+// threshold.
+func (b *DeliveryClientBuilder) WithPositionStabilization(threshold float64, ttl time.Duration) *DeliveryClientBuilder {
+	b.usage.record("WithPositionStabilization")
+	b.stabilizationThreshold = threshold
+	b.stabilizationTTL = ttl
+	return b
+}
+
+// WithContentScorer sends each insertion's local score from cs in the localScore property.
+func (b *DeliveryClientBuilder) WithContentScorer(cs ContentScorer) *DeliveryClientBuilder {
+	b.usage.record("WithContentScorer")
+	b.contentScorer = cs
+	return b
+}
+
+// WithScoringConcurrency scores up to n insertions at once. The default is 8.
+func (b *DeliveryClientBuilder) WithScoringConcurrency(n int) *DeliveryClientBuilder {
+	b.usage.record("WithScoringConcurrency")
+	b.scoringConcurrency = n
+	return b
+}
+
+// WithPriorityQueue allows up to maxConcurrent Delivery API calls at once. Others wait, and are let
+// through by importance.
+func (b *DeliveryClientBuilder) WithPriorityQueue(maxConcurrent int) *DeliveryClientBuilder {
+	b.usage.record("WithPriorityQueue")
+	b.priorityQueueSize = maxConcurrent
+	return b
+}
+
+// WithEventBus publishes an event to bus as each Delivery API call is sent and completes, and for
+// shadow traffic.
+func (b *DeliveryClientBuilder) WithEventBus(bus EventBus) *DeliveryClientBuilder {
+	b.usage.record("WithEventBus")
+	b.eventBus = bus
+	return b
+}
+
+// WithBudgetTracker serves SDK delivery without calling the Delivery API once bt's budget is used up.
+func (b *DeliveryClientBuilder) WithBudgetTracker(bt *BudgetTracker) *DeliveryClientBuilder {
+	b.usage.record("WithBudgetTracker")
+	b.budgetTracker = bt
+	return b
+}
+
+// WithInsertionPropertyEncryption encrypts the named insertion properties before they are sent and
+// decrypts them in responses.
+func (b *DeliveryClientBuilder) WithInsertionPropertyEncryption(encryptor InsertionPropertyEncryptor, keys []string) *DeliveryClientBuilder {
+	b.usage.record("WithInsertionPropertyEncryption")
+	b.propertyEncryptor = encryptor
+	b.encryptedPropertyKeys = keys
+	return b
+}
+
+// WithUserIDAnonymizer anonymizes user IDs before the request is logged.
+func (b *DeliveryClientBuilder) WithUserIDAnonymizer(a UserIDAnonymizer) *DeliveryClientBuilder {
+	b.usage.record("WithUserIDAnonymizer")
+	b.userIDAnonymizer = a
+	return b
+}
+
+// WithUserIDAnonymizationPepper anonymizes user IDs with HMAC-SHA256 keyed by pepper, unless
+// WithUserIDAnonymizer sets another anonymizer.
+func (b *DeliveryClientBuilder) WithUserIDAnonymizationPepper(pepper []byte) *DeliveryClientBuilder {
+	b.usage.record("WithUserIDAnonymizationPepper")
+	b.userIDAnonymizationPepper = pepper
+	return b
+}
+
+// WithGDPROptOut skips personalization and logging for calls where fn reports the user opted out.
+func (b *DeliveryClientBuilder) WithGDPROptOut(fn func(ctx context.Context) bool) *DeliveryClientBuilder {
+	b.usage.record("WithGDPROptOut")
+	b.gdprOptOut = fn
+	return b
+}
+
+// WithDataResidencyRouter sends each request to the endpoints of its user's region.
+func (b *DeliveryClientBuilder) WithDataResidencyRouter(router *DataResidencyRouter) *DeliveryClientBuilder {
+	b.usage.record("WithDataResidencyRouter")
+	b.dataResidencyRouter = router
+	return b
+}
+
+// WithRegionExtractor reads the user's region from the request context, e.g. from a JWT claim.
+func (b *DeliveryClientBuilder) WithRegionExtractor(fn func(ctx context.Context) string) *DeliveryClientBuilder {
+	b.usage.record("WithRegionExtractor")
+	b.regionExtractor = fn
+	return b
+}
+
+// WithCurrencyNormalization rewrites the priceKey property of each insertion to USD, keeping the
+// original amount and currency in originalPrice and originalCurrency.
+func (b *DeliveryClientBuilder) WithCurrencyNormalization(normalizer CurrencyNormalizer, priceKey string) *DeliveryClientBuilder {
+	b.usage.record("WithCurrencyNormalization")
+	b.currencyNormalizer = &normalizer
+	b.priceKey = priceKey
+	return b
+}
+
+// WithCategoryTaxonomy expands request category filters to include subcategories.
+func (b *DeliveryClientBuilder) WithCategoryTaxonomy(t *CategoryTaxonomy) *DeliveryClientBuilder {
+	b.usage.record("WithCategoryTaxonomy")
+	b.categoryTaxonomy = t
+	return b
+}
+
+// WithSynonymExpander sends search queries expanded with their synonyms.
+func (b *DeliveryClientBuilder) WithSynonymExpander(e *SynonymExpander) *DeliveryClientBuilder {
+	b.usage.record("WithSynonymExpander")
+	b.synonymExpander = e
+	return b
+}
+
+// WithSpellCorrector corrects typos in search queries before sending.
+func (b *DeliveryClientBuilder) WithSpellCorrector(sc *SpellCorrector) *DeliveryClientBuilder {
+	b.usage.record("WithSpellCorrector")
+	b.spellCorrector = sc
+	return b
+}
+
+// WithDryRunEndpoint sends ValidateRequest calls to url instead of the delivery endpoint.
+func (b *DeliveryClientBuilder) WithDryRunEndpoint(url string) *DeliveryClientBuilder {
+	b.usage.record("WithDryRunEndpoint")
+	b.dryRunEndpoint = url
+	return b
+}
+
+// WithExplainEndpoint sends Explain calls to url instead of the delivery endpoint's /explain.
+func (b *DeliveryClientBuilder) WithExplainEndpoint(url string) *DeliveryClientBuilder {
+	b.usage.record("WithExplainEndpoint")
+	b.explainEndpoint = url
+	return b
+}
+
+// WithExplainTimeout bounds each Explain call. Defaults to 2m.
+func (b *DeliveryClientBuilder) WithExplainTimeout(d time.Duration) *DeliveryClientBuilder {
+	b.usage.record("WithExplainTimeout")
+	b.explainTimeout = d
+	return b
+}
+
+// WithRequestLinter logs lint warnings for each request and rejects requests with lint errors.
+func (b *DeliveryClientBuilder) WithRequestLinter(l *RequestLinter) *DeliveryClientBuilder {
+	b.usage.record("WithRequestLinter")
+	b.requestLinter = l
+	return b
+}
+
+// WithModelVersion asks the Delivery API to rank every request with the given model version.
+func (b *DeliveryClientBuilder) WithModelVersion(version string) *DeliveryClientBuilder {
+	b.usage.record("WithModelVersion")
+	b.modelVersion = version
+	return b
+}
+
+// WithSSEMaxReconnectDelay caps the backoff between DeliverSSE reconnects. Defaults to 30s.
+func (b *DeliveryClientBuilder) WithSSEMaxReconnectDelay(d time.Duration) *DeliveryClientBuilder {
+	b.usage.record("WithSSEMaxReconnectDelay")
+	b.sseMaxReconnectDelay = d
+	return b
+}
+
+// WithDeterministicRequestIDs generates client request IDs from a seeded source, for replay tests.
+// It requires WithTestMode.
+func (b *DeliveryClientBuilder) WithDeterministicRequestIDs(seed int64) *DeliveryClientBuilder {
+	b.usage.record("WithDeterministicRequestIDs")
+	b.deterministicIDs = true
+	b.requestIDSeed = seed
+	return b
+}
+
+// WithTestMode allows options that are unsafe in production.
+func (b *DeliveryClientBuilder) WithTestMode(testMode bool) *DeliveryClientBuilder {
+	b.usage.record("WithTestMode")
+	b.testMode = testMode
+	return b
+}
+
+// WithTestRecording records Delivery API calls to a go-vcr compatible cassette, or replays them if
+// the cassette exists. It requires WithTestMode.
+func (b *DeliveryClientBuilder) WithTestRecording(cassettePath string) *DeliveryClientBuilder {
+	b.usage.record("WithTestRecording")
+	b.cassettePath = cassettePath
+	return b
+}
+
+// WithCassetteMode forces recording, replay or passthrough instead of choosing by whether the
+// cassette exists.
+func (b *DeliveryClientBuilder) WithCassetteMode(mode CassetteMode) *DeliveryClientBuilder {
+	b.usage.record("WithCassetteMode")
+	b.cassetteMode = mode
+	return b
+}
+
+// WithOfflineMode serves stored rankings instead of calling the Delivery API, keyed by
+// OfflineRankingKey or RequestFingerprint. Nothing is logged to the Metrics API.
+func (b *DeliveryClientBuilder) WithOfflineMode(rankings map[string][]*delivery.Insertion) *DeliveryClientBuilder {
+	b.usage.record("WithOfflineMode")
+	b.offlineRankings = rankings
+	return b
+}
+
+// WithOfflineModeFromFile loads offline rankings from a JSON file mapping keys to insertions.
+func (b *DeliveryClientBuilder) WithOfflineModeFromFile(path string) *DeliveryClientBuilder {
+	b.usage.record("WithOfflineModeFromFile")
+	b.offlineRankingsPath = path
+	return b
+}
+
+// WithStaleWhileRevalidate caches responses, serving ones older than staleTTL while refreshing them
+// in the background within bgRefreshTimeout.
+func (b *DeliveryClientBuilder) WithStaleWhileRevalidate(staleTTL, bgRefreshTimeout time.Duration) *DeliveryClientBuilder {
+	b.usage.record("WithStaleWhileRevalidate")
+	b.staleTTL = staleTTL
+	b.bgRefreshTimeout = bgRefreshTimeout
+	return b
+}
+
+// WithStaleWhileRevalidateLimits bounds the stale-while-revalidate cache: responses are never
+// served once maxAge old, and the least recently used ones are evicted past maxEntries. They
+// default to ten times the staleTTL and 10000.
+func (b *DeliveryClientBuilder) WithStaleWhileRevalidateLimits(maxAge time.Duration, maxEntries int) *DeliveryClientBuilder {
+	b.usage.record("WithStaleWhileRevalidateLimits")
+	b.staleMaxAge = maxAge
+	b.staleMaxEntries = maxEntries
+	return b
+}
+
+// WithWarmUpConcurrency sets how many WarmCache requests run at once. Defaults to 4.
+func (b *DeliveryClientBuilder) WithWarmUpConcurrency(n int) *DeliveryClientBuilder {
+	b.usage.record("WithWarmUpConcurrency")
+	b.warmUpConcurrency = n
+	return b
+}
+
+// WithRecencyBooster re-ranks every response to favor recently created content.
+func (b *DeliveryClientBuilder) WithRecencyBooster(rb *RecencyBooster) *DeliveryClientBuilder {
+	b.usage.record("WithRecencyBooster")
+	b.recencyBooster = rb
+	return b
+}
+
+// WithComplementaryRecommender attaches items bought with the ranked ones to every response.
+func (b *DeliveryClientBuilder) WithComplementaryRecommender(r *ComplementaryRecommender) *DeliveryClientBuilder {
+	b.usage.record("WithComplementaryRecommender")
+	b.complementaryRecommender = r
+	return b
+}
+
+// WithComplementaryCount sets how many complementary items are attached. Defaults to 5.
+func (b *DeliveryClientBuilder) WithComplementaryCount(k int) *DeliveryClientBuilder {
+	b.usage.record("WithComplementaryCount")
+	b.complementaryCount = k
+	return b
+}
+
+// WithCrossSellHook attaches the hook's recommendations for the top-ranked item to every response.
+func (b *DeliveryClientBuilder) WithCrossSellHook(h CrossSellHook) *DeliveryClientBuilder {
+	b.usage.record("WithCrossSellHook")
+	b.crossSellHook = h
+	return b
+}
+
+// WithUpSellHook attaches the hook's recommendations for the top-ranked item to every response.
+func (b *DeliveryClientBuilder) WithUpSellHook(h UpSellHook) *DeliveryClientBuilder {
+	b.usage.record("WithUpSellHook")
+	b.upSellHook = h
+	return b
+}
+
+// WithLocalReRanker post-processes each response's ranking with r, e.g. SponsoredSlotReRanker.
+func (b *DeliveryClientBuilder) WithLocalReRanker(r LocalReRanker) *DeliveryClientBuilder {
+	b.usage.record("WithLocalReRanker")
+	b.localReRanker = r
+	return b
+}
+
+// WithQueryLogger logs the search query and result count of every Deliver call to ql once delivery
+// returns. User IDs are logged anonymized, if an anonymizer is configured, and omitted for GDPR
+// opted-out users.
+func (b *DeliveryClientBuilder) WithQueryLogger(ql QueryLogger) *DeliveryClientBuilder {
+	b.usage.record("WithQueryLogger")
+	b.queryLogger = ql
+	return b
+}
+
+// WithOIDCAuth authenticates Delivery API calls with the provider's bearer tokens instead of the API
+// key.
+func (b *DeliveryClientBuilder) WithOIDCAuth(provider OIDCTokenProvider) *DeliveryClientBuilder {
+	b.usage.record("WithOIDCAuth")
+	b.oidcProvider = provider
+	return b
+}
+
+// WithRetryBackoff retries Delivery API calls that fail with 429, 502, 503 or 504 up to maxRetries
+// times, waiting as backoff says between them. The delivery timeout applies to each attempt.
+func (b *DeliveryClientBuilder) WithRetryBackoff(backoff *RetryAfterAwareBackoff, maxRetries int) *DeliveryClientBuilder {
+	b.usage.record("WithRetryBackoff")
+	b.retryBackoff = backoff
+	b.maxRetries = maxRetries
+	return b
+}
+
+// WithFieldMask sends only the request fields in paths, such as "user_info.anon_user_id", to the
+// Delivery API. Insertions can only be kept or dropped whole.
+func (b *DeliveryClientBuilder) WithFieldMask(paths ...string) *DeliveryClientBuilder {
+	b.usage.record("WithFieldMask")
+	b.fieldMaskPaths = append(b.fieldMaskPaths, paths...)
+	return b
+}
+
+// WithAutoFieldMask sends only the non-default fields of each request, e.g. dropping empty messages.
+func (b *DeliveryClientBuilder) WithAutoFieldMask(enabled bool) *DeliveryClientBuilder {
+	b.usage.record("WithAutoFieldMask")
+	b.autoFieldMask = enabled
+	return b
+}
+
+// WithABTestPlugin sends each user's variant of experimentKey, as assigned by plugin, in the
+// abVariant request property.
+func (b *DeliveryClientBuilder) WithABTestPlugin(plugin ABTestPlugin, experimentKey string) *DeliveryClientBuilder {
+	b.usage.record("WithABTestPlugin")
+	b.abTestPlugin = plugin
+	b.abTestExperimentKey = experimentKey
+	return b
+}
+
+// WithRolloutGate enables gradually rolled out features, such as RolloutFeatureCache, only for the
+// users gate lets in.
+func (b *DeliveryClientBuilder) WithRolloutGate(gate RolloutGate) *DeliveryClientBuilder {
+	b.usage.record("WithRolloutGate")
+	b.rolloutGate = gate
+	return b
+}
+
+// WithFeatureRolloutPercent sets the percentage of users the rollout gate enables featureKey for.
+// Defaults to 100.
+func (b *DeliveryClientBuilder) WithFeatureRolloutPercent(featureKey string, pct float64) *DeliveryClientBuilder {
+	b.usage.record("WithFeatureRolloutPercent")
+	if b.rolloutPercents == nil {
+		b.rolloutPercents = map[string]float64{}
+	}
+	b.rolloutPercents[featureKey] = pct
+	return b
+}
+
+// WithContentStoreEnrichment fills in each response insertion's properties from store.
+func (b *DeliveryClientBuilder) WithContentStoreEnrichment(store ContentStore) *DeliveryClientBuilder {
+	b.usage.record("WithContentStoreEnrichment")
+	b.contentStore = store
+	return b
+}
+
+// WithContentStoreConcurrency sets how many content store lookups run at once. Defaults to 8.
+func (b *DeliveryClientBuilder) WithContentStoreConcurrency(n int) *DeliveryClientBuilder {
+	b.usage.record("WithContentStoreConcurrency")
+	b.contentStoreConcurrency = n
+	return b
+}
+
+// WithTemporalBoostRules boosts matching insertions while a rule is in effect, ranking them above
+// unboosted ones.
+func (b *DeliveryClientBuilder) WithTemporalBoostRules(rules ...TemporalBoostRule) *DeliveryClientBuilder {
+	b.usage.record("WithTemporalBoostRules")
+	b.temporalBoostRules = append(b.temporalBoostRules, rules...)
+	return b
+}
+
+// WithPopularityProvider adds weight times each insertion's popularity to its retrieval score.
+func (b *DeliveryClientBuilder) WithPopularityProvider(p PopularityProvider, weight float64) *DeliveryClientBuilder {
+	b.usage.record("WithPopularityProvider")
+	b.popularityProvider = p
+	b.popularityWeight = weight
+	return b
+}
+
+// WithPopularityFetchTimeout bounds how long popularity scores may take to fetch. Defaults to 50ms.
+func (b *DeliveryClientBuilder) WithPopularityFetchTimeout(d time.Duration) *DeliveryClientBuilder {
+	b.usage.record("WithPopularityFetchTimeout")
+	b.popularityFetchTimeout = d
+	return b
+}
+
+// WithDiversityFilter drops insertions from responses once MaxPerValue insertions share their
+// propKey property value.
+func (b *DeliveryClientBuilder) WithDiversityFilter(f DiversityFilter, propKey string) *DeliveryClientBuilder {
+	b.usage.record("WithDiversityFilter")
+	b.diversityFilter = &f
+	b.diversityPropKey = propKey
+	return b
+}
+
+// WithFairnessConstraint reorders responses so enough of the top insertions come from the
+// constrained groups.
+func (b *DeliveryClientBuilder) WithFairnessConstraint(c FairnessConstraint) *DeliveryClientBuilder {
+	b.usage.record("WithFairnessConstraint")
+	b.fairnessConstraint = &c
+	return b
+}
+
+// WithInventoryFilter removes out-of-stock insertions from requests before sending.
+func (b *DeliveryClientBuilder) WithInventoryFilter(ic InventoryChecker) *DeliveryClientBuilder {
+	b.usage.record("WithInventoryFilter")
+	b.inventoryChecker = ic
+	return b
+}
+
+// WithPriceTierComputation writes the tier of each insertion's key property to its tiersKey
+// property. See NewPriceTierComputer for breakpoints and labels.
+func (b *DeliveryClientBuilder) WithPriceTierComputation(key string, tiersKey string, breakpoints []float64, labels []string) *DeliveryClientBuilder {
+	b.usage.record("WithPriceTierComputation")
+	b.priceTierKey = key
+	b.priceTiersKey = tiersKey
+	b.priceTierBreakpoints = breakpoints
+	b.priceTierLabels = labels
+	return b
+}
+
+// WithDiscountProperties writes each insertion's discount percentage, computed from its price
+// properties, to its discountPctKey property.
+func (b *DeliveryClientBuilder) WithDiscountProperties(pricePropKey, originalPricePropKey, discountPctKey string) *DeliveryClientBuilder {
+	b.usage.record("WithDiscountProperties")
+	b.discountProperties = &discountProperties{
+		pricePropKey:         pricePropKey,
+		originalPricePropKey: originalPricePropKey,
+		discountPctKey:       discountPctKey,
+	}
+	return b
+}
+
+// WithImpressionTracker records what each response showed and sends the user's recent impressions
+// and clicks in the contextualSignals request property.
+func (b *DeliveryClientBuilder) WithImpressionTracker(t *ImpressionTracker) *DeliveryClientBuilder {
+	b.usage.record("WithImpressionTracker")
+	b.impressionTracker = t
+	return b
+}
+
+// WithQueryExpander sends search queries expanded with up to maxTerms related terms.
+func (b *DeliveryClientBuilder) WithQueryExpander(e *QueryExpander, maxTerms int) *DeliveryClientBuilder {
+	b.usage.record("WithQueryExpander")
+	b.queryExpander = e
+	b.queryExpansionMaxTerms = maxTerms
+	return b
+}
+
+// WithStopWordRemoval strips stop words from search queries before sending.
+func (b *DeliveryClientBuilder) WithStopWordRemoval(swm *StopWordRemover) *DeliveryClientBuilder {
+	b.usage.record("WithStopWordRemoval")
+	b.stopWordRemover = swm
+	return b
+}
+
+// WithProfiling attaches a breakdown of where each call spent its time to the response's Profile.
+func (b *DeliveryClientBuilder) WithProfiling(enabled bool) *DeliveryClientBuilder {
+	b.usage.record("WithProfiling")
+	b.profiling = enabled
+	return b
+}
+
+// WithWarmupOnBuild makes Build call Warmup, failing if the Delivery API is unreachable.
+func (b *DeliveryClientBuilder) WithWarmupOnBuild(enabled bool) *DeliveryClientBuilder {
+	b.usage.record("WithWarmupOnBuild")
+	b.warmupOnBuild = enabled
+	return b
+}
+
+// WithPerformanceWindowSize sets about how many recent calls GetPerformanceReport covers. Defaults to 1000.
+func (b *DeliveryClientBuilder) WithPerformanceWindowSize(n int) *DeliveryClientBuilder {
+	b.usage.record("WithPerformanceWindowSize")
+	b.performanceWindowSize = n
+	return b
+}
+
+// WithStaticHeaders sends headers on every Delivery API request, e.g. service mesh tokens.
+func (b *DeliveryClientBuilder) WithStaticHeaders(headers map[string]string) *DeliveryClientBuilder {
+	b.usage.record("WithStaticHeaders")
+	b.staticHeaders = headers
+	return b
+}
+
+// WithDynamicHeaders sends the headers fn returns for each Delivery API request, e.g. auth tokens.
+// They override static headers on key collision.
+func (b *DeliveryClientBuilder) WithDynamicHeaders(fn func(ctx context.Context) map[string]string) *DeliveryClientBuilder {
+	b.usage.record("WithDynamicHeaders")
+	b.dynamicHeaders = fn
+	return b
+}
+
+// WithCapabilityRefreshInterval sets how long DiscoverCapabilities caches the server's
+// capabilities. Defaults to 5 minutes.
+func (b *DeliveryClientBuilder) WithCapabilityRefreshInterval(d time.Duration) *DeliveryClientBuilder {
+	b.usage.record("WithCapabilityRefreshInterval")
+	b.capabilityRefreshInterval = d
+	return b
+}
+
+// WithCapabilityCheckOnBuild makes Build discover the server's capabilities and warn about
+// options it doesn't support.
+func (b *DeliveryClientBuilder) WithCapabilityCheckOnBuild(enabled bool) *DeliveryClientBuilder {
+	b.usage.record("WithCapabilityCheckOnBuild")
+	b.capabilityCheckOnBuild = enabled
+	return b
+}
+
+// WithDisableUsageTracking turns off counting builder method calls for UsageReport.
+func (b *DeliveryClientBuilder) WithDisableUsageTracking(disabled bool) *DeliveryClientBuilder {
+	b.disableUsageTracking = disabled
+	return b
+}
+
+func (b *DeliveryClientBuilder) Build() (*DeliveryClient, error) {
+	if b.deliveryTimeoutMillis <= 0 {
+		b.deliveryTimeoutMillis = defaultDeliveryTimeoutMillis
+	}
+
+	if b.metricsTimeoutMillis <= 0 {
+		b.metricsTimeoutMillis = defaultMetricsTimeoutMillis
+	}
+
+	if b.maxRequestInsertions <= 0 {
+		b.maxRequestInsertions = defaultMaxRequestInsertions
+	}
+
+	if b.performanceWindowSize <= 0 {
+		b.performanceWindowSize = defaultPerformanceWindowSize
+	}
+
+	if b.capabilityRefreshInterval <= 0 {
+		b.capabilityRefreshInterval = defaultCapabilityRefreshInterval
+	}
+
+	if b.deliveryEndpoint == "" {
+		return nil, errors.New("deliveryEndpoint needs to be specified")
+	}
+
+	if b.sseMaxReconnectDelay <= 0 {
+		b.sseMaxReconnectDelay = defaultSSEMaxReconnectDelay
+	}
+
+	if b.warmUpConcurrency <= 0 {
+		b.warmUpConcurrency = defaultWarmUpConcurrency
+	}
+
+	if b.complementaryCount <= 0 {
+		b.complementaryCount = defaultComplementaryCount
+	}
+
+	if b.contentStoreConcurrency <= 0 {
+		b.contentStoreConcurrency = defaultContentStoreConcurrency
+	}
+
+	if b.popularityFetchTimeout <= 0 {
+		b.popularityFetchTimeout = defaultPopularityFetchTimeout
+	}
+
+	if b.scoringConcurrency <= 0 {
+		b.scoringConcurrency = defaultScoringConcurrency
+	}
+
+	if b.staleMaxAge <= 0 {
+		b.staleMaxAge = defaultStaleMaxAgeMultiple * b.staleTTL
+	}
+
+	if b.staleMaxEntries <= 0 {
+		b.staleMaxEntries = defaultStaleMaxEntries
+	}
+
+	if b.staleMaxAge < b.staleTTL {
+		return nil, errors.New("staleMaxAge must not be less than staleTTL")
+	}
+
+	if b.batchMaxSize <= 0 {
+		b.batchMaxSize = defaultBatchMaxSize
+	}
+
+	if b.batchingWindow > 0 && b.batchDeliveryAPI == nil {
+		return nil, errors.New("batchDeliveryAPI needs to be specified when request batching is enabled")
+	}
+
+	if b.batchingWindow > 0 && b.requiredAPIVersion != "" {
+		return nil, errors.New("requiredAPIVersion cannot be checked on batched calls")
+	}
+
+	if b.adaptiveTimeoutCeiling > 0 {
+		if b.adaptiveTimeoutFloor <= 0 || b.adaptiveTimeoutFloor > b.adaptiveTimeoutTarget || b.adaptiveTimeoutTarget > b.adaptiveTimeoutCeiling {
+			return nil, errors.New("adaptive timeout must satisfy 0 < floor <= target <= ceiling")
+		}
+		if b.adaptiveTimeoutAlpha <= 0 || b.adaptiveTimeoutAlpha > 1 {
+			return nil, errors.New("adaptiveTimeoutAlpha must be in (0, 1]")
+		}
+		b.deliveryTimeoutMillis = b.adaptiveTimeoutCeiling.Milliseconds()
+	}
+
+	if b.rateLimitRPS > 0 && b.rateLimitBurst < 1 {
+		return nil, errors.New("rateLimitBurst must be at least 1")
+	}
+
+	if len(b.allowedPropertyKeys) > 0 && len(b.deniedPropertyKeys) > 0 {
+		return nil, errors.New("allowedPropertyKeys and deniedPropertyKeys cannot both be set")
+	}
+
+	if b.stabilizationThreshold < 0 || b.stabilizationThreshold > 1 {
+		return nil, errors.New("stabilizationThreshold must be in [0, 1]")
+	}
+	if b.priorityQueueSize < 0 {
+		return nil, errors.New("priorityQueueSize must not be negative")
+	}
+	if b.perUserRateLimitRPS > 0 && (b.perUserRateLimitBurst < 1 || b.perUserRateLimitMaxUsers < 1) {
+		return nil, errors.New("perUserRateLimitBurst and perUserRateLimitMaxUsers must be at least 1")
+	}
+
+	if b.deterministicIDs && !b.testMode {
+		return nil, errors.New("deterministic request IDs are only allowed in test mode")
+	}
+
+	if b.cassettePath != "" {
+		if !b.testMode {
+			return nil, errors.New("test recording is only allowed in test mode")
+		}
+		cassette, err := newCassetteTransport(b.cassettePath, b.cassetteMode, nil)
+		if err != nil {
+			return nil, err
+		}
+		b.cassette = cassette
+	}
+
+	if b.offlineRankingsPath != "" {
+		rankings, err := loadOfflineRankings(b.offlineRankingsPath)
+		if err != nil {
+			return nil, err
+		}
+		b.offlineRankings = rankings
+	}
+
+	for _, rule := range b.temporalBoostRules {
+		if rule.ContentIDPattern == nil {
+			return nil, errors.New("temporal boost rules need a ContentIDPattern")
+		}
+	}
+
+	if b.fairnessConstraint != nil && (b.fairnessConstraint.RequiredMinFraction < 0 || b.fairnessConstraint.RequiredMinFraction > 1) {
+		return nil, errors.New("fairness RequiredMinFraction must be in [0, 1]")
+	}
+
+	if len(b.fieldMaskPaths) > 0 {
+		mask, err := fieldmaskpb.New(&delivery.Request{}, b.fieldMaskPaths...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field mask: %v", err)
+		}
+		mask.Normalize()
+		b.fieldMask = mask
+	}
+
+	if b.abTestPlugin != nil && b.abTestExperimentKey == "" {
+		return nil, errors.New("experimentKey needs to be specified with an AB test plugin")
+	}
+
+	for featureKey, pct := range b.rolloutPercents {
+		if pct < 0 || pct > 100 {
+			return nil, fmt.Errorf("rollout percent of %s must be in [0, 100]", featureKey)
+		}
+	}
+
+	if b.priceTierKey != "" {
+		computer, err := NewPriceTierComputer(b.priceTierBreakpoints, b.priceTierLabels)
+		if err != nil {
+			return nil, err
+		}
+		b.priceTierComputer = computer
+	}
+
+	if b.telemetry && b.telemetryEndpoint == "" {
+		return nil, errors.New("telemetryEndpoint needs to be specified when telemetry is enabled")
+	}
+
+	if b.requiredAPIVersion != "" {
+		if _, err := parseAPIVersion(b.requiredAPIVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.dataResidencyRouter != nil && b.regionExtractor == nil {
+		return nil, errors.New("regionExtractor needs to be specified with a data residency router")
+	}
+
+	if b.dataResidencyRouter != nil && b.batchingWindow > 0 {
+		return nil, errors.New("request batching cannot be combined with data residency routing")
+	}
+
+	promoted, deliveryAPI, err := b.buildBackend(b.deliveryEndpoint, b.metricsEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &DeliveryClient{
+		promoted:               promoted,
+		deliveryAPI:            deliveryAPI,
+		organizationID:         b.organizationID,
+		correlationIDExtractor: b.correlationIDExtractor,
+		sseMaxReconnectDelay:   b.sseMaxReconnectDelay,
+		warmUpConcurrency:      b.warmUpConcurrency,
+		capabilities:           &capabilityCache{refreshInterval: b.capabilityRefreshInterval},
+	}
+	if !b.disableUsageTracking {
+		c.usage = b.usage
+	}
+	if b.dataResidencyRouter != nil {
+		c.regionExtractor = b.regionExtractor
+		c.regions = map[string]*deliveryBackend{}
+		for region, endpoints := range b.dataResidencyRouter.regions {
+			regionPromoted, regionAPI, err := b.buildBackend(endpoints.deliveryEndpoint, endpoints.metricsEndpoint)
+			if err != nil {
+				return nil, fmt.Errorf("error building client for region %s: %v", region, err)
+			}
+			c.regions[region] = &deliveryBackend{promoted: regionPromoted, deliveryAPI: regionAPI}
+		}
+	}
+	if b.requiredAPIVersion != "" {
+		c.apiVersion = &apiVersionChecker{requiredVersion: b.requiredAPIVersion}
+	}
+	if b.adaptiveTimeoutCeiling > 0 {
+		c.adaptiveTimeout = newAdaptiveTimeout(b.adaptiveTimeoutTarget, b.adaptiveTimeoutFloor, b.adaptiveTimeoutCeiling, b.adaptiveTimeoutAlpha)
+	}
+	if b.rateLimitRPS > 0 {
+		c.rateLimiter = &outboundRateLimiter{
+			limiter: rate.NewLimiter(rate.Limit(b.rateLimitRPS), b.rateLimitBurst),
+			drop:    b.rateLimitDrop,
+		}
+	}
+	c.userIDAnonymizer = b.userIDAnonymizer
+	if c.userIDAnonymizer == nil && len(b.userIDAnonymizationPepper) > 0 {
+		c.userIDAnonymizer = NewHMACUserIDAnonymizer(b.userIDAnonymizationPepper)
+	}
+	if b.deterministicIDs {
+		c.requestIDs = newDeterministicIDGenerator(b.requestIDSeed)
+	}
+	if b.perUserRateLimitRPS > 0 {
+		c.perUserRateLimiter = NewPerUserRateLimiter(b.perUserRateLimitRPS, b.perUserRateLimitBurst, b.perUserRateLimitMaxUsers)
+	}
+	c.budgetTracker = b.budgetTracker
+	c.eventBus = b.eventBus
+	if b.priorityQueueSize > 0 {
+		c.priorityQueue = newPriorityQueue(b.priorityQueueSize)
+	}
+	if b.batchingWindow > 0 {
+		c.batcher = &requestBatcher{
+			api:             b.batchDeliveryAPI,
+			window:          b.batchingWindow,
+			maxSize:         b.batchMaxSize,
+			timeoutDuration: time.Duration(b.deliveryTimeoutMillis) * time.Millisecond,
+		}
+	}
+	// Explain and DeliverSSE skip the middlewares, so they apply these themselves.
+	c.optedOut = b.gdprOptOut
+	if b.propertyEncryptor != nil && len(b.encryptedPropertyKeys) > 0 {
+		c.propertyEncryption = &propertyEncryption{encryptor: b.propertyEncryptor, keys: b.encryptedPropertyKeys}
+	}
+	if len(b.allowedPropertyKeys) > 0 || len(b.deniedPropertyKeys) > 0 {
+		c.propertyFilter = newPropertyKeyFilter(b.allowedPropertyKeys, b.deniedPropertyKeys)
+	}
+	c.deliver = c.deliverPromoted
+
+	c.performance = newLatencyWindow(b.performanceWindowSize)
+	rollout := &rolloutGating{gate: b.rolloutGate, percents: b.rolloutPercents}
+	middlewares := []deliveryMiddleware{c.performance.middleware}
+	if b.profiling {
+		middlewares = append(middlewares, profiling)
+	}
+	if b.telemetry && !telemetryOptedOut.Load() {
+		c.telemetry = newTelemetryReporter(b.telemetryEndpoint, b.enabledFeatures(), telemetryFlushInterval)
+		middlewares = append(middlewares, c.telemetry.middleware)
+	}
+	if b.requestLinter != nil {
+		middlewares = append(middlewares, b.requestLinter.middleware)
+	}
+	if b.useCaseValidation {
+		middlewares = append(middlewares, NewUseCaseValidator().middleware)
+	}
+	if b.queryLogger != nil {
+		logging := &queryLogging{logger: b.queryLogger, optedOut: b.gdprOptOut, anonymizer: c.userIDAnonymizer}
+		middlewares = append(middlewares, logging.middleware)
+	}
+	if b.stabilizationTTL > 0 {
+		stabilizer := NewPositionStabilizer(b.stabilizationThreshold, b.stabilizationTTL)
+		stabilizer.optedOut = b.gdprOptOut
+		middlewares = append(middlewares, stabilizer.middleware)
+	}
+	if b.contentIDValidator != nil {
+		validator := &contentIDValidator{pattern: b.contentIDValidator, mode: b.contentIDValidationMode}
+		middlewares = append(middlewares, validator.middleware)
+	}
+	if b.contentStore != nil {
+		enrichment := &contentStoreEnrichment{store: b.contentStore, concurrency: b.contentStoreConcurrency}
+		middlewares = append(middlewares, enrichment.middleware)
+	}
+	if b.localReRanker != nil {
+		reRanking := &localReRanking{reRanker: b.localReRanker}
+		middlewares = append(middlewares, reRanking.middleware)
+	}
+	if b.crossSellHook != nil || b.upSellHook != nil {
+		hooks := &salesHooks{crossSell: b.crossSellHook, upSell: b.upSellHook}
+		middlewares = append(middlewares, hooks.middleware)
+	}
+	if b.complementaryRecommender != nil {
+		complementary := &complementaryRecommendations{recommender: b.complementaryRecommender, k: b.complementaryCount}
+		middlewares = append(middlewares, complementary.middleware)
+	}
+	if b.impressionTracker != nil {
+		tracking := &impressionTracking{tracker: b.impressionTracker, optedOut: b.gdprOptOut}
+		middlewares = append(middlewares, tracking.middleware)
+	}
+	if b.fairnessConstraint != nil {
+		fairness := &fairnessEnforcement{constraint: *b.fairnessConstraint}
+		middlewares = append(middlewares, fairness.middleware)
+	}
+	filtering := &categoryFiltering{taxonomy: b.categoryTaxonomy}
+	middlewares = append(middlewares, filtering.middleware, attachBundles, attachInsertionGroups)
+	if b.gdprOptOut != nil {
+		optOut := &gdprOptOut{optedOut: b.gdprOptOut}
+		middlewares = append(middlewares, optOut.middleware)
+	}
+	// Inside the opt-out, so opted-out users aren't bucketed into experiments or rollouts nor have
+	// their IDs sent to the experimentation framework or rollout gate.
+	if b.diversityFilter != nil {
+		diversity := &diversityFiltering{filter: *b.diversityFilter, propKey: b.diversityPropKey}
+		middlewares = append(middlewares, rollout.gated(RolloutFeatureDiversityFilter, diversity.middleware))
+	}
+	if b.recencyBooster != nil {
+		boosting := &recencyBoosting{booster: b.recencyBooster}
+		middlewares = append(middlewares, rollout.gated(RolloutFeatureRecencyBoost, boosting.middleware))
+	}
+	if b.featureStore != nil {
+		middlewares = append(middlewares, b.featureStore.middleware)
+	}
+	if b.abTestPlugin != nil {
+		assignment := &abTestAssignment{plugin: b.abTestPlugin, experimentKey: b.abTestExperimentKey}
+		middlewares = append(middlewares, assignment.middleware)
+	}
+	if b.inventoryChecker != nil {
+		c.inventoryFilter = &inventoryFilter{checker: b.inventoryChecker}
+		middlewares = append(middlewares, c.inventoryFilter.middleware)
+	}
+	if b.deduplicationWindow > 0 {
+		c.deduplicator = newRequestDeduplicator(b.deduplicationWindow)
+		middlewares = append(middlewares, c.deduplicator.middleware)
+	}
+	if b.staleTTL > 0 {
+		c.staleWhileRevalidate = newStaleWhileRevalidateCache(b.staleTTL, b.bgRefreshTimeout, b.staleMaxAge, b.staleMaxEntries)
+		middlewares = append(middlewares, rollout.gated(RolloutFeatureCache, c.staleWhileRevalidate.middleware))
+	}
+	middlewares = append(middlewares, resolveLazyProperties)
+	if b.contentScorer != nil {
+		scoring := &contentScoring{scorer: b.contentScorer, concurrency: b.scoringConcurrency}
+		middlewares = append(middlewares, scoring.middleware)
+	}
+	if len(b.temporalBoostRules) > 0 {
+		c.temporalBoosts = &temporalBoosts{rules: b.temporalBoostRules, now: time.Now}
+		middlewares = append(middlewares, c.temporalBoosts.middleware)
+	}
+	// Inside the temporal boosts, so it sees their multipliers as well as the request builder's.
+	middlewares = append(middlewares, applyBoostMultipliers)
+	if b.popularityProvider != nil {
+		popularity := &popularityBoost{provider: b.popularityProvider, weight: b.popularityWeight, timeout: b.popularityFetchTimeout}
+		middlewares = append(middlewares, popularity.middleware)
+	}
+	if b.stopWordRemover != nil {
+		removal := &stopWordRemoval{remover: b.stopWordRemover}
+		middlewares = append(middlewares, removal.middleware)
+	}
+	if b.spellCorrector != nil {
+		correction := &spellCorrection{corrector: b.spellCorrector}
+		middlewares = append(middlewares, correction.middleware)
+	}
+	if b.synonymExpander != nil {
+		expansion := &synonymExpansion{expander: b.synonymExpander}
+		middlewares = append(middlewares, expansion.middleware)
+	}
+	if b.queryExpander != nil {
+		expansion := &queryExpansion{expander: b.queryExpander, maxTerms: b.queryExpansionMaxTerms}
+		middlewares = append(middlewares, expansion.middleware)
+	}
+	if b.discountProperties != nil {
+		middlewares = append(middlewares, b.discountProperties.middleware)
+	}
+	if b.currencyNormalizer != nil {
+		normalization := &currencyNormalization{normalizer: *b.currencyNormalizer, priceKey: b.priceKey}
+		middlewares = append(middlewares, normalization.middleware)
+	}
+	if b.priceTierComputer != nil {
+		tiering := &priceTiering{computer: b.priceTierComputer, key: b.priceTierKey, tiersKey: b.priceTiersKey}
+		middlewares = append(middlewares, tiering.middleware)
+	}
+	if b.confidenceThreshold > 0 {
+		fallback := &confidenceFallback{threshold: b.confidenceThreshold}
+		middlewares = append(middlewares, fallback.middleware)
+	}
+	if c.propertyEncryption != nil {
+		middlewares = append(middlewares, c.propertyEncryption.middleware)
+	}
+	if b.offlineRankings != nil {
+		offline := &offlineMode{rankings: b.offlineRankings}
+		middlewares = append(middlewares, offline.middleware)
+	}
+	// Last, so properties added by every other feature are filtered before sending.
+	if c.propertyFilter != nil {
+		middlewares = append(middlewares, c.propertyFilter.middleware)
+	}
+	c.use(middlewares...)
+
+	if b.warmupOnBuild {
+		if err := c.Warmup(context.Background()); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if b.capabilityCheckOnBuild {
+		capabilities, err := c.DiscoverCapabilities(context.Background())
+		if err != nil {
+			log.Printf("WARN: error discovering Delivery API capabilities: %v\n", err)
+		} else {
+			b.warnOnCapabilityConflicts(capabilities)
+		}
+	}
+	return c, nil
+}
+
+// buildBackend creates the SDK client and Delivery API for one pair of endpoints.
+func (b *DeliveryClientBuilder) buildBackend(deliveryEndpoint, metricsEndpoint string) (*client.PromotedDeliveryClient, *deliveryAPI, error) {
+	deliveryAPI, err := newDeliveryAPI(
+		deliveryEndpoint,
+		b.deliveryAPIKey,
+		b.deliveryTimeoutMillis,
+		b.maxRequestInsertions,
+		b.acceptsGzip,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, value := range b.staticHeaders {
+		deliveryAPI.headers.Set(key, value)
+	}
+	deliveryAPI.dynamicHeaders = b.dynamicHeaders
+	deliveryAPI.fieldMask = b.fieldMask
+	deliveryAPI.autoFieldMask = b.autoFieldMask
+	deliveryAPI.backoff = b.retryBackoff
+	deliveryAPI.maxRetries = b.maxRetries
+	deliveryAPI.eventBus = b.eventBus
+	if b.organizationID != "" {
+		deliveryAPI.headers.Set(organizationIDHeader, b.organizationID)
+	}
+	if b.requiredAPIVersion != "" {
+		deliveryAPI.headers.Set(apiVersionHeader, b.requiredAPIVersion)
+	}
+	if b.cassette != nil {
+		deliveryAPI.httpClient.Transport = b.cassette
+	}
+	if b.modelVersion != "" {
+		deliveryAPI.headers.Set(modelVersionHeader, b.modelVersion)
+	}
+	if b.oidcProvider != nil {
+		next := deliveryAPI.httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		deliveryAPI.httpClient.Transport = &oidcTransport{provider: b.oidcProvider, next: next}
+	}
+	if b.dryRunEndpoint != "" {
+		deliveryAPI.dryRunHTTPEndpoint = b.dryRunEndpoint
+	}
+	if b.explainEndpoint != "" {
+		deliveryAPI.explainHTTPEndpoint = b.explainEndpoint
+	}
+	if b.explainTimeout > 0 {
+		deliveryAPI.explainTimeout = b.explainTimeout
+	}
+
+	promoted, err := client.NewPromotedDeliveryClientBuilder().
+		WithDeliveryEndpoint(deliveryEndpoint).
+		WithDeliveryAPIKey(b.deliveryAPIKey).
+		WithDeliveryTimeoutMillis(b.deliveryTimeoutMillis).
+		WithMetricsEndpoint(metricsEndpoint).
+		WithMetricsAPIKey(b.metricsAPIKey).
+		WithMetricsTimeoutMillis(b.metricsTimeoutMillis).
+		WithMaxRequestInsertions(b.maxRequestInsertions).
+		WithApplyTreatmentChecker(b.applyTreatmentChecker).
+		WithSampler(b.sampler).
+		WithShadowTrafficDeliveryRate(b.shadowTrafficDeliveryRate).
+		WithPerformChecks(b.performChecks).
+		WithBlockingShadowTraffic(b.blockingShadowTraffic).
+		WithAcceptsGzip(b.acceptsGzip).
+		WithAPIFactory(&apiFactory{deliveryAPI: deliveryAPI}).
+		Build()
+	if err != nil {
+		return nil, nil, err
+	}
+	return promoted, deliveryAPI, nil
+}
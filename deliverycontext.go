@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const anonUserIDHeader = "X-Anon-ID"
+const useCaseHeader = "X-Use-Case"
+
+// Context keys for values stored by DeliveryContextMiddleware.
+type userIDKey struct{}
+type anonUserIDKey struct{}
+type useCaseKey struct{}
+
+// DeliveryContextMiddleware stores the X-User-ID, X-Anon-ID, X-Request-ID and X-Use-Case headers
+// in the request context for building delivery requests downstream. X-Request-ID is stored as the
+// correlation ID, so it works with CorrelationIDFromContext.
+func DeliveryContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if userID := r.Header.Get(UserIDHeader); userID != "" {
+			ctx = context.WithValue(ctx, userIDKey{}, userID)
+		}
+		if anonUserID := r.Header.Get(anonUserIDHeader); anonUserID != "" {
+			ctx = context.WithValue(ctx, anonUserIDKey{}, anonUserID)
+		}
+		if requestID := r.Header.Get(correlationIDHeader); requestID != "" {
+			ctx = ContextWithCorrelationID(ctx, requestID)
+		}
+		if useCase, err := ParseUseCase(r.Header.Get(useCaseHeader)); err == nil {
+			ctx = context.WithValue(ctx, useCaseKey{}, useCase)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// UserIDFromContext returns the user ID stored by DeliveryContextMiddleware.
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
+// AnonIDFromContext returns the anonymous user ID stored by DeliveryContextMiddleware.
+func AnonIDFromContext(ctx context.Context) string {
+	anonUserID, _ := ctx.Value(anonUserIDKey{}).(string)
+	return anonUserID
+}
+
+// UseCaseFromContext returns the use case stored by DeliveryContextMiddleware, or
+// UNKNOWN_USE_CASE if the header was missing or invalid.
+func UseCaseFromContext(ctx context.Context) delivery.UseCase {
+	useCase, _ := ctx.Value(useCaseKey{}).(delivery.UseCase)
+	return useCase
+}
+
+// UserInfoFromContext builds user info from the IDs stored by DeliveryContextMiddleware, for
+// DeliveryRequestBuilder.WithUserInfo.
+func UserInfoFromContext(ctx context.Context) *common.UserInfo {
+	return &common.UserInfo{
+		UserId:     UserIDFromContext(ctx),
+		AnonUserId: AnonIDFromContext(ctx),
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// serveWithDeliveryContext runs r through DeliveryContextMiddleware and returns the handler's
+// request context.
+func serveWithDeliveryContext(t *testing.T, r *http.Request) context.Context {
+	t.Helper()
+	var ctx context.Context
+	handler := DeliveryContextMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if ctx == nil {
+		t.Fatal("handler wasn't called")
+	}
+	return ctx
+}
+
+func TestDeliveryContextMiddleware(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/products", nil)
+	r.Header.Set("X-User-ID", "user-1")
+	r.Header.Set("X-Anon-ID", "anon-1")
+	r.Header.Set("X-Request-ID", "trace-1")
+	r.Header.Set("X-Use-Case", "search")
+
+	ctx := serveWithDeliveryContext(t, r)
+	if got := UserIDFromContext(ctx); got != "user-1" {
+		t.Errorf("got user ID %q, want user-1", got)
+	}
+	if got := AnonIDFromContext(ctx); got != "anon-1" {
+		t.Errorf("got anonymous ID %q, want anon-1", got)
+	}
+	if got := CorrelationIDFromContext(ctx); got != "trace-1" {
+		t.Errorf("got correlation ID %q, want trace-1", got)
+	}
+	if got := UseCaseFromContext(ctx); got != delivery.UseCase_SEARCH {
+		t.Errorf("got use case %v, want SEARCH", got)
+	}
+	if got := UserInfoFromContext(ctx); got.GetUserId() != "user-1" || got.GetAnonUserId() != "anon-1" {
+		t.Errorf("got user info %v, want user-1 and anon-1", got)
+	}
+}
+
+func TestDeliveryContextMiddlewareWithoutHeaders(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/products", nil)
+	r.Header.Set("X-Use-Case", "not-a-use-case")
+
+	ctx := serveWithDeliveryContext(t, r)
+	if UserIDFromContext(ctx) != "" || AnonIDFromContext(ctx) != "" || CorrelationIDFromContext(ctx) != "" {
+		t.Error("got IDs in the context without headers")
+	}
+	if got := UseCaseFromContext(ctx); got != delivery.UseCase_UNKNOWN_USE_CASE {
+		t.Errorf("got use case %v for an invalid header, want UNKNOWN_USE_CASE", got)
+	}
+}
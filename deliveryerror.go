@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DeliveryError is a failed Delivery API call, with what's needed to retry it or file a support
+// ticket. Use errors.As to get it from the returned error.
+type DeliveryError struct {
+	// StatusCode is the HTTP status, or 0 if no response was received.
+	StatusCode int
+
+	// RequestID is the server's ID for the call, if the response had one in its X-Request-ID header.
+	RequestID string
+
+	// ClientRequestID is the request's client request ID, for correlating with logs.
+	ClientRequestID string
+
+	Message string
+
+	// RetryAfter is the wait the server asked for, if any.
+	RetryAfter time.Duration
+
+	// IsRetriable is set for overload and outage statuses and for timeouts.
+	IsRetriable bool
+
+	header http.Header
+	err    error
+}
+
+// newDeliveryError wraps err, if any, with the call's context. header is the response's, if one was
+// received.
+func newDeliveryError(clientRequestID string, statusCode int, header http.Header, message string, err error) *DeliveryError {
+	e := &DeliveryError{
+		StatusCode:      statusCode,
+		RequestID:       header.Get(correlationIDHeader),
+		ClientRequestID: clientRequestID,
+		Message:         message,
+		IsRetriable:     retryableStatusCodes[statusCode] || isTimeout(err),
+		header:          header,
+		err:             err,
+	}
+	if retryAfter, ok := parseRetryAfter(header.Get("Retry-After"), time.Now()); ok {
+		e.RetryAfter = retryAfter
+	}
+	return e
+}
+
+func (e *DeliveryError) Error() string {
+	msg := e.Message
+	if e.err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.err)
+	}
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s; statusCode=%d", msg, e.StatusCode)
+	}
+	if e.RequestID != "" {
+		msg = fmt.Sprintf("%s; requestId=%s", msg, e.RequestID)
+	}
+	if e.ClientRequestID != "" {
+		msg = fmt.Sprintf("%s; clientRequestId=%s", msg, e.ClientRequestID)
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error, e.g. context.DeadlineExceeded.
+func (e *DeliveryError) Unwrap() error {
+	return e.err
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
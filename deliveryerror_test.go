@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestDeliveryErrorScenarios(t *testing.T) {
+	tests := []struct {
+		name          string
+		respond       func(w http.ResponseWriter, req *delivery.Request)
+		wantStatus    int
+		wantRetriable bool
+		wantTimeout   bool
+	}{
+		{
+			name: "timeout",
+			respond: func(w http.ResponseWriter, req *delivery.Request) {
+				time.Sleep(200 * time.Millisecond)
+				writeDeliveryResponse(t, w, echoResponse(req))
+			},
+			wantRetriable: true,
+			wantTimeout:   true,
+		},
+		{
+			name: "4xx",
+			respond: func(w http.ResponseWriter, req *delivery.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "5xx",
+			respond: func(w http.ResponseWriter, req *delivery.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantStatus:    http.StatusServiceUnavailable,
+			wantRetriable: true,
+		},
+		{
+			name: "parse error",
+			respond: func(w http.ResponseWriter, req *delivery.Request) {
+				w.Write([]byte("not a delivery response"))
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			api := newFakeAPI(t)
+			api.setRespond(tc.respond)
+			c := buildTestClient(t, newTestClientBuilder(t, api).WithDeliveryTimeoutMillis(50))
+
+			req := newTestDeliveryRequest(t, "a")
+			resp, err := c.Deliver(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Deliver failed: %v", err)
+			}
+			var de *DeliveryError
+			if !errors.As(resp.APIError, &de) {
+				t.Fatalf("got API error %v, want a *DeliveryError", resp.APIError)
+			}
+			if de.StatusCode != tc.wantStatus || de.IsRetriable != tc.wantRetriable {
+				t.Errorf("got status %d and retriable %v, want %d and %v", de.StatusCode, de.IsRetriable, tc.wantStatus, tc.wantRetriable)
+			}
+			if de.ClientRequestID == "" || de.ClientRequestID != req.Request.GetClientRequestId() {
+				t.Errorf("got client request ID %q, want the request's %q", de.ClientRequestID, req.Request.GetClientRequestId())
+			}
+			if errors.Is(de, context.DeadlineExceeded) != tc.wantTimeout {
+				t.Errorf("errors.Is(%v, context.DeadlineExceeded) = %v, want %v", de, !tc.wantTimeout, tc.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestDeliveryErrorCarriesResponseHeaders(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setResponseHeader(correlationIDHeader, "server-123")
+	api.setResponseHeader("Retry-After", "7")
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	var de *DeliveryError
+	if !errors.As(resp.APIError, &de) {
+		t.Fatalf("got API error %v, want a *DeliveryError", resp.APIError)
+	}
+	if de.RequestID != "server-123" || de.RetryAfter != 7*time.Second || !de.IsRetriable {
+		t.Errorf("got request ID %q, retry after %v and retriable %v, want server-123, 7s and true", de.RequestID, de.RetryAfter, de.IsRetriable)
+	}
+	for _, part := range []string{"statusCode=429", "requestId=server-123", "clientRequestId=" + de.ClientRequestID} {
+		if !strings.Contains(de.Error(), part) {
+			t.Errorf("error %q is missing %q", de.Error(), part)
+		}
+	}
+}
+
+func TestSuccessfulDeliveryHasNoAPIError(t *testing.T) {
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)))
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if resp.APIError != nil {
+		t.Errorf("got API error %v from a successful call", resp.APIError)
+	}
+}
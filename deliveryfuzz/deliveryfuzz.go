@@ -0,0 +1,120 @@
+// Package deliveryfuzz generates arbitrary but structurally valid delivery requests for
+// property-based tests with rapid, and fuzzes delivery clients with them.
+package deliveryfuzz
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/types/known/structpb"
+	"pgregory.net/rapid"
+)
+
+const maxInsertions = 50
+const maxProperties = 8
+const maxPagingSize = 100
+
+// DeliverFunc delivers one generated request. It adapts clients whose request type wraps the proto,
+// such as the example's DeliveryClient, which this package can't import.
+type DeliverFunc func(ctx context.Context, req *delivery.Request) error
+
+// useCases are the known use cases other than UNKNOWN_USE_CASE, in a stable order so failing
+// draws replay the same way.
+var useCases = func() []delivery.UseCase {
+	var useCases []delivery.UseCase
+	for _, v := range delivery.UseCase_value {
+		if delivery.UseCase(v) != delivery.UseCase_UNKNOWN_USE_CASE {
+			useCases = append(useCases, delivery.UseCase(v))
+		}
+	}
+	sort.Slice(useCases, func(i, j int) bool { return useCases[i] < useCases[j] })
+	return useCases
+}()
+
+// ArbitraryDeliveryRequest generates requests with an anonymous user ID, a known use case, a
+// positive paging size and insertions with distinct content IDs. Request and insertion IDs are left
+// for the SDK to set.
+func ArbitraryDeliveryRequest() *rapid.Generator[*delivery.Request] {
+	return rapid.Custom(func(t *rapid.T) *delivery.Request {
+		req := &delivery.Request{
+			UserInfo: &common.UserInfo{
+				AnonUserId: rapid.StringMatching(`[a-zA-Z0-9-]{1,36}`).Draw(t, "anonUserId"),
+			},
+			UseCase: rapid.SampledFrom(useCases).Draw(t, "useCase"),
+			Paging: &delivery.Paging{
+				Size: rapid.Int32Range(1, maxPagingSize).Draw(t, "pagingSize"),
+			},
+			Insertion: rapid.SliceOfNDistinct(ArbitraryInsertion(), 0, maxInsertions, (*delivery.Insertion).GetContentId).Draw(t, "insertions"),
+		}
+		if rapid.Bool().Draw(t, "hasUserId") {
+			req.UserInfo.UserId = rapid.StringMatching(`[a-zA-Z0-9-]{1,36}`).Draw(t, "userId")
+		}
+		if req.UseCase == delivery.UseCase_SEARCH || rapid.Bool().Draw(t, "hasSearchQuery") {
+			req.SearchQuery = rapid.String().Draw(t, "searchQuery")
+		}
+		if rapid.Bool().Draw(t, "hasProperties") {
+			req.Properties = ArbitraryProperties().Draw(t, "properties")
+		}
+		return req
+	})
+}
+
+// ArbitraryInsertion generates insertions with a non-empty content ID and optional retrieval rank,
+// retrieval score and properties.
+func ArbitraryInsertion() *rapid.Generator[*delivery.Insertion] {
+	return rapid.Custom(func(t *rapid.T) *delivery.Insertion {
+		ins := &delivery.Insertion{
+			ContentId: rapid.StringMatching(`[a-zA-Z0-9_-]{1,32}`).Draw(t, "contentId"),
+		}
+		if rapid.Bool().Draw(t, "hasRetrievalRank") {
+			rank := rapid.Uint64Range(0, maxInsertions).Draw(t, "retrievalRank")
+			ins.RetrievalRank = &rank
+		}
+		if rapid.Bool().Draw(t, "hasRetrievalScore") {
+			score := rapid.Float32Range(0, 1).Draw(t, "retrievalScore")
+			ins.RetrievalScore = &score
+		}
+		if rapid.Bool().Draw(t, "hasProperties") {
+			ins.Properties = ArbitraryProperties().Draw(t, "properties")
+		}
+		return ins
+	})
+}
+
+// ArbitraryProperties generates struct properties of strings, finite numbers and booleans.
+func ArbitraryProperties() *rapid.Generator[*common.Properties] {
+	value := rapid.OneOf(
+		rapid.Custom(func(t *rapid.T) *structpb.Value {
+			return structpb.NewStringValue(rapid.String().Draw(t, "string"))
+		}),
+		rapid.Custom(func(t *rapid.T) *structpb.Value {
+			return structpb.NewNumberValue(rapid.Float64Range(-1e9, 1e9).Draw(t, "number"))
+		}),
+		rapid.Custom(func(t *rapid.T) *structpb.Value {
+			return structpb.NewBoolValue(rapid.Bool().Draw(t, "bool"))
+		}),
+	)
+	return rapid.Custom(func(t *rapid.T) *common.Properties {
+		fields := rapid.MapOfN(rapid.StringMatching(`[a-zA-Z][a-zA-Z0-9_]{0,15}`), value, 0, maxProperties).Draw(t, "fields")
+		return &common.Properties{
+			StructField: &common.Properties_Struct{Struct: &structpb.Struct{Fields: fields}},
+		}
+	})
+}
+
+// FuzzDeliver fuzzes deliver with arbitrary valid requests. It fails if deliver panics or returns an
+// error.
+func FuzzDeliver(f *testing.F, deliver DeliverFunc) {
+	// rapid skips inputs too short to draw a request from, so seed with a long zero input, which
+	// draws the smallest request.
+	f.Add(make([]byte, 1024))
+	f.Fuzz(rapid.MakeFuzz(func(t *rapid.T) {
+		req := ArbitraryDeliveryRequest().Draw(t, "request")
+		if err := deliver(context.Background(), req); err != nil {
+			t.Fatalf("error delivering %v: %v", req, err)
+		}
+	}))
+}
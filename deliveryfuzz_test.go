@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/promotedai/promoted-go-delivery-client-example/deliveryfuzz"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"pgregory.net/rapid"
+)
+
+// fuzzDeliver adapts c to deliveryfuzz, failing responses that rank content the request didn't
+// have or rank content twice.
+func fuzzDeliver(c DeliveryClientInterface) deliveryfuzz.DeliverFunc {
+	return func(ctx context.Context, req *delivery.Request) error {
+		deliveryReq, err := NewDeliveryRequestBuilder(req).Build()
+		if err != nil {
+			return fmt.Errorf("error building request: %v", err)
+		}
+		resp, err := c.Deliver(ctx, deliveryReq)
+		if err != nil {
+			return err
+		}
+		requested := map[string]bool{}
+		for _, ins := range req.GetInsertion() {
+			requested[ins.GetContentId()] = true
+		}
+		for _, ins := range resp.Response.GetInsertion() {
+			if !requested[ins.GetContentId()] {
+				return fmt.Errorf("response ranked %q, which wasn't requested or was ranked twice", ins.GetContentId())
+			}
+			delete(requested, ins.GetContentId())
+		}
+		return nil
+	}
+}
+
+func FuzzDeliver(f *testing.F) {
+	api := newFakeAPI(f)
+	c := buildTestClient(f, newTestClientBuilder(f, api).
+		WithDiversityFilter(DiversityFilter{Attribute: "brand", MaxPerValue: 2}, "brand").
+		WithRequestLinter(NewRequestLinter()))
+	deliveryfuzz.FuzzDeliver(f, fuzzDeliver(c))
+}
+
+func FuzzDeliverWithFakeClient(f *testing.F) {
+	deliveryfuzz.FuzzDeliver(f, fuzzDeliver(&fakeDeliveryClient{name: "fake"}))
+}
+
+func TestArbitraryDeliveryRequestIsValid(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		req := deliveryfuzz.ArbitraryDeliveryRequest().Draw(t, "request")
+		if req.GetUserInfo().GetAnonUserId() == "" {
+			t.Fatal("generated a request without an anonymous user ID")
+		}
+		if req.GetUseCase() == delivery.UseCase_UNKNOWN_USE_CASE {
+			t.Fatal("generated a request with an unknown use case")
+		}
+		if req.GetPaging().GetSize() <= 0 {
+			t.Fatalf("generated paging size %d, want positive", req.GetPaging().GetSize())
+		}
+		seen := map[string]bool{}
+		for _, ins := range req.GetInsertion() {
+			if ins.GetContentId() == "" || seen[ins.GetContentId()] {
+				t.Fatalf("generated empty or duplicate content ID %q", ins.GetContentId())
+			}
+			seen[ins.GetContentId()] = true
+		}
+	})
+}
+
+func TestDeliverHandlesArbitraryRequests(t *testing.T) {
+	api := newFakeAPI(t)
+	deliver := fuzzDeliver(buildTestClient(t, newTestClientBuilder(t, api)))
+	rapid.Check(t, func(t *rapid.T) {
+		req := deliveryfuzz.ArbitraryDeliveryRequest().Draw(t, "request")
+		if err := deliver(context.Background(), req); err != nil {
+			t.Fatalf("error delivering %v: %v", req, err)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const deliveryHandlerPath = "/delivery"
+const defaultHandlerTimeout = 500 * time.Millisecond
+const defaultHandlerMaxBodyBytes = 1 << 20
+
+// DeliveryHandler serves delivery over HTTP, e.g. as a thin proxy endpoint of a backend for
+// frontend. It accepts POST /delivery with a JSON delivery.Request and responds with the JSON
+// delivery.Response.
+type DeliveryHandler struct {
+	client       DeliveryClientInterface
+	timeout      time.Duration
+	apiKey       string
+	maxBodyBytes int64
+	allowOrigin  string
+}
+
+// NewDeliveryHandler is a factory method for DeliveryHandler. By default it allows any origin and
+// doesn't check API keys.
+func NewDeliveryHandler(client DeliveryClientInterface) *DeliveryHandler {
+	return &DeliveryHandler{
+		client:       client,
+		timeout:      defaultHandlerTimeout,
+		maxBodyBytes: defaultHandlerMaxBodyBytes,
+		allowOrigin:  "*",
+	}
+}
+
+// WithHandlerTimeout bounds each Deliver call. The default is 500ms.
+func (h *DeliveryHandler) WithHandlerTimeout(d time.Duration) *DeliveryHandler {
+	h.timeout = d
+	return h
+}
+
+// WithHandlerAPIKey rejects requests whose X-API-Key header isn't key.
+func (h *DeliveryHandler) WithHandlerAPIKey(key string) *DeliveryHandler {
+	h.apiKey = key
+	return h
+}
+
+// WithHandlerMaxBodyBytes rejects request bodies larger than n bytes. The default is 1MiB.
+func (h *DeliveryHandler) WithHandlerMaxBodyBytes(n int64) *DeliveryHandler {
+	h.maxBodyBytes = n
+	return h
+}
+
+// WithHandlerAllowOrigin sets the CORS allowed origin. The default is "*".
+func (h *DeliveryHandler) WithHandlerAllowOrigin(origin string) *DeliveryHandler {
+	h.allowOrigin = origin
+	return h
+}
+
+func (h *DeliveryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", h.allowOrigin)
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
+	if r.URL.Path != deliveryHandlerPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.apiKey != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-API-Key")), []byte(h.apiKey)) != 1 {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		return
+	}
+	var request delivery.Request
+	if err := protojson.Unmarshal(body, &request); err != nil {
+		http.Error(w, "invalid delivery request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := NewDeliveryRequestBuilder(&request).Build()
+	if err != nil {
+		http.Error(w, "invalid delivery request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+	resp, err := h.client.Deliver(ctx, req)
+	if err != nil {
+		log.Printf("Error serving delivery request: %v\n", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, "delivery timed out", http.StatusGatewayTimeout)
+			return
+		}
+		http.Error(w, "delivery failed", http.StatusBadGateway)
+		return
+	}
+	out, err := protojson.Marshal(resp.Response)
+	if err != nil {
+		http.Error(w, "error marshaling delivery response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
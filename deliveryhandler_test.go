@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const handlerTestBody = `{"userInfo": {"anonUserId": "anon-1"}, "insertion": [{"contentId": "a"}, {"contentId": "b"}]}`
+
+// blockingDeliveryClient waits for its context to end.
+type blockingDeliveryClient struct{}
+
+func (blockingDeliveryClient) Deliver(ctx context.Context, _ *DeliveryRequest) (*DeliveryResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func serveDelivery(h http.Handler, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestDeliveryHandlerServesJSON(t *testing.T) {
+	c := &fakeDeliveryClient{name: "request-1"}
+	w := serveDelivery(NewDeliveryHandler(c), http.MethodPost, "/delivery", handlerTestBody, nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d (%s), want 200", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("got content type %q, want application/json", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("got allowed origin %q, want *", got)
+	}
+	var resp delivery.Response
+	if err := protojson.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("error parsing response %q: %v", w.Body, err)
+	}
+	if resp.GetRequestId() != "request-1" || !reflect.DeepEqual(insertionContentIDsOf(resp.GetInsertion()), []string{"a", "b"}) {
+		t.Errorf("got response %v, want request-1 with a and b", &resp)
+	}
+	if c.calls() != 1 || c.requests[0].Request.GetUserInfo().GetAnonUserId() != "anon-1" {
+		t.Errorf("got %d Deliver calls, want one for anon-1", c.calls())
+	}
+}
+
+func TestDeliveryHandlerStatusCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler *DeliveryHandler
+		method  string
+		path    string
+		body    string
+		header  http.Header
+		want    int
+	}{
+		{"preflight", NewDeliveryHandler(&fakeDeliveryClient{}), http.MethodOptions, "/delivery", "", nil, http.StatusNoContent},
+		{"wrong path", NewDeliveryHandler(&fakeDeliveryClient{}), http.MethodPost, "/other", handlerTestBody, nil, http.StatusNotFound},
+		{"wrong method", NewDeliveryHandler(&fakeDeliveryClient{}), http.MethodGet, "/delivery", "", nil, http.StatusMethodNotAllowed},
+		{"missing API key", NewDeliveryHandler(&fakeDeliveryClient{}).WithHandlerAPIKey("secret"), http.MethodPost, "/delivery", handlerTestBody, nil, http.StatusUnauthorized},
+		{"wrong API key", NewDeliveryHandler(&fakeDeliveryClient{}).WithHandlerAPIKey("secret"), http.MethodPost, "/delivery", handlerTestBody, http.Header{"X-Api-Key": {"guess"}}, http.StatusUnauthorized},
+		{"API key", NewDeliveryHandler(&fakeDeliveryClient{}).WithHandlerAPIKey("secret"), http.MethodPost, "/delivery", handlerTestBody, http.Header{"X-Api-Key": {"secret"}}, http.StatusOK},
+		{"body too large", NewDeliveryHandler(&fakeDeliveryClient{}).WithHandlerMaxBodyBytes(10), http.MethodPost, "/delivery", handlerTestBody, nil, http.StatusRequestEntityTooLarge},
+		{"invalid JSON", NewDeliveryHandler(&fakeDeliveryClient{}), http.MethodPost, "/delivery", "{", nil, http.StatusBadRequest},
+		{"delivery failure", NewDeliveryHandler(&fakeDeliveryClient{err: errors.New("down")}), http.MethodPost, "/delivery", handlerTestBody, nil, http.StatusBadGateway},
+		{"timeout", NewDeliveryHandler(blockingDeliveryClient{}).WithHandlerTimeout(20 * time.Millisecond), http.MethodPost, "/delivery", handlerTestBody, nil, http.StatusGatewayTimeout},
+	}
+	for _, tc := range tests {
+		if w := serveDelivery(tc.handler, tc.method, tc.path, tc.body, tc.header); w.Code != tc.want {
+			t.Errorf("%s: got status %d (%s), want %d", tc.name, w.Code, strings.TrimSpace(w.Body.String()), tc.want)
+		}
+	}
+}
+
+func TestDeliveryHandlerAllowOrigin(t *testing.T) {
+	h := NewDeliveryHandler(&fakeDeliveryClient{}).WithHandlerAllowOrigin("https://shop.example")
+	w := serveDelivery(h, http.MethodOptions, "/delivery", "", nil)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://shop.example" {
+		t.Errorf("got allowed origin %q, want https://shop.example", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "X-API-Key") {
+		t.Errorf("got allowed headers %q, want X-API-Key allowed", got)
+	}
+}
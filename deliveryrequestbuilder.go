@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"github.com/promotedai/schema/generated/go/proto/event"
+	"google.golang.org/protobuf/proto"
+)
+
+// DeliveryRequestBuilder builds a DeliveryRequest along with its per-request options.
+type DeliveryRequestBuilder struct {
+	request                  *delivery.Request
+	onlyLog                  bool
+	experiment               *event.CohortMembership
+	retrievalInsertionOffset int
+	organizationID           string
+	categoryFilter           *categoryFilter
+	facetFilters             []FacetFilter
+	rangeFilters             []RangeFilter
+	purchaseHistory          *PurchaseHistory
+	maxPurchaseHistoryItems  int
+	viewHistory              *ViewHistory
+	modelVersion             string
+	userInfo                 *common.UserInfo
+	lazyProperties           []LazyProperty
+	lazyPropertyTimeout      time.Duration
+	computedProperties       []ComputedProperty
+	subscription             *SubscriptionContext
+	bundles                  []BundleInsertion
+	wishlist                 *WishlistContext
+	wishlistBoostMultiplier  float64
+	maxWishlistItems         int
+	cart                     *CartContext
+	cartBuryMultiplier       float64
+	cartFilterMode           CartFilterMode
+	platformInfo             *PlatformInfo
+	allowCustomPlatforms     bool
+	locale                   *LocaleInfo
+	experimentOverride       *ExperimentOverride
+	importance               ImportanceLevel
+	insertionGroups          []InsertionGroup
+}
+
+// NewDeliveryRequestBuilder implements a builder interface for DeliveryRequest.
+func NewDeliveryRequestBuilder(request *delivery.Request) *DeliveryRequestBuilder {
+	return &DeliveryRequestBuilder{request: request}
+}
+
+func (b *DeliveryRequestBuilder) WithOnlyLog(onlyLog bool) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithOnlyLog")
+	b.onlyLog = onlyLog
+	return b
+}
+
+func (b *DeliveryRequestBuilder) WithExperiment(experiment *event.CohortMembership) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithExperiment")
+	b.experiment = experiment
+	return b
+}
+
+func (b *DeliveryRequestBuilder) WithRetrievalInsertionOffset(retrievalInsertionOffset int) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithRetrievalInsertionOffset")
+	b.retrievalInsertionOffset = retrievalInsertionOffset
+	return b
+}
+
+// WithRequestOrganizationID overrides the client's org ID for this request.
+func (b *DeliveryRequestBuilder) WithRequestOrganizationID(orgID string) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithRequestOrganizationID")
+	b.organizationID = orgID
+	return b
+}
+
+// WithCategoryFilter keeps only insertions in category or its subcategories if inclusive, and
+// removes them otherwise.
+func (b *DeliveryRequestBuilder) WithCategoryFilter(category string, inclusive bool) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithCategoryFilter")
+	b.categoryFilter = &categoryFilter{category: category, inclusive: inclusive}
+	return b
+}
+
+// WithFacetFilters sends categorical facet filters in the facetFilters request property.
+func (b *DeliveryRequestBuilder) WithFacetFilters(filters ...FacetFilter) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithFacetFilters")
+	b.facetFilters = append(b.facetFilters, filters...)
+	return b
+}
+
+// WithRangeFilters sends numeric range filters in the rangeFilters request property.
+func (b *DeliveryRequestBuilder) WithRangeFilters(filters ...RangeFilter) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithRangeFilters")
+	b.rangeFilters = append(b.rangeFilters, filters...)
+	return b
+}
+
+// WithPurchaseHistory sends the user's purchases in the purchaseHistory request property.
+func (b *DeliveryRequestBuilder) WithPurchaseHistory(history PurchaseHistory) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithPurchaseHistory")
+	b.purchaseHistory = &history
+	return b
+}
+
+// WithMaxPurchaseHistoryItems sends only the n most recent purchases.
+func (b *DeliveryRequestBuilder) WithMaxPurchaseHistoryItems(n int) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithMaxPurchaseHistoryItems")
+	b.maxPurchaseHistoryItems = n
+	return b
+}
+
+// WithViewHistory appends the user's views to the viewHistory request property.
+func (b *DeliveryRequestBuilder) WithViewHistory(h ViewHistory) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithViewHistory")
+	b.viewHistory = &h
+	return b
+}
+
+// WithModelVersionOverride overrides the client's pinned model version for this request.
+func (b *DeliveryRequestBuilder) WithModelVersionOverride(version string) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithModelVersionOverride")
+	b.modelVersion = version
+	return b
+}
+
+// WithUserInfo sets the request's user info, e.g. from UserInfoFromHTTPRequest.
+func (b *DeliveryRequestBuilder) WithUserInfo(u *common.UserInfo) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithUserInfo")
+	b.userInfo = u
+	return b
+}
+
+// WithLazyProperties resolves the properties of every insertion in parallel just before sending.
+func (b *DeliveryRequestBuilder) WithLazyProperties(props ...LazyProperty) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithLazyProperties")
+	b.lazyProperties = append(b.lazyProperties, props...)
+	return b
+}
+
+// WithLazyPropertyTimeout bounds how long lazy properties may take to resolve. Defaults to 50ms.
+func (b *DeliveryRequestBuilder) WithLazyPropertyTimeout(d time.Duration) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithLazyPropertyTimeout")
+	b.lazyPropertyTimeout = d
+	return b
+}
+
+// WithComputedProperties derives properties of every insertion from its other properties, after
+// lazy properties are resolved.
+func (b *DeliveryRequestBuilder) WithComputedProperties(props ...ComputedProperty) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithComputedProperties")
+	b.computedProperties = append(b.computedProperties, props...)
+	return b
+}
+
+// WithSubscriptionContext sends the user's subscription in the subscription request property and,
+// for subscribers, ranks eligible insertions above the others.
+func (b *DeliveryRequestBuilder) WithSubscriptionContext(sc SubscriptionContext) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithSubscriptionContext")
+	b.subscription = &sc
+	return b
+}
+
+// WithBundleInsertions ranks each bundle by its primary content ID, sending the bundle in the
+// insertion's bundle property. Use ExtractBundles to read them back from the response.
+func (b *DeliveryRequestBuilder) WithBundleInsertions(bundles ...BundleInsertion) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithBundleInsertions")
+	b.bundles = append(b.bundles, bundles...)
+	return b
+}
+
+// WithWishlistContext sends the user's wishlist in the wishlist request property and ranks
+// wishlisted insertions above the others.
+func (b *DeliveryRequestBuilder) WithWishlistContext(wl WishlistContext) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithWishlistContext")
+	b.wishlist = &wl
+	return b
+}
+
+// WithWishlistBoostMultiplier sets the boost of wishlisted insertions. Defaults to 1.5.
+func (b *DeliveryRequestBuilder) WithWishlistBoostMultiplier(m float64) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithWishlistBoostMultiplier")
+	b.wishlistBoostMultiplier = m
+	return b
+}
+
+// WithMaxWishlistItems sends only the first n wishlisted IDs. Defaults to 50.
+func (b *DeliveryRequestBuilder) WithMaxWishlistItems(n int) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithMaxWishlistItems")
+	b.maxWishlistItems = n
+	return b
+}
+
+// WithCartContext ranks insertions already in the user's cart below the others, or removes them
+// with CartFilterModeExclude.
+func (b *DeliveryRequestBuilder) WithCartContext(cc CartContext) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithCartContext")
+	b.cart = &cc
+	return b
+}
+
+// WithCartBuryMultiplier sets the bury multiplier of in-cart insertions. Defaults to 0.1.
+func (b *DeliveryRequestBuilder) WithCartBuryMultiplier(m float64) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithCartBuryMultiplier")
+	b.cartBuryMultiplier = m
+	return b
+}
+
+// WithCartFilterMode sets whether in-cart insertions are buried or removed. Defaults to burying.
+func (b *DeliveryRequestBuilder) WithCartFilterMode(mode CartFilterMode) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithCartFilterMode")
+	b.cartFilterMode = mode
+	return b
+}
+
+// WithPlatformInfo sends the client app's platform in the platform request property and headers.
+func (b *DeliveryRequestBuilder) WithPlatformInfo(p PlatformInfo) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithPlatformInfo")
+	b.platformInfo = &p
+	return b
+}
+
+// WithAllowCustomPlatforms accepts platforms other than "ios", "android" and "web".
+func (b *DeliveryRequestBuilder) WithAllowCustomPlatforms(allow bool) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithAllowCustomPlatforms")
+	b.allowCustomPlatforms = allow
+	return b
+}
+
+// WithLocale sends the user's language, currency and timezone in the locale request property.
+func (b *DeliveryRequestBuilder) WithLocale(l LocaleInfo) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithLocale")
+	b.locale = &l
+	return b
+}
+
+// WithExperimentOverride forces the Delivery API to serve the given model variant for this request.
+func (b *DeliveryRequestBuilder) WithExperimentOverride(override ExperimentOverride) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithExperimentOverride")
+	b.experimentOverride = &override
+	return b
+}
+
+// ClearExperimentOverride removes an override set by WithExperimentOverride.
+func (b *DeliveryRequestBuilder) ClearExperimentOverride() *DeliveryRequestBuilder {
+	requestBuilderUsage.record("ClearExperimentOverride")
+	b.experimentOverride = nil
+	return b
+}
+
+// WithInsertionGroups adds the groups' insertions to the request, tagged with their group so
+// RegroupResponse can split the ranked response back up.
+func (b *DeliveryRequestBuilder) WithInsertionGroups(groups ...InsertionGroup) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithInsertionGroups")
+	b.insertionGroups = append(b.insertionGroups, groups...)
+	return b
+}
+
+// WithImportance sends the call's importance to the Delivery API and orders it in the client's
+// priority queue. CRITICAL calls bypass the rate limiters.
+func (b *DeliveryRequestBuilder) WithImportance(level ImportanceLevel) *DeliveryRequestBuilder {
+	requestBuilderUsage.record("WithImportance")
+	b.importance = level
+	return b
+}
+
+func (b *DeliveryRequestBuilder) Build() (*DeliveryRequest, error) {
+	if b.request == nil {
+		return nil, errors.New("request needs to be specified")
+	}
+
+	computedProperties, err := sortComputedProperties(b.computedProperties)
+	if err != nil {
+		return nil, err
+	}
+	if b.platformInfo != nil {
+		if err := validatePlatformInfo(*b.platformInfo, b.allowCustomPlatforms); err != nil {
+			return nil, err
+		}
+	}
+	if b.locale != nil {
+		if err := validateLocale(*b.locale); err != nil {
+			return nil, err
+		}
+	}
+	if b.importance != "" {
+		if err := validateImportance(b.importance); err != nil {
+			return nil, err
+		}
+	}
+
+	// Build works on a copy, so it can be called again and leaves the caller's request alone.
+	request := proto.Clone(b.request).(*delivery.Request)
+	if b.userInfo != nil {
+		request.UserInfo = b.userInfo
+	}
+	if err := addInsertionGroups(request, b.insertionGroups); err != nil {
+		return nil, err
+	}
+	if err := addBundleInsertions(request, b.bundles); err != nil {
+		return nil, err
+	}
+
+	req := &DeliveryRequest{
+		DeliveryRequest:     client.NewDeliveryRequest(request, b.experiment, b.onlyLog, b.retrievalInsertionOffset, nil),
+		Headers:             http.Header{},
+		categoryFilter:      b.categoryFilter,
+		lazyProperties:      b.lazyProperties,
+		lazyPropertyTimeout: b.lazyPropertyTimeout,
+		computedProperties:  computedProperties,
+		hasBundles:          len(b.bundles) > 0,
+		hasInsertionGroups:  len(b.insertionGroups) > 0,
+		importance:          b.importance,
+	}
+
+	if b.organizationID != "" {
+		req.Headers.Set(organizationIDHeader, b.organizationID)
+		if err := setProperty(&request.Properties, organizationIDPropertyKey, b.organizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.modelVersion != "" {
+		req.Headers.Set(modelVersionHeader, b.modelVersion)
+	}
+	if b.importance != "" {
+		req.Headers.Set(importanceHeader, string(b.importance))
+	}
+	if b.experimentOverride != nil {
+		if err := applyExperimentOverride(req, *b.experimentOverride); err != nil {
+			return nil, err
+		}
+	}
+	if b.platformInfo != nil {
+		if err := applyPlatformInfo(req, *b.platformInfo); err != nil {
+			return nil, err
+		}
+	}
+	if len(b.facetFilters) > 0 {
+		if err := setJSONProperty(&request.Properties, facetFiltersPropertyKey, b.facetFilters); err != nil {
+			return nil, err
+		}
+	}
+	if len(b.rangeFilters) > 0 {
+		if err := setJSONProperty(&request.Properties, rangeFiltersPropertyKey, b.rangeFilters); err != nil {
+			return nil, err
+		}
+	}
+	if b.locale != nil {
+		if err := setJSONProperty(&request.Properties, localePropertyKey, b.locale); err != nil {
+			return nil, err
+		}
+	}
+	if b.purchaseHistory != nil {
+		items := b.purchaseHistory.mostRecent(b.maxPurchaseHistoryItems)
+		if err := setJSONProperty(&request.Properties, purchaseHistoryPropertyKey, items); err != nil {
+			return nil, err
+		}
+	}
+	if b.viewHistory != nil {
+		if err := appendViewHistory(&request.Properties, b.viewHistory.Views); err != nil {
+			return nil, err
+		}
+	}
+	if b.cart != nil {
+		buryMultiplier := b.cartBuryMultiplier
+		if buryMultiplier <= 0 {
+			buryMultiplier = defaultCartBuryMultiplier
+		}
+		if err := applyCart(request, *b.cart, b.cartFilterMode, buryMultiplier); err != nil {
+			return nil, err
+		}
+	}
+	if b.wishlist != nil {
+		multiplier := b.wishlistBoostMultiplier
+		if multiplier <= 0 {
+			multiplier = defaultWishlistBoostMultiplier
+		}
+		maxItems := b.maxWishlistItems
+		if maxItems <= 0 {
+			maxItems = defaultMaxWishlistItems
+		}
+		if err := applyWishlist(request, *b.wishlist, multiplier, maxItems); err != nil {
+			return nil, err
+		}
+	}
+	if b.subscription != nil {
+		if err := setJSONProperty(&request.Properties, subscriptionPropertyKey, b.subscription); err != nil {
+			return nil, err
+		}
+		if err := applySubscriptionBoosts(request.Insertion, *b.subscription); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// GetDiscountPct returns round((1 - price/originalPrice) * 100) from the insertion's properties.
+func GetDiscountPct(insertion *delivery.Insertion, pricePropKey, originalPricePropKey string) (float64, error) {
+	price := getProperty(insertion.Properties, pricePropKey)
+	if price == nil {
+		return 0, fmt.Errorf("content %s has no %s property", insertion.GetContentId(), pricePropKey)
+	}
+	originalPrice := getProperty(insertion.Properties, originalPricePropKey)
+	if originalPrice == nil {
+		return 0, fmt.Errorf("content %s has no %s property", insertion.GetContentId(), originalPricePropKey)
+	}
+	if originalPrice.GetNumberValue() <= 0 {
+		return 0, fmt.Errorf("content %s has non-positive %s", insertion.GetContentId(), originalPricePropKey)
+	}
+	return math.Round((1 - price.GetNumberValue()/originalPrice.GetNumberValue()) * 100), nil
+}
+
+// discountProperties writes each insertion's discount percentage before sending.
+type discountProperties struct {
+	pricePropKey         string
+	originalPricePropKey string
+	discountPctKey       string
+}
+
+// middleware skips insertions whose discount can't be computed, e.g. ones that aren't on sale.
+func (d *discountProperties) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		req = cloneRequest(req)
+		for _, ins := range req.Request.GetInsertion() {
+			discountPct, err := GetDiscountPct(ins, d.pricePropKey, d.originalPricePropKey)
+			if err != nil {
+				continue
+			}
+			if err := setProperty(&ins.Properties, d.discountPctKey, discountPct); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// insertionWithProperties returns an insertion for contentID with props set.
+func insertionWithProperties(t *testing.T, contentID string, props map[string]any) *delivery.Insertion {
+	t.Helper()
+	ins := &delivery.Insertion{ContentId: contentID}
+	for key, value := range props {
+		if err := setProperty(&ins.Properties, key, value); err != nil {
+			t.Fatalf("error setting %s: %v", key, err)
+		}
+	}
+	return ins
+}
+
+func TestGetDiscountPct(t *testing.T) {
+	tests := []struct {
+		name    string
+		props   map[string]any
+		want    float64
+		wantErr bool
+	}{
+		{"discounted", map[string]any{"price": 75.0, "originalPrice": 100.0}, 25, false},
+		{"rounded", map[string]any{"price": 2.0, "originalPrice": 3.0}, 33, false},
+		{"full price", map[string]any{"price": 100.0, "originalPrice": 100.0}, 0, false},
+		{"zero original price", map[string]any{"price": 5.0, "originalPrice": 0.0}, 0, true},
+		{"negative original price", map[string]any{"price": 5.0, "originalPrice": -10.0}, 0, true},
+		{"missing price", map[string]any{"originalPrice": 100.0}, 0, true},
+		{"missing original price", map[string]any{"price": 75.0}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetDiscountPct(insertionWithProperties(t, "a", tt.props), "price", "originalPrice")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscountProperties(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithDiscountProperties("price", "originalPrice", "discountPct"))
+	req := newTestDeliveryRequest(t)
+	req.Request.Insertion = []*delivery.Insertion{
+		insertionWithProperties(t, "sale", map[string]any{"price": 40.0, "originalPrice": 50.0}),
+		insertionWithProperties(t, "free", map[string]any{"price": 0.0, "originalPrice": 0.0}),
+		insertionWithProperties(t, "unpriced", nil),
+	}
+
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	for id, want := range map[string]any{"sale": 20.0, "free": nil, "unpriced": nil} {
+		if got := sentProperty(t, api, id, "discountPct"); got != want {
+			t.Errorf("sent %s discountPct %v, want %v", id, got, want)
+		}
+	}
+}
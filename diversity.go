@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// DiversityFilter caps how many insertions may share a value of Attribute, e.g. at most 3 items
+// per brand.
+type DiversityFilter struct {
+	Attribute   string
+	MaxPerValue int
+}
+
+// ApplyDiversityFilter walks insertions in ranked order and drops those whose propKey property
+// value has already appeared MaxPerValue times. propKey defaults to the filter's Attribute.
+// Insertions without the property are always kept.
+func ApplyDiversityFilter(insertions []*delivery.Insertion, filter DiversityFilter, propKey string) []*delivery.Insertion {
+	return applyDiversityFilter(insertions, (*delivery.Insertion).GetProperties, filter, propKey)
+}
+
+// applyDiversityFilter is ApplyDiversityFilter reading properties with propertiesOf, since
+// response insertions don't carry their properties.
+func applyDiversityFilter(insertions []*delivery.Insertion, propertiesOf func(*delivery.Insertion) *common.Properties, filter DiversityFilter, propKey string) []*delivery.Insertion {
+	if propKey == "" {
+		propKey = filter.Attribute
+	}
+	counts := map[string]int{}
+	kept := make([]*delivery.Insertion, 0, len(insertions))
+	for _, ins := range insertions {
+		v := getProperty(propertiesOf(ins), propKey)
+		if v == nil {
+			kept = append(kept, ins)
+			continue
+		}
+		value := fmt.Sprint(v.AsInterface())
+		if counts[value] >= filter.MaxPerValue {
+			continue
+		}
+		counts[value]++
+		kept = append(kept, ins)
+	}
+	return kept
+}
+
+// diversityFiltering drops over-represented insertions from each response.
+type diversityFiltering struct {
+	filter  DiversityFilter
+	propKey string
+}
+
+func (d *diversityFiltering) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		requested := requestInsertionsByContentID(req)
+		resp = cloneResponse(resp)
+		resp.Response.Insertion = applyDiversityFilter(resp.Response.GetInsertion(), func(ins *delivery.Insertion) *common.Properties {
+			return requested[ins.GetContentId()].GetProperties()
+		}, d.filter, d.propKey)
+		renumberPositions(resp.Response.Insertion)
+		return resp, nil
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// brandedInsertions returns an insertion per content ID with the given brands in the key property,
+// leaving it out where the brand is empty.
+func brandedInsertions(t *testing.T, key string, ids []string, brands []string) []*delivery.Insertion {
+	t.Helper()
+	insertions := testInsertions(ids...)
+	for i, brand := range brands {
+		if brand == "" {
+			continue
+		}
+		if err := setProperty(&insertions[i].Properties, key, brand); err != nil {
+			t.Fatalf("error setting %s: %v", key, err)
+		}
+	}
+	return insertions
+}
+
+func TestApplyDiversityFilter(t *testing.T) {
+	insertions := brandedInsertions(t, "brand",
+		[]string{"n1", "n2", "a1", "n3", "x", "a2", "n4"},
+		[]string{"nike", "nike", "adidas", "nike", "", "adidas", "nike"})
+
+	got := insertionContentIDsOf(ApplyDiversityFilter(insertions, DiversityFilter{Attribute: "brand", MaxPerValue: 2}, ""))
+	// Insertions without a brand are always kept.
+	if want := []string{"n1", "n2", "a1", "x", "a2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestApplyDiversityFilterCapsEveryValue(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	brands := []string{"nike", "adidas", "puma"}
+	for trial := 0; trial < 50; trial++ {
+		n := rng.Intn(30)
+		ids := make([]string, n)
+		values := make([]string, n)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("item-%d", i)
+			values[i] = brands[rng.Intn(len(brands))]
+		}
+		maxPerValue := 1 + rng.Intn(4)
+		kept := ApplyDiversityFilter(brandedInsertions(t, "brand", ids, values), DiversityFilter{Attribute: "brand", MaxPerValue: maxPerValue}, "brand")
+
+		counts := map[string]int{}
+		last := -1
+		for _, ins := range kept {
+			brand := getProperty(ins.Properties, "brand").GetStringValue()
+			if counts[brand]++; counts[brand] > maxPerValue {
+				t.Fatalf("trial %d: got %d %s insertions, want at most %d", trial, counts[brand], brand, maxPerValue)
+			}
+			var i int
+			fmt.Sscanf(ins.GetContentId(), "item-%d", &i)
+			if i <= last {
+				t.Fatalf("trial %d: got %s after item-%d, want ranked order kept", trial, ins.GetContentId(), last)
+			}
+			last = i
+		}
+	}
+}
+
+func TestDiversityFilterAppliesToResponses(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithDiversityFilter(DiversityFilter{Attribute: "brand", MaxPerValue: 1}, "manufacturer"))
+	req := newTestDeliveryRequest(t)
+	req.Request.Insertion = brandedInsertions(t, "manufacturer", []string{"n1", "n2", "a1"}, []string{"nike", "nike", "adidas"})
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "n1", "a1")
+	if got := resp.Response.GetInsertion()[1].GetPosition(); got != 1 {
+		t.Errorf("got position %d for a1, want positions renumbered", got)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// dryRunQuery asks the Delivery API to validate a request without ranking or logging it.
+const dryRunQuery = "?dry_run=true"
+
+// ValidationResult is the outcome of a dry-run validation.
+type ValidationResult struct {
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+}
+
+// ValidateRequest checks req without ranking it or producing impressions. The SDK's own checks run
+// first; if they pass, the request is sent to the Delivery API as a dry run, prepared like Explain's.
+func (c *DeliveryClient) ValidateRequest(ctx context.Context, req *DeliveryRequest) (*ValidationResult, error) {
+	if errs := req.Validate(); len(errs) > 0 {
+		return &ValidationResult{Valid: false, Errors: errs}, nil
+	}
+	api, req, err := c.prepareDirectCall(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return api.runValidation(ctx, req, req.Headers)
+}
+
+// runValidation posts the request to the dry-run endpoint. A 400 response still carries a result.
+func (d *deliveryAPI) runValidation(ctx context.Context, req *DeliveryRequest, header http.Header) (*ValidationResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeoutDuration)
+	defer cancel()
+
+	requestBody, err := protojson.Marshal(req.Request)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling delivery request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, d.dryRunHTTPEndpoint, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("error creating HTTP request: %v", err)
+	}
+	d.setHeaders(httpReq, header)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	respHTTP, err := d.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error making HTTP request: %v", err)
+	}
+	defer respHTTP.Body.Close()
+
+	if (respHTTP.StatusCode < 200 || respHTTP.StatusCode >= 300) && respHTTP.StatusCode != http.StatusBadRequest {
+		return nil, fmt.Errorf("failure calling dry-run endpoint; statusCode=%d", respHTTP.StatusCode)
+	}
+
+	var result ValidationResult
+	if err := json.NewDecoder(respHTTP.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error unmarshaling validation result: %v", err)
+	}
+	return &result, nil
+}
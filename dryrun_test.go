@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// newDryRunAPI only accepts dry runs, rejecting requests without insertions.
+func newDryRunAPI(t *testing.T, path string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != path || r.URL.Query().Get("dry_run") != "true" {
+			t.Errorf("got call to %s, want a dry run to %s", r.URL, path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		result := ValidationResult{Valid: true, Warnings: []string{"no search query"}}
+		if req["insertion"] == nil {
+			result = ValidationResult{Valid: false, Errors: []string{"no insertions"}}
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestValidateRequestSendsDryRun(t *testing.T) {
+	server, _ := newDryRunAPI(t, deliveryEndpointSuffix)
+	metrics, logged := newRecordingServer(t)
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithMetricsEndpoint(metrics.URL).
+		WithDisableUsageTracking(true))
+
+	result, err := c.ValidateRequest(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("ValidateRequest failed: %v", err)
+	}
+	if want := (ValidationResult{Valid: true, Warnings: []string{"no search query"}}); !reflect.DeepEqual(*result, want) {
+		t.Errorf("got %+v, want %+v", *result, want)
+	}
+
+	result, err = c.ValidateRequest(context.Background(), &DeliveryRequest{
+		DeliveryRequest: newTestDeliveryRequest(t).DeliveryRequest,
+	})
+	if err != nil {
+		t.Fatalf("ValidateRequest failed: %v", err)
+	}
+	if result.Valid || !reflect.DeepEqual(result.Errors, []string{"no insertions"}) {
+		t.Errorf("got %+v for a request without insertions, want the server's errors", *result)
+	}
+	c.Close()
+	if len(logged()) != 0 {
+		t.Errorf("got %d Metrics API calls from dry runs, want 0", len(logged()))
+	}
+}
+
+func TestValidateRequestUsesDryRunEndpoint(t *testing.T) {
+	server, calls := newDryRunAPI(t, "/validate")
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithDryRunEndpoint(server.URL+"/validate?dry_run=true"))
+
+	if _, err := c.ValidateRequest(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("ValidateRequest failed: %v", err)
+	}
+	if calls.Load() != 1 || api.calls() != 0 {
+		t.Errorf("got %d dry-run endpoint and %d delivery endpoint calls, want 1 and 0", calls.Load(), api.calls())
+	}
+}
+
+func TestValidateRequestRunsSDKChecksFirst(t *testing.T) {
+	server, calls := newDryRunAPI(t, deliveryEndpointSuffix)
+	c := buildTestClient(t, NewDeliveryClientBuilder().WithDeliveryEndpoint(server.URL).WithDisableUsageTracking(true))
+
+	req := &DeliveryRequest{DeliveryRequest: newTestDeliveryRequest(t, "a").DeliveryRequest}
+	req.Request.UserInfo = &common.UserInfo{}
+	req.Request.Insertion = append(req.Request.Insertion, &delivery.Insertion{ContentId: "a", InsertionId: "set-by-caller"})
+	result, err := c.ValidateRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ValidateRequest failed: %v", err)
+	}
+	if result.Valid || len(result.Errors) == 0 || calls.Load() != 0 {
+		t.Errorf("got %+v after %d dry runs, want SDK errors without a dry run", *result, calls.Load())
+	}
+}
+
+func TestValidateRequestEncryptsAndFiltersProperties(t *testing.T) {
+	var sent atomic.Pointer[delivery.Request]
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req delivery.Request
+		body, _ := io.ReadAll(r.Body)
+		if err := protojson.Unmarshal(body, &req); err != nil {
+			t.Errorf("error parsing dry-run request: %v", err)
+			return
+		}
+		sent.Store(&req)
+		json.NewEncoder(w).Encode(ValidationResult{Valid: true})
+	}))
+	t.Cleanup(server.Close)
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithDisableUsageTracking(true).
+		WithDeniedPropertyKeys("email").
+		WithInsertionPropertyEncryption(newTestEncryptor(t, testEncryptionKey), []string{"customerId"}))
+
+	req := newTestDeliveryRequest(t, "a")
+	setProperty(&req.Request.Insertion[0].Properties, "email", "a@example.com")
+	setProperty(&req.Request.Insertion[0].Properties, "customerId", "customer-123")
+	if _, err := c.ValidateRequest(context.Background(), req); err != nil {
+		t.Fatalf("ValidateRequest failed: %v", err)
+	}
+	props := sent.Load().GetInsertion()[0].GetProperties()
+	if getProperty(props, "email") != nil {
+		t.Error("sent the denied email property")
+	}
+	if got := getProperty(props, "customerId").GetStringValue(); got == "customer-123" || got == "" {
+		t.Errorf("customerId was sent as %q, want it encrypted", got)
+	}
+	if got := getProperty(req.Request.GetInsertion()[0].GetProperties(), "email").GetStringValue(); got != "a@example.com" {
+		t.Errorf("the caller's email became %q", got)
+	}
+}
+
+func TestValidateRequestRefusesOptedOutUsers(t *testing.T) {
+	server, calls := newDryRunAPI(t, deliveryEndpointSuffix)
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithDisableUsageTracking(true).
+		WithGDPROptOut(func(context.Context) bool { return true }))
+
+	if _, err := c.ValidateRequest(context.Background(), newTestDeliveryRequest(t, "a")); !errors.Is(err, errOptedOut) {
+		t.Errorf("got error %v, want the opt-out error", err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("got %d dry runs for an opted-out user, want 0", calls.Load())
+	}
+}
+
+func TestValidateRequestFollowsDataResidency(t *testing.T) {
+	defaultServer, defaultCalls := newDryRunAPI(t, deliveryEndpointSuffix)
+	euServer, euCalls := newDryRunAPI(t, deliveryEndpointSuffix)
+	euMetrics, _ := newRecordingServer(t)
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(defaultServer.URL).
+		WithDisableUsageTracking(true).
+		WithDataResidencyRouter(NewDataResidencyRouter().WithRegion("eu", euServer.URL, euMetrics.URL)).
+		WithRegionExtractor(regionFromContext))
+
+	ctx := context.WithValue(context.Background(), regionKey{}, "eu")
+	if _, err := c.ValidateRequest(ctx, newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("ValidateRequest failed: %v", err)
+	}
+	if euCalls.Load() != 1 || defaultCalls.Load() != 0 {
+		t.Errorf("got %d EU and %d default dry runs, want 1 and 0", euCalls.Load(), defaultCalls.Load())
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// EventType is the kind of a DeliveryEvent.
+type EventType string
+
+const (
+	// EventTypeRequestSent is published before calling the Delivery API, with the *delivery.Request.
+	EventTypeRequestSent EventType = "REQUEST_SENT"
+	// EventTypeResponseReceived is published after a successful call, with the *delivery.Response.
+	EventTypeResponseReceived EventType = "RESPONSE_RECEIVED"
+	// EventTypeError is published after a failed call, with the error.
+	EventTypeError EventType = "ERROR"
+	// EventTypeShadowSent is published when the SDK sends shadow traffic, with the *delivery.Request.
+	EventTypeShadowSent EventType = "SHADOW_SENT"
+)
+
+// DeliveryEvent describes a step of a Delivery API call.
+type DeliveryEvent struct {
+	Type            EventType
+	Timestamp       time.Time
+	ClientRequestID string
+	Payload         any
+}
+
+// EventBus publishes delivery events, e.g. to a message queue for observability.
+type EventBus interface {
+	Publish(event DeliveryEvent) error
+}
+
+// channelEventBus sends events to a channel.
+type channelEventBus struct {
+	ch chan<- DeliveryEvent
+}
+
+// ChannelEventBus publishes events to ch. Events are dropped with an error when ch is full, so
+// delivery never blocks on a slow reader.
+func ChannelEventBus(ch chan<- DeliveryEvent) EventBus {
+	return &channelEventBus{ch: ch}
+}
+
+func (b *channelEventBus) Publish(event DeliveryEvent) error {
+	select {
+	case b.ch <- event:
+		return nil
+	default:
+		return errors.New("event channel is full")
+	}
+}
+
+// publishEvent publishes an event to bus, if any, logging failures since events are best-effort.
+func publishEvent(bus EventBus, eventType EventType, clientRequestID string, payload any) {
+	if bus == nil {
+		return
+	}
+	event := DeliveryEvent{
+		Type:            eventType,
+		Timestamp:       time.Now(),
+		ClientRequestID: clientRequestID,
+		Payload:         payload,
+	}
+	if err := bus.Publish(event); err != nil {
+		log.Printf("WARN: error publishing %s event: %v\n", eventType, err)
+	}
+}
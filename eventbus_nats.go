@@ -0,0 +1,72 @@
+//go:build nats
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// natsEventBus publishes events as JSON messages on a NATS subject.
+type natsEventBus struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// natsEvent is the JSON message of a DeliveryEvent.
+type natsEvent struct {
+	Type            EventType       `json:"type"`
+	Timestamp       time.Time       `json:"timestamp"`
+	ClientRequestID string          `json:"clientRequestId,omitempty"`
+	Payload         json.RawMessage `json:"payload,omitempty"`
+}
+
+// NATSEventBus publishes events to subject on nc. Proto payloads are sent as protojson and errors
+// as their message. Build with the nats tag to use it.
+func NATSEventBus(nc *nats.Conn, subject string) EventBus {
+	return &natsEventBus{conn: nc, subject: subject}
+}
+
+func (b *natsEventBus) Publish(event DeliveryEvent) error {
+	payload, err := marshalEventPayload(event.Payload)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(natsEvent{
+		Type:            event.Type,
+		Timestamp:       event.Timestamp,
+		ClientRequestID: event.ClientRequestID,
+		Payload:         payload,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling event: %v", err)
+	}
+	if err := b.conn.Publish(b.subject, data); err != nil {
+		return fmt.Errorf("error publishing event to NATS: %v", err)
+	}
+	return nil
+}
+
+func marshalEventPayload(payload any) (json.RawMessage, error) {
+	var data []byte
+	var err error
+	switch p := payload.(type) {
+	case nil:
+		return nil, nil
+	case proto.Message:
+		data, err = protojson.Marshal(p)
+	case error:
+		data, err = json.Marshal(p.Error())
+	default:
+		data, err = json.Marshal(p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling event payload: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// drainEvents returns the events buffered in ch.
+func drainEvents(ch chan DeliveryEvent) []DeliveryEvent {
+	var events []DeliveryEvent
+	for {
+		select {
+		case event := <-ch:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func assertEventTypes(t *testing.T, events []DeliveryEvent, clientRequestID string, want ...EventType) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %v, want %v", len(events), events, want)
+	}
+	for i, event := range events {
+		if event.Type != want[i] {
+			t.Errorf("event %d is %s, want %s", i, event.Type, want[i])
+		}
+		if event.ClientRequestID != clientRequestID || event.Timestamp.IsZero() {
+			t.Errorf("event %d has client request ID %q at %v, want %q with a timestamp", i, event.ClientRequestID, event.Timestamp, clientRequestID)
+		}
+	}
+}
+
+func TestEventsOfSuccessfulCall(t *testing.T) {
+	ch := make(chan DeliveryEvent, 10)
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithEventBus(ChannelEventBus(ch)))
+
+	req := newTestDeliveryRequest(t, "a", "b")
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	events := drainEvents(ch)
+	assertEventTypes(t, events, req.Request.GetClientRequestId(), EventTypeRequestSent, EventTypeResponseReceived)
+	if sent, ok := events[0].Payload.(*delivery.Request); !ok || len(sent.GetInsertion()) != 2 {
+		t.Errorf("got request payload %v, want the sent request", events[0].Payload)
+	}
+	if resp, ok := events[1].Payload.(*delivery.Response); !ok || len(resp.GetInsertion()) != 2 {
+		t.Errorf("got response payload %v, want the received response", events[1].Payload)
+	}
+}
+
+func TestEventsOfFailedCall(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	ch := make(chan DeliveryEvent, 10)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithEventBus(ChannelEventBus(ch)))
+
+	req := newTestDeliveryRequest(t, "a")
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	events := drainEvents(ch)
+	assertEventTypes(t, events, req.Request.GetClientRequestId(), EventTypeRequestSent, EventTypeError)
+	var de *DeliveryError
+	if err, ok := events[1].Payload.(error); !ok || !errors.As(err, &de) || de.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got error payload %v, want the 500 DeliveryError", events[1].Payload)
+	}
+}
+
+func TestEventOfShadowTraffic(t *testing.T) {
+	api := newFakeAPI(t)
+	ch := make(chan DeliveryEvent, 10)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithEventBus(ChannelEventBus(ch)).
+		WithShadowTrafficDeliveryRate(1).
+		WithBlockingShadowTraffic(true))
+
+	// Only-log requests are delivered by the SDK, with the Delivery API call sent as shadow traffic.
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:  &common.UserInfo{AnonUserId: "anon-1"},
+		Insertion: testInsertions("a"),
+	}).WithOnlyLog(true))
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertEventTypes(t, drainEvents(ch), req.Request.GetClientRequestId(), EventTypeShadowSent)
+	if api.calls() != 1 {
+		t.Errorf("got %d Delivery API calls, want the shadow call", api.calls())
+	}
+}
+
+func TestEventsCarryAnonymizedUserIDs(t *testing.T) {
+	ch := make(chan DeliveryEvent, 10)
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).
+		WithEventBus(ChannelEventBus(ch)).
+		WithUserIDAnonymizer(NewHMACUserIDAnonymizer([]byte("pepper"))))
+
+	if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	sent := drainEvents(ch)[0].Payload.(*delivery.Request)
+	if userID := sent.GetUserInfo().GetUserId(); userID == "" || userID == "user-1" {
+		t.Errorf("published user ID %q, want it anonymized", userID)
+	}
+}
+
+func TestChannelEventBusDropsWhenFull(t *testing.T) {
+	bus := ChannelEventBus(make(chan DeliveryEvent, 1))
+	if err := bus.Publish(DeliveryEvent{Type: EventTypeRequestSent}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if err := bus.Publish(DeliveryEvent{Type: EventTypeRequestSent}); err == nil {
+		t.Error("Publish to a full channel succeeded")
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const experimentOverrideHeader = "X-Promoted-Experiment-Override"
+
+// ExperimentOverride forces the Delivery API to serve a specific model variant, e.g. for QA of a
+// new model version against production traffic.
+type ExperimentOverride struct {
+	ModelID     string `json:"modelId,omitempty"`
+	TreatmentID string `json:"treatmentId,omitempty"`
+}
+
+// applyExperimentOverride sends o as JSON in the experiment override header.
+func applyExperimentOverride(req *DeliveryRequest, o ExperimentOverride) error {
+	if o.ModelID == "" && o.TreatmentID == "" {
+		return errors.New("experiment override needs a model ID or treatment ID")
+	}
+	value, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("error marshaling experiment override: %v", err)
+	}
+	req.Headers.Set(experimentOverrideHeader, string(value))
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func newOverrideRequestBuilder() *DeliveryRequestBuilder {
+	return NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:  &common.UserInfo{AnonUserId: "anon-1"},
+		Insertion: testInsertions("a"),
+	})
+}
+
+func TestExperimentOverrideHeaderIsSent(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	req := buildTestRequest(t, newOverrideRequestBuilder().WithExperimentOverride(ExperimentOverride{ModelID: "model-7", TreatmentID: "treatment-2"}))
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	value := api.lastHeader(t).Get(experimentOverrideHeader)
+	var got ExperimentOverride
+	if err := json.Unmarshal([]byte(value), &got); err != nil {
+		t.Fatalf("error parsing %s header %q: %v", experimentOverrideHeader, value, err)
+	}
+	if want := (ExperimentOverride{ModelID: "model-7", TreatmentID: "treatment-2"}); got != want {
+		t.Errorf("got override %+v, want %+v", got, want)
+	}
+}
+
+func TestClearExperimentOverrideRemovesHeader(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	req := buildTestRequest(t, newOverrideRequestBuilder().
+		WithExperimentOverride(ExperimentOverride{ModelID: "model-7"}).
+		ClearExperimentOverride())
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if value := api.lastHeader(t).Get(experimentOverrideHeader); value != "" {
+		t.Errorf("sent %s header %q after clearing the override", experimentOverrideHeader, value)
+	}
+}
+
+func TestEmptyExperimentOverrideIsRejected(t *testing.T) {
+	if _, err := newOverrideRequestBuilder().WithExperimentOverride(ExperimentOverride{}).Build(); err == nil {
+		t.Error("Build succeeded with an empty experiment override")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const explainEndpointSuffix = "/explain"
+
+// defaultExplainTimeout bounds Explain calls, which take far longer than a delivery.
+const defaultExplainTimeout = 2 * time.Minute
+
+// ExplanationResponse says why the Delivery API ranked an item where it did.
+type ExplanationResponse struct {
+	Rank                 int                `json:"rank"`
+	Score                float64            `json:"score"`
+	FeatureContributions map[string]float64 `json:"featureContributions"`
+	FilterReasons        []string           `json:"filterReasons"`
+}
+
+// explainRequest is the body of an explain call: the item and the full request it was ranked in.
+type explainRequest struct {
+	ContentID string          `json:"contentId"`
+	Request   json.RawMessage `json:"request"`
+}
+
+// Explain asks the Delivery API why contentID ranks where it does for req. It is a debugging tool
+// for merchandisers: the server ranks the whole request again and computes per-feature
+// contributions on top, so a call costs several deliveries and bypasses the middlewares, caches and
+// logs. Like Deliver, it refuses opted-out users, encrypts and filters insertion properties, and
+// follows data residency. It is bounded by ctx and the explain timeout, not the delivery timeout.
+// Never call it in the serving path.
+func (c *DeliveryClient) Explain(ctx context.Context, contentID string, req *DeliveryRequest) (*ExplanationResponse, error) {
+	if contentID == "" {
+		return nil, errors.New("contentID must not be empty")
+	}
+	api, req, err := c.prepareDirectCall(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return api.runExplain(ctx, contentID, req, req.Headers)
+}
+
+// runExplain posts the content ID and request to the explain endpoint.
+func (d *deliveryAPI) runExplain(ctx context.Context, contentID string, req *DeliveryRequest, header http.Header) (*ExplanationResponse, error) {
+	request, err := protojson.Marshal(req.Request)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling delivery request: %v", err)
+	}
+	requestBody, err := json.Marshal(explainRequest{ContentID: contentID, Request: request})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling explain request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, d.explainHTTPEndpoint, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("error creating HTTP request: %v", err)
+	}
+	d.setHeaders(httpReq, header)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	// Explanations take far longer than the delivery timeout, so they have their own.
+	httpClient := &http.Client{Transport: d.httpClient.Transport, Timeout: d.explainTimeout}
+	respHTTP, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error making HTTP request: %v", err)
+	}
+	defer respHTTP.Body.Close()
+
+	if respHTTP.StatusCode < 200 || respHTTP.StatusCode >= 300 {
+		return nil, fmt.Errorf("failure calling explain endpoint; statusCode=%d", respHTTP.StatusCode)
+	}
+
+	var explanation ExplanationResponse
+	if err := json.NewDecoder(respHTTP.Body).Decode(&explanation); err != nil {
+		return nil, fmt.Errorf("error unmarshaling explanation: %v", err)
+	}
+	return &explanation, nil
+}
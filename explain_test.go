@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// explainServer answers explain calls on path with respond and records what it was sent.
+type explainServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	paths   []string
+	bodies  [][]byte
+	headers []http.Header
+}
+
+func newExplainServer(t *testing.T, path string, respond func(w http.ResponseWriter)) *explainServer {
+	t.Helper()
+	s := &explainServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("error reading explain request: %v", err)
+		}
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.bodies = append(s.bodies, body)
+		s.headers = append(s.headers, r.Header.Clone())
+		s.mu.Unlock()
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		respond(w)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *explainServer) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.paths)
+}
+
+var testExplanation = &ExplanationResponse{
+	Rank:                 2,
+	Score:                0.42,
+	FeatureContributions: map[string]float64{"price": -0.1, "ctr": 0.3},
+	FilterReasons:        []string{"diversity"},
+}
+
+func writeExplanation(w http.ResponseWriter) {
+	json.NewEncoder(w).Encode(testExplanation)
+}
+
+func TestExplainCallsExplainEndpoint(t *testing.T) {
+	server := newExplainServer(t, explainEndpointSuffix, writeExplanation)
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithDeliveryAPIKey("key-1").
+		WithDisableUsageTracking(true))
+
+	explanation, err := c.Explain(context.Background(), "b", newTestDeliveryRequest(t, "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if !reflect.DeepEqual(explanation, testExplanation) {
+		t.Errorf("got explanation %+v, want %+v", explanation, testExplanation)
+	}
+
+	if server.calls() != 1 {
+		t.Fatalf("got %d explain calls, want 1", server.calls())
+	}
+	var sent struct {
+		ContentID string          `json:"contentId"`
+		Request   json.RawMessage `json:"request"`
+	}
+	if err := json.Unmarshal(server.bodies[0], &sent); err != nil {
+		t.Fatalf("error parsing explain request: %v", err)
+	}
+	var req delivery.Request
+	if err := protojson.Unmarshal(sent.Request, &req); err != nil {
+		t.Fatalf("error parsing explained delivery request: %v", err)
+	}
+	if sent.ContentID != "b" || !reflect.DeepEqual(insertionContentIDsOf(req.GetInsertion()), []string{"a", "b", "c"}) {
+		t.Errorf("explained %s in %v, want b in the full request", sent.ContentID, insertionContentIDsOf(req.GetInsertion()))
+	}
+	if got := server.headers[0].Get("x-api-key"); got != "key-1" {
+		t.Errorf("got x-api-key %q, want key-1", got)
+	}
+}
+
+func TestExplainUsesExplainEndpointOption(t *testing.T) {
+	server := newExplainServer(t, "/debug/explain", writeExplanation)
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithExplainEndpoint(server.URL+"/debug/explain"))
+
+	if _, err := c.Explain(context.Background(), "a", newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if server.calls() != 1 {
+		t.Errorf("got %d calls to the explain endpoint, want 1", server.calls())
+	}
+}
+
+func TestExplainIsNotBoundByDeliveryTimeout(t *testing.T) {
+	server := newExplainServer(t, explainEndpointSuffix, func(w http.ResponseWriter) {
+		time.Sleep(150 * time.Millisecond)
+		writeExplanation(w)
+	})
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithDeliveryTimeoutMillis(50).
+		WithDisableUsageTracking(true))
+
+	if _, err := c.Explain(context.Background(), "a", newTestDeliveryRequest(t, "a")); err != nil {
+		t.Errorf("Explain failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Explain(ctx, "a", newTestDeliveryRequest(t, "a")); err == nil {
+		t.Error("Explain succeeded past its context deadline")
+	}
+}
+
+func TestExplainTimeout(t *testing.T) {
+	server := newExplainServer(t, explainEndpointSuffix, func(w http.ResponseWriter) {
+		time.Sleep(150 * time.Millisecond)
+		writeExplanation(w)
+	})
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithExplainTimeout(20*time.Millisecond).
+		WithDisableUsageTracking(true))
+
+	if _, err := c.Explain(context.Background(), "a", newTestDeliveryRequest(t, "a")); err == nil {
+		t.Error("Explain succeeded past the explain timeout")
+	}
+	unset := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)))
+	if got := unset.deliveryAPI.explainTimeout; got != defaultExplainTimeout {
+		t.Errorf("got default explain timeout %v, want %v", got, defaultExplainTimeout)
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		contentID string
+		respond   func(w http.ResponseWriter)
+		wantCalls int
+	}{
+		{"empty content ID", "", writeExplanation, 0},
+		{"server error", "a", func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) }, 1},
+		{"invalid explanation", "a", func(w http.ResponseWriter) { w.Write([]byte("{")) }, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newExplainServer(t, explainEndpointSuffix, tc.respond)
+			c := buildTestClient(t, NewDeliveryClientBuilder().
+				WithDeliveryEndpoint(server.URL).
+				WithDisableUsageTracking(true))
+
+			if _, err := c.Explain(context.Background(), tc.contentID, newTestDeliveryRequest(t, "a")); err == nil {
+				t.Error("Explain succeeded")
+			}
+			if got := server.calls(); got != tc.wantCalls {
+				t.Errorf("got %d explain calls, want %d", got, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestExplainEncryptsAndFiltersProperties(t *testing.T) {
+	server := newExplainServer(t, explainEndpointSuffix, writeExplanation)
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithDisableUsageTracking(true).
+		WithDeniedPropertyKeys("email").
+		WithInsertionPropertyEncryption(newTestEncryptor(t, testEncryptionKey), []string{"customerId"}))
+
+	req := newTestDeliveryRequest(t, "a")
+	setProperty(&req.Request.Insertion[0].Properties, "email", "a@example.com")
+	setProperty(&req.Request.Insertion[0].Properties, "customerId", "customer-123")
+	if _, err := c.Explain(context.Background(), "a", req); err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+
+	var sent struct {
+		Request json.RawMessage `json:"request"`
+	}
+	if err := json.Unmarshal(server.bodies[0], &sent); err != nil {
+		t.Fatalf("error parsing explain request: %v", err)
+	}
+	var sentReq delivery.Request
+	if err := protojson.Unmarshal(sent.Request, &sentReq); err != nil {
+		t.Fatalf("error parsing explained delivery request: %v", err)
+	}
+	props := sentReq.GetInsertion()[0].GetProperties()
+	if getProperty(props, "email") != nil {
+		t.Error("sent the denied email property")
+	}
+	if got := getProperty(props, "customerId").GetStringValue(); got == "customer-123" || got == "" {
+		t.Errorf("customerId was sent as %q, want it encrypted", got)
+	}
+	if got := getProperty(req.Request.GetInsertion()[0].GetProperties(), "email").GetStringValue(); got != "a@example.com" {
+		t.Errorf("the caller's email became %q", got)
+	}
+}
+
+func TestExplainRefusesOptedOutUsers(t *testing.T) {
+	server := newExplainServer(t, explainEndpointSuffix, writeExplanation)
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithDisableUsageTracking(true).
+		WithGDPROptOut(func(context.Context) bool { return true }))
+
+	if _, err := c.Explain(context.Background(), "a", newTestDeliveryRequest(t, "a")); !errors.Is(err, errOptedOut) {
+		t.Errorf("got error %v, want the opt-out error", err)
+	}
+	if server.calls() != 0 {
+		t.Errorf("got %d explain calls for an opted-out user, want 0", server.calls())
+	}
+}
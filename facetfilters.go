@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const facetFiltersPropertyKey = "facetFilters"
+const rangeFiltersPropertyKey = "rangeFilters"
+
+// FacetFilter matches items whose attribute has one of the values, e.g. brand in [Nike, Adidas].
+type FacetFilter struct {
+	Attribute string   `json:"attribute"`
+	Values    []string `json:"values"`
+}
+
+// RangeFilter matches items whose numeric attribute is within [Min, Max].
+type RangeFilter struct {
+	Attribute string  `json:"attribute"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+}
+
+// BuildFilterExpression renders filters for debugging, e.g.
+// `brand IN (Nike, Adidas) AND price BETWEEN 50 AND 200`.
+func BuildFilterExpression(facets []FacetFilter, ranges []RangeFilter) string {
+	var terms []string
+	for _, f := range facets {
+		terms = append(terms, fmt.Sprintf("%s IN (%s)", f.Attribute, strings.Join(f.Values, ", ")))
+	}
+	for _, r := range ranges {
+		terms = append(terms, fmt.Sprintf("%s BETWEEN %s AND %s", r.Attribute,
+			strconv.FormatFloat(r.Min, 'g', -1, 64), strconv.FormatFloat(r.Max, 'g', -1, 64)))
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// FacetFiltersFromRequest reads back the filters set with WithFacetFilters and WithRangeFilters.
+func FacetFiltersFromRequest(req *delivery.Request) ([]FacetFilter, []RangeFilter, error) {
+	var facets []FacetFilter
+	if err := getJSONProperty(req.GetProperties(), facetFiltersPropertyKey, &facets); err != nil {
+		return nil, nil, err
+	}
+	var ranges []RangeFilter
+	if err := getJSONProperty(req.GetProperties(), rangeFiltersPropertyKey, &ranges); err != nil {
+		return nil, nil, err
+	}
+	return facets, ranges, nil
+}
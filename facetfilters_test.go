@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestFacetFiltersRoundTrip(t *testing.T) {
+	facets := []FacetFilter{{Attribute: "brand", Values: []string{"Nike", "Adidas"}}}
+	ranges := []RangeFilter{{Attribute: "price", Min: 50, Max: 200.5}}
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).
+		WithFacetFilters(facets...).
+		WithRangeFilters(ranges...))
+
+	// Round trip through the wire format too.
+	body, err := protojson.Marshal(req.Request)
+	if err != nil {
+		t.Fatalf("error marshaling request: %v", err)
+	}
+	var sent delivery.Request
+	if err := protojson.Unmarshal(body, &sent); err != nil {
+		t.Fatalf("error unmarshaling request: %v", err)
+	}
+
+	gotFacets, gotRanges, err := FacetFiltersFromRequest(&sent)
+	if err != nil {
+		t.Fatalf("FacetFiltersFromRequest failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotFacets, facets) || !reflect.DeepEqual(gotRanges, ranges) {
+		t.Errorf("got filters %v and %v, want %v and %v", gotFacets, gotRanges, facets, ranges)
+	}
+}
+
+func TestNoFacetFiltersByDefault(t *testing.T) {
+	req := newTestDeliveryRequest(t, "a")
+	facets, ranges, err := FacetFiltersFromRequest(req.Request)
+	if err != nil {
+		t.Fatalf("FacetFiltersFromRequest failed: %v", err)
+	}
+	if facets != nil || ranges != nil {
+		t.Errorf("got filters %v and %v, want none", facets, ranges)
+	}
+}
+
+func TestBuildFilterExpression(t *testing.T) {
+	got := BuildFilterExpression(
+		[]FacetFilter{{Attribute: "brand", Values: []string{"Nike", "Adidas"}}},
+		[]RangeFilter{{Attribute: "price", Min: 50, Max: 200.5}})
+	if want := "brand IN (Nike, Adidas) AND price BETWEEN 50 AND 200.5"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// FairnessConstraint requires that at least RequiredMinFraction of the top TopK insertions have an
+// AttributeKey property in AttributeValues, e.g. items from minority-owned vendors.
+type FairnessConstraint struct {
+	AttributeKey        string
+	RequiredMinFraction float64
+	AttributeValues     []string
+
+	// TopK is how many leading insertions the constraint applies to. 0 means all of them.
+	TopK int
+}
+
+// EnforceFairnessConstraint reorders insertions to satisfy the constraint, promoting the
+// highest-ranked insertions of the constrained groups just far enough, and displacing the
+// lowest-ranked other insertions in the top K. Each group keeps its relative order.
+func EnforceFairnessConstraint(insertions []*delivery.Insertion, constraint FairnessConstraint) []*delivery.Insertion {
+	return enforceFairnessConstraint(insertions, (*delivery.Insertion).GetProperties, constraint)
+}
+
+// enforceFairnessConstraint is EnforceFairnessConstraint reading properties with propertiesOf,
+// since response insertions don't carry their properties.
+func enforceFairnessConstraint(insertions []*delivery.Insertion, propertiesOf func(*delivery.Insertion) *common.Properties, constraint FairnessConstraint) []*delivery.Insertion {
+	k := len(insertions)
+	if constraint.TopK > 0 && constraint.TopK < k {
+		k = constraint.TopK
+	}
+	required := int(math.Ceil(constraint.RequiredMinFraction * float64(k)))
+
+	values := make(map[string]bool, len(constraint.AttributeValues))
+	for _, value := range constraint.AttributeValues {
+		values[value] = true
+	}
+	var protected, others []*delivery.Insertion
+	for _, ins := range insertions {
+		v := getProperty(propertiesOf(ins), constraint.AttributeKey)
+		if v != nil && values[fmt.Sprint(v.AsInterface())] {
+			protected = append(protected, ins)
+		} else {
+			others = append(others, ins)
+		}
+	}
+
+	// Merge the groups by original rank, taking a protected insertion early whenever the
+	// remaining top K slots are only just enough to reach the requirement.
+	rank := make(map[*delivery.Insertion]int, len(insertions))
+	for i, ins := range insertions {
+		rank[ins] = i
+	}
+	reordered := make([]*delivery.Insertion, 0, len(insertions))
+	taken := 0
+	for len(protected) > 0 || len(others) > 0 {
+		position := len(reordered)
+		mustTakeProtected := position < k && required-taken >= k-position
+		takeProtected := len(protected) > 0 &&
+			(mustTakeProtected || len(others) == 0 || rank[protected[0]] < rank[others[0]])
+		if takeProtected {
+			reordered = append(reordered, protected[0])
+			protected = protected[1:]
+			if position < k {
+				taken++
+			}
+		} else {
+			reordered = append(reordered, others[0])
+			others = others[1:]
+		}
+	}
+	return reordered
+}
+
+// fairnessEnforcement reorders each response to satisfy a FairnessConstraint.
+type fairnessEnforcement struct {
+	constraint FairnessConstraint
+}
+
+func (f *fairnessEnforcement) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		requested := requestInsertionsByContentID(req)
+		resp = cloneResponse(resp)
+		resp.Response.Insertion = enforceFairnessConstraint(resp.Response.GetInsertion(), func(ins *delivery.Insertion) *common.Properties {
+			return requested[ins.GetContentId()].GetProperties()
+		}, f.constraint)
+		renumberPositions(resp.Response.Insertion)
+		return resp, nil
+	}
+}
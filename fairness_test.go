@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestEnforceFairnessConstraint(t *testing.T) {
+	constraint := FairnessConstraint{AttributeKey: "vendor", RequiredMinFraction: 0.5, AttributeValues: []string{"minority"}, TopK: 4}
+	tests := []struct {
+		name    string
+		ids     []string
+		vendors []string
+		want    []string
+	}{
+		{
+			// The highest-ranked minority items are promoted just far enough, in their order, and
+			// the displaced items keep theirs.
+			name:    "promotes",
+			ids:     []string{"o1", "o2", "o3", "m1", "o4", "m2"},
+			vendors: []string{"other", "other", "other", "minority", "other", "minority"},
+			want:    []string{"o1", "o2", "m1", "m2", "o3", "o4"},
+		},
+		{
+			name:    "already fair",
+			ids:     []string{"m1", "o1", "m2", "o2", "o3"},
+			vendors: []string{"minority", "other", "minority", "other", "other"},
+			want:    []string{"m1", "o1", "m2", "o2", "o3"},
+		},
+		{
+			// Every minority item is promoted as if the requirement could still be met.
+			name:    "too few to meet",
+			ids:     []string{"o1", "o2", "o3", "o4", "m1"},
+			vendors: []string{"other", "other", "other", "other", "minority"},
+			want:    []string{"o1", "o2", "m1", "o3", "o4"},
+		},
+		{
+			name:    "missing property",
+			ids:     []string{"x1", "x2", "x3", "m1", "m2"},
+			vendors: []string{"", "", "", "minority", "minority"},
+			want:    []string{"x1", "x2", "m1", "m2", "x3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := insertionContentIDsOf(EnforceFairnessConstraint(brandedInsertions(t, "vendor", tt.ids, tt.vendors), constraint))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFairnessConstraintDefaultsToAllInsertions(t *testing.T) {
+	constraint := FairnessConstraint{AttributeKey: "vendor", RequiredMinFraction: 0.4, AttributeValues: []string{"minority"}}
+	insertions := brandedInsertions(t, "vendor",
+		[]string{"o1", "o2", "o3", "m1", "m2"},
+		[]string{"other", "other", "other", "minority", "minority"})
+	// 40% of all 5 is already met.
+	got := insertionContentIDsOf(EnforceFairnessConstraint(insertions, constraint))
+	if want := []string{"o1", "o2", "o3", "m1", "m2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFairnessConstraintAppliesToResponses(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithFairnessConstraint(FairnessConstraint{
+		AttributeKey: "vendor", RequiredMinFraction: 0.5, AttributeValues: []string{"minority"}, TopK: 2,
+	}))
+	req := newTestDeliveryRequest(t)
+	req.Request.Insertion = brandedInsertions(t, "vendor", []string{"o1", "o2", "m1"}, []string{"other", "other", "minority"})
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "o1", "m1", "o2")
+	if got := resp.Response.GetInsertion()[1].GetPosition(); got != 1 {
+		t.Errorf("got position %d for m1, want positions renumbered", got)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/promotedai/schema/generated/go/proto/event"
+	"github.com/spaolacci/murmur3"
+	"sigs.k8s.io/yaml"
+)
+
+// featureStoreBuckets is the hash resolution of traffic fractions and treatment weights.
+const featureStoreBuckets = 10000
+
+// armSeed makes arm assignment independent of traffic assignment.
+const armSeed = 1
+
+// ErrExperimentNotFound is returned by GetTreatment for experiments that weren't loaded.
+var ErrExperimentNotFound = errors.New("experiment not found")
+
+// ErrNotInExperiment is returned by GetTreatment for users outside the experiment's traffic.
+var ErrNotInExperiment = errors.New("user is not in the experiment's traffic")
+
+// TreatmentArm is a user's treatment in an experiment.
+type TreatmentArm struct {
+	ExperimentID string
+	Name         string
+	Arm          event.CohortArm
+}
+
+// experimentConfig is one experiment of the YAML config.
+type experimentConfig struct {
+	ID string `json:"id"`
+	// TrafficFraction is the share of users in the experiment, from 0 to 1.
+	TrafficFraction float64           `json:"trafficFraction"`
+	Treatments      []treatmentConfig `json:"treatments"`
+}
+
+type treatmentConfig struct {
+	Name string `json:"name"`
+	// Arm is the cohort arm sent to Promoted, e.g. CONTROL or TREATMENT.
+	Arm    string  `json:"arm"`
+	Weight float64 `json:"weight"`
+}
+
+// loadedExperiment is an experiment with its arms' cumulative bucket bounds.
+type loadedExperiment struct {
+	id             string
+	trafficBuckets uint32
+	arms           []TreatmentArm
+	armBounds      []uint32
+}
+
+// LocalFeatureStore buckets users into experiment treatments locally by hashing, so assignment
+// doesn't depend on a remote experimentation service. Assignments are stable for a given config.
+type LocalFeatureStore struct {
+	mu          sync.RWMutex
+	experiments map[string]*loadedExperiment
+	order       []string
+}
+
+// NewLocalFeatureStore is a factory method for LocalFeatureStore.
+func NewLocalFeatureStore() *LocalFeatureStore {
+	return &LocalFeatureStore{experiments: map[string]*loadedExperiment{}}
+}
+
+// LoadExperiments replaces the experiments with those of a YAML config like:
+//
+//	experiments:
+//	  - id: ranking-v2
+//	    trafficFraction: 0.5
+//	    treatments:
+//	      - {name: control, arm: CONTROL, weight: 1}
+//	      - {name: new-model, arm: TREATMENT, weight: 1}
+func (s *LocalFeatureStore) LoadExperiments(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error reading experiments: %v", err)
+	}
+	var config struct {
+		Experiments []experimentConfig `json:"experiments"`
+	}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return fmt.Errorf("error parsing experiments: %v", err)
+	}
+
+	experiments := make(map[string]*loadedExperiment, len(config.Experiments))
+	order := make([]string, 0, len(config.Experiments))
+	for _, exp := range config.Experiments {
+		loaded, err := loadExperiment(exp)
+		if err != nil {
+			return err
+		}
+		if _, ok := experiments[exp.ID]; ok {
+			return fmt.Errorf("duplicate experiment %s", exp.ID)
+		}
+		experiments[exp.ID] = loaded
+		order = append(order, exp.ID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.experiments = experiments
+	s.order = order
+	return nil
+}
+
+func loadExperiment(exp experimentConfig) (*loadedExperiment, error) {
+	if exp.ID == "" {
+		return nil, errors.New("experiment ID needs to be specified")
+	}
+	if exp.TrafficFraction < 0 || exp.TrafficFraction > 1 {
+		return nil, fmt.Errorf("traffic fraction of experiment %s must be between 0 and 1", exp.ID)
+	}
+	if len(exp.Treatments) == 0 {
+		return nil, fmt.Errorf("experiment %s needs treatments", exp.ID)
+	}
+	var totalWeight float64
+	for _, t := range exp.Treatments {
+		if t.Weight <= 0 {
+			return nil, fmt.Errorf("treatment %s of experiment %s needs a positive weight", t.Name, exp.ID)
+		}
+		totalWeight += t.Weight
+	}
+
+	loaded := &loadedExperiment{id: exp.ID, trafficBuckets: uint32(exp.TrafficFraction * featureStoreBuckets)}
+	var cumulative float64
+	for _, t := range exp.Treatments {
+		arm, ok := event.CohortArm_value[t.Arm]
+		if !ok {
+			return nil, fmt.Errorf("unknown arm %q of experiment %s", t.Arm, exp.ID)
+		}
+		cumulative += t.Weight
+		loaded.arms = append(loaded.arms, TreatmentArm{ExperimentID: exp.ID, Name: t.Name, Arm: event.CohortArm(arm)})
+		loaded.armBounds = append(loaded.armBounds, uint32(cumulative/totalWeight*featureStoreBuckets))
+	}
+	// Rounding must not leave the last buckets unassigned.
+	loaded.armBounds[len(loaded.armBounds)-1] = featureStoreBuckets
+	return loaded, nil
+}
+
+// GetTreatment returns the user's treatment in the experiment, ErrExperimentNotFound if it isn't
+// loaded, or ErrNotInExperiment if the user is outside its traffic.
+func (s *LocalFeatureStore) GetTreatment(experimentID, userID string) (TreatmentArm, error) {
+	s.mu.RLock()
+	exp, ok := s.experiments[experimentID]
+	s.mu.RUnlock()
+	if !ok {
+		return TreatmentArm{}, ErrExperimentNotFound
+	}
+	return exp.assign(userID)
+}
+
+func (e *loadedExperiment) assign(userID string) (TreatmentArm, error) {
+	key := []byte(e.id + ":" + userID)
+	if hashWithSeed(key, 0)%featureStoreBuckets >= e.trafficBuckets {
+		return TreatmentArm{}, ErrNotInExperiment
+	}
+	bucket := hashWithSeed(key, armSeed) % featureStoreBuckets
+	for i, bound := range e.armBounds {
+		if bucket < bound {
+			return e.arms[i], nil
+		}
+	}
+	return e.arms[len(e.arms)-1], nil
+}
+
+// hashWithSeed is murmur3.Sum32WithSeed through the digest, whose block reads pass the race
+// detector's pointer checks.
+func hashWithSeed(key []byte, seed uint32) uint32 {
+	h := murmur3.New32WithSeed(seed)
+	h.Write(key)
+	return h.Sum32()
+}
+
+// firstTreatment returns the user's treatment in the first loaded experiment whose traffic they're
+// in.
+func (s *LocalFeatureStore) firstTreatment(userID string) (TreatmentArm, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, id := range s.order {
+		if arm, err := s.experiments[id].assign(userID); err == nil {
+			return arm, true
+		}
+	}
+	return TreatmentArm{}, false
+}
+
+// middleware assigns requests without an experiment to the user's cohort from the store, so the SDK
+// serves control users without calling the Delivery API and logs their cohort membership.
+func (s *LocalFeatureStore) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		userID := requestUserID(req.Request)
+		if req.Experiment != nil || userID == "" {
+			return next(ctx, req)
+		}
+		arm, ok := s.firstTreatment(userID)
+		if !ok {
+			return next(ctx, req)
+		}
+		req = cloneRequest(req)
+		req.Experiment = &event.CohortMembership{CohortId: arm.ExperimentID, Arm: arm.Arm}
+		return next(ctx, req)
+	}
+}
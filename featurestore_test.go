@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/event"
+)
+
+const testExperiments = `
+experiments:
+  - id: ranking-v2
+    trafficFraction: 0.5
+    treatments:
+      - {name: control, arm: CONTROL, weight: 1}
+      - {name: new-model, arm: TREATMENT, weight: 3}
+  - id: everyone
+    trafficFraction: 1
+    treatments:
+      - {name: control, arm: CONTROL, weight: 1}
+      - {name: new-model, arm: TREATMENT, weight: 1}
+`
+
+func newTestFeatureStore(t *testing.T, config string) *LocalFeatureStore {
+	t.Helper()
+	fs := NewLocalFeatureStore()
+	if err := fs.LoadExperiments(strings.NewReader(config)); err != nil {
+		t.Fatalf("LoadExperiments failed: %v", err)
+	}
+	return fs
+}
+
+// userInArm returns a user the store assigns to arm of experimentID.
+func userInArm(t *testing.T, fs *LocalFeatureStore, experimentID string, arm event.CohortArm) string {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		if treatment, err := fs.GetTreatment(experimentID, userID); err == nil && treatment.Arm == arm {
+			return userID
+		}
+	}
+	t.Fatalf("no user is in arm %v of %s", arm, experimentID)
+	return ""
+}
+
+func TestFeatureStoreAssignmentDistribution(t *testing.T) {
+	fs := newTestFeatureStore(t, testExperiments)
+
+	const users = 10000
+	counts := map[string]int{}
+	for i := 0; i < users; i++ {
+		treatment, err := fs.GetTreatment("ranking-v2", fmt.Sprintf("user-%d", i))
+		switch {
+		case errors.Is(err, ErrNotInExperiment):
+			counts["outside"]++
+		case err != nil:
+			t.Fatalf("GetTreatment failed: %v", err)
+		default:
+			counts[treatment.Name]++
+		}
+	}
+	// Each count is within about 5 standard deviations of its expected share.
+	for name, share := range map[string]float64{"outside": 0.5, "control": 0.125, "new-model": 0.375} {
+		want := share * users
+		tolerance := 5 * math.Sqrt(users*share*(1-share))
+		if got := float64(counts[name]); math.Abs(got-want) > tolerance {
+			t.Errorf("got %v users in %s, want %v ± %.0f", got, name, want, tolerance)
+		}
+	}
+}
+
+func TestFeatureStoreAssignmentIsStable(t *testing.T) {
+	fs := newTestFeatureStore(t, testExperiments)
+	reloaded := newTestFeatureStore(t, testExperiments)
+	for i := 0; i < 100; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		first, firstErr := fs.GetTreatment("everyone", userID)
+		second, secondErr := reloaded.GetTreatment("everyone", userID)
+		if first != second || firstErr != secondErr {
+			t.Fatalf("%s got %v and %v from the same config", userID, first, second)
+		}
+	}
+}
+
+func TestFeatureStoreExperimentNotFound(t *testing.T) {
+	fs := newTestFeatureStore(t, testExperiments)
+	if _, err := fs.GetTreatment("missing", "user-1"); !errors.Is(err, ErrExperimentNotFound) {
+		t.Errorf("got error %v, want ErrExperimentNotFound", err)
+	}
+}
+
+func TestFeatureStoreRejectsInvalidConfig(t *testing.T) {
+	for name, config := range map[string]string{
+		"missing ID":        "experiments: [{trafficFraction: 1, treatments: [{name: a, arm: CONTROL, weight: 1}]}]",
+		"traffic over 1":    "experiments: [{id: x, trafficFraction: 2, treatments: [{name: a, arm: CONTROL, weight: 1}]}]",
+		"no treatments":     "experiments: [{id: x, trafficFraction: 1}]",
+		"zero weight":       "experiments: [{id: x, trafficFraction: 1, treatments: [{name: a, arm: CONTROL, weight: 0}]}]",
+		"unknown arm":       "experiments: [{id: x, trafficFraction: 1, treatments: [{name: a, arm: BLUE, weight: 1}]}]",
+		"unknown field":     "experiments: [{id: x, traffic: 1, treatments: [{name: a, arm: CONTROL, weight: 1}]}]",
+		"duplicate":         "experiments: [{id: x, trafficFraction: 1, treatments: [{name: a, arm: CONTROL, weight: 1}]}, {id: x, trafficFraction: 1, treatments: [{name: a, arm: CONTROL, weight: 1}]}]",
+		"not an experiment": "experiments: nope",
+	} {
+		if err := NewLocalFeatureStore().LoadExperiments(strings.NewReader(config)); err == nil {
+			t.Errorf("%s: LoadExperiments succeeded", name)
+		}
+	}
+}
+
+func TestFeatureStoreAssignsRequestCohorts(t *testing.T) {
+	fs := newTestFeatureStore(t, testExperiments)
+	for _, arm := range []event.CohortArm{event.CohortArm_CONTROL, event.CohortArm_TREATMENT} {
+		userID := userInArm(t, fs, "ranking-v2", arm)
+		var got *event.CohortMembership
+		deliver := fs.middleware(func(_ context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+			got = req.Experiment
+			return nil, nil
+		})
+		req := newUserDeliveryRequest(t, userID, "a")
+		if _, err := deliver(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+		if got.GetCohortId() != "ranking-v2" || got.GetArm() != arm {
+			t.Errorf("%s got cohort %v, want %v of ranking-v2", userID, got, arm)
+		}
+		if req.Experiment != nil {
+			t.Error("the caller's request was modified")
+		}
+	}
+}
+
+func TestFeatureStoreKeepsRequestExperiment(t *testing.T) {
+	fs := newTestFeatureStore(t, testExperiments)
+	want := &event.CohortMembership{CohortId: "caller", Arm: event.CohortArm_TREATMENT}
+	var got *event.CohortMembership
+	deliver := fs.middleware(func(_ context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		got = req.Experiment
+		return nil, nil
+	})
+	req := newUserDeliveryRequest(t, "user-1", "a")
+	req.Experiment = want
+	if _, err := deliver(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got cohort %v, want the caller's", got)
+	}
+}
+
+func TestFeatureStoreControlSkipsDeliveryAPI(t *testing.T) {
+	api := newFakeAPI(t)
+	// Everyone is in ranking-v2's traffic, so it's the first experiment of each user.
+	fs := newTestFeatureStore(t, strings.Replace(testExperiments, "trafficFraction: 0.5", "trafficFraction: 1", 1))
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithLocalFeatureStore(fs))
+
+	control := userInArm(t, fs, "ranking-v2", event.CohortArm_CONTROL)
+	if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, control, "a", "b")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.calls(); got != 0 {
+		t.Errorf("control user called the Delivery API %d times", got)
+	}
+
+	treatment := userInArm(t, fs, "ranking-v2", event.CohortArm_TREATMENT)
+	if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, treatment, "a", "b")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.calls(); got != 1 {
+		t.Errorf("treatment user called the Delivery API %d times, want 1", got)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// fieldMaskTree is a field mask as a tree of field names. A leaf keeps its whole field.
+type fieldMaskTree map[protoreflect.Name]fieldMaskTree
+
+func newFieldMaskTree(mask *fieldmaskpb.FieldMask) fieldMaskTree {
+	tree := fieldMaskTree{}
+	for _, path := range mask.GetPaths() {
+		node := tree
+		for _, name := range strings.Split(path, ".") {
+			child, ok := node[protoreflect.Name(name)]
+			if !ok {
+				child = fieldMaskTree{}
+				node[protoreflect.Name(name)] = child
+			}
+			node = child
+		}
+	}
+	return tree
+}
+
+// prune clears the fields of m that aren't in the tree.
+func (t fieldMaskTree) prune(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		subtree, ok := t[fd.Name()]
+		switch {
+		case !ok:
+			m.Clear(fd)
+		case len(subtree) > 0 && fd.Message() != nil && !fd.IsList() && !fd.IsMap():
+			subtree.prune(v.Message())
+		}
+		return true
+	})
+}
+
+// autoFieldMask masks m to its non-default fields. Proto serialization already skips default
+// scalars, so this mostly drops empty messages, which are otherwise sent as {}.
+func autoFieldMask(m protoreflect.Message) *fieldmaskpb.FieldMask {
+	mask := &fieldmaskpb.FieldMask{}
+	appendPopulatedPaths(mask, "", m)
+	return mask
+}
+
+func appendPopulatedPaths(mask *fieldmaskpb.FieldMask, prefix string, m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		path := prefix + string(fd.Name())
+		if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+			appendPopulatedPaths(mask, path+".", v.Message())
+			return true
+		}
+		mask.Paths = append(mask.Paths, path)
+		return true
+	})
+}
+
+// applyFieldMasks returns a copy of request keeping only the fields in mask, if set, and then only
+// the non-default fields if auto is set. It returns request itself if neither applies.
+func applyFieldMasks(request *delivery.Request, mask *fieldmaskpb.FieldMask, auto bool) *delivery.Request {
+	if mask == nil && !auto {
+		return request
+	}
+	masked := proto.Clone(request).(*delivery.Request)
+	if mask != nil {
+		newFieldMaskTree(mask).prune(masked.ProtoReflect())
+	}
+	if auto {
+		newFieldMaskTree(autoFieldMask(masked.ProtoReflect())).prune(masked.ProtoReflect())
+	}
+	return masked
+}
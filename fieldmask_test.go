@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// maskTestRequest has nested, repeated and empty message fields.
+func maskTestRequest(t *testing.T) *delivery.Request {
+	t.Helper()
+	req := &delivery.Request{
+		UserInfo:    &common.UserInfo{UserId: "user-1", AnonUserId: "anon-1"},
+		SearchQuery: "shoes",
+		Paging:      &delivery.Paging{},
+		Insertion:   testInsertions("a", "b"),
+	}
+	if err := setProperty(&req.Properties, "region", "us"); err != nil {
+		t.Fatalf("error setting property: %v", err)
+	}
+	return req
+}
+
+func marshaledSize(t *testing.T, req *delivery.Request) int {
+	t.Helper()
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("error marshaling request: %v", err)
+	}
+	return len(data)
+}
+
+func TestFieldMaskKeepsOnlyMaskedFields(t *testing.T) {
+	req := maskTestRequest(t)
+	mask, err := fieldmaskpb.New(req, "user_info.anon_user_id", "insertion")
+	if err != nil {
+		t.Fatalf("invalid field mask: %v", err)
+	}
+
+	masked := applyFieldMasks(req, mask, false)
+	if marshaledSize(t, masked) >= marshaledSize(t, req) {
+		t.Errorf("masked request is %d bytes, want fewer than the request's %d", marshaledSize(t, masked), marshaledSize(t, req))
+	}
+	if masked.GetUserInfo().GetAnonUserId() != "anon-1" || len(masked.GetInsertion()) != 2 {
+		t.Errorf("masked request %v lost masked fields", masked)
+	}
+	if masked.GetUserInfo().GetUserId() != "" || masked.SearchQuery != "" || masked.Paging != nil || masked.Properties != nil {
+		t.Errorf("masked request %v kept unmasked fields", masked)
+	}
+	if req.GetUserInfo().GetUserId() != "user-1" || req.SearchQuery != "shoes" {
+		t.Error("masking modified the original request")
+	}
+}
+
+func TestAutoFieldMaskDropsEmptyMessages(t *testing.T) {
+	req := maskTestRequest(t)
+
+	masked := applyFieldMasks(req, nil, true)
+	if marshaledSize(t, masked) >= marshaledSize(t, req) {
+		t.Errorf("masked request is %d bytes, want fewer than the request's %d", marshaledSize(t, masked), marshaledSize(t, req))
+	}
+	if masked.Paging != nil {
+		t.Errorf("kept empty paging %v", masked.Paging)
+	}
+	req.Paging = nil
+	if !proto.Equal(masked, req) {
+		t.Errorf("got masked request %v, want %v without the empty paging", masked, req)
+	}
+}
+
+func TestNoFieldMaskSendsRequestAsIs(t *testing.T) {
+	req := maskTestRequest(t)
+	if applyFieldMasks(req, nil, false) != req {
+		t.Error("copied the request without a field mask")
+	}
+}
+
+func TestClientSendsMaskedRequest(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithFieldMask("user_info", "insertion", "client_request_id").
+		WithAutoFieldMask(true))
+
+	resp, err := c.Deliver(context.Background(), buildTestRequest(t, NewDeliveryRequestBuilder(maskTestRequest(t))))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "a", "b")
+	sent := api.lastRequest(t)
+	if sent.GetUserInfo().GetAnonUserId() != "anon-1" || sent.GetClientRequestId() == "" {
+		t.Errorf("sent request %v lost masked fields", sent)
+	}
+	if sent.SearchQuery != "" || sent.Paging != nil || sent.Properties != nil {
+		t.Errorf("sent request %v kept unmasked fields", sent)
+	}
+}
+
+func TestInvalidFieldMaskFailsBuild(t *testing.T) {
+	if _, err := newTestClientBuilder(t, newFakeAPI(t)).WithFieldMask("no_such_field").Build(); err == nil {
+		t.Error("Build succeeded with an invalid field mask")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/hex"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// unrankedHeaders are per-call headers that don't change the ranking, so they're left out of
+// fingerprints.
+var unrankedHeaders = map[string]bool{
+	http.CanonicalHeaderKey(correlationIDHeader): true,
+	http.CanonicalHeaderKey(importanceHeader):    true,
+}
+
+// RequestFingerprint hashes the parts of a request that determine its ranking: use case, user and
+// anonymous user IDs, search query, request properties, sorted insertion content IDs, paging, the
+// experiment cohort and per-call headers such as model version and experiment overrides. Equal
+// fingerprints mean the requests are interchangeable.
+func RequestFingerprint(req *DeliveryRequest) string {
+	r := req.Request
+	contentIDs := make([]string, 0, len(r.GetInsertion()))
+	for _, ins := range r.GetInsertion() {
+		contentIDs = append(contentIDs, ins.GetContentId())
+	}
+	sort.Strings(contentIDs)
+
+	h := fnv.New128a()
+	write := func(s string) {
+		h.Write([]byte(s))
+		// Separate fields so that ("ab", "c") and ("a", "bc") hash differently.
+		h.Write([]byte{0})
+	}
+	write(r.GetUseCase().String())
+	write(r.GetUserInfo().GetUserId())
+	write(r.GetUserInfo().GetAnonUserId())
+	write(r.GetSearchQuery())
+	// Deterministic marshaling orders map entries, so equal properties hash the same.
+	properties, _ := proto.MarshalOptions{Deterministic: true}.Marshal(r.GetProperties())
+	write(string(properties))
+	write(strconv.Itoa(len(contentIDs)))
+	for _, id := range contentIDs {
+		write(id)
+	}
+	write(strconv.FormatInt(int64(r.GetPaging().GetOffset()), 10))
+	write(strconv.FormatInt(int64(r.GetPaging().GetSize()), 10))
+	write(req.Experiment.GetCohortId())
+	write(req.Experiment.GetArm().String())
+
+	keys := make([]string, 0, len(req.Headers))
+	for key := range req.Headers {
+		if !unrankedHeaders[http.CanonicalHeaderKey(key)] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	write(strconv.Itoa(len(keys)))
+	for _, key := range keys {
+		write(http.CanonicalHeaderKey(key))
+		write(strings.Join(req.Headers.Values(key), ","))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
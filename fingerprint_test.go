@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"github.com/promotedai/schema/generated/go/proto/event"
+)
+
+func TestRequestFingerprintIgnoresInsertionOrder(t *testing.T) {
+	a := newTestDeliveryRequest(t, "a", "b", "c")
+	b := newTestDeliveryRequest(t, "c", "a", "b")
+	if RequestFingerprint(a) != RequestFingerprint(b) {
+		t.Error("requests differing only in insertion order have different fingerprints")
+	}
+}
+
+func TestRequestFingerprintDistinguishesRequests(t *testing.T) {
+	base := newTestDeliveryRequest(t, "a", "b")
+	variants := map[string]func(r *delivery.Request){
+		"use case":   func(r *delivery.Request) { r.UseCase = delivery.UseCase_SEARCH },
+		"user":       func(r *delivery.Request) { r.UserInfo.AnonUserId = "anon-2" },
+		"user ID":    func(r *delivery.Request) { r.UserInfo.UserId = "user-2" },
+		"properties": func(r *delivery.Request) { setProperty(&r.Properties, "segment", "vip") },
+		"query":      func(r *delivery.Request) { r.SearchQuery = "shoes" },
+		"insertions": func(r *delivery.Request) { r.Insertion = testInsertions("a", "c") },
+		"offset":     func(r *delivery.Request) { r.Paging = &delivery.Paging{Starting: &delivery.Paging_Offset{Offset: 10}} },
+		"size":       func(r *delivery.Request) { r.Paging = &delivery.Paging{Size: 10} },
+		"split ids":  func(r *delivery.Request) { r.Insertion = testInsertions("ab") },
+	}
+	for name, mutate := range variants {
+		req := cloneRequest(base)
+		mutate(req.Request)
+		if RequestFingerprint(req) == RequestFingerprint(base) {
+			t.Errorf("changing the %s didn't change the fingerprint", name)
+		}
+	}
+}
+
+func TestRequestFingerprintDistinguishesCallOptions(t *testing.T) {
+	base := newTestDeliveryRequest(t, "a", "b")
+	variants := map[string]func(req *DeliveryRequest){
+		"model version": func(req *DeliveryRequest) { req.Headers = http.Header{modelVersionHeader: {"v2"}} },
+		"experiment override": func(req *DeliveryRequest) {
+			if err := applyExperimentOverride(req, ExperimentOverride{ModelID: "model-2"}); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"cohort": func(req *DeliveryRequest) {
+			req.Experiment = &event.CohortMembership{CohortId: "ranking-v2", Arm: event.CohortArm_CONTROL}
+		},
+	}
+	for name, mutate := range variants {
+		req := newTestDeliveryRequest(t, "a", "b")
+		mutate(req)
+		if RequestFingerprint(req) == RequestFingerprint(base) {
+			t.Errorf("changing the %s didn't change the fingerprint", name)
+		}
+	}
+
+	// Correlation IDs differ on every call but don't change the ranking.
+	req := newTestDeliveryRequest(t, "a", "b")
+	req.Headers.Set(correlationIDHeader, "request-1")
+	if RequestFingerprint(req) != RequestFingerprint(base) {
+		t.Error("a correlation ID changed the fingerprint")
+	}
+}
+
+func TestRequestFingerprintIsStableForEqualProperties(t *testing.T) {
+	a := newTestDeliveryRequest(t, "a")
+	b := newTestDeliveryRequest(t, "a")
+	for _, key := range []string{"x", "y", "z", "w"} {
+		setProperty(&a.Request.Properties, key, key)
+	}
+	for _, key := range []string{"w", "z", "y", "x"} {
+		setProperty(&b.Request.Properties, key, key)
+	}
+	for i := 0; i < 20; i++ {
+		if RequestFingerprint(a) != RequestFingerprint(b) {
+			t.Fatal("equal properties have different fingerprints")
+		}
+	}
+}
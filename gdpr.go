@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// FallbackReasonGDPROptOut marks responses for users who opted out of personalization.
+const FallbackReasonGDPROptOut = "GDPR_OPT_OUT"
+
+// errOptedOut is returned by calls with no SDK fallback, e.g. Explain, for opted-out users.
+var errOptedOut = errors.New("user opted out under GDPR")
+
+// gdprOptOut serves opted-out users their insertions in the original order, without calling the
+// Delivery API or logging to the Metrics API.
+type gdprOptOut struct {
+	optedOut func(ctx context.Context) bool
+}
+
+func (g *gdprOptOut) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		if !g.optedOut(ctx) {
+			return next(ctx, req)
+		}
+		// Opting out is routine, so it isn't logged.
+		req = cloneRequest(req)
+		req.Request.DisablePersonalization = true
+		if req.Request.UserInfo != nil {
+			req.Request.UserInfo.UserId = ""
+			req.Request.UserInfo.AnonUserId = ""
+		}
+		return newSDKResponse(req, FallbackReasonGDPROptOut)
+	}
+}
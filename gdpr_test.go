@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// syncBuffer is a bytes.Buffer that's safe to log to from several goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLog sends the standard logger's output to a buffer for the rest of the test.
+func captureLog(t *testing.T) *syncBuffer {
+	t.Helper()
+	buf := &syncBuffer{}
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return buf
+}
+
+type optedOutKey struct{}
+
+func optedOutFromContext(ctx context.Context) bool {
+	optedOut, _ := ctx.Value(optedOutKey{}).(bool)
+	return optedOut
+}
+
+func TestGDPROptOutMakesNoHTTPCalls(t *testing.T) {
+	api := newFakeAPI(t)
+	metrics, logged := newRecordingServer(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithMetricsEndpoint(metrics.URL).WithGDPROptOut(optedOutFromContext))
+	logs := captureLog(t)
+
+	ctx := context.WithValue(context.Background(), optedOutKey{}, true)
+	for _, onlyLog := range []bool{false, true} {
+		req := buildTestRequest(t, NewDeliveryRequestBuilder(newUserDeliveryRequest(t, "user-secret", "b", "a").Request).WithOnlyLog(onlyLog))
+		resp, err := c.Deliver(ctx, req)
+		if err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+		assertContentIDs(t, resp, "b", "a")
+		if resp.FallbackReason != FallbackReasonGDPROptOut || resp.ExecutionServer != delivery.ExecutionServer_SDK {
+			t.Errorf("got fallback reason %q from %v, want %q from the SDK", resp.FallbackReason, resp.ExecutionServer, FallbackReasonGDPROptOut)
+		}
+	}
+	c.Close()
+
+	if api.calls() != 0 || len(logged()) != 0 {
+		t.Errorf("got %d Delivery API and %d Metrics API calls for an opted-out user, want none", api.calls(), len(logged()))
+	}
+	if strings.Contains(logs.String(), "user-secret") {
+		t.Errorf("logs contain the opted-out user's ID: %s", logs.String())
+	}
+	if strings.Contains(logs.String(), "opted out") {
+		t.Errorf("logged routine opt-outs: %s", logs.String())
+	}
+}
+
+func TestGDPROptInIsPersonalized(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithGDPROptOut(optedOutFromContext))
+
+	if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	sent := api.lastRequest(t)
+	if sent.GetDisablePersonalization() || sent.GetUserInfo().GetUserId() != "user-1" {
+		t.Errorf("opted-in request was sent with personalization disabled=%v and user ID %q", sent.GetDisablePersonalization(), sent.GetUserInfo().GetUserId())
+	}
+}
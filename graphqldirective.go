@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// PromotedDirective ranks the results of GraphQL fields annotated with
+// `@promoted(useCase: "SEARCH")` in gqlgen servers. Set it as DirectiveRoot.Promoted, declared in
+// the schema as:
+//
+//	directive @promoted(useCase: String!) on FIELD_DEFINITION
+type PromotedDirective struct {
+	client             DeliveryClientInterface
+	contentIDExtractor func(any) string
+}
+
+// NewPromotedDirective is a factory method for PromotedDirective. contentIDExtractor returns the
+// content ID of one element of a field's result list.
+func NewPromotedDirective(client DeliveryClientInterface, contentIDExtractor func(any) string) *PromotedDirective {
+	return &PromotedDirective{client: client, contentIDExtractor: contentIDExtractor}
+}
+
+// Promoted resolves the field, then returns its list of results in delivery order. The user info
+// comes from the context, so the GraphQL handler should be wrapped in DeliveryContextMiddleware.
+// The search query and paging come from the field's arguments, see requestFromArgs. Results the
+// response leaves out are appended in their original order, unless a page size was requested, and
+// results sharing a content ID are kept together.
+func (d *PromotedDirective) Promoted(ctx context.Context, obj any, next graphql.Resolver, useCase string) (any, error) {
+	res, err := next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := reflect.ValueOf(res)
+	if items.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("@promoted field must resolve to a list, got %T", res)
+	}
+	if items.Len() == 0 {
+		return res, nil
+	}
+	parsedUseCase, err := ParseUseCase(useCase)
+	if err != nil {
+		return nil, err
+	}
+
+	byContentID := make(map[string][]int, items.Len())
+	var insertions []*delivery.Insertion
+	for i := 0; i < items.Len(); i++ {
+		contentID := d.contentIDExtractor(items.Index(i).Interface())
+		if _, ok := byContentID[contentID]; ok {
+			log.Printf("WARN: @promoted field has duplicate content ID %q, ranking its results together\n", contentID)
+		} else {
+			insertions = append(insertions, &delivery.Insertion{ContentId: contentID})
+		}
+		byContentID[contentID] = append(byContentID[contentID], i)
+	}
+	request := &delivery.Request{
+		UseCase:   parsedUseCase,
+		Insertion: insertions,
+	}
+	if fc := graphql.GetFieldContext(ctx); fc != nil {
+		requestFromArgs(fc.Args, request)
+	}
+	req, err := NewDeliveryRequestBuilder(request).WithUserInfo(UserInfoFromContext(ctx)).Build()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Deliver(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := reflect.MakeSlice(items.Type(), 0, items.Len())
+	placed := make([]bool, items.Len())
+	for _, ins := range resp.Response.GetInsertion() {
+		for _, i := range byContentID[ins.GetContentId()] {
+			if !placed[i] {
+				ranked = reflect.Append(ranked, items.Index(i))
+				placed[i] = true
+			}
+		}
+	}
+	if request.GetPaging().GetSize() > 0 {
+		return ranked.Interface(), nil
+	}
+	unranked := 0
+	for i := 0; i < items.Len(); i++ {
+		if !placed[i] {
+			ranked = reflect.Append(ranked, items.Index(i))
+			unranked++
+		}
+	}
+	if unranked > 0 {
+		log.Printf("WARN: delivery response left out %d @promoted results, appending them in their original order\n", unranked)
+	}
+	return ranked.Interface(), nil
+}
+
+// requestFromArgs sets the request's search query from a query or searchQuery argument, its page
+// size from first, limit or size, and where the page starts from offset, or after or cursor.
+func requestFromArgs(args map[string]any, req *delivery.Request) {
+	if query, ok := stringArg(args, "query", "searchQuery"); ok {
+		req.SearchQuery = query
+	}
+	size, hasSize := intArg(args, "first", "limit", "size")
+	offset, hasOffset := intArg(args, "offset")
+	cursor, hasCursor := stringArg(args, "after", "cursor")
+	if !hasSize && !hasOffset && !hasCursor {
+		return
+	}
+	req.Paging = &delivery.Paging{Size: int32(size)}
+	if hasCursor {
+		req.Paging.Starting = &delivery.Paging_Cursor{Cursor: cursor}
+	} else if hasOffset {
+		req.Paging.Starting = &delivery.Paging_Offset{Offset: int32(offset)}
+	}
+}
+
+// stringArg returns the first of the named arguments that is a non-empty string.
+func stringArg(args map[string]any, names ...string) (string, bool) {
+	for _, name := range names {
+		if v := argValue(args[name]); v.IsValid() && v.Kind() == reflect.String && v.String() != "" {
+			return v.String(), true
+		}
+	}
+	return "", false
+}
+
+// intArg returns the first of the named arguments that is an integer.
+func intArg(args map[string]any, names ...string) (int64, bool) {
+	for _, name := range names {
+		v := argValue(args[name])
+		if v.IsValid() && v.CanInt() {
+			return v.Int(), true
+		}
+	}
+	return 0, false
+}
+
+// argValue dereferences an argument, since gqlgen passes optional arguments as pointers.
+func argValue(arg any) reflect.Value {
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+type testProduct struct {
+	ID   string
+	Name string
+}
+
+func productID(v any) string {
+	return v.(*testProduct).ID
+}
+
+func productIDs(t *testing.T, res any) []string {
+	t.Helper()
+	products, ok := res.([]*testProduct)
+	if !ok {
+		t.Fatalf("got result %T, want []*testProduct", res)
+	}
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// resolveProducts calls the directive as gqlgen's generated code does, for a field with args
+// resolving to products with ids.
+func resolveProducts(t *testing.T, d *PromotedDirective, args map[string]any, ids ...string) (any, error) {
+	t.Helper()
+	products := make([]*testProduct, len(ids))
+	for i, id := range ids {
+		products[i] = &testProduct{ID: id, Name: "product " + id}
+	}
+	ctx := graphql.WithFieldContext(context.Background(), &graphql.FieldContext{Args: args})
+	ctx = context.WithValue(ctx, userIDKey{}, "user-1")
+	return d.Promoted(ctx, nil, func(context.Context) (any, error) { return products, nil }, "SEARCH")
+}
+
+func newRankingDirective(t *testing.T, ranking ...string) (*PromotedDirective, *fakeAPI) {
+	t.Helper()
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		writeDeliveryResponse(t, w, rankedResponse(ranking...))
+	})
+	return NewPromotedDirective(buildTestClient(t, newTestClientBuilder(t, api)), productID), api
+}
+
+func TestPromotedDirectiveRanksResults(t *testing.T) {
+	d, api := newRankingDirective(t, "c", "a")
+
+	res, err := resolveProducts(t, d, nil, "a", "b", "c")
+	if err != nil {
+		t.Fatalf("Promoted failed: %v", err)
+	}
+	// Results the response leaves out keep their original order at the end.
+	if got, want := productIDs(t, res), []string{"c", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	sent := api.lastRequest(t)
+	if sent.GetUseCase() != delivery.UseCase_SEARCH || sent.GetUserInfo().GetUserId() != "user-1" {
+		t.Errorf("got use case %v and user info %v, want SEARCH and user-1", sent.GetUseCase(), sent.GetUserInfo())
+	}
+	if got := insertionContentIDsOf(sent.GetInsertion()); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("sent insertions %v, want [a b c]", got)
+	}
+}
+
+func TestPromotedDirectiveUsesFieldArguments(t *testing.T) {
+	d, api := newRankingDirective(t, "c", "a")
+	first := 2
+	res, err := resolveProducts(t, d, map[string]any{"query": "shoes", "first": &first, "after": "cursor-1"}, "a", "b", "c")
+	if err != nil {
+		t.Fatalf("Promoted failed: %v", err)
+	}
+	// With a page size, only the page the response returns is kept.
+	if got, want := productIDs(t, res), []string{"c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	sent := api.lastRequest(t)
+	if sent.GetSearchQuery() != "shoes" || sent.GetPaging().GetSize() != 2 || sent.GetPaging().GetCursor() != "cursor-1" {
+		t.Errorf("got search query %q and paging %v, want shoes, size 2 and cursor-1", sent.GetSearchQuery(), sent.GetPaging())
+	}
+}
+
+func TestPromotedDirectiveKeepsDuplicatesTogether(t *testing.T) {
+	d, api := newRankingDirective(t, "b", "a")
+	res, err := resolveProducts(t, d, nil, "a", "b", "a")
+	if err != nil {
+		t.Fatalf("Promoted failed: %v", err)
+	}
+	if got, want := productIDs(t, res), []string{"b", "a", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := len(api.lastRequest(t).GetInsertion()); got != 2 {
+		t.Errorf("sent %d insertions, want duplicates sent once", got)
+	}
+}
+
+func TestPromotedDirectiveErrors(t *testing.T) {
+	d, api := newRankingDirective(t)
+	ctx := context.Background()
+	if _, err := d.Promoted(ctx, nil, func(context.Context) (any, error) { return &testProduct{ID: "a"}, nil }, "SEARCH"); err == nil {
+		t.Error("Promoted succeeded on a field that isn't a list")
+	}
+	products := func(context.Context) (any, error) { return []*testProduct{{ID: "a"}}, nil }
+	if _, err := d.Promoted(ctx, nil, products, "NOT_A_USE_CASE"); err == nil {
+		t.Error("Promoted succeeded with an invalid use case")
+	}
+	if api.calls() != 0 {
+		t.Errorf("got %d Delivery API calls, want none", api.calls())
+	}
+}
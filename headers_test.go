@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+type authTokenKey struct{}
+
+func TestStaticAndDynamicHeaders(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithDeliveryAPIKey("secret").
+		WithStaticHeaders(map[string]string{"X-Mesh-Token": "mesh", "Authorization": "Bearer static"}).
+		WithDynamicHeaders(func(ctx context.Context) map[string]string {
+			token, _ := ctx.Value(authTokenKey{}).(string)
+			return map[string]string{"Authorization": "Bearer " + token, "x-api-key": "overridden"}
+		}))
+
+	for _, token := range []string{"token-1", "token-2"} {
+		ctx := context.WithValue(context.Background(), authTokenKey{}, token)
+		if _, err := c.Deliver(ctx, newTestDeliveryRequest(t, "a")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+		header := api.lastHeader(t)
+		if got := header.Get("X-Mesh-Token"); got != "mesh" {
+			t.Errorf("got X-Mesh-Token %q, want mesh", got)
+		}
+		// Dynamic headers are evaluated per call and override static ones.
+		if got := header.Get("Authorization"); got != "Bearer "+token {
+			t.Errorf("got Authorization %q, want Bearer %s", got, token)
+		}
+		if got := header.Get("x-api-key"); got != "secret" {
+			t.Errorf("got API key %q, want the configured key kept", got)
+		}
+	}
+}
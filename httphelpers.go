@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// UserIDHeader is the header UserInfoFromHTTPRequest reads the logged-in user ID from.
+var UserIDHeader = "X-User-ID"
+
+// AnonUserIDCookie is the cookie UserInfoFromHTTPRequest reads the anonymous user ID from. The
+// default is the one set by Segment's analytics.js.
+var AnonUserIDCookie = "ajs_anonymous_id"
+
+// UseCaseFromHTTPRequest parses the use case in the named query parameter, e.g. ?use_case=SEARCH.
+func UseCaseFromHTTPRequest(r *http.Request, param string) (delivery.UseCase, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return delivery.UseCase_UNKNOWN_USE_CASE, fmt.Errorf("missing query parameter %s", param)
+	}
+	return ParseUseCase(value)
+}
+
+// UserInfoFromHTTPRequest reads the user ID from the UserIDHeader header and the anonymous user ID
+// from the AnonUserIDCookie cookie. Either may be empty.
+func UserInfoFromHTTPRequest(r *http.Request) *common.UserInfo {
+	userInfo := &common.UserInfo{UserId: r.Header.Get(UserIDHeader)}
+	if cookie, err := r.Cookie(AnonUserIDCookie); err == nil {
+		userInfo.AnonUserId = cookie.Value
+	}
+	return userInfo
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestUseCaseFromHTTPRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    delivery.UseCase
+		wantErr bool
+	}{
+		{"search", "/products?use_case=SEARCH", delivery.UseCase_SEARCH, false},
+		{"lower case", "/products?use_case=feed", delivery.UseCase_FEED, false},
+		{"missing", "/products", delivery.UseCase_UNKNOWN_USE_CASE, true},
+		{"empty", "/products?use_case=", delivery.UseCase_UNKNOWN_USE_CASE, true},
+		{"invalid", "/products?use_case=CHECKOUT_PAGE", delivery.UseCase_UNKNOWN_USE_CASE, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UseCaseFromHTTPRequest(httptest.NewRequest(http.MethodGet, tt.url, nil), "use_case")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got use case %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserInfoFromHTTPRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/products", nil)
+	r.Header.Set(UserIDHeader, "user-1")
+	r.AddCookie(&http.Cookie{Name: AnonUserIDCookie, Value: "anon-1"})
+
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).
+		WithUserInfo(UserInfoFromHTTPRequest(r)))
+	if got := req.Request.GetUserInfo(); got.GetUserId() != "user-1" || got.GetAnonUserId() != "anon-1" {
+		t.Errorf("got user info %v, want user-1 and anon-1", got)
+	}
+}
+
+func TestUserInfoFromHTTPRequestWithoutCookie(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/products", nil)
+	r.Header.Set(UserIDHeader, "user-1")
+
+	got := UserInfoFromHTTPRequest(r)
+	if got.GetUserId() != "user-1" || got.GetAnonUserId() != "" {
+		t.Errorf("got user info %v, want user-1 with no anonymous ID", got)
+	}
+	if got := UserInfoFromHTTPRequest(httptest.NewRequest(http.MethodGet, "/products", nil)); got.GetUserId() != "" {
+		t.Errorf("got user ID %q without the header, want none", got.GetUserId())
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+)
+
+const importanceHeader = "X-Promoted-Importance"
+
+// ImportanceLevel says how much a delivery call matters, for prioritizing it on the server and in
+// the client's priority queue.
+type ImportanceLevel string
+
+const (
+	ImportanceLow    ImportanceLevel = "LOW"
+	ImportanceNormal ImportanceLevel = "NORMAL"
+	ImportanceHigh   ImportanceLevel = "HIGH"
+	// ImportanceCritical calls also bypass the outbound and per-user rate limiters.
+	ImportanceCritical ImportanceLevel = "CRITICAL"
+)
+
+// importanceRanks orders the levels, higher first.
+var importanceRanks = map[ImportanceLevel]int{
+	ImportanceLow:      0,
+	ImportanceNormal:   1,
+	ImportanceHigh:     2,
+	ImportanceCritical: 3,
+}
+
+// rank is the level's priority. Unset levels are NORMAL.
+func (l ImportanceLevel) rank() int {
+	if l == "" {
+		return importanceRanks[ImportanceNormal]
+	}
+	return importanceRanks[l]
+}
+
+func validateImportance(l ImportanceLevel) error {
+	if _, ok := importanceRanks[l]; !ok {
+		return fmt.Errorf("unknown importance level %q", l)
+	}
+	return nil
+}
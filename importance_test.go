@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func newImportanceRequest(t *testing.T, userID string, level ImportanceLevel) *DeliveryRequest {
+	t.Helper()
+	return buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:  &common.UserInfo{UserId: userID},
+		Insertion: testInsertions("a"),
+	}).WithImportance(level))
+}
+
+func TestHighImportanceLeavesQueueBeforeLow(t *testing.T) {
+	q := newPriorityQueue(1)
+	if err := q.acquire(context.Background(), ImportanceNormal); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	// Queue LOW calls before HIGH ones while the only slot is taken.
+	var mu sync.Mutex
+	var order []ImportanceLevel
+	var wg sync.WaitGroup
+	levels := []ImportanceLevel{ImportanceLow, ImportanceLow, ImportanceHigh, ImportanceHigh, ImportanceCritical}
+	for i, level := range levels {
+		wg.Add(1)
+		go func(level ImportanceLevel) {
+			defer wg.Done()
+			if err := q.acquire(context.Background(), level); err != nil {
+				t.Errorf("acquire failed: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, level)
+			mu.Unlock()
+			q.release()
+		}(level)
+		waitFor(t, "the call to queue", func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return q.waiters.Len() == i+1
+		})
+	}
+	q.release()
+	wg.Wait()
+
+	want := []ImportanceLevel{ImportanceCritical, ImportanceHigh, ImportanceHigh, ImportanceLow, ImportanceLow}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("calls left the queue in order %v, want %v", order, want)
+		}
+	}
+}
+
+func TestCanceledWaiterLeavesQueue(t *testing.T) {
+	q := newPriorityQueue(1)
+	if err := q.acquire(context.Background(), ImportanceNormal); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.acquire(ctx, ImportanceHigh); err == nil {
+		t.Fatal("acquire succeeded with no free slot")
+	}
+	q.release()
+	if q.waiters.Len() != 0 || q.active != 0 {
+		t.Errorf("got %d waiters and %d active calls, want the queue empty", q.waiters.Len(), q.active)
+	}
+}
+
+func TestImportanceHeaderIsSent(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	if _, err := c.Deliver(context.Background(), newImportanceRequest(t, "user-1", ImportanceHigh)); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.lastHeader(t).Get(importanceHeader); got != "HIGH" {
+		t.Errorf("got %s header %q, want HIGH", importanceHeader, got)
+	}
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.lastHeader(t).Get(importanceHeader); got != "" {
+		t.Errorf("got %s header %q without an importance, want none", importanceHeader, got)
+	}
+}
+
+func TestCriticalCallsBypassRateLimiters(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithOutboundRateLimit(0.01, 1).
+		WithRateLimitDropBehavior(true).
+		WithPerUserRateLimit(0.01, 1, 100))
+
+	for _, level := range []ImportanceLevel{ImportanceNormal, ImportanceCritical, ImportanceCritical} {
+		resp, err := c.Deliver(context.Background(), newImportanceRequest(t, "user-1", level))
+		if err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+		if resp.ExecutionServer != delivery.ExecutionServer_API {
+			t.Errorf("%s call got fallback reason %q, want an API response", level, resp.FallbackReason)
+		}
+	}
+	resp, err := c.Deliver(context.Background(), newImportanceRequest(t, "user-1", ImportanceHigh))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if resp.ExecutionServer == delivery.ExecutionServer_API {
+		t.Error("HIGH call bypassed the rate limiters")
+	}
+}
+
+func TestUnknownImportanceIsRejected(t *testing.T) {
+	_, err := NewDeliveryRequestBuilder(&delivery.Request{Insertion: testInsertions("a")}).WithImportance("URGENT").Build()
+	if err == nil {
+		t.Error("Build succeeded with an unknown importance")
+	}
+}
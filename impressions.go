@@ -0,0 +1,166 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const contextualSignalsPropertyKey = "contextualSignals"
+
+// ContextualSignals is what a user recently saw, split by whether they clicked it.
+type ContextualSignals struct {
+	ShownAndNotClicked []string `json:"shownAndNotClicked"`
+	Clicked            []string `json:"clicked"`
+}
+
+// impressionEntry is what was shown and clicked for one request.
+type impressionEntry struct {
+	requestID  string
+	anonUserID string
+	shown      []string
+	clicked    map[string]bool
+}
+
+// ImpressionTracker remembers what recent requests showed and what was clicked, so the model can
+// learn from items the user saw but skipped. The least recent requests are evicted past
+// maxRequests.
+type ImpressionTracker struct {
+	maxRequests int
+
+	mu       sync.Mutex
+	requests map[string]*list.Element
+	lru      *list.List
+}
+
+// NewImpressionTracker is a factory method for ImpressionTracker.
+func NewImpressionTracker(maxRequests int) *ImpressionTracker {
+	return &ImpressionTracker{
+		maxRequests: maxRequests,
+		requests:    map[string]*list.Element{},
+		lru:         list.New(),
+	}
+}
+
+// RecordImpression records the insertions shown for a request, replacing those recorded before.
+// Requests delivered by a client with WithImpressionTracker are recorded with all their
+// insertions and attributed to the request's anonymous user; use this to narrow them down to what
+// was actually rendered.
+func (t *ImpressionTracker) RecordImpression(requestID string, insertions []*delivery.Insertion) {
+	t.record(requestID, "", insertions)
+}
+
+// RecordClick records that the user clicked content shown for a request.
+func (t *ImpressionTracker) RecordClick(requestID string, contentID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry := t.entryLocked(requestID)
+	entry.clicked[contentID] = true
+}
+
+// GetContextualSignals returns what the anonymous user was shown across their tracked requests,
+// most recent first.
+func (t *ImpressionTracker) GetContextualSignals(anonUserID string) *ContextualSignals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clicked := map[string]bool{}
+	for elem := t.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*impressionEntry)
+		if entry.anonUserID != anonUserID {
+			continue
+		}
+		for contentID := range entry.clicked {
+			clicked[contentID] = true
+		}
+	}
+
+	signals := &ContextualSignals{}
+	seen := map[string]bool{}
+	for elem := t.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*impressionEntry)
+		if entry.anonUserID != anonUserID {
+			continue
+		}
+		for _, contentID := range entry.shown {
+			if seen[contentID] {
+				continue
+			}
+			seen[contentID] = true
+			if clicked[contentID] {
+				signals.Clicked = append(signals.Clicked, contentID)
+			} else {
+				signals.ShownAndNotClicked = append(signals.ShownAndNotClicked, contentID)
+			}
+		}
+	}
+	return signals
+}
+
+// record sets the insertions shown for a request, attributing it to anonUserID if set.
+func (t *ImpressionTracker) record(requestID, anonUserID string, insertions []*delivery.Insertion) {
+	shown := make([]string, len(insertions))
+	for i, ins := range insertions {
+		shown[i] = ins.GetContentId()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry := t.entryLocked(requestID)
+	entry.shown = shown
+	if anonUserID != "" {
+		entry.anonUserID = anonUserID
+	}
+}
+
+// entryLocked returns the request's entry, creating it and evicting old ones if needed.
+func (t *ImpressionTracker) entryLocked(requestID string) *impressionEntry {
+	if elem, ok := t.requests[requestID]; ok {
+		t.lru.MoveToFront(elem)
+		return elem.Value.(*impressionEntry)
+	}
+	entry := &impressionEntry{requestID: requestID, clicked: map[string]bool{}}
+	t.requests[requestID] = t.lru.PushFront(entry)
+	for t.maxRequests > 0 && t.lru.Len() > t.maxRequests {
+		oldest := t.lru.Back()
+		t.lru.Remove(oldest)
+		delete(t.requests, oldest.Value.(*impressionEntry).requestID)
+	}
+	return entry
+}
+
+// impressionTracking attaches the user's contextual signals to each request and records what the
+// response showed. Users who opted out under GDPR are neither tracked nor sent signals.
+type impressionTracking struct {
+	tracker  *ImpressionTracker
+	optedOut func(ctx context.Context) bool
+}
+
+func (i *impressionTracking) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		if i.optedOut != nil && i.optedOut(ctx) {
+			return next(ctx, req)
+		}
+		anonUserID := req.Request.GetUserInfo().GetAnonUserId()
+		if anonUserID != "" {
+			req = cloneRequest(req)
+			signals := i.tracker.GetContextualSignals(anonUserID)
+			if err := setJSONProperty(&req.Request.Properties, contextualSignalsPropertyKey, signals); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		requestID := resp.Response.GetRequestId()
+		if requestID == "" {
+			requestID = resp.ClientRequestID
+		}
+		if requestID != "" {
+			i.tracker.record(requestID, anonUserID, resp.Response.GetInsertion())
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func sentContextualSignals(t *testing.T, api *fakeAPI) *ContextualSignals {
+	t.Helper()
+	var signals ContextualSignals
+	if err := getJSONProperty(api.lastRequest(t).GetProperties(), contextualSignalsPropertyKey, &signals); err != nil {
+		t.Fatalf("error reading contextual signals: %v", err)
+	}
+	return &signals
+}
+
+func TestImpressionTrackerSendsContextualSignals(t *testing.T) {
+	api := newFakeAPI(t)
+	tracker := NewImpressionTracker(100)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithImpressionTracker(tracker))
+
+	resp, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "1", "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := sentContextualSignals(t, api); len(got.Clicked)+len(got.ShownAndNotClicked) != 0 {
+		t.Errorf("sent signals %+v for a new user, want none", got)
+	}
+	tracker.RecordClick(resp.Response.GetRequestId(), "b")
+
+	if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "1", "d")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	want := &ContextualSignals{ShownAndNotClicked: []string{"a", "c"}, Clicked: []string{"b"}}
+	if got := sentContextualSignals(t, api); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent signals %+v, want %+v", got, want)
+	}
+
+	// Other users' impressions aren't sent.
+	if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "2", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := sentContextualSignals(t, api); len(got.Clicked)+len(got.ShownAndNotClicked) != 0 {
+		t.Errorf("sent signals %+v for another user, want none", got)
+	}
+}
+
+func TestRecordImpressionNarrowsWhatWasShown(t *testing.T) {
+	tracker := NewImpressionTracker(100)
+	tracker.record("request-1", "anon-1", testInsertions("a", "b", "c"))
+	tracker.RecordImpression("request-1", testInsertions("a"))
+
+	got := tracker.GetContextualSignals("anon-1")
+	if want := []string{"a"}; !reflect.DeepEqual(got.ShownAndNotClicked, want) {
+		t.Errorf("got shown %v, want %v", got.ShownAndNotClicked, want)
+	}
+}
+
+func TestImpressionTrackerEvictsLeastRecentRequests(t *testing.T) {
+	tracker := NewImpressionTracker(2)
+	tracker.record("request-1", "anon-1", testInsertions("a"))
+	tracker.record("request-2", "anon-1", testInsertions("b"))
+	tracker.RecordClick("request-1", "a")
+	tracker.record("request-3", "anon-1", testInsertions("c"))
+
+	// request-2 was the least recently used after the click on request-1.
+	got := tracker.GetContextualSignals("anon-1")
+	if !reflect.DeepEqual(got.ShownAndNotClicked, []string{"c"}) || !reflect.DeepEqual(got.Clicked, []string{"a"}) {
+		t.Errorf("got signals %+v, want c shown and a clicked", got)
+	}
+}
+
+func TestImpressionTrackerIsSafeForConcurrentUse(t *testing.T) {
+	tracker := NewImpressionTracker(50)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			requestID := fmt.Sprintf("request-%d", i)
+			tracker.record(requestID, "anon-1", testInsertions(fmt.Sprintf("item-%d", i)))
+			tracker.RecordClick(requestID, fmt.Sprintf("item-%d", i))
+			tracker.GetContextualSignals("anon-1")
+		}(i)
+	}
+	wg.Wait()
+	if got := len(tracker.GetContextualSignals("anon-1").Clicked); got != 20 {
+		t.Errorf("got %d clicked items, want 20", got)
+	}
+}
+
+func TestImpressionTrackerSkipsOptedOutUsers(t *testing.T) {
+	tracker := NewImpressionTracker(100)
+	tracker.record("request-1", "anon-1", testInsertions("a"))
+	tracking := &impressionTracking{tracker: tracker, optedOut: optedOutFromContext}
+	deliver := tracking.middleware(func(_ context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		if v := getProperty(req.Request.GetProperties(), contextualSignalsPropertyKey); v != nil {
+			t.Errorf("attached signals %v for an opted-out user", v)
+		}
+		resp := newResponseWithInsertions(testInsertions("b"))
+		resp.Response.RequestId = "request-2"
+		return resp, nil
+	})
+
+	ctx := context.WithValue(context.Background(), optedOutKey{}, true)
+	if _, err := deliver(ctx, newUserDeliveryRequest(t, "1", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if got := tracker.GetContextualSignals("anon-1"); !reflect.DeepEqual(got.ShownAndNotClicked, []string{"a"}) {
+		t.Errorf("got shown %v, want only the impression from before opting out", got.ShownAndNotClicked)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/proto"
+)
+
+const groupIDPropertyKey = "groupId"
+const contentTypePropertyKey = "contentType"
+
+// InsertionGroup is a segment of a mixed feed, e.g. its products, articles or videos.
+type InsertionGroup struct {
+	GroupID    string
+	Type       string
+	Insertions []*delivery.Insertion
+}
+
+// addInsertionGroups appends copies of the groups' insertions to the request, tagging each with its
+// group ID and content type. The caller's insertions are left alone, so groups can be reused.
+func addInsertionGroups(req *delivery.Request, groups []InsertionGroup) error {
+	for _, group := range groups {
+		if group.GroupID == "" {
+			return errors.New("insertion group ID needs to be specified")
+		}
+		for _, ins := range group.Insertions {
+			ins = proto.Clone(ins).(*delivery.Insertion)
+			if err := setProperty(&ins.Properties, groupIDPropertyKey, group.GroupID); err != nil {
+				return err
+			}
+			if group.Type != "" {
+				if err := setProperty(&ins.Properties, contentTypePropertyKey, group.Type); err != nil {
+					return err
+				}
+			}
+			req.Insertion = append(req.Insertion, ins)
+		}
+	}
+	return nil
+}
+
+// RegroupResponse splits the response's insertions back into their groups, keyed by group ID and
+// in ranked order. Insertions that weren't in a group are left out.
+func RegroupResponse(resp *DeliveryResponse) map[string][]*delivery.Insertion {
+	groups := map[string][]*delivery.Insertion{}
+	for _, ins := range resp.Response.GetInsertion() {
+		groupID := getProperty(ins.Properties, groupIDPropertyKey).GetStringValue()
+		if groupID == "" {
+			continue
+		}
+		groups[groupID] = append(groups[groupID], ins)
+	}
+	return groups
+}
+
+// attachInsertionGroups copies the group properties from the request to the response insertions,
+// which don't carry properties.
+func attachInsertionGroups(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		if !req.hasInsertionGroups {
+			return next(ctx, req)
+		}
+		requested := requestInsertionsByContentID(req)
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp = cloneResponse(resp)
+		for _, ins := range resp.Response.GetInsertion() {
+			props := requested[ins.GetContentId()].GetProperties()
+			for _, key := range []string{groupIDPropertyKey, contentTypePropertyKey} {
+				value := getProperty(props, key)
+				if value == nil || getProperty(ins.Properties, key) != nil {
+					continue
+				}
+				if err := setProperty(&ins.Properties, key, value.AsInterface()); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestInsertionGroupsSurviveDelivery(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		writeDeliveryResponse(t, w, rankedResponse("v1", "p2", "a1", "p1"))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo: &common.UserInfo{AnonUserId: "anon-1"},
+	}).WithInsertionGroups(
+		InsertionGroup{GroupID: "products", Type: "product", Insertions: testInsertions("p1", "p2")},
+		InsertionGroup{GroupID: "articles", Type: "article", Insertions: testInsertions("a1")},
+		InsertionGroup{GroupID: "videos", Insertions: testInsertions("v1")},
+	))
+	if got := insertionContentIDsOf(req.Request.GetInsertion()); !reflect.DeepEqual(got, []string{"p1", "p2", "a1", "v1"}) {
+		t.Errorf("flattened insertions to %v, want [p1 p2 a1 v1]", got)
+	}
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := sentProperty(t, api, "a1", contentTypePropertyKey); got != "article" {
+		t.Errorf("sent content type %v for a1, want article", got)
+	}
+
+	groups := RegroupResponse(resp)
+	want := map[string][]string{"products": {"p2", "p1"}, "articles": {"a1"}, "videos": {"v1"}}
+	if len(groups) != len(want) {
+		t.Errorf("got %d groups, want %d", len(groups), len(want))
+	}
+	for groupID, ids := range want {
+		if got := insertionContentIDsOf(groups[groupID]); !reflect.DeepEqual(got, ids) {
+			t.Errorf("group %s has %v, want %v in ranked order", groupID, got, ids)
+		}
+	}
+	if got := getProperty(groups["products"][0].GetProperties(), contentTypePropertyKey).GetStringValue(); got != "product" {
+		t.Errorf("got content type %q for a regrouped product, want product", got)
+	}
+	if getProperty(groups["videos"][0].GetProperties(), contentTypePropertyKey) != nil {
+		t.Error("an untyped group got a content type")
+	}
+}
+
+func TestRegroupResponseSkipsUngroupedInsertions(t *testing.T) {
+	resp := newResponseWithInsertions(testInsertions("a", "b"))
+	setProperty(&resp.Response.Insertion[1].Properties, groupIDPropertyKey, "g")
+
+	groups := RegroupResponse(resp)
+	if len(groups) != 1 || len(groups["g"]) != 1 || groups["g"][0].GetContentId() != "b" {
+		t.Errorf("got groups %v, want only b in g", groups)
+	}
+}
+
+func TestInsertionGroupRequiresID(t *testing.T) {
+	_, err := NewDeliveryRequestBuilder(&delivery.Request{}).
+		WithInsertionGroups(InsertionGroup{Type: "product", Insertions: testInsertions("p1")}).
+		Build()
+	if err == nil {
+		t.Error("Build succeeded with an unnamed insertion group")
+	}
+}
+
+func TestInsertionGroupsLeaveCallerInsertionsAlone(t *testing.T) {
+	shared := testInsertions("p1")
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{}).WithInsertionGroups(
+		InsertionGroup{GroupID: "products", Type: "product", Insertions: shared},
+		InsertionGroup{GroupID: "deals", Insertions: shared},
+	))
+
+	if shared[0].GetProperties() != nil {
+		t.Errorf("the caller's insertion got properties %v", shared[0].GetProperties())
+	}
+	sent := req.Request.GetInsertion()
+	if len(sent) != 2 || sent[0] == shared[0] || sent[1] == shared[0] {
+		t.Fatal("the request shares the caller's insertions")
+	}
+	if got := getProperty(sent[0].GetProperties(), groupIDPropertyKey).GetStringValue(); got != "products" {
+		t.Errorf("got group ID %q for the first copy, want products", got)
+	}
+	if got := getProperty(sent[1].GetProperties(), groupIDPropertyKey).GetStringValue(); got != "deals" {
+		t.Errorf("got group ID %q for the second copy, want deals", got)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// InventoryChecker reports whether content is in stock.
+type InventoryChecker interface {
+	IsAvailable(ctx context.Context, contentID string) bool
+}
+
+// batchInventoryChecker is implemented by checkers that can check many content IDs at once.
+type batchInventoryChecker interface {
+	areAvailable(ctx context.Context, contentIDs []string) []bool
+}
+
+// parallelInventoryChecker checks availability with up to batchSize calls in flight.
+type parallelInventoryChecker struct {
+	checker   InventoryChecker
+	batchSize int
+}
+
+// BatchInventoryChecker wraps checker to check a request's insertions in parallel, batchSize at a
+// time.
+func BatchInventoryChecker(checker InventoryChecker, batchSize int) InventoryChecker {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &parallelInventoryChecker{checker: checker, batchSize: batchSize}
+}
+
+func (p *parallelInventoryChecker) IsAvailable(ctx context.Context, contentID string) bool {
+	return p.checker.IsAvailable(ctx, contentID)
+}
+
+func (p *parallelInventoryChecker) areAvailable(ctx context.Context, contentIDs []string) []bool {
+	available := make([]bool, len(contentIDs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, p.batchSize)
+	for i, contentID := range contentIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, contentID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			available[i] = p.checker.IsAvailable(ctx, contentID)
+		}(i, contentID)
+	}
+	wg.Wait()
+	return available
+}
+
+// inventoryFilter removes unavailable insertions before sending, so the model doesn't spend
+// capacity ranking them.
+type inventoryFilter struct {
+	checker  InventoryChecker
+	filtered atomic.Uint64
+}
+
+func (f *inventoryFilter) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		insertions := req.Request.GetInsertion()
+		contentIDs := make([]string, len(insertions))
+		for i, ins := range insertions {
+			contentIDs[i] = ins.GetContentId()
+		}
+		var available []bool
+		if batch, ok := f.checker.(batchInventoryChecker); ok {
+			available = batch.areAvailable(ctx, contentIDs)
+		} else {
+			available = make([]bool, len(contentIDs))
+			for i, contentID := range contentIDs {
+				available[i] = f.checker.IsAvailable(ctx, contentID)
+			}
+		}
+
+		req = cloneRequest(req)
+		kept := req.Request.Insertion[:0]
+		for i, ins := range req.Request.Insertion {
+			if available[i] {
+				kept = append(kept, ins)
+			}
+		}
+		f.filtered.Add(uint64(len(available) - len(kept)))
+		req.Request.Insertion = kept
+		return next(ctx, req)
+	}
+}
+
+// FilteredByInventory returns how many insertions were removed for being out of stock.
+func (c *DeliveryClient) FilteredByInventory() uint64 {
+	if c.inventoryFilter == nil {
+		return 0
+	}
+	return c.inventoryFilter.filtered.Load()
+}
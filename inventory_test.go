@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeInventory reports the content IDs in outOfStock as unavailable, after a delay, tracking the
+// most checks in flight at once.
+type fakeInventory struct {
+	outOfStock map[string]bool
+	delay      time.Duration
+
+	inFlight    atomic.Int32
+	mu          sync.Mutex
+	maxInFlight int32
+}
+
+func (f *fakeInventory) IsAvailable(ctx context.Context, contentID string) bool {
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	f.mu.Lock()
+	f.maxInFlight = max(f.maxInFlight, n)
+	f.mu.Unlock()
+	time.Sleep(f.delay)
+	return !f.outOfStock[contentID]
+}
+
+func TestInventoryFilterRemovesUnavailableInsertions(t *testing.T) {
+	api := newFakeAPI(t)
+	inventory := &fakeInventory{outOfStock: map[string]bool{"b": true, "d": true}}
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithInventoryFilter(inventory))
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c", "d"))
+		if err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+		assertContentIDs(t, resp, "a", "c")
+	}
+	if got := insertionContentIDsOf(api.lastRequest(t).GetInsertion()); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("sent insertions %v, want [a c]", got)
+	}
+	if got := c.FilteredByInventory(); got != 4 {
+		t.Errorf("got %d filtered by inventory, want 4", got)
+	}
+	if inventory.maxInFlight != 1 {
+		t.Errorf("got %d checks at once, want them one at a time without BatchInventoryChecker", inventory.maxInFlight)
+	}
+}
+
+func TestBatchInventoryCheckerRunsInParallel(t *testing.T) {
+	api := newFakeAPI(t)
+	inventory := &fakeInventory{outOfStock: map[string]bool{"e": true}, delay: 20 * time.Millisecond}
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithInventoryFilter(BatchInventoryChecker(inventory, 3)))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c", "d", "e", "f"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "a", "b", "c", "d", "f")
+	if inventory.maxInFlight != 3 {
+		t.Errorf("got at most %d checks at once, want 3", inventory.maxInFlight)
+	}
+	if got := c.FilteredByInventory(); got != 1 {
+		t.Errorf("got %d filtered by inventory, want 1", got)
+	}
+}
+
+func TestFilteredByInventoryWithoutFilter(t *testing.T) {
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)))
+	if got := c.FilteredByInventory(); got != 0 {
+		t.Errorf("got %d filtered by inventory without a filter, want 0", got)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const defaultLazyPropertyTimeout = 50 * time.Millisecond
+
+// LazyProperty is an insertion property that is expensive to compute, e.g. a real-time inventory
+// count, so it is resolved only when the request is about to be sent.
+type LazyProperty struct {
+	Key      string
+	Resolver func(ctx context.Context, contentID string) (any, error)
+}
+
+// resolvedProperty is the outcome of one LazyProperty resolver call.
+type resolvedProperty struct {
+	insertion int
+	key       string
+	value     any
+	err       error
+}
+
+// resolveLazyProperties resolves the request's lazy properties in parallel, then evaluates its
+// computed properties so they can use lazy values. It runs after caching so cache hits don't pay
+// for resolvers.
+func resolveLazyProperties(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		if len(req.lazyProperties) == 0 && len(req.computedProperties) == 0 {
+			return next(ctx, req)
+		}
+		req = cloneRequest(req)
+		if err := applyLazyProperties(ctx, req); err != nil {
+			return nil, err
+		}
+		if err := applyComputedProperties(req); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// applyLazyProperties sets the resolved values on req's insertions. Failed or timed out resolvers
+// are logged and their keys left unset rather than failing the request.
+func applyLazyProperties(ctx context.Context, req *DeliveryRequest) error {
+	timeout := req.lazyPropertyTimeout
+	if timeout <= 0 {
+		timeout = defaultLazyPropertyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	insertions := req.Request.GetInsertion()
+	pending := len(insertions) * len(req.lazyProperties)
+	if pending == 0 {
+		return nil
+	}
+	// Buffered so resolvers that finish after the timeout don't block.
+	results := make(chan resolvedProperty, pending)
+	for i, ins := range insertions {
+		for _, prop := range req.lazyProperties {
+			go func(i int, contentID string, prop LazyProperty) {
+				value, err := prop.Resolver(ctx, contentID)
+				results <- resolvedProperty{insertion: i, key: prop.Key, value: value, err: err}
+			}(i, ins.GetContentId(), prop)
+		}
+	}
+
+	for ; pending > 0; pending-- {
+		select {
+		case result := <-results:
+			ins := insertions[result.insertion]
+			if result.err != nil {
+				log.Printf("WARN: Omitting lazy property %s of content %s: %v\n", result.key, ins.GetContentId(), result.err)
+				continue
+			}
+			if err := setProperty(&ins.Properties, result.key, result.value); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			log.Printf("WARN: Omitting %d lazy properties that didn't resolve within %v\n", pending, timeout)
+			return nil
+		}
+	}
+	return nil
+}
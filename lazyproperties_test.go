@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func sentProperty(t *testing.T, api *fakeAPI, contentID, key string) any {
+	t.Helper()
+	for _, ins := range api.lastRequest(t).GetInsertion() {
+		if ins.GetContentId() == contentID {
+			if v := getProperty(ins.GetProperties(), key); v != nil {
+				return v.AsInterface()
+			}
+			return nil
+		}
+	}
+	t.Fatalf("content %s wasn't sent", contentID)
+	return nil
+}
+
+func deliverWithLazyProperties(t *testing.T, api *fakeAPI, timeout time.Duration, props ...LazyProperty) {
+	t.Helper()
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a", "b", "c").Request).
+		WithLazyProperties(props...).
+		WithLazyPropertyTimeout(timeout))
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+}
+
+func TestLazyPropertiesResolveInParallel(t *testing.T) {
+	api := newFakeAPI(t)
+	slow := func(value any) func(context.Context, string) (any, error) {
+		return func(ctx context.Context, contentID string) (any, error) {
+			time.Sleep(50 * time.Millisecond)
+			return value, nil
+		}
+	}
+
+	start := time.Now()
+	deliverWithLazyProperties(t, api, time.Second,
+		LazyProperty{Key: "inventory", Resolver: slow(3.0)},
+		LazyProperty{Key: "badge", Resolver: slow("sale")})
+	// Resolved one at a time, the six 50ms resolvers would take 300ms.
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Deliver took %v, want resolvers run in parallel", elapsed)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if got := sentProperty(t, api, id, "inventory"); got != 3.0 {
+			t.Errorf("got %s inventory %v, want 3", id, got)
+		}
+		if got := sentProperty(t, api, id, "badge"); got != "sale" {
+			t.Errorf("got %s badge %v, want sale", id, got)
+		}
+	}
+}
+
+func TestFailedLazyPropertiesAreOmitted(t *testing.T) {
+	api := newFakeAPI(t)
+	deliverWithLazyProperties(t, api, time.Second, LazyProperty{
+		Key: "inventory",
+		Resolver: func(ctx context.Context, contentID string) (any, error) {
+			if contentID == "b" {
+				return nil, errors.New("inventory service unavailable")
+			}
+			return 1.0, nil
+		},
+	})
+	if got := sentProperty(t, api, "a", "inventory"); got != 1.0 {
+		t.Errorf("got a inventory %v, want 1", got)
+	}
+	if got := sentProperty(t, api, "b", "inventory"); got != nil {
+		t.Errorf("got b inventory %v from a failed resolver, want it omitted", got)
+	}
+}
+
+func TestLazyPropertyTimeout(t *testing.T) {
+	api := newFakeAPI(t)
+	start := time.Now()
+	deliverWithLazyProperties(t, api, 20*time.Millisecond,
+		LazyProperty{Key: "fast", Resolver: func(ctx context.Context, contentID string) (any, error) {
+			return true, nil
+		}},
+		LazyProperty{Key: "stuck", Resolver: func(ctx context.Context, contentID string) (any, error) {
+			<-ctx.Done()
+			time.Sleep(200 * time.Millisecond)
+			return true, nil
+		}})
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Deliver took %v, want it bounded by the 20ms lazy property timeout", elapsed)
+	}
+	if got := sentProperty(t, api, "a", "fast"); got != true {
+		t.Errorf("got fast property %v, want true", got)
+	}
+	if got := sentProperty(t, api, "a", "stuck"); got != nil {
+		t.Errorf("got stuck property %v, want it omitted after the timeout", got)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/proto"
+)
+
+// LintSeverity is how serious a lint finding is.
+type LintSeverity int
+
+const (
+	// LintSeverityWarning is logged and the request is still sent.
+	LintSeverityWarning LintSeverity = iota
+	// LintSeverityError fails the Deliver call.
+	LintSeverityError
+)
+
+func (s LintSeverity) String() string {
+	if s == LintSeverityError {
+		return "ERROR"
+	}
+	return "WARNING"
+}
+
+// LintWarning is one finding from RequestLinter.
+type LintWarning struct {
+	Code     string
+	Message  string
+	Severity LintSeverity
+}
+
+// LintRule checks a request for one kind of mistake.
+type LintRule func(req *DeliveryRequest) []LintWarning
+
+// RequestLinter catches common request mistakes before they are sent.
+type RequestLinter struct {
+	rules []LintRule
+}
+
+// NewRequestLinter returns a linter with the built-in rules.
+func NewRequestLinter() *RequestLinter {
+	return &RequestLinter{rules: []LintRule{
+		lintUseCase,
+		lintIdenticalProperties,
+		lintPageSize,
+		lintUserIDs,
+	}}
+}
+
+// AddRule adds a custom rule after the built-in ones.
+func (l *RequestLinter) AddRule(rule LintRule) *RequestLinter {
+	l.rules = append(l.rules, rule)
+	return l
+}
+
+// Lint runs every rule against req.
+func (l *RequestLinter) Lint(req *DeliveryRequest) []LintWarning {
+	var warnings []LintWarning
+	for _, rule := range l.rules {
+		warnings = append(warnings, rule(req)...)
+	}
+	return warnings
+}
+
+func (l *RequestLinter) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		for _, w := range l.Lint(req) {
+			if w.Severity == LintSeverityError {
+				return nil, fmt.Errorf("request failed lint check %s: %s", w.Code, w.Message)
+			}
+			log.Printf("Request lint warning %s: %s\n", w.Code, w.Message)
+		}
+		return next(ctx, req)
+	}
+}
+
+func lintUseCase(req *DeliveryRequest) []LintWarning {
+	if req.Request.GetUseCase() != delivery.UseCase_UNKNOWN_USE_CASE {
+		return nil
+	}
+	return []LintWarning{{Code: "UNKNOWN_USE_CASE", Message: "UseCase is not set", Severity: LintSeverityWarning}}
+}
+
+// lintIdenticalProperties catches insertion properties copied from one item to all of them.
+func lintIdenticalProperties(req *DeliveryRequest) []LintWarning {
+	insertions := req.Request.GetInsertion()
+	if len(insertions) < 2 || insertions[0].GetProperties() == nil {
+		return nil
+	}
+	for _, ins := range insertions[1:] {
+		if !proto.Equal(ins.GetProperties(), insertions[0].GetProperties()) {
+			return nil
+		}
+	}
+	return []LintWarning{{
+		Code:     "IDENTICAL_INSERTION_PROPERTIES",
+		Message:  fmt.Sprintf("all %d insertions have identical properties", len(insertions)),
+		Severity: LintSeverityWarning,
+	}}
+}
+
+func lintPageSize(req *DeliveryRequest) []LintWarning {
+	if req.Request.GetPaging() == nil || req.Request.GetPaging().GetSize() != 0 {
+		return nil
+	}
+	return []LintWarning{{Code: "ZERO_PAGE_SIZE", Message: "Paging.Size is 0", Severity: LintSeverityError}}
+}
+
+func lintUserIDs(req *DeliveryRequest) []LintWarning {
+	if requestUserID(req.Request) != "" {
+		return nil
+	}
+	return []LintWarning{{Code: "MISSING_USER_IDS", Message: "UserInfo has neither UserId nor AnonUserId", Severity: LintSeverityWarning}}
+}
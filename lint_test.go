@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// lintCodes returns the code and severity of each finding.
+func lintCodes(warnings []LintWarning) map[string]LintSeverity {
+	codes := map[string]LintSeverity{}
+	for _, w := range warnings {
+		codes[w.Code] = w.Severity
+	}
+	return codes
+}
+
+// newLintedRequest wraps r without the request builder's checks.
+func newLintedRequest(r *delivery.Request) *DeliveryRequest {
+	return &DeliveryRequest{DeliveryRequest: &client.DeliveryRequest{Request: r}}
+}
+
+func TestRequestLinterBuiltInRules(t *testing.T) {
+	sameProps := func() *common.Properties {
+		var props *common.Properties
+		setProperty(&props, "price", 9.99)
+		return props
+	}
+	tests := []struct {
+		name string
+		req  *delivery.Request
+		want map[string]LintSeverity
+	}{
+		{"clean", &delivery.Request{
+			UseCase:   delivery.UseCase_SEARCH,
+			UserInfo:  &common.UserInfo{AnonUserId: "anon-1"},
+			Paging:    &delivery.Paging{Size: 10},
+			Insertion: testInsertions("a", "b"),
+		}, map[string]LintSeverity{}},
+		{"every rule", &delivery.Request{
+			Paging: &delivery.Paging{},
+			Insertion: []*delivery.Insertion{
+				{ContentId: "a", Properties: sameProps()},
+				{ContentId: "b", Properties: sameProps()},
+			},
+		}, map[string]LintSeverity{
+			"UNKNOWN_USE_CASE":               LintSeverityWarning,
+			"IDENTICAL_INSERTION_PROPERTIES": LintSeverityWarning,
+			"ZERO_PAGE_SIZE":                 LintSeverityError,
+			"MISSING_USER_IDS":               LintSeverityWarning,
+		}},
+	}
+	for _, tt := range tests {
+		if got := lintCodes(NewRequestLinter().Lint(newLintedRequest(tt.req))); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got findings %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRequestLinterCustomRule(t *testing.T) {
+	linter := NewRequestLinter().AddRule(func(req *DeliveryRequest) []LintWarning {
+		return []LintWarning{{Code: "CUSTOM", Severity: LintSeverityWarning}}
+	})
+	if _, ok := lintCodes(linter.Lint(newLintedRequest(&delivery.Request{})))["CUSTOM"]; !ok {
+		t.Error("custom rule didn't run")
+	}
+}
+
+func TestLintErrorsFailDeliver(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRequestLinter(NewRequestLinter()))
+
+	req := newTestDeliveryRequest(t, "a")
+	req.Request.Paging = &delivery.Paging{}
+	if _, err := c.Deliver(context.Background(), req); err == nil {
+		t.Error("Deliver succeeded with a lint error")
+	}
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Errorf("Deliver with only lint warnings failed: %v", err)
+	}
+	if api.calls() != 1 {
+		t.Errorf("got %d Delivery API calls, want 1", api.calls())
+	}
+}
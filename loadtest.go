@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LoadTestConfig configures LoadTest.
+type LoadTestConfig struct {
+	// TargetQPS is the rate calls are started at.
+	TargetQPS float64
+	// Duration is how long calls are started for.
+	Duration time.Duration
+	// WorkerCount bounds the calls in flight. Defaults to 1.
+	WorkerCount int
+	// RequestTemplate is copied for every call.
+	RequestTemplate *DeliveryRequest
+}
+
+// LoadTestReport summarizes a load test.
+type LoadTestReport struct {
+	// Requests is the number of calls made.
+	Requests int
+	// Dropped counts ticks skipped because every worker was busy, i.e. the client couldn't keep up.
+	Dropped int
+	// ErrorRate is the fraction of calls that returned an error.
+	ErrorRate float64
+	// AchievedQPS is the rate calls completed at.
+	AchievedQPS float64
+
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+}
+
+// LoadTest drives client at the target QPS for the configured duration, e.g. to find the
+// sustainable QPS on some hardware before going to production.
+func LoadTest(ctx context.Context, client DeliveryClientInterface, cfg LoadTestConfig) (*LoadTestReport, error) {
+	if cfg.TargetQPS <= 0 {
+		return nil, errors.New("target QPS must be positive")
+	}
+	if cfg.Duration <= 0 {
+		return nil, errors.New("load test duration must be positive")
+	}
+	if cfg.RequestTemplate == nil {
+		return nil, errors.New("request template needs to be specified")
+	}
+	interval := max(time.Duration(float64(time.Second)/cfg.TargetQPS), time.Nanosecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(cfg.Duration)
+	defer deadline.Stop()
+	return runLoadTest(ctx, client, cfg, ticker.C, deadline.C), ctx.Err()
+}
+
+// runLoadTest starts a call for each tick until deadline fires or ctx is done.
+func runLoadTest(ctx context.Context, client DeliveryClientInterface, cfg LoadTestConfig, tick, deadline <-chan time.Time) *LoadTestReport {
+	workers := max(cfg.WorkerCount, 1)
+
+	var mu sync.Mutex
+	var latencies []float64
+	var failures int
+	ticks := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range ticks {
+				// Each call gets its own copy since delivery fills in the request's IDs.
+				req := cloneRequest(cfg.RequestTemplate)
+				start := time.Now()
+				_, err := client.Deliver(ctx, req)
+				latency := time.Since(start)
+
+				mu.Lock()
+				latencies = append(latencies, float64(latency))
+				if err != nil {
+					failures++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	report := &LoadTestReport{}
+	start := time.Now()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-deadline:
+			break loop
+		case <-tick:
+			select {
+			case ticks <- struct{}{}:
+			default:
+				report.Dropped++
+			}
+		}
+	}
+	close(ticks)
+	wg.Wait()
+
+	report.Requests = len(latencies)
+	if report.Requests == 0 {
+		return report
+	}
+	report.ErrorRate = float64(failures) / float64(report.Requests)
+	report.AchievedQPS = float64(report.Requests) / time.Since(start).Seconds()
+	sort.Float64s(latencies)
+	report.P50 = time.Duration(percentile(latencies, 0.50))
+	report.P95 = time.Duration(percentile(latencies, 0.95))
+	report.P99 = time.Duration(percentile(latencies, 0.99))
+	return report
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// gatedDeliveryClient blocks every call to a fakeDeliveryClient until release is closed,
+// signaling started as each call begins.
+type gatedDeliveryClient struct {
+	fakeDeliveryClient
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *gatedDeliveryClient) Deliver(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return b.fakeDeliveryClient.Deliver(ctx, req)
+}
+
+func TestLoadTestAccountsForEveryTick(t *testing.T) {
+	c := &fakeDeliveryClient{name: "fake"}
+	tick := make(chan time.Time)
+	deadline := make(chan time.Time)
+	done := make(chan *LoadTestReport)
+	go func() {
+		done <- runLoadTest(context.Background(), c, LoadTestConfig{
+			WorkerCount:     4,
+			RequestTemplate: newTestDeliveryRequest(t, "a", "b"),
+		}, tick, deadline)
+	}()
+	const ticks = 500
+	for i := 0; i < ticks; i++ {
+		tick <- time.Now()
+	}
+	close(deadline)
+	report := <-done
+
+	// Ticks arriving while every worker is busy are dropped, so only the total is exact.
+	if report.Requests != c.calls() || report.Requests+report.Dropped != ticks {
+		t.Errorf("got %d requests, %d calls and %d dropped ticks, want a call or a drop for each of %d ticks",
+			report.Requests, c.calls(), report.Dropped, ticks)
+	}
+	if report.ErrorRate != 0 {
+		t.Errorf("got error rate %v, want 0", report.ErrorRate)
+	}
+	if report.P50 > report.P95 || report.P95 > report.P99 {
+		t.Errorf("got latency percentiles %v, %v, %v, want nondecreasing", report.P50, report.P95, report.P99)
+	}
+}
+
+func TestLoadTestStaysWithinTargetQPS(t *testing.T) {
+	c := &fakeDeliveryClient{name: "fake"}
+	report, err := LoadTest(context.Background(), c, LoadTestConfig{
+		TargetQPS:       100,
+		Duration:        500 * time.Millisecond,
+		WorkerCount:     4,
+		RequestTemplate: newTestDeliveryRequest(t, "a"),
+	})
+	if err != nil {
+		t.Fatalf("LoadTest failed: %v", err)
+	}
+	// Tickers never fire early, so a slow machine can only lower the rate.
+	if report.Requests == 0 || report.AchievedQPS > 101 {
+		t.Errorf("achieved %v QPS over %d requests, want at most 100", report.AchievedQPS, report.Requests)
+	}
+}
+
+func TestLoadTestCopiesTheTemplate(t *testing.T) {
+	c := &fakeDeliveryClient{name: "fake"}
+	template := newTestDeliveryRequest(t, "a")
+	if _, err := LoadTest(context.Background(), c, LoadTestConfig{TargetQPS: 100, Duration: 100 * time.Millisecond, RequestTemplate: template}); err != nil {
+		t.Fatalf("LoadTest failed: %v", err)
+	}
+	for _, req := range c.requests {
+		if req == template {
+			t.Fatal("delivered the template itself")
+		}
+	}
+}
+
+func TestLoadTestReportsErrorRate(t *testing.T) {
+	c := &fakeDeliveryClient{err: errors.New("unavailable")}
+	report, err := LoadTest(context.Background(), c, LoadTestConfig{
+		TargetQPS:       100,
+		Duration:        200 * time.Millisecond,
+		RequestTemplate: newTestDeliveryRequest(t, "a"),
+	})
+	if err != nil {
+		t.Fatalf("LoadTest failed: %v", err)
+	}
+	if report.Requests == 0 || report.ErrorRate != 1 {
+		t.Errorf("got error rate %v over %d requests, want 1", report.ErrorRate, report.Requests)
+	}
+}
+
+func TestLoadTestDropsTicksWhenWorkersAreBusy(t *testing.T) {
+	c := &gatedDeliveryClient{started: make(chan struct{}), release: make(chan struct{})}
+	tick := make(chan time.Time)
+	deadline := make(chan time.Time)
+	done := make(chan *LoadTestReport)
+	go func() {
+		done <- runLoadTest(context.Background(), c, LoadTestConfig{
+			WorkerCount:     1,
+			RequestTemplate: newTestDeliveryRequest(t, "a"),
+		}, tick, deadline)
+	}()
+	// Tick until the only worker is busy, then every further tick is dropped.
+	sent := 0
+	for busy := false; !busy; {
+		tick <- time.Now()
+		sent++
+		select {
+		case <-c.started:
+			busy = true
+		case <-time.After(time.Millisecond):
+		}
+	}
+	for i := 0; i < 10; i++ {
+		tick <- time.Now()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(c.release)
+	close(deadline)
+	report := <-done
+
+	if report.Requests != 1 || report.Dropped != sent-1+10 {
+		t.Errorf("got %d requests with %d dropped ticks, want 1 request and %d dropped", report.Requests, report.Dropped, sent-1+10)
+	}
+	if report.P50 < 20*time.Millisecond {
+		t.Errorf("got p50 latency %v, want at least the 20ms the call was blocked", report.P50)
+	}
+}
+
+func TestLoadTestStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := LoadTest(ctx, &fakeDeliveryClient{}, LoadTestConfig{
+		TargetQPS:       100,
+		Duration:        time.Minute,
+		RequestTemplate: newTestDeliveryRequest(t, "a"),
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the context's deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("LoadTest ran for %v after its context ended", elapsed)
+	}
+}
+
+func TestLoadTestValidatesConfig(t *testing.T) {
+	req := newTestDeliveryRequest(t, "a")
+	for name, cfg := range map[string]LoadTestConfig{
+		"no QPS":      {Duration: time.Second, RequestTemplate: req},
+		"no duration": {TargetQPS: 10, RequestTemplate: req},
+		"no template": {TargetQPS: 10, Duration: time.Second},
+	} {
+		if _, err := LoadTest(context.Background(), &fakeDeliveryClient{}, cfg); err == nil {
+			t.Errorf("%s: LoadTest succeeded, want an error", name)
+		}
+	}
+}
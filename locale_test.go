@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestLocaleIsSentAsProperty(t *testing.T) {
+	locale := LocaleInfo{Language: "en-US", Currency: "USD", Timezone: "America/New_York"}
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithLocale(locale))
+
+	var sent LocaleInfo
+	if err := getJSONProperty(req.Request.GetProperties(), localePropertyKey, &sent); err != nil {
+		t.Fatalf("error reading locale property: %v", err)
+	}
+	if sent != locale {
+		t.Errorf("sent locale %+v, want %+v", sent, locale)
+	}
+}
+
+func TestLocaleValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		locale  LocaleInfo
+		wantErr bool
+	}{
+		{"valid", LocaleInfo{Language: "pt-BR", Currency: "BRL", Timezone: "America/Sao_Paulo"}, false},
+		{"language only", LocaleInfo{Language: "de"}, false},
+		{"empty", LocaleInfo{}, false},
+		{"invalid language", LocaleInfo{Language: "en_US!"}, true},
+		{"malformed language", LocaleInfo{Language: "toolonglanguage"}, true},
+		{"unrecognized currency", LocaleInfo{Currency: "XYZ"}, true},
+		{"lower case currency", LocaleInfo{Currency: "usd"}, true},
+		{"unknown timezone", LocaleInfo{Timezone: "Mars/Olympus_Mons"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithLocale(tt.locale).Build()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
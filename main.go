@@ -1,12 +1,12 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
 
-	client "github.com/promotedai/promoted-go-delivery-client/delivery"
 	"github.com/promotedai/schema/generated/go/proto/common"
 	"github.com/promotedai/schema/generated/go/proto/delivery"
 	"google.golang.org/protobuf/types/known/structpb"
@@ -72,7 +72,7 @@ func main() {
 	}
 
 	// Call the Promoted delivery API.
-	response, err := client.Deliver(req)
+	response, err := client.Deliver(context.Background(), req)
 	if err != nil {
 		fmt.Println("Delivery called failed")
 		panic(err)
@@ -109,7 +109,7 @@ func validateConfig(config Config) error {
 	return nil
 }
 
-func newTestRequest(insertions []*delivery.Insertion, onlyLog bool) (*client.DeliveryRequest, error) {
+func newTestRequest(insertions []*delivery.Insertion, onlyLog bool) (*DeliveryRequest, error) {
 	// Request-level parameters go here.
 	requestPropsValue, err := structpb.NewValue(map[string]any{
 		"category": "topic",
@@ -118,28 +118,27 @@ func newTestRequest(insertions []*delivery.Insertion, onlyLog bool) (*client.Del
 	if err != nil {
 		return nil, err
 	}
-	return &client.DeliveryRequest{
-		Request: &delivery.Request{
-			UserInfo: &common.UserInfo{
-				AnonUserId: "testAnonUserId1",
-				UserId:     "testUserId1",
-			},
-			UseCase:     delivery.UseCase_SEARCH,
-			SearchQuery: "query",
-			Paging: &delivery.Paging{
-				Starting: &delivery.Paging_Offset{Offset: 0},
-				Size:     3,
-			},
-			DisablePersonalization: false,
-			Properties: &common.Properties{
-				StructField: &common.Properties_Struct{
-					Struct: requestPropsValue.GetStructValue(),
-				},
+	return NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo: &common.UserInfo{
+			AnonUserId: "testAnonUserId1",
+			UserId:     "testUserId1",
+		},
+		UseCase:     delivery.UseCase_SEARCH,
+		SearchQuery: "query",
+		Paging: &delivery.Paging{
+			Starting: &delivery.Paging_Offset{Offset: 0},
+			Size:     3,
+		},
+		DisablePersonalization: false,
+		Properties: &common.Properties{
+			StructField: &common.Properties_Struct{
+				Struct: requestPropsValue.GetStructValue(),
 			},
-			Insertion: insertions,
 		},
-		OnlyLog: onlyLog,
-	}, nil
+		Insertion: insertions,
+	}).
+		WithOnlyLog(onlyLog).
+		Build()
 }
 
 func newTestRequestInsertions(products []*Product) []*delivery.Insertion {
@@ -191,8 +190,8 @@ func parseFloatEnv(key string, defaultValue float64) float64 {
 	return parsed
 }
 
-func NewPromotedDeliveryClient(config Config) (*client.PromotedDeliveryClient, error) {
-	return client.NewPromotedDeliveryClientBuilder().
+func NewPromotedDeliveryClient(config Config) (*DeliveryClient, error) {
+	return NewDeliveryClientBuilder().
 		WithDeliveryEndpoint(config.DeliveryApiEndpointUrl).
 		WithDeliveryAPIKey(config.DeliveryApiKey).
 		WithDeliveryTimeoutMillis(1000).
@@ -200,7 +199,6 @@ func NewPromotedDeliveryClient(config Config) (*client.PromotedDeliveryClient, e
 		WithMetricsAPIKey(config.MetricsApiKey).
 		WithMetricsTimeoutMillis(1000).
 		WithAcceptsGzip(true).
-		WithAPIFactory(&client.DefaultAPIFactory{}).
 		Build()
 }
 
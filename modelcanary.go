@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// ModelDiff compares the primary and canary rankings of one request. Delivery responses carry no
+// model scores, so rankings are compared by position.
+type ModelDiff struct {
+	PrimaryRequestID string
+	CanaryRequestID  string
+	// PositionChanges is the canary position minus the primary position, for content in both
+	// responses whose position changed.
+	PositionChanges map[string]int
+	// Added is content only in the canary response; Removed is content only in the primary one.
+	Added   []string
+	Removed []string
+	// Err is set if the canary call failed.
+	Err error
+}
+
+// ShadowDiffLogger receives the diff of each canary call.
+type ShadowDiffLogger interface {
+	LogDiff(diff *ModelDiff)
+}
+
+// logShadowDiffLogger writes diffs to the standard logger.
+type logShadowDiffLogger struct{}
+
+func (logShadowDiffLogger) LogDiff(diff *ModelDiff) {
+	if diff.Err != nil {
+		log.Printf("Canary call for request %s failed: %v\n", diff.PrimaryRequestID, diff.Err)
+		return
+	}
+	log.Printf("Canary diff for request %s: %d position changes, %d added, %d removed\n",
+		diff.PrimaryRequestID, len(diff.PositionChanges), len(diff.Added), len(diff.Removed))
+}
+
+// ModelCanaryClient sends a sample of requests to a canary client alongside the primary one, e.g.
+// one built with a newer WithModelVersion. Callers always get the primary response; the canary
+// response is only diffed and logged. A *DeliveryClient canary is called straight through its
+// Delivery API with SHADOW traffic type, skipping its middlewares, SDK fallback and Metrics API
+// logging, so nothing is logged twice. It still skips opted-out users, encrypts and filters
+// properties, and follows data residency, like Explain. Other canaries are called through Deliver and shouldn't log
+// impressions themselves.
+type ModelCanaryClient struct {
+	primary DeliveryClientInterface
+	canary  DeliveryClientInterface
+	logger  ShadowDiffLogger
+
+	mu   sync.Mutex
+	rand *rand.Rand
+	rate float64
+}
+
+// NewModelCanaryClient runs the canary on every call until WithModelCanaryRate is set. A nil
+// logger logs diffs with the standard logger.
+func NewModelCanaryClient(primary, canary DeliveryClientInterface, logger ShadowDiffLogger) *ModelCanaryClient {
+	if logger == nil {
+		logger = logShadowDiffLogger{}
+	}
+	return &ModelCanaryClient{
+		primary: primary,
+		canary:  canary,
+		logger:  logger,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		rate:    1,
+	}
+}
+
+// WithModelCanaryRate sets the fraction of calls that also run the canary.
+func (m *ModelCanaryClient) WithModelCanaryRate(rate float64) *ModelCanaryClient {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rate = rate
+	return m
+}
+
+// Deliver returns the primary response, running the canary in parallel for sampled calls.
+func (m *ModelCanaryClient) Deliver(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+	m.mu.Lock()
+	runCanary := m.rand.Float64() < m.rate
+	m.mu.Unlock()
+	if !runCanary {
+		return m.primary.Deliver(ctx, req)
+	}
+
+	canaryReq := cloneRequest(req)
+	if canaryReq.Request.ClientInfo == nil {
+		canaryReq.Request.ClientInfo = &common.ClientInfo{}
+	}
+	canaryReq.Request.ClientInfo.TrafficType = common.ClientInfo_SHADOW
+	var canaryAPI *deliveryAPI
+	if canary, ok := m.canary.(*DeliveryClient); ok {
+		api, prepared, err := canary.prepareDirectCall(ctx, canaryReq)
+		if err != nil {
+			// Opted-out users are never sent to the canary. Other errors come from the same
+			// encryption the primary call runs, so the primary reports them.
+			if !errors.Is(err, errOptedOut) {
+				log.Printf("Skipping canary call: %v\n", err)
+			}
+			return m.primary.Deliver(ctx, req)
+		}
+		canaryAPI, canaryReq = api, prepared
+	}
+
+	primaryDone := make(chan *DeliveryResponse, 1)
+	go func() {
+		// The canary outlives the caller's context so the diff is logged after Deliver returns.
+		canaryResp, err := m.deliverCanary(context.WithoutCancel(ctx), canaryAPI, canaryReq)
+		primaryResp := <-primaryDone
+		if primaryResp == nil {
+			return
+		}
+		if err != nil {
+			m.logger.LogDiff(&ModelDiff{PrimaryRequestID: primaryResp.Response.GetRequestId(), Err: err})
+			return
+		}
+		m.logger.LogDiff(diffResponses(primaryResp, canaryResp))
+	}()
+
+	resp, err := m.primary.Deliver(ctx, req)
+	if err != nil {
+		primaryDone <- nil
+		return nil, err
+	}
+	primaryDone <- resp
+	return resp, nil
+}
+
+// deliverCanary calls the canary through api without logging, if it is a *DeliveryClient, and
+// through Deliver otherwise.
+func (m *ModelCanaryClient) deliverCanary(ctx context.Context, api *deliveryAPI, req *DeliveryRequest) (*DeliveryResponse, error) {
+	if api == nil {
+		return m.canary.Deliver(ctx, req)
+	}
+	resp, _, err := api.runDelivery(ctx, req.DeliveryRequest, req.Headers)
+	if err != nil {
+		return nil, err
+	}
+	return &DeliveryResponse{
+		DeliveryResponse: &client.DeliveryResponse{
+			Response:        resp,
+			ClientRequestID: req.Request.GetClientRequestId(),
+			ExecutionServer: delivery.ExecutionServer_API,
+		},
+	}, nil
+}
+
+// diffResponses compares the positions of content in the two responses.
+func diffResponses(primary, canary *DeliveryResponse) *ModelDiff {
+	diff := &ModelDiff{
+		PrimaryRequestID: primary.Response.GetRequestId(),
+		CanaryRequestID:  canary.Response.GetRequestId(),
+		PositionChanges:  map[string]int{},
+	}
+	primaryPositions := map[string]int{}
+	for i, ins := range primary.Response.GetInsertion() {
+		primaryPositions[ins.GetContentId()] = i
+	}
+	canaryPositions := map[string]int{}
+	for i, ins := range canary.Response.GetInsertion() {
+		contentID := ins.GetContentId()
+		canaryPositions[contentID] = i
+		primaryPosition, ok := primaryPositions[contentID]
+		if !ok {
+			diff.Added = append(diff.Added, contentID)
+		} else if primaryPosition != i {
+			diff.PositionChanges[contentID] = i - primaryPosition
+		}
+	}
+	for _, ins := range primary.Response.GetInsertion() {
+		if _, ok := canaryPositions[ins.GetContentId()]; !ok {
+			diff.Removed = append(diff.Removed, ins.GetContentId())
+		}
+	}
+	return diff
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// chanDiffLogger hands each diff to the test.
+type chanDiffLogger chan *ModelDiff
+
+func (l chanDiffLogger) LogDiff(diff *ModelDiff) {
+	l <- diff
+}
+
+func (l chanDiffLogger) next(t *testing.T) *ModelDiff {
+	t.Helper()
+	select {
+	case diff := <-l:
+		return diff
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the canary diff")
+		return nil
+	}
+}
+
+func TestModelCanaryAlwaysReturnsPrimary(t *testing.T) {
+	for name, canary := range map[string]*fakeDeliveryClient{
+		"canary succeeds": {name: "canary"},
+		"canary fails":    {name: "canary", err: errors.New("canary is down")},
+	} {
+		logger := make(chanDiffLogger, 1)
+		m := NewModelCanaryClient(&fakeDeliveryClient{name: "primary"}, canary, logger)
+
+		resp, err := m.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b"))
+		if err != nil {
+			t.Fatalf("%s: Deliver failed: %v", name, err)
+		}
+		if got := resp.Response.GetRequestId(); got != "primary" {
+			t.Errorf("%s: got response from %s, want primary", name, got)
+		}
+		if diff := logger.next(t); (diff.Err != nil) != (canary.err != nil) {
+			t.Errorf("%s: got diff error %v", name, diff.Err)
+		}
+	}
+}
+
+func TestModelCanaryPrimaryErrorIsReturned(t *testing.T) {
+	m := NewModelCanaryClient(&fakeDeliveryClient{name: "primary", err: errors.New("primary is down")}, &fakeDeliveryClient{name: "canary"}, make(chanDiffLogger, 1))
+	if _, err := m.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err == nil {
+		t.Error("Deliver succeeded when the primary failed")
+	}
+}
+
+func TestModelCanarySendsShadowTrafficWithoutLogging(t *testing.T) {
+	primaryAPI := newFakeAPI(t)
+	primary := buildTestClient(t, newTestClientBuilder(t, primaryAPI))
+	canaryAPI := newFakeAPI(t)
+	canaryAPI.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		writeDeliveryResponse(t, w, rankedResponse("b", "a", "c"))
+	})
+	canaryMetrics, canaryLogged := newRecordingServer(t)
+	canary := buildTestClient(t, newTestClientBuilder(t, canaryAPI).WithMetricsEndpoint(canaryMetrics.URL))
+	logger := make(chanDiffLogger, 1)
+	m := NewModelCanaryClient(primary, canary, logger)
+
+	req := newTestDeliveryRequest(t, "a", "b")
+	resp, err := m.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "a", "b")
+
+	diff := logger.next(t)
+	if want := map[string]int{"a": 1, "b": -1}; !reflect.DeepEqual(diff.PositionChanges, want) {
+		t.Errorf("got position changes %v, want %v", diff.PositionChanges, want)
+	}
+	if !reflect.DeepEqual(diff.Added, []string{"c"}) || len(diff.Removed) != 0 {
+		t.Errorf("got added %v and removed %v, want c added", diff.Added, diff.Removed)
+	}
+	if got := canaryAPI.lastRequest(t).GetClientInfo().GetTrafficType(); got != common.ClientInfo_SHADOW {
+		t.Errorf("canary got traffic type %v, want SHADOW", got)
+	}
+	if got := primaryAPI.lastRequest(t).GetClientInfo().GetTrafficType(); got == common.ClientInfo_SHADOW {
+		t.Error("primary got SHADOW traffic")
+	}
+	canary.Close()
+	if len(canaryLogged()) != 0 {
+		t.Errorf("got %d Metrics API calls from the canary, want 0", len(canaryLogged()))
+	}
+}
+
+func TestModelCanaryRate(t *testing.T) {
+	canary := &fakeDeliveryClient{name: "canary"}
+	m := NewModelCanaryClient(&fakeDeliveryClient{name: "primary"}, canary, make(chanDiffLogger, 10)).WithModelCanaryRate(0)
+	for i := 0; i < 10; i++ {
+		if _, err := m.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if canary.calls() != 0 {
+		t.Errorf("got %d canary calls at rate 0, want 0", canary.calls())
+	}
+}
+
+func TestModelCanarySkipsOptedOutUsers(t *testing.T) {
+	canaryAPI := newFakeAPI(t)
+	canary := buildTestClient(t, newTestClientBuilder(t, canaryAPI).
+		WithGDPROptOut(func(context.Context) bool { return true }))
+	logger := make(chanDiffLogger, 1)
+	m := NewModelCanaryClient(&fakeDeliveryClient{name: "primary"}, canary, logger)
+
+	if _, err := m.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if canaryAPI.calls() != 0 {
+		t.Errorf("got %d canary calls for an opted-out user, want 0", canaryAPI.calls())
+	}
+	select {
+	case diff := <-logger:
+		t.Errorf("got a canary diff for an opted-out user: %+v", diff)
+	default:
+	}
+}
+
+func TestModelCanaryFiltersProperties(t *testing.T) {
+	canaryAPI := newFakeAPI(t)
+	canary := buildTestClient(t, newTestClientBuilder(t, canaryAPI).WithDeniedPropertyKeys("email"))
+	logger := make(chanDiffLogger, 1)
+	m := NewModelCanaryClient(&fakeDeliveryClient{name: "primary"}, canary, logger)
+
+	req := newTestDeliveryRequest(t, "a")
+	setProperty(&req.Request.Insertion[0].Properties, "email", "a@example.com")
+	if _, err := m.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if diff := logger.next(t); diff.Err != nil {
+		t.Fatalf("canary call failed: %v", diff.Err)
+	}
+	if getProperty(canaryAPI.lastRequest(t).GetInsertion()[0].GetProperties(), "email") != nil {
+		t.Error("the canary got the denied email property")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const modelVersionHeader = "X-Promoted-Model-Version"
+const modelVersionUsedHeader = "X-Promoted-Model-Version-Used"
+
+// ModelVersionMismatchWarning reports that the Delivery API ranked with a different model version
+// than the one pinned.
+type ModelVersionMismatchWarning struct {
+	RequestedVersion string
+	UsedVersion      string
+}
+
+func (w *ModelVersionMismatchWarning) Error() string {
+	return fmt.Sprintf("requested model version %s but the Delivery API used %s", w.RequestedVersion, w.UsedVersion)
+}
+
+// checkModelVersion compares the pinned model version, from the call's headers or the API's own,
+// with the one the server reports using. Servers that don't report it are not flagged.
+func checkModelVersion(api *deliveryAPI, header, respHeader http.Header) *ModelVersionMismatchWarning {
+	requested := header.Get(modelVersionHeader)
+	if requested == "" {
+		requested = api.headers.Get(modelVersionHeader)
+	}
+	used := respHeader.Get(modelVersionUsedHeader)
+	if requested == "" || used == "" || used == requested {
+		return nil
+	}
+	return &ModelVersionMismatchWarning{RequestedVersion: requested, UsedVersion: used}
+}
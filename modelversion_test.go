@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestModelVersionHeaderIsSent(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithModelVersion("v41"))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.lastHeader(t).Get(modelVersionHeader); got != "v41" {
+		t.Errorf("got %s header %q, want v41", modelVersionHeader, got)
+	}
+	if resp.ModelVersionMismatch != nil {
+		t.Errorf("got mismatch %v from a server that didn't report a version", resp.ModelVersionMismatch)
+	}
+}
+
+func TestModelVersionOverride(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setResponseHeader(modelVersionUsedHeader, "v42")
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithModelVersion("v41"))
+
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:  newTestDeliveryRequest(t).Request.GetUserInfo(),
+		Insertion: testInsertions("a"),
+	}).WithModelVersionOverride("v42"))
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.lastHeader(t).Get(modelVersionHeader); got != "v42" {
+		t.Errorf("got %s header %q, want the v42 override", modelVersionHeader, got)
+	}
+	if resp.ModelVersionMismatch != nil {
+		t.Errorf("got mismatch %v when the server used the override", resp.ModelVersionMismatch)
+	}
+}
+
+func TestModelVersionMismatchWarns(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setResponseHeader(modelVersionUsedHeader, "v40")
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithModelVersion("v41"))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	want := ModelVersionMismatchWarning{RequestedVersion: "v41", UsedVersion: "v40"}
+	if resp.ModelVersionMismatch == nil || *resp.ModelVersionMismatch != want {
+		t.Errorf("got mismatch %v, want %v", resp.ModelVersionMismatch, want)
+	}
+	assertContentIDs(t, resp, "a")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// FallbackReasonOffline marks responses served by offline mode.
+const FallbackReasonOffline = "OFFLINE"
+
+// OfflineRankingKey returns the "UseCase:SearchQuery" key offline mode looks rankings up by,
+// e.g. "SEARCH:shoes".
+func OfflineRankingKey(req *delivery.Request) string {
+	return req.GetUseCase().String() + ":" + req.GetSearchQuery()
+}
+
+// offlineMode serves stored rankings without calling the Delivery API or the Metrics API.
+type offlineMode struct {
+	rankings map[string][]*delivery.Insertion
+}
+
+// loadOfflineRankings reads a JSON object of ranking key to insertions in protojson form.
+func loadOfflineRankings(path string) (map[string][]*delivery.Insertion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading offline rankings: %v", err)
+	}
+	var raw map[string][]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing offline rankings: %v", err)
+	}
+	rankings := make(map[string][]*delivery.Insertion, len(raw))
+	for key, messages := range raw {
+		insertions := make([]*delivery.Insertion, len(messages))
+		for i, message := range messages {
+			insertions[i] = &delivery.Insertion{}
+			if err := protojson.Unmarshal(message, insertions[i]); err != nil {
+				return nil, fmt.Errorf("error parsing offline ranking %s: %v", key, err)
+			}
+		}
+		rankings[key] = insertions
+	}
+	return rankings, nil
+}
+
+// middleware looks the request up by OfflineRankingKey, then by RequestFingerprint, and serves
+// the request in its original order on a miss.
+func (o *offlineMode) middleware(deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		ranking, ok := o.rankings[OfflineRankingKey(req.Request)]
+		if !ok {
+			ranking, ok = o.rankings[RequestFingerprint(req)]
+		}
+		if !ok {
+			return newSDKResponse(cloneRequest(req), FallbackReasonOffline)
+		}
+
+		insertions := make([]*delivery.Insertion, len(ranking))
+		for i, ins := range ranking {
+			insertions[i] = proto.Clone(ins).(*delivery.Insertion)
+		}
+		renumberPositions(insertions)
+		return &DeliveryResponse{
+			DeliveryResponse: &client.DeliveryResponse{
+				Response:        &delivery.Response{Insertion: insertions},
+				ClientRequestID: req.Request.GetClientRequestId(),
+				ExecutionServer: delivery.ExecutionServer_SDK,
+			},
+			FallbackReason: FallbackReasonOffline,
+		}, nil
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func newTestOfflineClient(t *testing.T, b *DeliveryClientBuilder) (*DeliveryClient, *fakeAPI, func() []string) {
+	t.Helper()
+	api := newFakeAPI(t)
+	metrics, logged := newRecordingServer(t)
+	c := buildTestClient(t, b.WithDeliveryEndpoint(api.URL).WithMetricsEndpoint(metrics.URL).WithDisableUsageTracking(true))
+	return c, api, logged
+}
+
+func TestOfflineModeLooksUpRankings(t *testing.T) {
+	c, api, logged := newTestOfflineClient(t, NewDeliveryClientBuilder().WithOfflineMode(map[string][]*delivery.Insertion{
+		"SEARCH:shoes": testInsertions("c", "a"),
+	}))
+
+	req := newQueryRequest(t, "shoes", "a", "b", "c")
+	req.Request.UseCase = delivery.UseCase_SEARCH
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "c", "a")
+	if resp.FallbackReason != FallbackReasonOffline || resp.Response.GetInsertion()[1].GetPosition() != 1 {
+		t.Errorf("got fallback reason %q and positions %v, want offline and renumbered", resp.FallbackReason, resp.Response.GetInsertion())
+	}
+
+	miss, err := c.Deliver(context.Background(), newQueryRequest(t, "hats", "b", "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, miss, "b", "a")
+	c.Close()
+
+	if api.calls() != 0 || len(logged()) != 0 {
+		t.Errorf("got %d Delivery API and %d Metrics API calls in offline mode, want none", api.calls(), len(logged()))
+	}
+}
+
+func TestOfflineModeLooksUpByFingerprint(t *testing.T) {
+	req := newTestDeliveryRequest(t, "a", "b")
+	c, _, _ := newTestOfflineClient(t, NewDeliveryClientBuilder().WithOfflineMode(map[string][]*delivery.Insertion{
+		RequestFingerprint(req): testInsertions("b", "a"),
+	}))
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "b", "a")
+}
+
+func TestOfflineModeFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rankings.json")
+	if err := os.WriteFile(path, []byte(`{"UNKNOWN_USE_CASE:": [{"contentId": "b"}, {"contentId": "a"}]}`), 0o644); err != nil {
+		t.Fatalf("error writing rankings: %v", err)
+	}
+	c, _, _ := newTestOfflineClient(t, NewDeliveryClientBuilder().WithOfflineModeFromFile(path))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "b", "a")
+}
+
+func TestOfflineModeFromInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rankings.json")
+	os.WriteFile(path, []byte(`{"SEARCH:shoes": [{"contentId": 5}]}`), 0o644)
+	if _, err := NewDeliveryClientBuilder().WithOfflineModeFromFile(path).Build(); err == nil {
+		t.Error("Build succeeded with an invalid rankings file")
+	}
+}
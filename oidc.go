@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// oidcTokenRefreshMargin is how long before expiry a cached token is refreshed, so it can't expire
+// in flight.
+const oidcTokenRefreshMargin = 60 * time.Second
+
+// OIDCTokenProvider supplies bearer tokens for the Delivery API, e.g. for enterprise SSO.
+type OIDCTokenProvider interface {
+	GetToken(ctx context.Context) (string, error)
+}
+
+// clientCredentialsProvider gets tokens with the OAuth2 client credentials grant, caching each
+// until shortly before it expires.
+type clientCredentialsProvider struct {
+	config *clientcredentials.Config
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// NewClientCredentialsProvider is a factory method for an OIDCTokenProvider using the client
+// credentials grant against tokenURL.
+func NewClientCredentialsProvider(tokenURL, clientID, clientSecret string, scopes []string) OIDCTokenProvider {
+	return &clientCredentialsProvider{config: &clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     tokenURL,
+		Scopes:       scopes,
+	}}
+}
+
+func (p *clientCredentialsProvider) GetToken(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != nil && (p.token.Expiry.IsZero() || time.Until(p.token.Expiry) > oidcTokenRefreshMargin) {
+		return p.token.AccessToken, nil
+	}
+	token, err := p.config.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error getting OIDC token: %v", err)
+	}
+	p.token = token
+	return token.AccessToken, nil
+}
+
+// oidcTransport authenticates requests with a bearer token instead of the API key.
+type oidcTransport struct {
+	provider OIDCTokenProvider
+	next     http.RoundTripper
+}
+
+func (t *oidcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.provider.GetToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	// RoundTrippers must not modify the request they're given.
+	req = req.Clone(req.Context())
+	req.Header.Del("x-api-key")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.next.RoundTrip(req)
+}
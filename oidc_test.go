@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// newOIDCServer is a token endpoint granting client credentials for client-1 and secret-1. Tokens
+// are numbered, expiring after expiresIn seconds.
+func newOIDCServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var grants atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("error parsing token request: %v", err)
+		}
+		clientID, secret, _ := r.BasicAuth()
+		if r.PostForm.Get("grant_type") != "client_credentials" || clientID != "client-1" || secret != "secret-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if got := r.PostForm.Get("scope"); got != "delivery" {
+			t.Errorf("got scope %q, want delivery", got)
+		}
+		n := grants.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &grants
+}
+
+func TestClientCredentialsTokenIsCached(t *testing.T) {
+	server, grants := newOIDCServer(t, 3600)
+	provider := NewClientCredentialsProvider(server.URL, "client-1", "secret-1", []string{"delivery"})
+
+	for i := 0; i < 3; i++ {
+		token, err := provider.GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+		if token != "token-1" {
+			t.Errorf("got token %q, want the cached token-1", token)
+		}
+	}
+	if grants.Load() != 1 {
+		t.Errorf("got %d token grants, want 1", grants.Load())
+	}
+}
+
+func TestClientCredentialsTokenIsRefreshedNearExpiry(t *testing.T) {
+	// Tokens expiring within the refresh margin are never reused.
+	server, grants := newOIDCServer(t, 30)
+	provider := NewClientCredentialsProvider(server.URL, "client-1", "secret-1", []string{"delivery"})
+
+	for i, want := range []string{"token-1", "token-2"} {
+		token, err := provider.GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+		if token != want {
+			t.Errorf("call %d got token %q, want %q", i, token, want)
+		}
+	}
+	if grants.Load() != 2 {
+		t.Errorf("got %d token grants, want 2", grants.Load())
+	}
+}
+
+func TestClientCredentialsRejected(t *testing.T) {
+	server, _ := newOIDCServer(t, 3600)
+	provider := NewClientCredentialsProvider(server.URL, "client-1", "wrong", []string{"delivery"})
+	if _, err := provider.GetToken(context.Background()); err == nil {
+		t.Error("GetToken succeeded with the wrong secret")
+	}
+}
+
+func TestOIDCAuthReplacesAPIKey(t *testing.T) {
+	server, _ := newOIDCServer(t, 3600)
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithDeliveryAPIKey("secret").
+		WithOIDCAuth(NewClientCredentialsProvider(server.URL, "client-1", "secret-1", []string{"delivery"})))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "a")
+	header := api.lastHeader(t)
+	if got := header.Get("Authorization"); got != "Bearer token-1" {
+		t.Errorf("got Authorization %q, want Bearer token-1", got)
+	}
+	if got := header.Get("x-api-key"); got != "" {
+		t.Errorf("sent API key %q with OIDC auth", got)
+	}
+}
+
+func TestOIDCTokenFailureFallsBack(t *testing.T) {
+	server, _ := newOIDCServer(t, 3600)
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithOIDCAuth(NewClientCredentialsProvider(server.URL, "client-1", "wrong", nil)))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if api.calls() != 0 || resp.ExecutionServer != delivery.ExecutionServer_SDK {
+		t.Errorf("got %d Delivery API calls and execution server %v, want no calls and an SDK fallback", api.calls(), resp.ExecutionServer)
+	}
+}
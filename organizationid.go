@@ -0,0 +1,17 @@
+package main
+
+import "github.com/promotedai/schema/generated/go/proto/delivery"
+
+// organizationIDHeader tells the Delivery API which org's model to use.
+const organizationIDHeader = "X-Promoted-Org-ID"
+
+// organizationIDPropertyKey is the request property that carries the org ID.
+const organizationIDPropertyKey = "orgId"
+
+// applyOrganizationID sets the org ID property unless the request already carries one.
+func applyOrganizationID(req *delivery.Request, orgID string) error {
+	if getProperty(req.Properties, organizationIDPropertyKey) != nil {
+		return nil
+	}
+	return setProperty(&req.Properties, organizationIDPropertyKey, orgID)
+}
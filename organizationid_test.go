@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestOrganizationIDIsSentAsHeaderAndProperty(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithOrganizationID("org-client"))
+
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertOrganizationID(t, api, "org-client")
+}
+
+func TestRequestOrganizationIDOverridesClient(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithOrganizationID("org-client"))
+
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:  &common.UserInfo{AnonUserId: "anon-1"},
+		Insertion: testInsertions("a"),
+	}).WithRequestOrganizationID("org-request"))
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertOrganizationID(t, api, "org-request")
+}
+
+func TestNoOrganizationIDByDefault(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.lastHeader(t).Get(organizationIDHeader); got != "" {
+		t.Errorf("got %s header %q, want none", organizationIDHeader, got)
+	}
+	if getProperty(api.lastRequest(t).GetProperties(), organizationIDPropertyKey) != nil {
+		t.Errorf("got %s property, want none", organizationIDPropertyKey)
+	}
+}
+
+func assertOrganizationID(t *testing.T, api *fakeAPI, want string) {
+	t.Helper()
+	if got := api.lastHeader(t).Get(organizationIDHeader); got != want {
+		t.Errorf("got %s header %q, want %q", organizationIDHeader, got, want)
+	}
+	if got := getProperty(api.lastRequest(t).GetProperties(), organizationIDPropertyKey).GetStringValue(); got != want {
+		t.Errorf("got %s property %q, want %q", organizationIDPropertyKey, got, want)
+	}
+}
+
+func TestOrganizationIDLeavesCallerRequestAlone(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithOrganizationID("org-client"))
+
+	req := newTestDeliveryRequest(t, "a")
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertOrganizationID(t, api, "org-client")
+	if getProperty(req.Request.GetProperties(), organizationIDPropertyKey) != nil {
+		t.Errorf("the caller's request got the %s property", organizationIDPropertyKey)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultPerformanceWindowSize = 1000
+
+// PerformanceReport summarizes the latency of recent Deliver calls.
+type PerformanceReport struct {
+	// Count is the number of calls in the window.
+	Count int
+	P50Ms float64
+	P95Ms float64
+	P99Ms float64
+}
+
+// performanceBuckets is how many buckets the window is split into; the oldest bucket is dropped as
+// the window slides.
+const performanceBuckets = 10
+
+// performanceSamplesPerBucket bounds the latencies kept per bucket, so memory doesn't grow with the
+// window size.
+const performanceSamplesPerBucket = 1024
+
+// latencyBucket is a reservoir sample of the latencies of consecutive calls.
+type latencyBucket struct {
+	count   int
+	samples []float64
+}
+
+// latencyWindow estimates latency percentiles over the most recent calls. The window is split into
+// buckets of consecutive calls, each keeping a reservoir sample of its latencies, so memory stays
+// bounded however large the window is. Percentiles are exact while buckets hold all their calls.
+type latencyWindow struct {
+	mu         sync.Mutex
+	rand       *rand.Rand
+	buckets    []latencyBucket
+	bucketSize int
+	current    int
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+	buckets := min(performanceBuckets, size)
+	return &latencyWindow{
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		buckets:    make([]latencyBucket, buckets),
+		bucketSize: (size + buckets - 1) / buckets,
+	}
+}
+
+// observe records a latency, dropping the oldest bucket once the window is full.
+func (w *latencyWindow) observe(latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	bucket := &w.buckets[w.current]
+	if bucket.count == w.bucketSize {
+		w.current = (w.current + 1) % len(w.buckets)
+		bucket = &w.buckets[w.current]
+		bucket.count = 0
+		bucket.samples = bucket.samples[:0]
+	}
+	bucket.count++
+	ms := float64(latency) / float64(time.Millisecond)
+	if len(bucket.samples) < performanceSamplesPerBucket {
+		bucket.samples = append(bucket.samples, ms)
+	} else if i := w.rand.Intn(bucket.count); i < performanceSamplesPerBucket {
+		bucket.samples[i] = ms
+	}
+}
+
+// weightedLatency is a sampled latency standing for weight calls.
+type weightedLatency struct {
+	ms     float64
+	weight float64
+}
+
+// report estimates the window's percentiles. It is the zero report when nothing was observed.
+func (w *latencyWindow) report() PerformanceReport {
+	w.mu.Lock()
+	var latencies []weightedLatency
+	count := 0
+	for _, bucket := range w.buckets {
+		count += bucket.count
+		for _, ms := range bucket.samples {
+			latencies = append(latencies, weightedLatency{ms: ms, weight: float64(bucket.count) / float64(len(bucket.samples))})
+		}
+	}
+	w.mu.Unlock()
+
+	if count == 0 {
+		return PerformanceReport{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i].ms < latencies[j].ms })
+	return PerformanceReport{
+		Count: count,
+		P50Ms: weightedPercentile(latencies, 0.50),
+		P95Ms: weightedPercentile(latencies, 0.95),
+		P99Ms: weightedPercentile(latencies, 0.99),
+	}
+}
+
+func (w *latencyWindow) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		start := time.Now()
+		resp, err := next(ctx, req)
+		if err == nil {
+			w.observe(time.Since(start))
+		}
+		return resp, err
+	}
+}
+
+// percentile linearly interpolates the p-th percentile of sorted, which must not be empty.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+// weightedPercentile returns the smallest latency covering the p-th percentile of the calls sorted
+// stands for. sorted must not be empty.
+func weightedPercentile(sorted []weightedLatency, p float64) float64 {
+	var total float64
+	for _, l := range sorted {
+		total += l.weight
+	}
+	var covered float64
+	for _, l := range sorted {
+		covered += l.weight
+		if covered >= p*total {
+			return l.ms
+		}
+	}
+	return sorted[len(sorted)-1].ms
+}
+
+// GetPerformanceReport returns the latency percentiles of the most recent successful Deliver calls.
+func (c *DeliveryClient) GetPerformanceReport() PerformanceReport {
+	return c.performance.report()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func assertWithinPercent(t *testing.T, name string, got, want, percent float64) {
+	t.Helper()
+	if math.Abs(got-want) > want*percent/100 {
+		t.Errorf("got %s %v, want within %v%% of %v", name, got, percent, want)
+	}
+}
+
+func TestPerformanceReportPercentiles(t *testing.T) {
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)))
+	// 1ms to 1000ms in random order, so the true p-th percentile is about p*1000ms.
+	for _, i := range rand.New(rand.NewSource(1)).Perm(1000) {
+		c.performance.observe(time.Duration(i+1) * time.Millisecond)
+	}
+
+	report := c.GetPerformanceReport()
+	if report.Count != 1000 {
+		t.Errorf("got count %d, want 1000", report.Count)
+	}
+	assertWithinPercent(t, "p50", report.P50Ms, 500, 5)
+	assertWithinPercent(t, "p95", report.P95Ms, 950, 5)
+	assertWithinPercent(t, "p99", report.P99Ms, 990, 5)
+}
+
+func TestPerformanceWindowKeepsRecentCalls(t *testing.T) {
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithPerformanceWindowSize(100))
+	for i := 0; i < 900; i++ {
+		c.performance.observe(time.Second)
+	}
+	for i := 1; i <= 100; i++ {
+		c.performance.observe(time.Duration(i) * time.Millisecond)
+	}
+
+	report := c.GetPerformanceReport()
+	if report.Count != 100 {
+		t.Errorf("got count %d, want the window size 100", report.Count)
+	}
+	// The slow calls have left the window.
+	assertWithinPercent(t, "p99", report.P99Ms, 99, 5)
+}
+
+func TestPerformanceReportCountsDeliverCalls(t *testing.T) {
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)))
+	if report := c.GetPerformanceReport(); report != (PerformanceReport{}) {
+		t.Errorf("got report %+v before any calls, want the zero report", report)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if report := c.GetPerformanceReport(); report.Count != 3 || report.P50Ms <= 0 {
+		t.Errorf("got report %+v, want 3 timed calls", report)
+	}
+}
+
+func TestPerformanceWindowMemoryIsBounded(t *testing.T) {
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithPerformanceWindowSize(100000))
+	for _, i := range rand.New(rand.NewSource(1)).Perm(100000) {
+		c.performance.observe(time.Duration(i+1) * time.Millisecond)
+	}
+
+	samples := 0
+	for _, bucket := range c.performance.buckets {
+		samples += len(bucket.samples)
+	}
+	if limit := performanceBuckets * performanceSamplesPerBucket; samples > limit {
+		t.Errorf("kept %d latencies, want at most %d", samples, limit)
+	}
+	report := c.GetPerformanceReport()
+	if report.Count != 100000 {
+		t.Errorf("got count %d, want 100000", report.Count)
+	}
+	assertWithinPercent(t, "p50", report.P50Ms, 50000, 5)
+	assertWithinPercent(t, "p95", report.P95Ms, 95000, 5)
+	assertWithinPercent(t, "p99", report.P99Ms, 99000, 5)
+}
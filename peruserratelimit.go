@@ -0,0 +1,98 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+// FallbackReasonRateLimited marks responses served by SDK delivery because the user was over their
+// rate limit.
+const FallbackReasonRateLimited = "RATE_LIMITED"
+
+// userBucket is the token bucket of one user, kept in LRU order.
+type userBucket struct {
+	userID  string
+	limiter *rate.Limiter
+}
+
+// PerUserRateLimiter gives each user their own token bucket so one user can't use up the
+// Delivery API's QPS. The least recently seen users are evicted past maxUsers.
+type PerUserRateLimiter struct {
+	limit    rate.Limit
+	burst    int
+	maxUsers int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List
+
+	limited atomic.Uint64
+}
+
+// NewPerUserRateLimiter is a factory method for PerUserRateLimiter.
+func NewPerUserRateLimiter(rps float64, burst int, maxUsers int) *PerUserRateLimiter {
+	return &PerUserRateLimiter{
+		limit:    rate.Limit(rps),
+		burst:    burst,
+		maxUsers: maxUsers,
+		buckets:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// Allow reports whether the user may make another call now, taking a token if so.
+func (l *PerUserRateLimiter) Allow(userID string) bool {
+	l.mu.Lock()
+	elem, ok := l.buckets[userID]
+	if ok {
+		l.lru.MoveToFront(elem)
+	} else {
+		elem = l.lru.PushFront(&userBucket{userID: userID, limiter: rate.NewLimiter(l.limit, l.burst)})
+		l.buckets[userID] = elem
+		for l.maxUsers > 0 && l.lru.Len() > l.maxUsers {
+			oldest := l.lru.Back()
+			l.lru.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*userBucket).userID)
+		}
+	}
+	limiter := elem.Value.(*userBucket).limiter
+	l.mu.Unlock()
+
+	if !limiter.Allow() {
+		l.limited.Add(1)
+		return false
+	}
+	return true
+}
+
+// RateLimitedCount returns how many calls have been rate limited.
+func (l *PerUserRateLimiter) RateLimitedCount() uint64 {
+	return l.limited.Load()
+}
+
+// ActiveUsers returns how many users currently have a token bucket.
+func (l *PerUserRateLimiter) ActiveUsers() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
+
+// RateLimitedCount returns how many calls were rate limited per user, or 0 if per-user rate
+// limiting is off.
+func (c *DeliveryClient) RateLimitedCount() uint64 {
+	if c.perUserRateLimiter == nil {
+		return 0
+	}
+	return c.perUserRateLimiter.RateLimitedCount()
+}
+
+// ActiveUsers returns how many users the per-user rate limiter is tracking.
+func (c *DeliveryClient) ActiveUsers() int {
+	if c.perUserRateLimiter == nil {
+		return 0
+	}
+	return c.perUserRateLimiter.ActiveUsers()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func newUserDeliveryRequest(t *testing.T, userID string, contentIDs ...string) *DeliveryRequest {
+	t.Helper()
+	return buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:  &common.UserInfo{UserId: userID, AnonUserId: "anon-" + userID},
+		Insertion: testInsertions(contentIDs...),
+	}))
+}
+
+func TestPerUserRateLimitDoesNotLimitOtherUsers(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithPerUserRateLimit(0.01, 5, 100))
+
+	for i := 0; i < 100; i++ {
+		if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-a", "x")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	resp, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-b", "x"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if resp.FallbackReason != "" || resp.ExecutionServer != delivery.ExecutionServer_API {
+		t.Errorf("user B got fallback reason %q from %v, want an API response", resp.FallbackReason, resp.ExecutionServer)
+	}
+	if got := c.RateLimitedCount(); got != 95 {
+		t.Errorf("got %d rate limited calls, want 95", got)
+	}
+	if got := api.calls(); got != 6 {
+		t.Errorf("got %d Delivery API calls, want 6", got)
+	}
+	if got := c.ActiveUsers(); got != 2 {
+		t.Errorf("got %d active users, want 2", got)
+	}
+}
+
+func TestPerUserRateLimitFallsBack(t *testing.T) {
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithPerUserRateLimit(0.01, 1, 100))
+
+	var resp *DeliveryResponse
+	for i := 0; i < 2; i++ {
+		var err error
+		if resp, err = c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-a", "x", "y")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if resp.FallbackReason != FallbackReasonRateLimited {
+		t.Errorf("got fallback reason %q, want %q", resp.FallbackReason, FallbackReasonRateLimited)
+	}
+	assertContentIDs(t, resp, "x", "y")
+}
+
+func TestPerUserRateLimiterEvictsLeastRecentUser(t *testing.T) {
+	l := NewPerUserRateLimiter(0.01, 1, 2)
+	l.Allow("a")
+	l.Allow("b")
+	l.Allow("a")
+	l.Allow("c")
+	if got := l.ActiveUsers(); got != 2 {
+		t.Errorf("got %d active users, want 2", got)
+	}
+	// "b" was evicted, so it gets a fresh bucket while "c" is still limited.
+	if !l.Allow("b") {
+		t.Error("evicted user was still rate limited")
+	}
+	if l.Allow("c") {
+		t.Error("recent user wasn't rate limited")
+	}
+}
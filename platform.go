@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+const platformPropertyKey = "platform"
+const platformHeader = "X-Promoted-Platform"
+const appVersionHeader = "X-Promoted-App-Version"
+
+// knownPlatforms are the platforms accepted without WithAllowCustomPlatforms.
+var knownPlatforms = map[string]bool{"ios": true, "android": true, "web": true}
+
+// PlatformInfo describes the client app, since users behave differently on each platform.
+type PlatformInfo struct {
+	// Platform is one of "ios", "android" or "web", unless custom platforms are allowed.
+	Platform    string `json:"platform"`
+	AppVersion  string `json:"appVersion,omitempty"`
+	BuildNumber int    `json:"buildNumber,omitempty"`
+}
+
+// validatePlatformInfo checks the platform is known, unless custom platforms are allowed.
+func validatePlatformInfo(p PlatformInfo, allowCustom bool) error {
+	if p.Platform == "" {
+		return errors.New("platform needs to be specified")
+	}
+	if !allowCustom && !knownPlatforms[p.Platform] {
+		return fmt.Errorf("unknown platform %q; allow custom platforms to use it", p.Platform)
+	}
+	return nil
+}
+
+// applyPlatformInfo sends p in the platform request property and the platform headers.
+func applyPlatformInfo(req *DeliveryRequest, p PlatformInfo) error {
+	if err := setJSONProperty(&req.Request.Properties, platformPropertyKey, p); err != nil {
+		return err
+	}
+	req.Headers.Set(platformHeader, p.Platform)
+	if p.AppVersion != "" {
+		req.Headers.Set(appVersionHeader, p.AppVersion)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPlatformInfoIsSentAsHeadersAndProperty(t *testing.T) {
+	for _, platform := range []string{"ios", "android", "web"} {
+		t.Run(platform, func(t *testing.T) {
+			api := newFakeAPI(t)
+			c := buildTestClient(t, newTestClientBuilder(t, api))
+			info := PlatformInfo{Platform: platform, AppVersion: "4.2.0", BuildNumber: 812}
+			req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithPlatformInfo(info))
+
+			if _, err := c.Deliver(context.Background(), req); err != nil {
+				t.Fatalf("Deliver failed: %v", err)
+			}
+			header := api.lastHeader(t)
+			if header.Get("X-Promoted-Platform") != platform || header.Get("X-Promoted-App-Version") != "4.2.0" {
+				t.Errorf("got headers %v, want platform %s and app version 4.2.0", header, platform)
+			}
+			var sent PlatformInfo
+			if err := getJSONProperty(api.lastRequest(t).GetProperties(), platformPropertyKey, &sent); err != nil {
+				t.Fatalf("error reading platform property: %v", err)
+			}
+			if sent != info {
+				t.Errorf("sent platform %+v, want %+v", sent, info)
+			}
+		})
+	}
+}
+
+func TestPlatformInfoWithoutAppVersion(t *testing.T) {
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithPlatformInfo(PlatformInfo{Platform: "web"}))
+	if _, ok := req.Headers["X-Promoted-App-Version"]; ok {
+		t.Errorf("got app version header %q without an app version, want none", req.Headers.Get("X-Promoted-App-Version"))
+	}
+}
+
+func TestCustomPlatformsNeedToBeAllowed(t *testing.T) {
+	tv := PlatformInfo{Platform: "smart-tv"}
+	if _, err := NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithPlatformInfo(tv).Build(); err == nil {
+		t.Error("Build succeeded with a custom platform")
+	}
+	if _, err := NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithPlatformInfo(PlatformInfo{}).Build(); err == nil {
+		t.Error("Build succeeded without a platform")
+	}
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).
+		WithPlatformInfo(tv).
+		WithAllowCustomPlatforms(true))
+	if got := req.Headers.Get("X-Promoted-Platform"); got != "smart-tv" {
+		t.Errorf("got platform header %q, want smart-tv", got)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultPopularityFetchTimeout = 50 * time.Millisecond
+
+// PopularityProvider returns how popular content is right now, e.g. recent view counts.
+type PopularityProvider interface {
+	GetPopularityScore(ctx context.Context, contentID string) (float64, error)
+}
+
+// batchPopularityProvider is implemented by providers that can fetch many scores in one call.
+type batchPopularityProvider interface {
+	GetPopularityScores(ctx context.Context, contentIDs []string) ([]float64, error)
+}
+
+// redisPopularityProvider reads scores from a Redis sorted set with content IDs as members.
+type redisPopularityProvider struct {
+	client *redis.Client
+	key    string
+}
+
+// RedisPopularityProvider reads popularity scores from the Redis sorted set named keyPrefix, e.g.
+// "popularity:1h", whose members are content IDs. Content missing from the set scores 0.
+func RedisPopularityProvider(client *redis.Client, keyPrefix string) PopularityProvider {
+	return &redisPopularityProvider{client: client, key: keyPrefix}
+}
+
+func (p *redisPopularityProvider) GetPopularityScore(ctx context.Context, contentID string) (float64, error) {
+	scores, err := p.GetPopularityScores(ctx, []string{contentID})
+	if err != nil {
+		return 0, err
+	}
+	return scores[0], nil
+}
+
+// GetPopularityScores fetches all the scores in one ZMSCORE call, which needs Redis 6.2.
+func (p *redisPopularityProvider) GetPopularityScores(ctx context.Context, contentIDs []string) ([]float64, error) {
+	scores, err := p.client.ZMScore(ctx, p.key, contentIDs...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error reading popularity scores: %v", err)
+	}
+	return scores, nil
+}
+
+// popularityBoost adds weight times each insertion's popularity to its retrieval score.
+type popularityBoost struct {
+	provider PopularityProvider
+	weight   float64
+	timeout  time.Duration
+}
+
+// middleware sends the request unboosted if the scores can't be fetched in time.
+func (p *popularityBoost) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		insertions := req.Request.GetInsertion()
+		if len(insertions) == 0 {
+			return next(ctx, req)
+		}
+		contentIDs := make([]string, len(insertions))
+		for i, ins := range insertions {
+			contentIDs[i] = ins.GetContentId()
+		}
+		scores, err := p.fetch(ctx, contentIDs)
+		if err != nil {
+			log.Printf("WARN: Sending without popularity boost: %v\n", err)
+			return next(ctx, req)
+		}
+
+		req = cloneRequest(req)
+		for i, ins := range req.Request.GetInsertion() {
+			score := ins.GetRetrievalScore() + float32(p.weight*scores[i])
+			ins.RetrievalScore = &score
+		}
+		return next(ctx, req)
+	}
+}
+
+// fetch gets the scores in one batch if the provider supports it, and in parallel otherwise.
+func (p *popularityBoost) fetch(ctx context.Context, contentIDs []string) ([]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	if batch, ok := p.provider.(batchPopularityProvider); ok {
+		return batch.GetPopularityScores(ctx, contentIDs)
+	}
+
+	type result struct {
+		i     int
+		score float64
+		err   error
+	}
+	// Buffered so calls that finish after the timeout don't block.
+	results := make(chan result, len(contentIDs))
+	for i, contentID := range contentIDs {
+		go func(i int, contentID string) {
+			score, err := p.provider.GetPopularityScore(ctx, contentID)
+			results <- result{i: i, score: score, err: err}
+		}(i, contentID)
+	}
+	scores := make([]float64, len(contentIDs))
+	for range contentIDs {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				return nil, fmt.Errorf("error getting popularity of content %s: %v", contentIDs[r.i], r.err)
+			}
+			scores[r.i] = r.score
+		case <-ctx.Done():
+			return nil, fmt.Errorf("popularity scores didn't arrive within %v", p.timeout)
+		}
+	}
+	return scores, nil
+}
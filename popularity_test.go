@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mapPopularityProvider serves scores from a map, after an optional delay.
+type mapPopularityProvider struct {
+	scores map[string]float64
+	delay  time.Duration
+	err    error
+}
+
+func (p *mapPopularityProvider) GetPopularityScore(ctx context.Context, contentID string) (float64, error) {
+	time.Sleep(p.delay)
+	return p.scores[contentID], p.err
+}
+
+// batchMapPopularityProvider also fetches scores in batches, counting the calls of each kind.
+type batchMapPopularityProvider struct {
+	mapPopularityProvider
+	single, batches atomic.Int32
+}
+
+func (p *batchMapPopularityProvider) GetPopularityScore(ctx context.Context, contentID string) (float64, error) {
+	p.single.Add(1)
+	return p.mapPopularityProvider.GetPopularityScore(ctx, contentID)
+}
+
+func (p *batchMapPopularityProvider) GetPopularityScores(ctx context.Context, contentIDs []string) ([]float64, error) {
+	p.batches.Add(1)
+	scores := make([]float64, len(contentIDs))
+	for i, id := range contentIDs {
+		scores[i] = p.scores[id]
+	}
+	return scores, nil
+}
+
+func sentRetrievalScores(t *testing.T, api *fakeAPI) map[string]float32 {
+	t.Helper()
+	scores := map[string]float32{}
+	for _, ins := range api.lastRequest(t).GetInsertion() {
+		scores[ins.GetContentId()] = ins.GetRetrievalScore()
+	}
+	return scores
+}
+
+func deliverWithPopularity(t *testing.T, api *fakeAPI, p PopularityProvider) {
+	t.Helper()
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithPopularityProvider(p, 0.5).
+		WithPopularityFetchTimeout(20*time.Millisecond))
+	req := newTestDeliveryRequest(t, "a", "b")
+	score := float32(1)
+	req.Request.Insertion[0].RetrievalScore = &score
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+}
+
+func TestPopularityBoostsRetrievalScores(t *testing.T) {
+	api := newFakeAPI(t)
+	deliverWithPopularity(t, api, &mapPopularityProvider{scores: map[string]float64{"a": 2, "b": 4}})
+	if got := sentRetrievalScores(t, api); got["a"] != 2 || got["b"] != 2 {
+		t.Errorf("sent retrieval scores %v, want a: 1 + 0.5*2 and b: 0 + 0.5*4", got)
+	}
+}
+
+func TestPopularityIsFetchedInBatches(t *testing.T) {
+	api := newFakeAPI(t)
+	p := &batchMapPopularityProvider{mapPopularityProvider: mapPopularityProvider{scores: map[string]float64{"b": 4}}}
+	deliverWithPopularity(t, api, p)
+	if p.batches.Load() != 1 || p.single.Load() != 0 {
+		t.Errorf("got %d batch and %d single fetches, want one batch", p.batches.Load(), p.single.Load())
+	}
+	if got := sentRetrievalScores(t, api); got["a"] != 1 || got["b"] != 2 {
+		t.Errorf("sent retrieval scores %v, want a: 1 and b: 2", got)
+	}
+}
+
+func TestUnavailablePopularitySendsUnboosted(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider *mapPopularityProvider
+	}{
+		{"error", &mapPopularityProvider{err: errors.New("counter service unavailable")}},
+		{"timeout", &mapPopularityProvider{scores: map[string]float64{"a": 2, "b": 4}, delay: 100 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t)
+			start := time.Now()
+			deliverWithPopularity(t, api, tt.provider)
+			if elapsed := time.Since(start); elapsed > 80*time.Millisecond {
+				t.Errorf("Deliver took %v, want it bounded by the 20ms fetch timeout", elapsed)
+			}
+			if got := sentRetrievalScores(t, api); got["a"] != 1 || got["b"] != 0 {
+				t.Errorf("sent retrieval scores %v, want them unboosted", got)
+			}
+		})
+	}
+}
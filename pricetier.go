@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+var defaultPriceTierBreakpoints = []float64{0, 10, 50}
+var defaultPriceTierLabels = []string{"low", "mid", "high"}
+
+// PriceTierComputer buckets prices, which ranking models handle better than continuous values.
+type PriceTierComputer struct {
+	breakpoints []float64
+	labels      []string
+}
+
+// NewPriceTierComputer is a factory method for PriceTierComputer. Prices in
+// [breakpoints[i], breakpoints[i+1]) get labels[i], and the last label is open-ended. Without
+// breakpoints it uses [0,10) low, [10,50) mid, [50,+) high.
+func NewPriceTierComputer(breakpoints []float64, labels []string) (*PriceTierComputer, error) {
+	if len(breakpoints) == 0 && len(labels) == 0 {
+		breakpoints, labels = defaultPriceTierBreakpoints, defaultPriceTierLabels
+	}
+	if len(breakpoints) != len(labels) {
+		return nil, errors.New("price tiers need one label per breakpoint")
+	}
+	if !sort.Float64sAreSorted(breakpoints) {
+		return nil, errors.New("price tier breakpoints must be ascending")
+	}
+	return &PriceTierComputer{
+		breakpoints: append([]float64(nil), breakpoints...),
+		labels:      append([]string(nil), labels...),
+	}, nil
+}
+
+// ComputeTier returns the tier of price, or "" if it is below the first breakpoint, e.g. negative.
+func (p *PriceTierComputer) ComputeTier(price float64) string {
+	// The first breakpoint above price ends its tier.
+	i := sort.Search(len(p.breakpoints), func(i int) bool { return p.breakpoints[i] > price })
+	if i == 0 {
+		return ""
+	}
+	return p.labels[i-1]
+}
+
+// priceTiering writes each insertion's price tier before sending.
+type priceTiering struct {
+	computer *PriceTierComputer
+	key      string
+	tiersKey string
+}
+
+func (p *priceTiering) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		req = cloneRequest(req)
+		for _, ins := range req.Request.GetInsertion() {
+			price := getProperty(ins.Properties, p.key)
+			if price == nil {
+				continue
+			}
+			tier := p.computer.ComputeTier(price.GetNumberValue())
+			if tier == "" {
+				continue
+			}
+			if err := setProperty(&ins.Properties, p.tiersKey, tier); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestComputeTier(t *testing.T) {
+	p, err := NewPriceTierComputer(nil, nil)
+	if err != nil {
+		t.Fatalf("NewPriceTierComputer failed: %v", err)
+	}
+	tests := []struct {
+		price float64
+		want  string
+	}{
+		{0, "low"},
+		{9.99, "low"},
+		{10, "mid"},
+		{49.99, "mid"},
+		{50, "high"},
+		{1e6, "high"},
+		{-0.01, ""},
+		{-10, ""},
+	}
+	for _, tt := range tests {
+		if got := p.ComputeTier(tt.price); got != tt.want {
+			t.Errorf("ComputeTier(%v) = %q, want %q", tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestNewPriceTierComputerValidates(t *testing.T) {
+	if _, err := NewPriceTierComputer([]float64{0, 10}, []string{"low"}); err == nil {
+		t.Error("NewPriceTierComputer succeeded with mismatched labels")
+	}
+	if _, err := NewPriceTierComputer([]float64{10, 0}, []string{"mid", "low"}); err == nil {
+		t.Error("NewPriceTierComputer succeeded with descending breakpoints")
+	}
+}
+
+func TestPriceTierComputation(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithPriceTierComputation("price", "priceTier", []float64{0, 100}, []string{"budget", "premium"}))
+	req := newTestDeliveryRequest(t, "cheap", "pricey", "negative", "unpriced")
+	for i, price := range []float64{5, 100, -1} {
+		if err := setProperty(&req.Request.Insertion[i].Properties, "price", price); err != nil {
+			t.Fatalf("error setting price: %v", err)
+		}
+	}
+
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	for id, want := range map[string]any{"cheap": "budget", "pricey": "premium", "negative": nil, "unpriced": nil} {
+		if got := sentProperty(t, api, id, "priceTier"); got != want {
+			t.Errorf("sent %s price tier %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestPriceTierComputationRejectsInvalidTiers(t *testing.T) {
+	_, err := newTestClientBuilder(t, newFakeAPI(t)).
+		WithPriceTierComputation("price", "priceTier", []float64{0}, []string{"low", "high"}).
+		Build()
+	if err == nil {
+		t.Error("Build succeeded with mismatched price tier labels")
+	}
+}
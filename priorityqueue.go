@@ -0,0 +1,109 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// priorityQueue bounds concurrent Delivery API calls. Under backpressure, waiting calls are let
+// through by importance, then in arrival order.
+type priorityQueue struct {
+	maxConcurrent int
+
+	mu      sync.Mutex
+	active  int
+	seq     uint64
+	waiters waiterHeap
+}
+
+// queueWaiter is a call waiting for a slot. ready is closed once the slot is handed to it.
+type queueWaiter struct {
+	rank  int
+	seq   uint64
+	ready chan struct{}
+	index int
+}
+
+func newPriorityQueue(maxConcurrent int) *priorityQueue {
+	return &priorityQueue{maxConcurrent: maxConcurrent}
+}
+
+// acquire waits for a slot. Callers must release it once their call is done.
+func (q *priorityQueue) acquire(ctx context.Context, importance ImportanceLevel) error {
+	q.mu.Lock()
+	if q.active < q.maxConcurrent && q.waiters.Len() == 0 {
+		q.active++
+		q.mu.Unlock()
+		return nil
+	}
+	q.seq++
+	w := &queueWaiter{rank: importance.rank(), seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiters, w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if w.index >= 0 {
+			heap.Remove(&q.waiters, w.index)
+		} else {
+			// The slot was handed over as ctx ended, so pass it on.
+			q.releaseLocked()
+		}
+		return fmt.Errorf("error waiting in priority queue: %v", ctx.Err())
+	}
+}
+
+// release frees a slot, handing it to the most important waiter, if any.
+func (q *priorityQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked()
+}
+
+func (q *priorityQueue) releaseLocked() {
+	if q.waiters.Len() == 0 {
+		q.active--
+		return
+	}
+	w := heap.Pop(&q.waiters).(*queueWaiter)
+	close(w.ready)
+}
+
+// waiterHeap implements heap.Interface, most important and then oldest first.
+type waiterHeap []*queueWaiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].rank != h[j].rank {
+		return h[i].rank > h[j].rank
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*queueWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
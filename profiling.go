@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DeliveryProfile breaks down where a Deliver call spent its time.
+type DeliveryProfile struct {
+	// SerializationMs is spent encoding the Delivery API request.
+	SerializationMs int64
+	// NetworkMs is spent sending the request and reading the response body.
+	NetworkMs int64
+	// DeserializationMs is spent decoding the Delivery API response.
+	DeserializationMs int64
+	// PostProcessingMs is spent after the Delivery API returned, e.g. logging and re-ranking.
+	PostProcessingMs int64
+	// TotalMs is the whole Deliver call, including work before the Delivery API call.
+	TotalMs int64
+}
+
+// profileKey is the context key for the profile of the current Deliver call.
+type profileKey struct{}
+
+// profilePhase is a phase of the Delivery API call.
+type profilePhase int
+
+const (
+	phaseSerialization profilePhase = iota
+	phaseNetwork
+	phaseDeserialization
+	numProfilePhases
+)
+
+// deliveryProfiler collects phase timings for one Deliver call. It is locked since background work
+// such as cache refreshes may share the call's context.
+type deliveryProfiler struct {
+	mu      sync.Mutex
+	phases  [numProfilePhases]time.Duration
+	apiDone time.Time
+}
+
+// profilerFromContext returns the current call's profiler, or nil if profiling is off.
+func profilerFromContext(ctx context.Context) *deliveryProfiler {
+	p, _ := ctx.Value(profileKey{}).(*deliveryProfiler)
+	return p
+}
+
+// observe adds the time since start to one of the profiler's phases. It is a no-op on a nil profiler.
+func (p *deliveryProfiler) observe(phase profilePhase, start time.Time) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.phases[phase] += time.Since(start)
+	p.apiDone = time.Now()
+}
+
+// profiling attaches a DeliveryProfile to each response.
+func profiling(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		p := &deliveryProfiler{}
+		start := time.Now()
+		resp, err := next(context.WithValue(ctx, profileKey{}, p), req)
+		if err != nil {
+			return nil, err
+		}
+		end := time.Now()
+
+		p.mu.Lock()
+		profile := &DeliveryProfile{
+			SerializationMs:   p.phases[phaseSerialization].Milliseconds(),
+			NetworkMs:         p.phases[phaseNetwork].Milliseconds(),
+			DeserializationMs: p.phases[phaseDeserialization].Milliseconds(),
+			TotalMs:           end.Sub(start).Milliseconds(),
+		}
+		if !p.apiDone.IsZero() {
+			profile.PostProcessingMs = end.Sub(p.apiDone).Milliseconds()
+		}
+		p.mu.Unlock()
+
+		// Copy the response since it may be shared, e.g. by the cache.
+		profiled := *resp
+		profiled.Profile = profile
+		return &profiled, nil
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestProfileCoversEveryPhase(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		time.Sleep(5 * time.Millisecond)
+		writeDeliveryResponse(t, w, echoResponse(req))
+	})
+	// The timeout leaves room for encoding this many insertions under the race detector.
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithProfiling(true).
+		WithDeliveryTimeoutMillis(5000).
+		WithMaxRequestInsertions(20000).
+		WithDiversityFilter(DiversityFilter{Attribute: "brand", MaxPerValue: 1}, ""))
+	// Enough insertions that encoding, decoding and re-ranking each take a measurable time.
+	ids := make([]string, 20000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("content-with-a-long-identifier-%d", i)
+	}
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, ids...))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	p := resp.Profile
+	if p == nil {
+		t.Fatal("got no profile")
+	}
+	if p.SerializationMs <= 0 || p.NetworkMs < 5 || p.DeserializationMs <= 0 || p.PostProcessingMs <= 0 {
+		t.Errorf("got profile %+v, want every phase timed", *p)
+	}
+	if p.TotalMs < p.SerializationMs+p.NetworkMs+p.DeserializationMs+p.PostProcessingMs {
+		t.Errorf("got total %dms less than its phases in %+v", p.TotalMs, *p)
+	}
+}
+
+func TestNoProfileByDefault(t *testing.T) {
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)))
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if resp.Profile != nil {
+		t.Errorf("got profile %+v without profiling, want none", *resp.Profile)
+	}
+}
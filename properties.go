@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ensurePropertiesStruct returns the struct behind props, creating it if unset.
+func ensurePropertiesStruct(props **common.Properties) *structpb.Struct {
+	if *props == nil {
+		*props = &common.Properties{}
+	}
+	s := (*props).GetStruct()
+	if s == nil {
+		s = &structpb.Struct{}
+		(*props).StructField = &common.Properties_Struct{Struct: s}
+	}
+	if s.Fields == nil {
+		s.Fields = map[string]*structpb.Value{}
+	}
+	return s
+}
+
+// setProperty converts value to a structpb.Value and stores it under key.
+func setProperty(props **common.Properties, key string, value any) error {
+	v, err := structpb.NewValue(value)
+	if err != nil {
+		return fmt.Errorf("error converting property %s: %v", key, err)
+	}
+	ensurePropertiesStruct(props).Fields[key] = v
+	return nil
+}
+
+// getProperty returns the value stored under key, or nil if it is not set.
+func getProperty(props *common.Properties, key string) *structpb.Value {
+	return props.GetStruct().GetFields()[key]
+}
+
+// setJSONProperty stores value under key as the structpb equivalent of its JSON encoding.
+func setJSONProperty(props **common.Properties, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("error encoding property %s: %v", key, err)
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Errorf("error encoding property %s: %v", key, err)
+	}
+	return setProperty(props, key, decoded)
+}
+
+// getJSONProperty decodes the property stored under key into out, leaving out alone if unset.
+func getJSONProperty(props *common.Properties, key string, out any) error {
+	v := getProperty(props, key)
+	if v == nil {
+		return nil
+	}
+	data, err := v.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("error decoding property %s: %v", key, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("error decoding property %s: %v", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// InsertionPropertyEncryptor encrypts insertion property values before they leave the process.
+type InsertionPropertyEncryptor interface {
+	Encrypt(value any) (string, error)
+	Decrypt(s string) (any, error)
+}
+
+// aesGCMEncryptor encrypts JSON-encoded values with AES-256-GCM.
+type aesGCMEncryptor struct {
+	aead cipher.AEAD
+}
+
+// AES256GCMEncryptor encrypts property values with AES-256-GCM under a 32-byte key. Each value gets
+// a random 12-byte nonce, prepended to the ciphertext and base64 encoded.
+func AES256GCMEncryptor(key []byte) (InsertionPropertyEncryptor, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("AES-256 key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AES cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating GCM: %v", err)
+	}
+	return &aesGCMEncryptor{aead: aead}, nil
+}
+
+func (e *aesGCMEncryptor) Encrypt(value any) (string, error) {
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("error encoding property value: %v", err)
+	}
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(e.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func (e *aesGCMEncryptor) Decrypt(s string) (any, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding encrypted property: %v", err)
+	}
+	nonceSize := e.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("encrypted property is too short")
+	}
+	plaintext, err := e.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting property: %v", err)
+	}
+	var value any
+	if err := json.Unmarshal(plaintext, &value); err != nil {
+		return nil, fmt.Errorf("error decoding property value: %v", err)
+	}
+	return value, nil
+}
+
+// propertyEncryption encrypts the named insertion properties on the way out and decrypts them in
+// the response.
+type propertyEncryption struct {
+	encryptor InsertionPropertyEncryptor
+	keys      []string
+}
+
+func (p *propertyEncryption) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		// Encrypt a copy so the caller's request keeps its plaintext values.
+		encrypted := cloneRequest(req)
+		for _, ins := range encrypted.Request.GetInsertion() {
+			if err := p.encrypt(ins); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := next(ctx, encrypted)
+		if err != nil {
+			return nil, err
+		}
+		resp = cloneResponse(resp)
+		for _, ins := range resp.Response.GetInsertion() {
+			if err := p.decrypt(ins); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+}
+
+func (p *propertyEncryption) encrypt(ins *delivery.Insertion) error {
+	for _, key := range p.keys {
+		v := getProperty(ins.Properties, key)
+		if v == nil {
+			continue
+		}
+		s, err := p.encryptor.Encrypt(v.AsInterface())
+		if err != nil {
+			return fmt.Errorf("error encrypting property %s: %v", key, err)
+		}
+		if err := setProperty(&ins.Properties, key, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decrypt decrypts the insertion's encrypted properties. Values that aren't encrypted strings, e.g.
+// ones the Delivery API set itself, are left as they are, since the ranking already succeeded.
+func (p *propertyEncryption) decrypt(ins *delivery.Insertion) error {
+	for _, key := range p.keys {
+		v := getProperty(ins.Properties, key)
+		if v == nil {
+			continue
+		}
+		ciphertext, ok := v.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			log.Printf("WARN: property %s of content %s isn't encrypted, leaving it as is\n", key, ins.GetContentId())
+			continue
+		}
+		value, err := p.encryptor.Decrypt(ciphertext.StringValue)
+		if err != nil {
+			log.Printf("WARN: error decrypting property %s of content %s, leaving it as is: %v\n", key, ins.GetContentId(), err)
+			continue
+		}
+		if err := setProperty(&ins.Properties, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
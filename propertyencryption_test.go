@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+var testEncryptionKey = bytes.Repeat([]byte{7}, 32)
+
+func newTestEncryptor(t *testing.T, key []byte) InsertionPropertyEncryptor {
+	t.Helper()
+	encryptor, err := AES256GCMEncryptor(key)
+	if err != nil {
+		t.Fatalf("error creating encryptor: %v", err)
+	}
+	return encryptor
+}
+
+func TestAES256GCMRoundTrip(t *testing.T) {
+	encryptor := newTestEncryptor(t, testEncryptionKey)
+	for _, value := range []any{"customer-123", 42.5, true, nil, map[string]any{"a": "b"}, []any{"x", 1.0}} {
+		s, err := encryptor.Encrypt(value)
+		if err != nil {
+			t.Fatalf("Encrypt(%v) failed: %v", value, err)
+		}
+		got, err := encryptor.Decrypt(s)
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, value) {
+			t.Errorf("round trip of %v returned %v", value, got)
+		}
+	}
+}
+
+func TestAES256GCMUsesRandomNonces(t *testing.T) {
+	encryptor := newTestEncryptor(t, testEncryptionKey)
+	a, _ := encryptor.Encrypt("customer-123")
+	b, _ := encryptor.Encrypt("customer-123")
+	if a == b {
+		t.Error("encrypting the same value twice gave the same ciphertext")
+	}
+}
+
+func TestAES256GCMRejectsWrongKeyAndTampering(t *testing.T) {
+	s, _ := newTestEncryptor(t, testEncryptionKey).Encrypt("customer-123")
+	if _, err := newTestEncryptor(t, bytes.Repeat([]byte{8}, 32)).Decrypt(s); err == nil {
+		t.Error("Decrypt with the wrong key succeeded")
+	}
+	if _, err := newTestEncryptor(t, testEncryptionKey).Decrypt("not base64!"); err == nil {
+		t.Error("Decrypt of a malformed value succeeded")
+	}
+	if _, err := AES256GCMEncryptor([]byte("short")); err == nil {
+		t.Error("AES256GCMEncryptor accepted a short key")
+	}
+}
+
+// echoProperties responds with the request's insertions and their properties.
+func echoProperties(t *testing.T) func(w http.ResponseWriter, req *delivery.Request) {
+	return func(w http.ResponseWriter, req *delivery.Request) {
+		resp := echoResponse(req)
+		for i, ins := range req.GetInsertion() {
+			resp.Insertion[i].Properties = ins.GetProperties()
+		}
+		writeDeliveryResponse(t, w, resp)
+	}
+}
+
+func TestInsertionPropertyEncryption(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(echoProperties(t))
+	encryptor := newTestEncryptor(t, testEncryptionKey)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithInsertionPropertyEncryption(encryptor, []string{"customerId"}))
+
+	req := newTestDeliveryRequest(t, "a")
+	setProperty(&req.Request.Insertion[0].Properties, "customerId", "customer-123")
+	setProperty(&req.Request.Insertion[0].Properties, "color", "red")
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+
+	sent := api.lastRequest(t).GetInsertion()[0].GetProperties()
+	if got := getProperty(sent, "customerId").GetStringValue(); got == "customer-123" || got == "" {
+		t.Errorf("customerId was sent as %q, want it encrypted", got)
+	}
+	if got := getProperty(sent, "color").GetStringValue(); got != "red" {
+		t.Errorf("color was sent as %q, want it unencrypted", got)
+	}
+	if got := getProperty(resp.Response.GetInsertion()[0].GetProperties(), "customerId").GetStringValue(); got != "customer-123" {
+		t.Errorf("got decrypted customerId %q, want customer-123", got)
+	}
+	if got := getProperty(req.Request.GetInsertion()[0].GetProperties(), "customerId").GetStringValue(); got != "customer-123" {
+		t.Errorf("the caller's customerId became %q", got)
+	}
+}
+
+func TestUndecryptableResponsePropertiesPassThrough(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		resp := echoResponse(req)
+		setProperty(&resp.Insertion[0].Properties, "customerId", 42)
+		setProperty(&resp.Insertion[1].Properties, "customerId", "plain")
+		writeDeliveryResponse(t, w, resp)
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithInsertionPropertyEncryption(newTestEncryptor(t, testEncryptionKey), []string{"customerId"}))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	insertions := resp.Response.GetInsertion()
+	if got := getProperty(insertions[0].GetProperties(), "customerId").GetNumberValue(); got != 42 {
+		t.Errorf("got non-string customerId %v, want 42 left as is", got)
+	}
+	if got := getProperty(insertions[1].GetProperties(), "customerId").GetStringValue(); got != "plain" {
+		t.Errorf("got undecodable customerId %q, want plain left as is", got)
+	}
+}
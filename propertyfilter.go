@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+// internalPropertyKeys are the insertion properties this client sets itself. They pass an
+// allowlist, since features like ExtractBundles and RegroupResponse read them back, but can still
+// be denied.
+var internalPropertyKeys = map[string]bool{
+	bundlePropertyKey:           true,
+	groupIDPropertyKey:          true,
+	contentTypePropertyKey:      true,
+	boostMultiplierPropertyKey:  true,
+	localScorePropertyKey:       true,
+	originalPricePropertyKey:    true,
+	originalCurrencyPropertyKey: true,
+}
+
+// propertyKeyFilter strips insertion property keys that mustn't leave the process, e.g. PII
+// like email or ssn. With an allowlist, only its keys and the internal ones are sent; otherwise
+// denied keys are removed. It runs innermost, so properties added by other features are filtered
+// too.
+type propertyKeyFilter struct {
+	allowed map[string]bool
+	denied  map[string]bool
+}
+
+func newPropertyKeyFilter(allowed, denied []string) *propertyKeyFilter {
+	return &propertyKeyFilter{allowed: keySet(allowed), denied: keySet(denied)}
+}
+
+func keySet(keys []string) map[string]bool {
+	if len(keys) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
+
+func (f *propertyKeyFilter) stripped(key string) bool {
+	if f.allowed != nil {
+		return !f.allowed[key] && !internalPropertyKeys[key]
+	}
+	return f.denied[key]
+}
+
+func (f *propertyKeyFilter) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		if !f.hasStrippedKeys(req) {
+			return next(ctx, req)
+		}
+		req = cloneRequest(req)
+		f.strip(req)
+		return next(ctx, req)
+	}
+}
+
+// strip removes the keys that aren't allowed from req's insertions, which it modifies.
+func (f *propertyKeyFilter) strip(req *DeliveryRequest) {
+	stripped := map[string]bool{}
+	for _, ins := range req.Request.GetInsertion() {
+		fields := ins.GetProperties().GetStruct().GetFields()
+		for key := range fields {
+			if f.stripped(key) {
+				delete(fields, key)
+				stripped[key] = true
+			}
+		}
+	}
+	// Only the keys are logged, as the values may be PII.
+	for key := range stripped {
+		log.Printf("WARN: stripped insertion property %q that is not allowed to be sent\n", key)
+	}
+}
+
+// hasStrippedKeys says whether any insertion has a property to strip, so clean requests aren't cloned.
+func (f *propertyKeyFilter) hasStrippedKeys(req *DeliveryRequest) bool {
+	for _, ins := range req.Request.GetInsertion() {
+		for key := range ins.GetProperties().GetStruct().GetFields() {
+			if f.stripped(key) {
+				return true
+			}
+		}
+	}
+	return false
+}
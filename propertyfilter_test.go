@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// newPIIRequest builds a request whose insertion a has a brand and PII properties.
+func newPIIRequest(t *testing.T) *DeliveryRequest {
+	t.Helper()
+	return buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo: &common.UserInfo{AnonUserId: "anon-1"},
+		Insertion: []*delivery.Insertion{
+			insertionWithProperties(t, "a", map[string]any{"brand": "acme", "email": "jane@example.com", "ssn": "123-45-6789"}),
+			{ContentId: "b"},
+		},
+	}))
+}
+
+func TestDeniedPropertyKeysAreStripped(t *testing.T) {
+	logs := captureLog(t)
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithDeniedPropertyKeys("email", "ssn"))
+
+	req := newPIIRequest(t)
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	for _, key := range []string{"email", "ssn"} {
+		if got := sentProperty(t, api, "a", key); got != nil {
+			t.Errorf("sent denied property %s=%v", key, got)
+		}
+		if !strings.Contains(logs.String(), `stripped insertion property "`+key+`"`) {
+			t.Errorf("no warning for %s in logs:\n%s", key, logs.String())
+		}
+	}
+	if got := sentProperty(t, api, "a", "brand"); got != "acme" {
+		t.Errorf("sent brand %v, want acme", got)
+	}
+	for _, value := range []string{"jane@example.com", "123-45-6789"} {
+		if strings.Contains(logs.String(), value) {
+			t.Errorf("logs contain the stripped value %s", value)
+		}
+	}
+	if getProperty(req.Request.Insertion[0].GetProperties(), "ssn") == nil {
+		t.Error("the caller's request was modified")
+	}
+}
+
+func TestAllowedPropertyKeysStripOthers(t *testing.T) {
+	logs := captureLog(t)
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithAllowedPropertyKeys("brand"))
+
+	if _, err := c.Deliver(context.Background(), newPIIRequest(t)); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := sentProperty(t, api, "a", "brand"); got != "acme" {
+		t.Errorf("sent brand %v, want acme", got)
+	}
+	for _, key := range []string{"email", "ssn"} {
+		if got := sentProperty(t, api, "a", key); got != nil {
+			t.Errorf("sent property %s=%v outside the allowlist", key, got)
+		}
+		if !strings.Contains(logs.String(), `"`+key+`"`) {
+			t.Errorf("no warning for %s in logs:\n%s", key, logs.String())
+		}
+	}
+}
+
+func TestInternalPropertyKeysPassTheAllowlist(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithAllowedPropertyKeys("brand").
+		WithContentScorer(&fakeContentScorer{}))
+
+	if _, err := c.Deliver(context.Background(), newPIIRequest(t)); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := sentProperty(t, api, "a", localScorePropertyKey); got == nil {
+		t.Errorf("%s was stripped by the allowlist", localScorePropertyKey)
+	}
+	if got := sentProperty(t, api, "a", "email"); got != nil {
+		t.Errorf("sent email %v outside the allowlist", got)
+	}
+}
+
+func TestDeniedPropertyKeysFilterFeatureProperties(t *testing.T) {
+	api := newFakeAPI(t)
+	// The filter runs after the content scorer sets the property.
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithDeniedPropertyKeys(localScorePropertyKey).
+		WithContentScorer(&fakeContentScorer{}))
+
+	if _, err := c.Deliver(context.Background(), newPIIRequest(t)); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	for _, contentID := range []string{"a", "b"} {
+		if got := sentProperty(t, api, contentID, localScorePropertyKey); got != nil {
+			t.Errorf("sent denied %s=%v for %s", localScorePropertyKey, got, contentID)
+		}
+	}
+}
+
+func TestAllowedAndDeniedPropertyKeysAreExclusive(t *testing.T) {
+	_, err := newTestClientBuilder(t, newFakeAPI(t)).
+		WithAllowedPropertyKeys("brand").
+		WithDeniedPropertyKeys("email").
+		Build()
+	if err == nil {
+		t.Error("Build succeeded with both an allowlist and a denylist")
+	}
+}
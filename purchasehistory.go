@@ -0,0 +1,32 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+const purchaseHistoryPropertyKey = "purchaseHistory"
+
+// PurchasedItem is one item the user bought.
+type PurchasedItem struct {
+	ContentID   string    `json:"contentId"`
+	PurchasedAt time.Time `json:"purchasedAt"`
+	Quantity    int       `json:"quantity"`
+}
+
+// PurchaseHistory is the user's recent purchases, sent to personalize ranking.
+type PurchaseHistory struct {
+	Items []PurchasedItem
+}
+
+// mostRecent returns up to n items, newest first. n <= 0 keeps every item.
+func (h PurchaseHistory) mostRecent(n int) []PurchasedItem {
+	items := append([]PurchasedItem(nil), h.Items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].PurchasedAt.After(items[j].PurchasedAt)
+	})
+	if n > 0 && len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
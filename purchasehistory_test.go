@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPurchaseHistoryIsCappedToMostRecent(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	history := PurchaseHistory{Items: []PurchasedItem{
+		{ContentID: "oldest", PurchasedAt: now.Add(-72 * time.Hour), Quantity: 1},
+		{ContentID: "newest", PurchasedAt: now, Quantity: 2},
+		{ContentID: "old", PurchasedAt: now.Add(-48 * time.Hour), Quantity: 1},
+		{ContentID: "recent", PurchasedAt: now.Add(-time.Hour), Quantity: 3},
+	}}
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).
+		WithPurchaseHistory(history).
+		WithMaxPurchaseHistoryItems(2))
+
+	var got []PurchasedItem
+	if err := getJSONProperty(req.Request.GetProperties(), purchaseHistoryPropertyKey, &got); err != nil {
+		t.Fatalf("error reading purchase history: %v", err)
+	}
+	want := []PurchasedItem{history.Items[1], history.Items[3]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got purchases %v, want the 2 most recent %v", got, want)
+	}
+}
+
+func TestPurchaseHistoryUsesISO8601Timestamps(t *testing.T) {
+	history := PurchaseHistory{Items: []PurchasedItem{
+		{ContentID: "a", PurchasedAt: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), Quantity: 1},
+	}}
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithPurchaseHistory(history))
+
+	item := getProperty(req.Request.GetProperties(), purchaseHistoryPropertyKey).GetListValue().GetValues()[0]
+	if got := item.GetStructValue().GetFields()["purchasedAt"].GetStringValue(); got != "2024-03-01T12:30:00Z" {
+		t.Errorf("got timestamp %q, want 2024-03-01T12:30:00Z", got)
+	}
+}
+
+func TestPurchaseHistoryIsUncappedByDefault(t *testing.T) {
+	history := PurchaseHistory{Items: make([]PurchasedItem, 50)}
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithPurchaseHistory(history))
+
+	var got []PurchasedItem
+	if err := getJSONProperty(req.Request.GetProperties(), purchaseHistoryPropertyKey, &got); err != nil {
+		t.Fatalf("error reading purchase history: %v", err)
+	}
+	if len(got) != 50 {
+		t.Errorf("got %d purchases, want 50", len(got))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// QueryExpander adds related terms to short queries, e.g. "phone" to "smartphone" and "mobile".
+// Unlike synonyms, expansions only apply from the query to its related terms.
+type QueryExpander struct {
+	related map[string][]string
+}
+
+// LoadQueryExpansionsFromJSON reads a JSON object mapping queries to related terms, most related
+// first, e.g. {"phone": ["smartphone", "mobile"]}.
+func LoadQueryExpansionsFromJSON(r io.Reader) (*QueryExpander, error) {
+	var raw map[string][]string
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error reading query expansions: %v", err)
+	}
+	related := make(map[string][]string, len(raw))
+	for query, terms := range raw {
+		related[normalizeQuery(query)] = terms
+	}
+	return &QueryExpander{related: related}, nil
+}
+
+// Expand returns the query followed by up to maxTerms related terms. Unknown queries are returned
+// alone.
+func (e *QueryExpander) Expand(query string, maxTerms int) []string {
+	terms := e.related[normalizeQuery(query)]
+	if len(terms) > maxTerms {
+		terms = terms[:max(maxTerms, 0)]
+	}
+	return append([]string{query}, terms...)
+}
+
+// queryExpansion rewrites the search query to an OR of its related terms before sending.
+type queryExpansion struct {
+	expander *QueryExpander
+	maxTerms int
+}
+
+func (q *queryExpansion) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		query := req.Request.GetSearchQuery()
+		expanded := q.expander.Expand(query, q.maxTerms)
+		if query == "" || len(expanded) == 1 {
+			return next(ctx, req)
+		}
+
+		req = cloneRequest(req)
+		if err := setOriginalQuery(req, query); err != nil {
+			return nil, err
+		}
+		req.Request.SearchQuery = strings.Join(expanded, " OR ")
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestQueryExpander(t *testing.T) *QueryExpander {
+	t.Helper()
+	e, err := LoadQueryExpansionsFromJSON(strings.NewReader(`{"Phone": ["smartphone", "mobile", "cell"]}`))
+	if err != nil {
+		t.Fatalf("LoadQueryExpansionsFromJSON failed: %v", err)
+	}
+	return e
+}
+
+func TestQueryExpansion(t *testing.T) {
+	e := newTestQueryExpander(t)
+	tests := []struct {
+		query    string
+		maxTerms int
+		want     []string
+	}{
+		{"phone", 5, []string{"phone", "smartphone", "mobile", "cell"}},
+		{" PHONE ", 2, []string{" PHONE ", "smartphone", "mobile"}},
+		{"phone", 0, []string{"phone"}},
+		{"laptop", 5, []string{"laptop"}},
+		// Expansions only go one way.
+		{"smartphone", 5, []string{"smartphone"}},
+	}
+	for _, tt := range tests {
+		if got := e.Expand(tt.query, tt.maxTerms); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Expand(%q, %d) = %v, want %v", tt.query, tt.maxTerms, got, tt.want)
+		}
+	}
+}
+
+func TestLoadQueryExpansionsRejectsInvalidJSON(t *testing.T) {
+	if _, err := LoadQueryExpansionsFromJSON(strings.NewReader(`["phone"]`)); err == nil {
+		t.Error("LoadQueryExpansionsFromJSON succeeded on a JSON list")
+	}
+}
+
+func TestQueryExpanderRewritesSearchQuery(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithQueryExpander(newTestQueryExpander(t), 2))
+
+	if _, err := c.Deliver(context.Background(), newQueryRequest(t, "phone", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	sent := api.lastRequest(t)
+	if got, want := sent.GetSearchQuery(), "phone OR smartphone OR mobile"; got != want {
+		t.Errorf("sent query %q, want %q", got, want)
+	}
+	if got := getProperty(sent.GetProperties(), originalQueryPropertyKey).GetStringValue(); got != "phone" {
+		t.Errorf("sent original query %q, want phone", got)
+	}
+
+	if _, err := c.Deliver(context.Background(), newQueryRequest(t, "laptop", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	sent = api.lastRequest(t)
+	if sent.GetSearchQuery() != "laptop" || getProperty(sent.GetProperties(), originalQueryPropertyKey) != nil {
+		t.Errorf("got query %q and original query %v, want laptop unchanged", sent.GetSearchQuery(), getProperty(sent.GetProperties(), originalQueryPropertyKey))
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// loggedQuery is one LogQuery call.
+type loggedQuery struct {
+	query       string
+	userID      string
+	useCase     delivery.UseCase
+	resultCount int
+}
+
+// recordingQueryLogger records every logged query.
+type recordingQueryLogger struct {
+	mu      sync.Mutex
+	queries []loggedQuery
+}
+
+func (l *recordingQueryLogger) LogQuery(_ context.Context, query string, userID string, useCase delivery.UseCase, resultCount int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queries = append(l.queries, loggedQuery{query, userID, useCase, resultCount})
+	return nil
+}
+
+func (l *recordingQueryLogger) logged(t *testing.T) []loggedQuery {
+	t.Helper()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]loggedQuery(nil), l.queries...)
+}
+
+func newSearchRequest(t *testing.T, userID, query string, contentIDs ...string) *DeliveryRequest {
+	t.Helper()
+	return buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:    &common.UserInfo{UserId: userID},
+		UseCase:     delivery.UseCase_SEARCH,
+		SearchQuery: query,
+		Insertion:   testInsertions(contentIDs...),
+	}))
+}
+
+func TestQueryIsLoggedWithResultCount(t *testing.T) {
+	logger := &recordingQueryLogger{}
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithQueryLogger(logger))
+
+	if _, err := c.Deliver(context.Background(), newSearchRequest(t, "user-1", "red shoes", "a", "b", "c")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	want := loggedQuery{query: "red shoes", userID: "user-1", useCase: delivery.UseCase_SEARCH, resultCount: 3}
+	if got := logger.logged(t); len(got) != 1 || got[0] != want {
+		t.Errorf("got logged queries %+v, want [%+v]", got, want)
+	}
+}
+
+func TestFailedDeliveryIsLoggedWithNoResults(t *testing.T) {
+	logger := &recordingQueryLogger{}
+	logging := &queryLogging{logger: logger}
+	failure := errors.New("delivery failed")
+	deliver := logging.middleware(func(context.Context, *DeliveryRequest) (*DeliveryResponse, error) {
+		return nil, failure
+	})
+
+	if _, err := deliver(context.Background(), newSearchRequest(t, "user-1", "shoes", "a")); !errors.Is(err, failure) {
+		t.Errorf("got error %v, want the delivery failure", err)
+	}
+	want := loggedQuery{query: "shoes", userID: "user-1", useCase: delivery.UseCase_SEARCH}
+	if got := logger.logged(t); len(got) != 1 || got[0] != want {
+		t.Errorf("got logged queries %+v, want [%+v]", got, want)
+	}
+}
+
+func TestQueryLogHidesUserIDs(t *testing.T) {
+	pepper := []byte("pepper")
+	logger := &recordingQueryLogger{}
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).
+		WithQueryLogger(logger).
+		WithUserIDAnonymizer(NewHMACUserIDAnonymizer(pepper)).
+		WithGDPROptOut(optedOutFromContext))
+
+	if _, err := c.Deliver(context.Background(), newSearchRequest(t, "user-1", "shoes", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	optedOut := context.WithValue(context.Background(), optedOutKey{}, true)
+	if _, err := c.Deliver(optedOut, newSearchRequest(t, "user-2", "shoes", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+
+	got := logger.logged(t)
+	if len(got) != 2 {
+		t.Fatalf("got %d logged queries, want 2", len(got))
+	}
+	if want := NewHMACUserIDAnonymizer(pepper).Anonymize("user-1"); got[0].userID != want {
+		t.Errorf("logged user ID %q, want the anonymized %q", got[0].userID, want)
+	}
+	if got[1].userID != "" {
+		t.Errorf("logged user ID %q for an opted-out user, want none", got[1].userID)
+	}
+}
+
+// fakeBigQueryInserter records inserted rows, blocking each insert until unblock is closed, if set.
+type fakeBigQueryInserter struct {
+	unblock chan struct{}
+
+	mu   sync.Mutex
+	rows []*queryLogRow
+}
+
+func (f *fakeBigQueryInserter) Put(_ context.Context, src any) error {
+	if f.unblock != nil {
+		<-f.unblock
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rows = append(f.rows, src.([]*queryLogRow)...)
+	return nil
+}
+
+func TestBigQueryQueryLoggerFlushesOnClose(t *testing.T) {
+	inserter := &fakeBigQueryInserter{}
+	logger := NewBigQueryQueryLogger(inserter)
+	for _, query := range []string{"a", "b", "c"} {
+		if err := logger.LogQuery(context.Background(), query, "user-1", delivery.UseCase_SEARCH, 2); err != nil {
+			t.Fatalf("LogQuery failed: %v", err)
+		}
+	}
+	logger.Close()
+
+	if len(inserter.rows) != 3 {
+		t.Fatalf("inserted %d rows, want 3", len(inserter.rows))
+	}
+	row := inserter.rows[2]
+	if row.Query != "c" || row.UserID != "user-1" || row.UseCase != "SEARCH" || row.ResultCount != 2 || row.Timestamp.IsZero() {
+		t.Errorf("got row %+v", row)
+	}
+}
+
+func TestBigQueryQueryLoggerDoesNotWaitForInserts(t *testing.T) {
+	inserter := &fakeBigQueryInserter{unblock: make(chan struct{})}
+	logger := NewBigQueryQueryLogger(inserter)
+	defer logger.Close()
+	defer close(inserter.unblock)
+
+	start := time.Now()
+	var dropped bool
+	for i := 0; i < 2*bigQueryBufferSize && !dropped; i++ {
+		dropped = logger.LogQuery(context.Background(), "shoes", "user-1", delivery.UseCase_SEARCH, 1) != nil
+	}
+	if !dropped {
+		t.Error("a stuck inserter never filled the buffer")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("logging took %v with a stuck inserter, want it not to wait", elapsed)
+	}
+}
+
+func TestFileQueryLoggerAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.jsonl")
+	for _, query := range []string{"first", "second"} {
+		logger, err := NewFileQueryLogger(path)
+		if err != nil {
+			t.Fatalf("NewFileQueryLogger failed: %v", err)
+		}
+		if err := logger.LogQuery(context.Background(), query, "user-1", delivery.UseCase_SEARCH, 4); err != nil {
+			t.Fatalf("LogQuery failed: %v", err)
+		}
+		logger.Close()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("error opening query log: %v", err)
+	}
+	defer file.Close()
+	var rows []queryLogRow
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var row queryLogRow
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("error parsing query log line %q: %v", scanner.Text(), err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 2 || rows[0].Query != "first" || rows[1].Query != "second" || rows[1].ResultCount != 4 {
+		t.Errorf("got rows %+v, want first and second appended", rows)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// QueryLogger records search queries for analytics, separately from impression logging.
+type QueryLogger interface {
+	LogQuery(ctx context.Context, query string, userID string, useCase delivery.UseCase, resultCount int) error
+}
+
+// queryLogRow is one logged query.
+type queryLogRow struct {
+	Timestamp   time.Time `json:"timestamp" bigquery:"timestamp"`
+	Query       string    `json:"query" bigquery:"query"`
+	UserID      string    `json:"userId" bigquery:"user_id"`
+	UseCase     string    `json:"useCase" bigquery:"use_case"`
+	ResultCount int       `json:"resultCount" bigquery:"result_count"`
+}
+
+func newQueryLogRow(query string, userID string, useCase delivery.UseCase, resultCount int) *queryLogRow {
+	return &queryLogRow{
+		Timestamp:   time.Now().UTC(),
+		Query:       query,
+		UserID:      userID,
+		UseCase:     useCase.String(),
+		ResultCount: resultCount,
+	}
+}
+
+// BigQueryInserter streams rows into a table. *bigquery.Inserter from cloud.google.com/go/bigquery
+// implements it.
+type BigQueryInserter interface {
+	Put(ctx context.Context, src any) error
+}
+
+const (
+	bigQueryBatchSize     = 500
+	bigQueryBufferSize    = 10000
+	bigQueryFlushInterval = time.Second
+	bigQueryInsertTimeout = 10 * time.Second
+)
+
+// BigQueryQueryLogger streams each query as a row with timestamp, query, user_id, use_case and
+// result_count columns. Rows are buffered and inserted in batches in the background, so logging
+// never waits on BigQuery. Rows logged while the buffer is full are dropped.
+type BigQueryQueryLogger struct {
+	inserter BigQueryInserter
+	rows     chan *queryLogRow
+	done     chan struct{}
+	close    sync.Once
+}
+
+// NewBigQueryQueryLogger is a factory method for BigQueryQueryLogger. Close it to flush buffered
+// rows.
+func NewBigQueryQueryLogger(inserter BigQueryInserter) *BigQueryQueryLogger {
+	l := &BigQueryQueryLogger{
+		inserter: inserter,
+		rows:     make(chan *queryLogRow, bigQueryBufferSize),
+		done:     make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+func (l *BigQueryQueryLogger) LogQuery(_ context.Context, query string, userID string, useCase delivery.UseCase, resultCount int) error {
+	select {
+	case l.rows <- newQueryLogRow(query, userID, useCase, resultCount):
+		return nil
+	default:
+		return errors.New("query log buffer is full, dropping row")
+	}
+}
+
+// Close inserts the buffered rows and stops the background inserter.
+func (l *BigQueryQueryLogger) Close() error {
+	l.close.Do(func() { close(l.rows) })
+	<-l.done
+	return nil
+}
+
+// run inserts rows once a batch fills up or the flush interval passes.
+func (l *BigQueryQueryLogger) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(bigQueryFlushInterval)
+	defer ticker.Stop()
+	batch := make([]*queryLogRow, 0, bigQueryBatchSize)
+	for {
+		select {
+		case row, ok := <-l.rows:
+			if !ok {
+				l.insert(batch)
+				return
+			}
+			batch = append(batch, row)
+			if len(batch) >= bigQueryBatchSize {
+				l.insert(batch)
+				batch = make([]*queryLogRow, 0, bigQueryBatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				l.insert(batch)
+				batch = make([]*queryLogRow, 0, bigQueryBatchSize)
+			}
+		}
+	}
+}
+
+func (l *BigQueryQueryLogger) insert(batch []*queryLogRow) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), bigQueryInsertTimeout)
+	defer cancel()
+	if err := l.inserter.Put(ctx, batch); err != nil {
+		log.Printf("WARN: error inserting %d query rows: %v\n", len(batch), err)
+	}
+}
+
+// FileQueryLogger appends each query to a file as a JSON line.
+type FileQueryLogger struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileQueryLogger is a factory method for FileQueryLogger. It appends to path, creating it if
+// needed.
+func NewFileQueryLogger(path string) (*FileQueryLogger, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening query log: %v", err)
+	}
+	return &FileQueryLogger{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func (l *FileQueryLogger) LogQuery(_ context.Context, query string, userID string, useCase delivery.UseCase, resultCount int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.enc.Encode(newQueryLogRow(query, userID, useCase, resultCount)); err != nil {
+		return fmt.Errorf("error writing query log: %v", err)
+	}
+	return nil
+}
+
+// Close closes the query log file.
+func (l *FileQueryLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// queryLogging logs each delivery's search query with its result count once delivery returns.
+type queryLogging struct {
+	logger QueryLogger
+
+	// optedOut, if set, reports users whose IDs mustn't be logged.
+	optedOut func(ctx context.Context) bool
+
+	// anonymizer, if set, replaces user IDs before they are logged.
+	anonymizer UserIDAnonymizer
+}
+
+// middleware logs every call with the caller's query, before any rewriting, and logs failed
+// deliveries with no results. Opted-out users are logged without a user ID, and other users by
+// their anonymized ID if an anonymizer is configured.
+func (q *queryLogging) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		query := req.Request.GetSearchQuery()
+		userID := q.loggedUserID(ctx, req)
+		useCase := req.Request.GetUseCase()
+
+		resp, err := next(ctx, req)
+		var resultCount int
+		if err == nil {
+			resultCount = len(resp.Response.GetInsertion())
+		}
+		if logErr := q.logger.LogQuery(ctx, query, userID, useCase, resultCount); logErr != nil {
+			log.Printf("WARN: error logging query: %v\n", logErr)
+		}
+		return resp, err
+	}
+}
+
+// loggedUserID is the user ID that may be logged for req, or "" for opted-out users.
+func (q *queryLogging) loggedUserID(ctx context.Context, req *DeliveryRequest) string {
+	if q.optedOut != nil && q.optedOut(ctx) {
+		return ""
+	}
+	userID := requestUserID(req.Request)
+	if userID != "" && q.anonymizer != nil {
+		return q.anonymizer.Anonymize(userID)
+	}
+	return userID
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/time/rate"
+)
+
+// errRateLimited makes a call fall back to SDK delivery when the limiter drops instead of waiting.
+var errRateLimited = errors.New("outbound rate limit exceeded")
+
+// RateLimitWaitError is returned by Deliver when the context ends while waiting for the outbound
+// rate limiter.
+type RateLimitWaitError struct {
+	Err error
+}
+
+func (e *RateLimitWaitError) Error() string {
+	return fmt.Sprintf("waiting for outbound rate limit: %v", e.Err)
+}
+
+func (e *RateLimitWaitError) Unwrap() error {
+	return e.Err
+}
+
+// outboundRateLimiter keeps Delivery API calls within a token bucket.
+type outboundRateLimiter struct {
+	limiter *rate.Limiter
+	drop    bool
+}
+
+// acquire takes a token, waiting for one unless the limiter drops.
+func (l *outboundRateLimiter) acquire(ctx context.Context) error {
+	if l.drop {
+		if !l.limiter.Allow() {
+			return errRateLimited
+		}
+		return nil
+	}
+	if err := l.limiter.Wait(ctx); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return &RateLimitWaitError{Err: ctxErr}
+		}
+		// The limiter gives up early when the wait would outlast the deadline.
+		return &RateLimitWaitError{Err: context.DeadlineExceeded}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestOutboundRateLimitHoldsQPSUnderBurst(t *testing.T) {
+	const rps = 100
+	const calls = 51
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithOutboundRateLimit(rps, 1))
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+				t.Errorf("Deliver failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The burst token is free and the rest arrive at the limit at the earliest. A slow machine can
+	// only lower the rate, so only the upper bound is checked.
+	qps := float64(calls-1) / time.Since(start).Seconds()
+	if qps > rps*1.01 {
+		t.Errorf("got %.1f QPS, want at most %d", qps, rps)
+	}
+	if api.calls() != calls {
+		t.Errorf("got %d Delivery API calls, want %d", api.calls(), calls)
+	}
+}
+
+func TestOutboundRateLimitWaitHonorsContext(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithOutboundRateLimit(0.01, 1))
+
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.Deliver(ctx, newTestDeliveryRequest(t, "a"))
+	var waitErr *RateLimitWaitError
+	if !errors.As(err, &waitErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want a RateLimitWaitError wrapping the deadline", err)
+	}
+}
+
+func TestOutboundRateLimitDropFallsBack(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithOutboundRateLimit(0.01, 1).WithRateLimitDropBehavior(true))
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b"))
+		if err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+		if i == 1 {
+			if !errors.Is(resp.APIError, errRateLimited) || resp.ExecutionServer != delivery.ExecutionServer_SDK {
+				t.Errorf("got API error %v from %v, want the rate limit error and an SDK fallback", resp.APIError, resp.ExecutionServer)
+			}
+			assertContentIDs(t, resp, "a", "b")
+		}
+	}
+	if api.calls() != 1 {
+		t.Errorf("got %d Delivery API calls, want 1", api.calls())
+	}
+}
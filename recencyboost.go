@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// RecencyBooster raises the retrieval score of recently created content, which tends to convert
+// better but has little engagement history yet.
+type RecencyBooster struct {
+	createdAtKey string
+	halfLife     time.Duration
+	now          func() time.Time
+}
+
+// NewRecencyBooster is a factory method for RecencyBooster. createdAtKey names the insertion
+// property holding the creation time, as an RFC 3339 string or Unix seconds.
+func NewRecencyBooster(createdAtKey string, halfLife time.Duration) *RecencyBooster {
+	return &RecencyBooster{createdAtKey: createdAtKey, halfLife: halfLife, now: time.Now}
+}
+
+// Boost adds 2^(-age/halfLifeDuration) to each insertion's retrieval score and returns copies of the
+// insertions sorted by the new score, keeping ties in order. Insertions without the createdAtKey
+// property get no boost. WithRecencyBooster calls it with the booster's own settings.
+func (b *RecencyBooster) Boost(insertions []*delivery.Insertion, createdAtKey string, halfLifeDuration time.Duration) []*delivery.Insertion {
+	now := b.now()
+	boosted := make([]*delivery.Insertion, len(insertions))
+	for i, ins := range insertions {
+		boosted[i] = proto.Clone(ins).(*delivery.Insertion)
+		score := ins.GetRetrievalScore() + recencyBoost(ins.GetProperties(), createdAtKey, halfLifeDuration, now)
+		boosted[i].RetrievalScore = &score
+	}
+	sortByRetrievalScore(boosted)
+	return boosted
+}
+
+// recencyBoost is 2^(-age/halfLife) for content created at the createdAtKey property, or 0 if it's
+// missing.
+func recencyBoost(props *common.Properties, createdAtKey string, halfLife time.Duration, now time.Time) float32 {
+	createdAt, ok := propertyTime(getProperty(props, createdAtKey))
+	if !ok || halfLife <= 0 {
+		return 0
+	}
+	age := now.Sub(createdAt)
+	if age < 0 {
+		age = 0
+	}
+	return float32(math.Exp2(-float64(age) / float64(halfLife)))
+}
+
+// propertyTime reads a property value as an RFC 3339 string or Unix seconds.
+func propertyTime(v *structpb.Value) (time.Time, bool) {
+	switch v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		t, err := time.Parse(time.RFC3339, v.GetStringValue())
+		return t, err == nil
+	case *structpb.Value_NumberValue:
+		return time.Unix(int64(v.GetNumberValue()), 0), true
+	}
+	return time.Time{}, false
+}
+
+// sortByRetrievalScore orders insertions by descending retrieval score, keeping ties in order.
+func sortByRetrievalScore(insertions []*delivery.Insertion) {
+	sort.SliceStable(insertions, func(i, j int) bool {
+		return insertions[i].GetRetrievalScore() > insertions[j].GetRetrievalScore()
+	})
+}
+
+// recencyBoosting re-ranks responses with a RecencyBooster.
+type recencyBoosting struct {
+	booster *RecencyBooster
+}
+
+// middleware re-ranks the response with Boost. Response insertions don't carry properties or
+// retrieval scores, so they're scored from the request's insertions and then put in the boosted
+// order unchanged.
+func (r *recencyBoosting) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		requested := requestInsertionsByContentID(req)
+		resp = cloneResponse(resp)
+		insertions := resp.Response.GetInsertion()
+		scored := make([]*delivery.Insertion, len(insertions))
+		// Content IDs can repeat, so each maps to its response insertions in order.
+		byContentID := make(map[string][]*delivery.Insertion, len(insertions))
+		for i, ins := range insertions {
+			reqIns := requested[ins.GetContentId()]
+			score := reqIns.GetRetrievalScore()
+			scored[i] = &delivery.Insertion{
+				ContentId:      ins.GetContentId(),
+				RetrievalScore: &score,
+				Properties:     reqIns.GetProperties(),
+			}
+			byContentID[ins.GetContentId()] = append(byContentID[ins.GetContentId()], ins)
+		}
+		for i, ins := range r.booster.Boost(scored, r.booster.createdAtKey, r.booster.halfLife) {
+			matches := byContentID[ins.GetContentId()]
+			insertions[i] = matches[0]
+			byContentID[ins.GetContentId()] = matches[1:]
+		}
+		renumberPositions(insertions)
+		return resp, nil
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var testNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestRecencyBooster(halfLife time.Duration) *RecencyBooster {
+	b := NewRecencyBooster("createdAt", halfLife)
+	b.now = func() time.Time { return testNow }
+	return b
+}
+
+// createdInsertions returns insertions with equal retrieval scores, created the given number of days
+// before testNow. Insertions not in agesInDays have no createdAt property.
+func createdInsertions(t *testing.T, agesInDays map[string]int, ids ...string) []*delivery.Insertion {
+	t.Helper()
+	insertions := testInsertions(ids...)
+	for _, ins := range insertions {
+		score := float32(1)
+		ins.RetrievalScore = &score
+		age, ok := agesInDays[ins.GetContentId()]
+		if !ok {
+			continue
+		}
+		props, err := structpb.NewStruct(map[string]any{
+			"createdAt": testNow.AddDate(0, 0, -age).Format(time.RFC3339),
+		})
+		if err != nil {
+			t.Fatalf("error building properties: %v", err)
+		}
+		ins.Properties = &common.Properties{StructField: &common.Properties_Struct{Struct: props}}
+	}
+	return insertions
+}
+
+func TestRecencyBoostFavorsNewerContent(t *testing.T) {
+	// Boost's arguments, not the booster's own half-life, set the boost.
+	b := newTestRecencyBooster(time.Hour)
+	insertions := createdInsertions(t, map[string]int{"old": 30, "new": 1}, "old", "new", "undated")
+
+	boosted := b.Boost(insertions, "createdAt", 7*24*time.Hour)
+	var ids []string
+	for _, ins := range boosted {
+		ids = append(ids, ins.GetContentId())
+	}
+	if ids[0] != "new" || ids[1] != "old" || ids[2] != "undated" {
+		t.Errorf("got ranking %v, want [new old undated]", ids)
+	}
+	if got := boosted[2].GetRetrievalScore(); got != 1 {
+		t.Errorf("got undated score %v, want it unboosted", got)
+	}
+	if got, want := boosted[0].GetRetrievalScore(), float32(1+0.9057); got < want-0.001 || got > want+0.001 {
+		t.Errorf("got 1-day-old score %v, want %v", got, want)
+	}
+}
+
+func TestRecencyBoosterReranksResponses(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		writeDeliveryResponse(t, w, rankedResponse("old", "new"))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRecencyBooster(newTestRecencyBooster(7*24*time.Hour)))
+	req := newTestDeliveryRequest(t)
+	req.Request.Insertion = createdInsertions(t, map[string]int{"old": 30, "new": 1}, "old", "new")
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "new", "old")
+	if got := resp.Response.GetInsertion()[0].GetPosition(); got != 0 {
+		t.Errorf("got position %d for the boosted insertion, want 0", got)
+	}
+}
+
+func TestRecencyBoosterKeepsTheAPIRankingOfUndatedContent(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		writeDeliveryResponse(t, w, rankedResponse("d", "c", "b", "a"))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRecencyBooster(newTestRecencyBooster(7*24*time.Hour)))
+	// No retrieval scores, as most callers send, and only b is dated.
+	req := newTestDeliveryRequest(t, "a", "b", "c", "d")
+	if err := setProperty(&req.Request.Insertion[1].Properties, "createdAt", testNow.Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	// b, brand new, moves up from position 2; the others keep the API's order.
+	assertContentIDs(t, resp, "b", "d", "c", "a")
+	for _, ins := range resp.Response.GetInsertion() {
+		if ins.RetrievalScore != nil {
+			t.Errorf("set retrieval score %v on %s", ins.GetRetrievalScore(), ins.GetContentId())
+		}
+	}
+}
+
+func TestRecencyBoosterAddsToRetrievalScores(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		writeDeliveryResponse(t, w, rankedResponse("a", "b", "c", "d"))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRecencyBooster(newTestRecencyBooster(7*24*time.Hour)))
+	// d is 5 days old with a 7-day half-life, so its boost of about 0.6 moves it above b and c but
+	// not a, whose retrieval score is 1.
+	req := newTestDeliveryRequest(t, "a", "b", "c", "d")
+	score := float32(1)
+	req.Request.Insertion[0].RetrievalScore = &score
+	if err := setProperty(&req.Request.Insertion[3].Properties, "createdAt", testNow.AddDate(0, 0, -5).Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "a", "d", "b", "c")
+}
+
+func TestRecencyBoostLeavesInsertionsAlone(t *testing.T) {
+	insertions := createdInsertions(t, map[string]int{"old": 30, "new": 1}, "old", "new")
+	newTestRecencyBooster(7*24*time.Hour).Boost(insertions, "createdAt", 7*24*time.Hour)
+	if insertions[0].GetContentId() != "old" || insertions[1].GetRetrievalScore() != 1 {
+		t.Error("Boost modified the caller's insertions")
+	}
+}
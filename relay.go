@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const relayCursorPrefix = "offset:"
+
+// RelayConnection is a page of delivered insertions as a GraphQL Relay cursor connection.
+type RelayConnection struct {
+	Edges    []RelayEdge
+	PageInfo RelayPageInfo
+}
+
+// RelayEdge is one insertion and its cursor.
+type RelayEdge struct {
+	Cursor string
+	Node   *delivery.Insertion
+}
+
+// RelayPageInfo is the Relay pageInfo of a connection.
+type RelayPageInfo struct {
+	HasNextPage     bool
+	HasPreviousPage bool
+	StartCursor     string
+	EndCursor       string
+}
+
+// ToRelayConnection pages resp's insertions by their offset in the full result set, taking the
+// page's offset and size from baseRequest's paging. There is a next page if the full page was
+// returned and, when the total count is known, results remain past it.
+func ToRelayConnection(resp *DeliveryResponse, baseRequest *DeliveryRequest) *RelayConnection {
+	paging := baseRequest.Request.GetPaging()
+	start := int(paging.GetOffset())
+	insertions := resp.Response.GetInsertion()
+
+	conn := &RelayConnection{Edges: make([]RelayEdge, len(insertions))}
+	for i, ins := range insertions {
+		conn.Edges[i] = RelayEdge{Cursor: EncodeRelayCursor(start + i), Node: ins}
+	}
+	conn.PageInfo.HasPreviousPage = start > 0
+	size := int(paging.GetSize())
+	conn.PageInfo.HasNextPage = size > 0 && len(insertions) >= size
+	if total := resp.Metadata.totalCount(); total > 0 {
+		conn.PageInfo.HasNextPage = conn.PageInfo.HasNextPage && int64(start+len(insertions)) < total
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn
+}
+
+// EncodeRelayCursor returns the opaque cursor of the result at offset.
+func EncodeRelayCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(relayCursorPrefix + strconv.Itoa(offset)))
+}
+
+// DecodeRelayCursor returns the offset of the result a cursor from EncodeRelayCursor points to.
+func DecodeRelayCursor(cursor string) (int, error) {
+	data, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor: %v", err)
+	}
+	value, ok := strings.CutPrefix(string(data), relayCursorPrefix)
+	if !ok {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return offset, nil
+}
+
+// RelayPaging returns the paging of the Relay page of size first after the cursor, which may be
+// empty for the first page.
+func RelayPaging(first int, after string) (*delivery.Paging, error) {
+	offset := 0
+	if after != "" {
+		afterOffset, err := DecodeRelayCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		offset = afterOffset + 1
+	}
+	return &delivery.Paging{Size: int32(first), Starting: &delivery.Paging_Offset{Offset: int32(offset)}}, nil
+}
+
+// totalCount is the result count across pages, or 0 if unknown.
+func (m *ResponseMetadata) totalCount() int64 {
+	if m == nil {
+		return 0
+	}
+	return m.TotalCount
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestRelayCursorRoundTrips(t *testing.T) {
+	for _, offset := range []int{0, 1, 9, 12345} {
+		got, err := DecodeRelayCursor(EncodeRelayCursor(offset))
+		if err != nil || got != offset {
+			t.Errorf("DecodeRelayCursor(EncodeRelayCursor(%d)) = %d, %v", offset, got, err)
+		}
+	}
+}
+
+func TestInvalidRelayCursors(t *testing.T) {
+	for _, cursor := range []string{
+		"not base64!",
+		base64.StdEncoding.EncodeToString([]byte("page:3")),
+		base64.StdEncoding.EncodeToString([]byte("offset:x")),
+		base64.StdEncoding.EncodeToString([]byte("offset:-1")),
+	} {
+		if _, err := DecodeRelayCursor(cursor); err == nil {
+			t.Errorf("DecodeRelayCursor(%q) succeeded, want an error", cursor)
+		}
+	}
+}
+
+// pagedRequest asks for the page of size after offset.
+func pagedRequest(t *testing.T, offset, size int32) *DeliveryRequest {
+	t.Helper()
+	req := newTestDeliveryRequest(t)
+	req.Request.Paging = &delivery.Paging{Size: size, Starting: &delivery.Paging_Offset{Offset: offset}}
+	return req
+}
+
+func TestToRelayConnection(t *testing.T) {
+	resp := newResponseWithInsertions(testInsertions("a", "b", "c"))
+	conn := ToRelayConnection(resp, pagedRequest(t, 10, 3))
+
+	if len(conn.Edges) != 3 || conn.Edges[1].Node.GetContentId() != "b" {
+		t.Fatalf("got edges %v, want a, b and c", conn.Edges)
+	}
+	for i, edge := range conn.Edges {
+		if offset, err := DecodeRelayCursor(edge.Cursor); err != nil || offset != 10+i {
+			t.Errorf("edge %d has cursor offset %d (%v), want %d", i, offset, err, 10+i)
+		}
+	}
+	want := RelayPageInfo{
+		HasNextPage:     true,
+		HasPreviousPage: true,
+		StartCursor:     EncodeRelayCursor(10),
+		EndCursor:       EncodeRelayCursor(12),
+	}
+	if conn.PageInfo != want {
+		t.Errorf("got page info %+v, want %+v", conn.PageInfo, want)
+	}
+}
+
+func TestRelayHasNextPage(t *testing.T) {
+	tests := []struct {
+		name       string
+		returned   int
+		size       int32
+		totalCount int64
+		want       bool
+	}{
+		{"full page", 3, 3, 0, true},
+		{"short page", 2, 3, 0, false},
+		{"no page size", 3, 0, 0, false},
+		{"more results", 3, 3, 10, true},
+		{"last full page", 3, 3, 3, false},
+	}
+	for _, tc := range tests {
+		resp := newResponseWithInsertions(testInsertions([]string{"a", "b", "c"}[:tc.returned]...))
+		resp.Metadata = &ResponseMetadata{TotalCount: tc.totalCount}
+		if got := ToRelayConnection(resp, pagedRequest(t, 0, tc.size)).PageInfo.HasNextPage; got != tc.want {
+			t.Errorf("%s: got HasNextPage %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestEmptyRelayConnection(t *testing.T) {
+	conn := ToRelayConnection(newResponseWithInsertions(nil), pagedRequest(t, 0, 3))
+	if len(conn.Edges) != 0 || conn.PageInfo != (RelayPageInfo{}) {
+		t.Errorf("got connection %+v, want no edges or cursors", conn)
+	}
+}
+
+func TestRelayPaging(t *testing.T) {
+	first, err := RelayPaging(5, "")
+	if err != nil || first.GetSize() != 5 || first.GetOffset() != 0 {
+		t.Errorf("RelayPaging(5, \"\") = %v, %v, want the first 5", first, err)
+	}
+	next, err := RelayPaging(5, EncodeRelayCursor(4))
+	if err != nil || next.GetSize() != 5 || next.GetOffset() != 5 {
+		t.Errorf("got paging %v, %v after offset 4, want 5 from offset 5", next, err)
+	}
+	if _, err := RelayPaging(5, "garbage"); err == nil {
+		t.Error("RelayPaging succeeded after an invalid cursor")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// RequestDiff is the difference between two delivery requests, e.g. before and after a config
+// change that shifted rankings.
+type RequestDiff struct {
+	// ChangedFields are the changed request fields, by proto name, and SDK options. Changed fields of
+	// insertions in both requests are prefixed with insertion[<content ID>].
+	ChangedFields []string
+	// AddedInsertions and RemovedInsertions are content IDs only in the second or first request.
+	AddedInsertions   []string
+	RemovedInsertions []string
+	// ChangedProperties are keyed by property key, prefixed with insertion[<content ID>]. for
+	// insertion properties.
+	ChangedProperties map[string]PropertyChange
+}
+
+// PropertyChange is a property's value in each request. A missing property is nil.
+type PropertyChange struct {
+	Old any
+	New any
+}
+
+// DiffDeliveryRequests compares a to b, with a being the old request.
+func DiffDeliveryRequests(a, b *DeliveryRequest) *RequestDiff {
+	diff := &RequestDiff{ChangedProperties: map[string]PropertyChange{}}
+	if a.OnlyLog != b.OnlyLog {
+		diff.ChangedFields = append(diff.ChangedFields, "OnlyLog")
+	}
+	if !proto.Equal(a.Experiment, b.Experiment) {
+		diff.ChangedFields = append(diff.ChangedFields, "Experiment")
+	}
+	if a.RetrievalInsertionOffset != b.RetrievalInsertionOffset {
+		diff.ChangedFields = append(diff.ChangedFields, "RetrievalInsertionOffset")
+	}
+	if !reflect.DeepEqual(a.Headers, b.Headers) && (len(a.Headers) > 0 || len(b.Headers) > 0) {
+		diff.ChangedFields = append(diff.ChangedFields, "Headers")
+	}
+	if proto.Equal(a.Request, b.Request) {
+		return diff
+	}
+
+	diff.ChangedFields = append(diff.ChangedFields, changedFields("", a.Request.ProtoReflect(), b.Request.ProtoReflect())...)
+	diffProperties(diff, "", a.Request.GetProperties(), b.Request.GetProperties())
+
+	aInsertions := requestInsertionsByContentID(a)
+	bInsertions := requestInsertionsByContentID(b)
+	for _, ins := range a.Request.GetInsertion() {
+		bIns, ok := bInsertions[ins.GetContentId()]
+		if !ok {
+			diff.RemovedInsertions = append(diff.RemovedInsertions, ins.GetContentId())
+			continue
+		}
+		prefix := fmt.Sprintf("insertion[%s].", ins.GetContentId())
+		diff.ChangedFields = append(diff.ChangedFields, changedFields(prefix, ins.ProtoReflect(), bIns.ProtoReflect())...)
+		diffProperties(diff, prefix, ins.GetProperties(), bIns.GetProperties())
+	}
+	for _, ins := range b.Request.GetInsertion() {
+		if _, ok := aInsertions[ins.GetContentId()]; !ok {
+			diff.AddedInsertions = append(diff.AddedInsertions, ins.GetContentId())
+		}
+	}
+	return diff
+}
+
+// changedFields lists the fields that differ between a and b, other than insertions and properties,
+// which are diffed by content ID and key.
+func changedFields(prefix string, a, b protoreflect.Message) []string {
+	var changed []string
+	fields := a.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Name() == "insertion" || fd.Name() == "properties" {
+			continue
+		}
+		var equal bool
+		if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+			equal = proto.Equal(a.Get(fd).Message().Interface(), b.Get(fd).Message().Interface())
+		} else {
+			equal = a.Get(fd).Equal(b.Get(fd))
+		}
+		if !equal {
+			changed = append(changed, prefix+string(fd.Name()))
+		}
+	}
+	return changed
+}
+
+// diffProperties records each property whose value differs between a and b.
+func diffProperties(diff *RequestDiff, prefix string, a, b *common.Properties) {
+	keys := map[string]bool{}
+	for key := range a.GetStruct().GetFields() {
+		keys[key] = true
+	}
+	for key := range b.GetStruct().GetFields() {
+		keys[key] = true
+	}
+	for key := range keys {
+		var change PropertyChange
+		if v := getProperty(a, key); v != nil {
+			change.Old = v.AsInterface()
+		}
+		if v := getProperty(b, key); v != nil {
+			change.New = v.AsInterface()
+		}
+		if !reflect.DeepEqual(change.Old, change.New) {
+			diff.ChangedProperties[prefix+key] = change
+		}
+	}
+}
+
+// IsEmpty reports whether the requests were the same.
+func (d *RequestDiff) IsEmpty() bool {
+	return len(d.ChangedFields) == 0 && len(d.AddedInsertions) == 0 && len(d.RemovedInsertions) == 0 && len(d.ChangedProperties) == 0
+}
+
+// String summarizes the diff, one kind of change per line.
+func (d *RequestDiff) String() string {
+	if d.IsEmpty() {
+		return "no differences"
+	}
+	var lines []string
+	if len(d.ChangedFields) > 0 {
+		lines = append(lines, "changed fields: "+strings.Join(d.ChangedFields, ", "))
+	}
+	if len(d.AddedInsertions) > 0 {
+		lines = append(lines, "added insertions: "+strings.Join(d.AddedInsertions, ", "))
+	}
+	if len(d.RemovedInsertions) > 0 {
+		lines = append(lines, "removed insertions: "+strings.Join(d.RemovedInsertions, ", "))
+	}
+	if len(d.ChangedProperties) > 0 {
+		keys := make([]string, 0, len(d.ChangedProperties))
+		for key := range d.ChangedProperties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		lines = append(lines, "changed properties:")
+		for _, key := range keys {
+			change := d.ChangedProperties[key]
+			lines = append(lines, fmt.Sprintf("  %s: %v -> %v", key, change.Old, change.New))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
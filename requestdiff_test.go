@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestDiffIdenticalRequestsIsEmpty(t *testing.T) {
+	a := newTestDeliveryRequest(t, "a", "b")
+	b := newTestDeliveryRequest(t, "a", "b")
+
+	diff := DiffDeliveryRequests(a, b)
+	if !diff.IsEmpty() {
+		t.Errorf("got diff %+v of identical requests, want it empty", diff)
+	}
+	if got := diff.String(); got != "no differences" {
+		t.Errorf("got summary %q, want \"no differences\"", got)
+	}
+}
+
+func TestDiffReportsChangedField(t *testing.T) {
+	a := newTestDeliveryRequest(t, "a")
+	b := newTestDeliveryRequest(t, "a")
+	b.Request.UseCase = delivery.UseCase_SEARCH
+	b.OnlyLog = true
+
+	diff := DiffDeliveryRequests(a, b)
+	if want := []string{"OnlyLog", "use_case"}; !reflect.DeepEqual(diff.ChangedFields, want) {
+		t.Errorf("got changed fields %v, want %v", diff.ChangedFields, want)
+	}
+	if len(diff.AddedInsertions) > 0 || len(diff.RemovedInsertions) > 0 || len(diff.ChangedProperties) > 0 {
+		t.Errorf("got diff %+v, want only changed fields", diff)
+	}
+}
+
+func TestDiffReportsInsertionDifferences(t *testing.T) {
+	a := newTestDeliveryRequest(t, "a", "b", "c")
+	b := newTestDeliveryRequest(t, "b", "c", "d", "e")
+	retrievalRank := uint64(3)
+	b.Request.Insertion[0].RetrievalRank = &retrievalRank
+
+	diff := DiffDeliveryRequests(a, b)
+	if !reflect.DeepEqual(diff.AddedInsertions, []string{"d", "e"}) || !reflect.DeepEqual(diff.RemovedInsertions, []string{"a"}) {
+		t.Errorf("got added %v and removed %v, want [d e] and [a]", diff.AddedInsertions, diff.RemovedInsertions)
+	}
+	if want := []string{"insertion[b].retrieval_rank"}; !reflect.DeepEqual(diff.ChangedFields, want) {
+		t.Errorf("got changed fields %v, want %v", diff.ChangedFields, want)
+	}
+}
+
+func TestDiffReportsChangedProperties(t *testing.T) {
+	a := newTestDeliveryRequest(t, "a")
+	b := newTestDeliveryRequest(t, "a")
+	setProperty(&a.Request.Properties, "region", "us")
+	setProperty(&b.Request.Properties, "region", "eu")
+	setProperty(&b.Request.Insertion[0].Properties, "price", 9.5)
+
+	diff := DiffDeliveryRequests(a, b)
+	want := map[string]PropertyChange{
+		"region":             {Old: "us", New: "eu"},
+		"insertion[a].price": {Old: nil, New: 9.5},
+	}
+	if !reflect.DeepEqual(diff.ChangedProperties, want) {
+		t.Errorf("got changed properties %v, want %v", diff.ChangedProperties, want)
+	}
+	summary := diff.String()
+	for _, line := range []string{"changed properties:", "  insertion[a].price: <nil> -> 9.5", "  region: us -> eu"} {
+		if !strings.Contains(summary, line) {
+			t.Errorf("summary %q is missing %q", summary, line)
+		}
+	}
+}
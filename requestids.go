@@ -0,0 +1,28 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// deterministicIDGenerator generates UUIDs from a seeded source so tests can predict them. It must
+// never be used in production, where IDs need crypto/rand.
+type deterministicIDGenerator struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newDeterministicIDGenerator(seed int64) *deterministicIDGenerator {
+	return &deterministicIDGenerator{rand: rand.New(rand.NewSource(seed))}
+}
+
+// next returns the next version 4 UUID in the seed's sequence.
+func (g *deterministicIDGenerator) next() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// Reads from math/rand never fail.
+	id, _ := uuid.NewRandomFromReader(g.rand)
+	return id.String()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// deliveredRequestIDs makes n calls and returns the client request IDs the Delivery API got.
+func deliveredRequestIDs(t *testing.T, seed int64, n int) []string {
+	t.Helper()
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithDeterministicRequestIDs(seed).WithTestMode(true))
+	var ids []string
+	for i := 0; i < n; i++ {
+		if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+		ids = append(ids, api.lastRequest(t).GetClientRequestId())
+	}
+	return ids
+}
+
+func TestDeterministicRequestIDsRepeatForSameSeed(t *testing.T) {
+	a := deliveredRequestIDs(t, 42, 3)
+	b := deliveredRequestIDs(t, 42, 3)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("clients with the same seed sent IDs %v and %v", a, b)
+	}
+	if a[0] == a[1] || a[1] == a[2] {
+		t.Errorf("got repeated IDs %v", a)
+	}
+	if c := deliveredRequestIDs(t, 43, 1); c[0] == a[0] {
+		t.Errorf("clients with different seeds both sent %s", c[0])
+	}
+}
+
+func TestDeterministicRequestIDsRequireTestMode(t *testing.T) {
+	if _, err := newTestClientBuilder(t, newFakeAPI(t)).WithDeterministicRequestIDs(42).Build(); err == nil {
+		t.Error("Build succeeded with deterministic request IDs outside test mode")
+	}
+}
+
+func TestDeterministicIDGeneratorIsGoroutineSafe(t *testing.T) {
+	g := newDeterministicIDGenerator(42)
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				id := g.next()
+				mu.Lock()
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 1000 {
+		t.Errorf("got %d distinct IDs from 1000 calls, want 1000", len(seen))
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// LocalReRanker post-processes the Delivery API's ranking with business logic, e.g. ad slots.
+type LocalReRanker interface {
+	ReRank(insertions []*delivery.Insertion) []*delivery.Insertion
+}
+
+// sponsoredSlotReRanker inserts sponsored content at fixed positions.
+type sponsoredSlotReRanker struct {
+	slots []sponsoredSlot
+}
+
+type sponsoredSlot struct {
+	contentID string
+	position  int
+}
+
+// SponsoredSlotReRanker inserts each sponsored content ID at the 0-based position of the same
+// index, shifting the other insertions down while keeping their order. Positions past the end
+// append, and sponsored content already ranked is moved rather than duplicated.
+func SponsoredSlotReRanker(sponsored []string, positions []int) LocalReRanker {
+	n := min(len(sponsored), len(positions))
+	slots := make([]sponsoredSlot, n)
+	for i := 0; i < n; i++ {
+		slots[i] = sponsoredSlot{contentID: sponsored[i], position: positions[i]}
+	}
+	// Filling slots in position order keeps earlier slots where they were put.
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].position < slots[j].position
+	})
+	return &sponsoredSlotReRanker{slots: slots}
+}
+
+func (r *sponsoredSlotReRanker) ReRank(insertions []*delivery.Insertion) []*delivery.Insertion {
+	isSponsored := make(map[string]bool, len(r.slots))
+	for _, slot := range r.slots {
+		isSponsored[slot.contentID] = true
+	}
+	ranked := make(map[string]*delivery.Insertion, len(r.slots))
+	reranked := make([]*delivery.Insertion, 0, len(insertions)+len(r.slots))
+	for _, ins := range insertions {
+		if isSponsored[ins.GetContentId()] {
+			ranked[ins.GetContentId()] = ins
+			continue
+		}
+		reranked = append(reranked, ins)
+	}
+
+	for _, slot := range r.slots {
+		ins, ok := ranked[slot.contentID]
+		if !ok {
+			ins = &delivery.Insertion{ContentId: slot.contentID}
+		}
+		position := min(max(slot.position, 0), len(reranked))
+		reranked = append(reranked, nil)
+		copy(reranked[position+1:], reranked[position:])
+		reranked[position] = ins
+	}
+	return reranked
+}
+
+// localReRanking applies a LocalReRanker to each response.
+type localReRanking struct {
+	reRanker LocalReRanker
+}
+
+func (l *localReRanking) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp = cloneResponse(resp)
+		resp.Response.Insertion = l.reRanker.ReRank(resp.Response.GetInsertion())
+		renumberPositions(resp.Response.Insertion)
+		return resp, nil
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestSponsoredSlotReRanker(t *testing.T) {
+	tests := []struct {
+		name      string
+		sponsored []string
+		positions []int
+		want      []string
+	}{
+		{"one slot", []string{"ad"}, []int{2}, []string{"a", "b", "ad", "c", "d"}},
+		{"first slot", []string{"ad"}, []int{0}, []string{"ad", "a", "b", "c", "d"}},
+		// Slots are filled in position order, whatever order they're given in.
+		{"several slots", []string{"ad2", "ad1"}, []int{3, 1}, []string{"a", "ad1", "b", "ad2", "c", "d"}},
+		{"past the end", []string{"ad"}, []int{10}, []string{"a", "b", "c", "d", "ad"}},
+		// Sponsored content already in the ranking is moved, not duplicated.
+		{"already ranked", []string{"c"}, []int{0}, []string{"c", "a", "b", "d"}},
+		{"extra positions", []string{"ad"}, []int{1, 2}, []string{"a", "ad", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := insertionContentIDsOf(SponsoredSlotReRanker(tt.sponsored, tt.positions).ReRank(testInsertions("a", "b", "c", "d")))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// reverseReRanker reverses the ranking.
+type reverseReRanker struct{}
+
+func (reverseReRanker) ReRank(insertions []*delivery.Insertion) []*delivery.Insertion {
+	reversed := make([]*delivery.Insertion, len(insertions))
+	for i, ins := range insertions {
+		reversed[len(insertions)-1-i] = ins
+	}
+	return reversed
+}
+
+func TestLocalReRankerAppliesToResponses(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithLocalReRanker(reverseReRanker{}))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "c", "b", "a")
+	for i, ins := range resp.Response.GetInsertion() {
+		if ins.GetPosition() != uint64(i) {
+			t.Errorf("got position %d for %s at %d, want positions renumbered", ins.GetPosition(), ins.GetContentId(), i)
+		}
+	}
+}
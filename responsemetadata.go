@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const totalCountHeader = "X-Promoted-Total-Count"
+const hasMoreHeader = "X-Promoted-Has-More"
+const prevPageTokenHeader = "X-Promoted-Prev-Page-Token"
+
+// ResponseMetadata describes where the response sits in the full result set, for rendering
+// pagination.
+type ResponseMetadata struct {
+	// TotalCount is the number of results across all pages, or 0 if unknown.
+	TotalCount int64
+	HasMore    bool
+	// NextPageToken is the paging cursor for the next page.
+	NextPageToken string
+	PrevPageToken string
+}
+
+// extractMetadata reads pagination metadata from the Delivery API's response headers, using the
+// response's paging cursor when the headers don't say whether there are more results.
+func extractMetadata(header http.Header, deliveryResp *delivery.Response) *ResponseMetadata {
+	metadata := &ResponseMetadata{
+		NextPageToken: deliveryResp.GetPagingInfo().GetCursor(),
+		PrevPageToken: header.Get(prevPageTokenHeader),
+		HasMore:       deliveryResp.GetPagingInfo().GetCursor() != "",
+	}
+	if v := header.Get(totalCountHeader); v != "" {
+		if totalCount, err := strconv.ParseInt(v, 10, 64); err == nil {
+			metadata.TotalCount = totalCount
+		}
+	}
+	if v := header.Get(hasMoreHeader); v != "" {
+		if hasMore, err := strconv.ParseBool(v); err == nil {
+			metadata.HasMore = hasMore
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestExtractMetadata(t *testing.T) {
+	withCursor := &delivery.Response{PagingInfo: &delivery.PagingInfo{Cursor: "next-1"}}
+	tests := []struct {
+		name   string
+		header map[string]string
+		resp   *delivery.Response
+		want   ResponseMetadata
+	}{
+		{"no metadata", nil, &delivery.Response{}, ResponseMetadata{}},
+		{"headers", map[string]string{totalCountHeader: "487", hasMoreHeader: "true", prevPageTokenHeader: "prev-1"},
+			&delivery.Response{}, ResponseMetadata{TotalCount: 487, HasMore: true, PrevPageToken: "prev-1"}},
+		{"cursor implies more", nil, withCursor, ResponseMetadata{HasMore: true, NextPageToken: "next-1"}},
+		{"header overrides cursor", map[string]string{hasMoreHeader: "false"}, withCursor, ResponseMetadata{NextPageToken: "next-1"}},
+		{"malformed headers are ignored", map[string]string{totalCountHeader: "lots", hasMoreHeader: "maybe"},
+			withCursor, ResponseMetadata{HasMore: true, NextPageToken: "next-1"}},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		for k, v := range tt.header {
+			header.Set(k, v)
+		}
+		if got := extractMetadata(header, tt.resp); *got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+}
+
+func TestDeliverPopulatesMetadata(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setResponseHeader(totalCountHeader, "487")
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		resp := echoResponse(req)
+		resp.PagingInfo = &delivery.PagingInfo{Cursor: "next-1"}
+		writeDeliveryResponse(t, w, resp)
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	want := ResponseMetadata{TotalCount: 487, HasMore: true, NextPageToken: "next-1"}
+	if resp.Metadata == nil || *resp.Metadata != want {
+		t.Errorf("got metadata %+v, want %+v", resp.Metadata, want)
+	}
+}
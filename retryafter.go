@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultRetryInitialDelay = 100 * time.Millisecond
+const defaultRetryMaxDelay = 30 * time.Second
+
+// retryableStatusCodes are the Delivery API statuses worth retrying, i.e. overload and outages.
+var retryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// RetryAfterAwareBackoff waits between Delivery API retries, honoring the server's Retry-After
+// header and falling back to exponential backoff without one.
+type RetryAfterAwareBackoff struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+// NewRetryAfterAwareBackoff is a factory method for RetryAfterAwareBackoff. Exponential backoff
+// starts at initial, and every wait, including Retry-After ones, is capped at max.
+func NewRetryAfterAwareBackoff(initial, max time.Duration) *RetryAfterAwareBackoff {
+	if initial <= 0 {
+		initial = defaultRetryInitialDelay
+	}
+	if max <= 0 {
+		max = defaultRetryMaxDelay
+	}
+	return &RetryAfterAwareBackoff{initial: initial, max: max}
+}
+
+// Delay returns how long to wait before retry number attempt, counting from 0, given the failed
+// response's header.
+func (b *RetryAfterAwareBackoff) Delay(attempt int, header http.Header) time.Duration {
+	if delay, ok := parseRetryAfter(header.Get("Retry-After"), time.Now()); ok {
+		return min(delay, b.max)
+	}
+	delay := b.initial
+	for i := 0; i < attempt && delay < b.max; i++ {
+		delay *= 2
+	}
+	return min(delay, b.max)
+}
+
+// Wait sleeps for Delay. It returns context.DeadlineExceeded right away if ctx would expire first,
+// since the retry could never be made.
+func (b *RetryAfterAwareBackoff) Wait(ctx context.Context, attempt int, header http.Header) error {
+	delay := b.Delay(attempt, header)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return context.DeadlineExceeded
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter parses a Retry-After value in delay-seconds or HTTP-date format. Dates in the past
+// mean no wait.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"30", 30 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+	for _, tc := range tests {
+		got, ok := parseRetryAfter(tc.value, now)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tc.value, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestRetryAfterAwareBackoffDelay(t *testing.T) {
+	b := NewRetryAfterAwareBackoff(100*time.Millisecond, 10*time.Second)
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		if got := b.Delay(attempt, http.Header{}); got != want {
+			t.Errorf("Delay(%d) without Retry-After = %v, want %v", attempt, got, want)
+		}
+	}
+	if got := b.Delay(20, http.Header{}); got != 10*time.Second {
+		t.Errorf("Delay(20) = %v, want the 10s cap", got)
+	}
+	if got := b.Delay(0, http.Header{"Retry-After": {"3"}}); got != 3*time.Second {
+		t.Errorf("Delay with Retry-After: 3 = %v, want 3s", got)
+	}
+	if got := b.Delay(0, http.Header{"Retry-After": {"60"}}); got != 10*time.Second {
+		t.Errorf("Delay with Retry-After: 60 = %v, want the 10s cap", got)
+	}
+}
+
+func TestRetryAfterAwareBackoffFailsFastPastDeadline(t *testing.T) {
+	b := NewRetryAfterAwareBackoff(0, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := b.Wait(ctx, 0, http.Header{"Retry-After": {"5"}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("waited %v before failing, want an immediate failure", elapsed)
+	}
+}
+
+func TestClientHonorsRetryAfter(t *testing.T) {
+	api := newFakeAPI(t)
+	var mu sync.Mutex
+	var callTimes []time.Time
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		mu.Lock()
+		callTimes = append(callTimes, time.Now())
+		first := len(callTimes) == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writeDeliveryResponse(t, w, echoResponse(req))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRetryBackoff(NewRetryAfterAwareBackoff(0, 0), 2))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "a", "b")
+	if resp.ExecutionServer != delivery.ExecutionServer_API {
+		t.Errorf("got execution server %v, want the retried API response", resp.ExecutionServer)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(callTimes) != 2 {
+		t.Fatalf("got %d Delivery API calls, want 2", len(callTimes))
+	}
+	if wait := callTimes[1].Sub(callTimes[0]); wait < 950*time.Millisecond || wait > 1500*time.Millisecond {
+		t.Errorf("retried after %v, want about 1s", wait)
+	}
+}
+
+func TestClientDoesNotRetryOtherFailures(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRetryBackoff(NewRetryAfterAwareBackoff(time.Millisecond, 0), 2))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if resp.ExecutionServer != delivery.ExecutionServer_SDK || api.calls() != 1 {
+		t.Errorf("got execution server %v after %d calls, want one call and an SDK fallback", resp.ExecutionServer, api.calls())
+	}
+}
+
+func TestClientStopsAfterMaxRetries(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithRetryBackoff(NewRetryAfterAwareBackoff(time.Millisecond, 0), 2))
+
+	resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if resp.ExecutionServer != delivery.ExecutionServer_SDK || api.calls() != 3 {
+		t.Errorf("got execution server %v after %d calls, want 3 calls and an SDK fallback", resp.ExecutionServer, api.calls())
+	}
+}
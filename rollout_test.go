@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHashRolloutGateIsStablePerUser(t *testing.T) {
+	gate := HashRolloutGate{}
+	for i := 0; i < 100; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		want := gate.IsEnabled(RolloutFeatureCache, userID, 50)
+		for j := 0; j < 10; j++ {
+			if got := gate.IsEnabled(RolloutFeatureCache, userID, 50); got != want {
+				t.Fatalf("IsEnabled(%s) changed from %v to %v", userID, want, got)
+			}
+		}
+	}
+}
+
+func TestHashRolloutGatePercentages(t *testing.T) {
+	gate := HashRolloutGate{}
+	const users = 10000
+	enabled := map[float64]int{}
+	for i := 0; i < users; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		for _, pct := range []float64{0, 50, 100} {
+			if gate.IsEnabled(RolloutFeatureDiversityFilter, userID, pct) {
+				enabled[pct]++
+			}
+		}
+	}
+	if enabled[0] != 0 {
+		t.Errorf("0%% rollout enabled %d users, want none", enabled[0])
+	}
+	if enabled[100] != users {
+		t.Errorf("100%% rollout enabled %d users, want all %d", enabled[100], users)
+	}
+	if enabled[50] < users*45/100 || enabled[50] > users*55/100 {
+		t.Errorf("50%% rollout enabled %d of %d users", enabled[50], users)
+	}
+}
+
+func TestStaticRolloutGateIgnoresConfiguredPercent(t *testing.T) {
+	all, none := StaticRolloutGate(100), StaticRolloutGate(0)
+	for i := 0; i < 100; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		if !all.IsEnabled(RolloutFeatureRecencyBoost, userID, 0) {
+			t.Errorf("StaticRolloutGate(100) disabled %s", userID)
+		}
+		if none.IsEnabled(RolloutFeatureRecencyBoost, userID, 100) {
+			t.Errorf("StaticRolloutGate(0) enabled %s", userID)
+		}
+	}
+}
+
+func TestRolloutGateControlsCachePerUser(t *testing.T) {
+	// Find one user inside and one outside a 50% cache rollout.
+	var inUser, outUser string
+	for i := 0; inUser == "" || outUser == ""; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		if (HashRolloutGate{}).IsEnabled(RolloutFeatureCache, userID, 50) {
+			inUser = userID
+		} else {
+			outUser = userID
+		}
+	}
+
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithStaleWhileRevalidate(time.Minute, time.Hour).
+		WithRolloutGate(HashRolloutGate{}).
+		WithFeatureRolloutPercent(RolloutFeatureCache, 50))
+	deliverTwice := func(userID string) int {
+		t.Helper()
+		before := api.calls()
+		for i := 0; i < 2; i++ {
+			if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, userID, "a", "b")); err != nil {
+				t.Fatalf("Deliver failed: %v", err)
+			}
+		}
+		return api.calls() - before
+	}
+
+	if calls := deliverTwice(inUser); calls != 1 {
+		t.Errorf("user in the rollout made %d Delivery API calls, want 1 with caching", calls)
+	}
+	if calls := deliverTwice(outUser); calls != 2 {
+		t.Errorf("user outside the rollout made %d Delivery API calls, want 2 without caching", calls)
+	}
+}
+
+func TestRolloutPercentIsValidated(t *testing.T) {
+	for _, pct := range []float64{-1, 101} {
+		_, err := newTestClientBuilder(t, newFakeAPI(t)).
+			WithRolloutGate(HashRolloutGate{}).
+			WithFeatureRolloutPercent(RolloutFeatureCache, pct).
+			Build()
+		if err == nil {
+			t.Errorf("Build with rollout percent %v succeeded, want an error", pct)
+		}
+	}
+}
+
+// recordingRolloutGate enables every feature, recording the users it was asked about.
+type recordingRolloutGate struct {
+	mu      sync.Mutex
+	userIDs []string
+}
+
+func (g *recordingRolloutGate) IsEnabled(featureKey string, userID string, rolloutPercent float64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.userIDs = append(g.userIDs, userID)
+	return true
+}
+
+func TestRolloutGateNeverSeesOptedOutUsers(t *testing.T) {
+	gate := &recordingRolloutGate{}
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).
+		WithDiversityFilter(DiversityFilter{Attribute: "brand", MaxPerValue: 2}, "brand").
+		WithRecencyBooster(NewRecencyBooster("createdAt", time.Hour)).
+		WithRolloutGate(gate).
+		WithGDPROptOut(func(context.Context) bool { return true }))
+
+	if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a", "b")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+	if len(gate.userIDs) != 0 {
+		t.Errorf("the rollout gate was asked about opted-out users %v", gate.userIDs)
+	}
+}
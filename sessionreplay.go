@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Session is a recorded sequence of delivery calls.
+type Session struct {
+	Calls []SessionCall
+}
+
+// SessionCall is one recorded delivery call.
+type SessionCall struct {
+	Request  *DeliveryRequest
+	Response *DeliveryResponse
+}
+
+// SessionDiff compares a replayed session's rankings to the recorded ones.
+type SessionDiff struct {
+	Calls []CallDiff
+	// MeanNDCG is the mean NDCG of the calls that replayed without error.
+	MeanNDCG float64
+	Errors   int
+}
+
+// CallDiff compares one replayed call's ranking to the recorded one.
+type CallDiff struct {
+	ClientRequestID string
+	// NDCG scores the new ranking against the recorded one as the ideal, so 1 means the same
+	// order.
+	NDCG float64
+	// PositionChanges are the recorded minus new positions of the content that moved, so moving up
+	// is positive. Content only in one of the rankings is left out.
+	PositionChanges map[string]int
+	Err             error
+}
+
+// SessionReplayer re-runs recorded sessions against another client, e.g. one using a new model.
+type SessionReplayer struct {
+	k int
+}
+
+// NewSessionReplayer is a factory method for SessionReplayer. NDCG is computed over the top k
+// recorded insertions, or all of them if k isn't positive.
+func NewSessionReplayer(k int) *SessionReplayer {
+	return &SessionReplayer{k: k}
+}
+
+// LoadSession reads the successful delivery calls of a cassette recorded with WithTestRecording.
+func (r *SessionReplayer) LoadSession(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %v", err)
+	}
+	var recorded cassette
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("error parsing cassette %s: %v", path, err)
+	}
+
+	session := &Session{}
+	for i, in := range recorded.Interactions {
+		u, err := url.Parse(in.Request.URL)
+		if err != nil || u.Path != deliveryEndpointSuffix || u.RawQuery != "" || in.Response.Code < 200 || in.Response.Code >= 300 {
+			continue
+		}
+		var request delivery.Request
+		if err := protojson.Unmarshal([]byte(in.Request.Body), &request); err != nil {
+			return nil, fmt.Errorf("error parsing request of interaction %d: %v", i, err)
+		}
+		var response delivery.Response
+		if err := protojson.Unmarshal([]byte(in.Response.Body), &response); err != nil {
+			return nil, fmt.Errorf("error parsing response of interaction %d: %v", i, err)
+		}
+		req, err := NewDeliveryRequestBuilder(&request).Build()
+		if err != nil {
+			return nil, fmt.Errorf("error building request of interaction %d: %v", i, err)
+		}
+		session.Calls = append(session.Calls, SessionCall{
+			Request: req,
+			Response: &DeliveryResponse{DeliveryResponse: &client.DeliveryResponse{
+				Response:        &response,
+				ClientRequestID: request.GetClientRequestId(),
+				ExecutionServer: delivery.ExecutionServer_API,
+			}},
+		})
+	}
+	return session, nil
+}
+
+// Replay sends the session's requests to newClient in order and compares the rankings. Failed
+// calls are counted in the diff rather than stopping the replay.
+func (r *SessionReplayer) Replay(ctx context.Context, session *Session, newClient DeliveryClientInterface) (*SessionDiff, error) {
+	diff := &SessionDiff{Calls: make([]CallDiff, len(session.Calls))}
+	var ndcgSum float64
+	for i, call := range session.Calls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		callDiff := CallDiff{ClientRequestID: call.Request.Request.GetClientRequestId()}
+		resp, err := newClient.Deliver(ctx, cloneRequest(call.Request))
+		if err != nil {
+			callDiff.Err = err
+			diff.Errors++
+		} else {
+			recorded := insertionContentIDs(call.Response.Response.GetInsertion())
+			replayed := insertionContentIDs(resp.Response.GetInsertion())
+			callDiff.NDCG = ndcg(recorded, replayed, r.k)
+			callDiff.PositionChanges = positionChanges(recorded, replayed)
+			ndcgSum += callDiff.NDCG
+		}
+		diff.Calls[i] = callDiff
+	}
+	if replayed := len(session.Calls) - diff.Errors; replayed > 0 {
+		diff.MeanNDCG = ndcgSum / float64(replayed)
+	}
+	return diff, nil
+}
+
+func insertionContentIDs(insertions []*delivery.Insertion) []string {
+	ids := make([]string, len(insertions))
+	for i, ins := range insertions {
+		ids[i] = ins.GetContentId()
+	}
+	return ids
+}
+
+// ndcg scores ranked against ideal, where the ideal's content at position i has relevance
+// len(ideal)-i. Only the top k of each count, or all if k isn't positive.
+func ndcg(ideal, ranked []string, k int) float64 {
+	if k <= 0 {
+		k = max(len(ideal), len(ranked))
+	}
+	relevance := make(map[string]float64, len(ideal))
+	for i, id := range ideal {
+		relevance[id] = float64(len(ideal) - i)
+	}
+	dcg := func(ids []string) float64 {
+		var sum float64
+		for i, id := range ids[:min(k, len(ids))] {
+			sum += relevance[id] / math.Log2(float64(i+2))
+		}
+		return sum
+	}
+	idealDCG := dcg(ideal)
+	if idealDCG == 0 {
+		return 1
+	}
+	return dcg(ranked) / idealDCG
+}
+
+func positionChanges(recorded, replayed []string) map[string]int {
+	recordedPositions := make(map[string]int, len(recorded))
+	for i, id := range recorded {
+		recordedPositions[id] = i
+	}
+	changes := map[string]int{}
+	for i, id := range replayed {
+		if before, ok := recordedPositions[id]; ok && before != i {
+			changes[id] = before - i
+		}
+	}
+	return changes
+}
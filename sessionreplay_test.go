@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func loadTestSession(t *testing.T, interactions ...*interaction) *Session {
+	t.Helper()
+	session, err := NewSessionReplayer(0).LoadSession(writeTestCassette(t, interactions...))
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+	return session
+}
+
+func TestReplayIdentityModelHasZeroDeltas(t *testing.T) {
+	// fakeDeliveryClient returns request order, as recorded here.
+	session := loadTestSession(t,
+		deliveryInteraction(t, http.StatusOK, []string{"a", "b", "c"}, "a", "b", "c"),
+		deliveryInteraction(t, http.StatusOK, []string{"d", "e"}, "d", "e"),
+	)
+
+	diff, err := NewSessionReplayer(0).Replay(context.Background(), session, &fakeDeliveryClient{})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(diff.Calls) != 2 || diff.Errors != 0 {
+		t.Fatalf("got %d calls and %d errors, want 2 calls", len(diff.Calls), diff.Errors)
+	}
+	for i, call := range diff.Calls {
+		if call.NDCG != 1 || len(call.PositionChanges) != 0 {
+			t.Errorf("call %d has NDCG %v and position changes %v, want 1 and none", i, call.NDCG, call.PositionChanges)
+		}
+	}
+	if diff.MeanNDCG != 1 {
+		t.Errorf("got mean NDCG %v, want 1", diff.MeanNDCG)
+	}
+}
+
+func TestReplayReportsRankingChanges(t *testing.T) {
+	session := loadTestSession(t, deliveryInteraction(t, http.StatusOK, []string{"a", "b", "c"}, "c", "b", "a"))
+
+	diff, err := NewSessionReplayer(0).Replay(context.Background(), session, &fakeDeliveryClient{})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	call := diff.Calls[0]
+	if want := map[string]int{"a": 2, "c": -2}; !reflect.DeepEqual(call.PositionChanges, want) {
+		t.Errorf("got position changes %v, want %v", call.PositionChanges, want)
+	}
+	// Relevances 1, 2, 3 ranked a, b, c against the ideal c, b, a.
+	want := (1 + 2/math.Log2(3) + 3/math.Log2(4)) / (3 + 2/math.Log2(3) + 1/math.Log2(4))
+	if math.Abs(call.NDCG-want) > 1e-9 {
+		t.Errorf("got NDCG %v, want %v", call.NDCG, want)
+	}
+}
+
+func TestReplayCountsErrors(t *testing.T) {
+	session := loadTestSession(t, deliveryInteraction(t, http.StatusOK, []string{"a", "b"}, "a", "b"))
+
+	diff, err := NewSessionReplayer(0).Replay(context.Background(), session, &fakeDeliveryClient{err: errors.New("unavailable")})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if diff.Errors != 1 || diff.Calls[0].Err == nil || diff.MeanNDCG != 0 {
+		t.Errorf("got %d errors and mean NDCG %v, want 1 error", diff.Errors, diff.MeanNDCG)
+	}
+}
+
+func TestLoadSessionSkipsFailedAndOtherCalls(t *testing.T) {
+	session := loadTestSession(t,
+		deliveryInteraction(t, http.StatusOK, []string{"a", "b"}, "b", "a"),
+		deliveryInteraction(t, http.StatusInternalServerError, []string{"a", "b"}, "a", "b"),
+		&interaction{
+			Request:  cassetteRequest{Method: http.MethodGet, URL: "https://recorded.example/healthz"},
+			Response: cassetteResponse{Code: http.StatusOK, Body: "ok"},
+		},
+	)
+	if len(session.Calls) != 1 {
+		t.Fatalf("got %d calls, want 1", len(session.Calls))
+	}
+	assertContentIDs(t, session.Calls[0].Response, "b", "a")
+	if got := insertionContentIDsOf(session.Calls[0].Request.Request.GetInsertion()); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("got request content %v, want a b", got)
+	}
+}
+
+func TestLoadSessionRejectsInvalidCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSessionReplayer(0).LoadSession(path); err == nil {
+		t.Error("expected an error for an invalid cassette")
+	}
+}
+
+func TestNDCGTopK(t *testing.T) {
+	// Only the top 1 counts, and both rankings put a first.
+	if got := ndcg([]string{"a", "b", "c"}, []string{"a", "c", "b"}, 1); got != 1 {
+		t.Errorf("got NDCG@1 %v, want 1", got)
+	}
+	if got := ndcg(nil, nil, 0); got != 1 {
+		t.Errorf("got NDCG %v of empty rankings, want 1", got)
+	}
+}
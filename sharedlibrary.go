@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrHandleReleased is returned by calls on a released SharedLibraryHandle.
+var ErrHandleReleased = errors.New("shared library handle is released")
+
+// SharedLibraryOption configures the client behind a SharedLibraryHandle.
+type SharedLibraryOption func(b *DeliveryClientBuilder)
+
+// SharedLibraryHandle is an independently configured client for one application of a shared
+// library. Handles are safe for concurrent use, and releasing one doesn't affect the others.
+type SharedLibraryHandle struct {
+	client *DeliveryClient
+
+	mu       sync.Mutex
+	released bool
+	inFlight sync.WaitGroup
+}
+
+// NewSharedLibraryClient is a factory method for SharedLibraryHandle. Each option configures the
+// handle's own builder, e.g.
+//
+//	func(b *DeliveryClientBuilder) { b.WithDeliveryEndpoint(endpoint).WithDeliveryAPIKey(key) }
+func NewSharedLibraryClient(opts ...SharedLibraryOption) (*SharedLibraryHandle, error) {
+	b := NewDeliveryClientBuilder()
+	for _, opt := range opts {
+		opt(b)
+	}
+	client, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return &SharedLibraryHandle{client: client}, nil
+}
+
+// Deliver delivers with the handle's client, or returns ErrHandleReleased.
+func (h *SharedLibraryHandle) Deliver(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+	h.mu.Lock()
+	if h.released {
+		h.mu.Unlock()
+		return nil, ErrHandleReleased
+	}
+	h.inFlight.Add(1)
+	h.mu.Unlock()
+	defer h.inFlight.Done()
+	return h.client.Deliver(ctx, req)
+}
+
+// Release rejects new calls, waits for in-flight ones, then closes the handle's client. Releasing
+// again is a no-op.
+func (h *SharedLibraryHandle) Release() error {
+	h.mu.Lock()
+	if h.released {
+		h.mu.Unlock()
+		return nil
+	}
+	h.released = true
+	h.mu.Unlock()
+	h.inFlight.Wait()
+	return h.client.Close()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// newTestSharedLibraryClient returns a handle calling api with apiKey.
+func newTestSharedLibraryClient(t *testing.T, api *fakeAPI, apiKey string) *SharedLibraryHandle {
+	t.Helper()
+	metrics, _ := newRecordingServer(t)
+	h, err := NewSharedLibraryClient(func(b *DeliveryClientBuilder) {
+		b.WithDeliveryEndpoint(api.URL).
+			WithMetricsEndpoint(metrics.URL).
+			WithDisableUsageTracking(true).
+			WithDeliveryAPIKey(apiKey)
+	})
+	if err != nil {
+		t.Fatalf("NewSharedLibraryClient failed: %v", err)
+	}
+	t.Cleanup(func() { h.Release() })
+	return h
+}
+
+func TestSharedLibraryHandlesAreIsolated(t *testing.T) {
+	const handles, callsPerHandle = 5, 20
+	apis := make([]*fakeAPI, handles)
+	clients := make([]*SharedLibraryHandle, handles)
+	for i := range clients {
+		apis[i] = newFakeAPI(t)
+		requestID := fmt.Sprintf("handle-%d", i)
+		apis[i].setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+			resp := echoResponse(req)
+			resp.RequestId = requestID
+			writeDeliveryResponse(t, w, resp)
+		})
+		clients[i] = newTestSharedLibraryClient(t, apis[i], fmt.Sprintf("key-%d", i))
+	}
+
+	var wg sync.WaitGroup
+	for i, h := range clients {
+		for j := 0; j < callsPerHandle; j++ {
+			wg.Add(1)
+			go func(i int, h *SharedLibraryHandle) {
+				defer wg.Done()
+				resp, err := h.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b"))
+				if err != nil {
+					t.Errorf("handle %d: Deliver failed: %v", i, err)
+					return
+				}
+				if want := fmt.Sprintf("handle-%d", i); resp.Response.GetRequestId() != want {
+					t.Errorf("handle %d got a response from %s", i, resp.Response.GetRequestId())
+				}
+			}(i, h)
+		}
+	}
+	wg.Wait()
+
+	for i, api := range apis {
+		if got := api.calls(); got != callsPerHandle {
+			t.Errorf("API %d got %d calls, want %d", i, got, callsPerHandle)
+		}
+		if got, want := api.lastHeader(t).Get("x-api-key"), fmt.Sprintf("key-%d", i); got != want {
+			t.Errorf("API %d got key %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestSharedLibraryReleaseWaitsForInFlightCalls(t *testing.T) {
+	blocked := newFakeAPI(t)
+	unblock := make(chan struct{})
+	blocked.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		<-unblock
+		writeDeliveryResponse(t, w, echoResponse(req))
+	})
+	h := newTestSharedLibraryClient(t, blocked, "key-1")
+	other := newTestSharedLibraryClient(t, newFakeAPI(t), "key-2")
+
+	delivered := make(chan error, 1)
+	go func() {
+		_, err := h.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+		delivered <- err
+	}()
+	waitFor(t, "the call to be in flight", func() bool { return blocked.calls() == 1 })
+
+	released := make(chan error, 1)
+	go func() { released <- h.Release() }()
+	select {
+	case <-released:
+		t.Fatal("Release returned with a call in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// New calls are rejected while releasing, and other handles keep working.
+	waitFor(t, "new calls to be rejected", func() bool {
+		_, err := h.Deliver(context.Background(), newTestDeliveryRequest(t, "a"))
+		return errors.Is(err, ErrHandleReleased)
+	})
+	if _, err := other.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Errorf("other handle failed: %v", err)
+	}
+
+	close(unblock)
+	if err := <-delivered; err != nil {
+		t.Errorf("in-flight call failed: %v", err)
+	}
+	if err := <-released; err != nil {
+		t.Errorf("Release failed: %v", err)
+	}
+	if err := h.Release(); err != nil {
+		t.Errorf("second Release failed: %v", err)
+	}
+}
+
+func TestSharedLibraryClientRejectsInvalidConfig(t *testing.T) {
+	_, err := NewSharedLibraryClient(func(b *DeliveryClientBuilder) { b.WithMaxRequestInsertions(-1) })
+	if err == nil {
+		t.Error("NewSharedLibraryClient succeeded with an invalid config")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const spellCorrectionsPropertyKey = "spellCorrections"
+
+// maxSpellCorrectionDistance is the largest edit distance a correction may be from the typo.
+const maxSpellCorrectionDistance = 2
+
+// minSpellCorrectionLength is the fewest runes a word needs to be corrected. Shorter words, e.g.
+// sizes like "xl", are within a couple of edits of too many dictionary words.
+const minSpellCorrectionLength = 4
+
+// SpellCorrector fixes typos in search queries, one word at a time, using the closest dictionary
+// word by Levenshtein distance and preferring more frequent words on ties.
+type SpellCorrector struct {
+	mu          sync.RWMutex
+	frequencies map[string]int
+}
+
+// NewSpellCorrector is a factory method for SpellCorrector.
+func NewSpellCorrector() *SpellCorrector {
+	return &SpellCorrector{frequencies: map[string]int{}}
+}
+
+// LoadDictionary adds newline-separated words. A line may give the word's frequency after it,
+// e.g. "shoes 120"; otherwise each occurrence of a word counts once.
+func (sc *SpellCorrector) LoadDictionary(r io.Reader) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		frequency := 1
+		if len(fields) > 1 {
+			var err error
+			if frequency, err = strconv.Atoi(fields[1]); err != nil {
+				return fmt.Errorf("invalid frequency for %q: %v", fields[0], err)
+			}
+		}
+		sc.frequencies[strings.ToLower(fields[0])] += frequency
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading dictionary: %v", err)
+	}
+	return nil
+}
+
+// Correct returns the query with each misspelled word replaced, and the corrections made in the
+// form "typo->word". Short words and words with digits, e.g. "42" or "ps5", are left alone.
+func (sc *SpellCorrector) Correct(query string) (corrected string, corrections []string) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	words := strings.Fields(query)
+	for i, word := range words {
+		if !isCorrectable(word) {
+			continue
+		}
+		lower := strings.ToLower(word)
+		if _, ok := sc.frequencies[lower]; ok {
+			continue
+		}
+		if best, ok := sc.closestLocked(lower); ok {
+			words[i] = best
+			corrections = append(corrections, word+"->"+best)
+		}
+	}
+	if len(corrections) == 0 {
+		return query, nil
+	}
+	return strings.Join(words, " "), corrections
+}
+
+// isCorrectable reports whether word is long enough and free of digits to be spell corrected.
+func isCorrectable(word string) bool {
+	if utf8.RuneCountInString(word) < minSpellCorrectionLength {
+		return false
+	}
+	return !strings.ContainsFunc(word, unicode.IsDigit)
+}
+
+func (sc *SpellCorrector) closestLocked(word string) (string, bool) {
+	best, bestDistance, bestFrequency := "", maxSpellCorrectionDistance+1, 0
+	for candidate, frequency := range sc.frequencies {
+		if abs(len(candidate)-len(word)) > maxSpellCorrectionDistance {
+			continue
+		}
+		d := levenshtein(word, candidate)
+		if d < bestDistance || (d == bestDistance && (frequency > bestFrequency || (frequency == bestFrequency && candidate < best))) {
+			best, bestDistance, bestFrequency = candidate, d, frequency
+		}
+	}
+	return best, bestDistance <= maxSpellCorrectionDistance
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// spellCorrection corrects the search query before sending, keeping the original.
+type spellCorrection struct {
+	corrector *SpellCorrector
+}
+
+func (s *spellCorrection) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		query := req.Request.GetSearchQuery()
+		corrected, corrections := s.corrector.Correct(query)
+		if len(corrections) == 0 {
+			return next(ctx, req)
+		}
+
+		req = cloneRequest(req)
+		if err := setOriginalQuery(req, query); err != nil {
+			return nil, err
+		}
+		if err := setProperty(&req.Request.Properties, spellCorrectionsPropertyKey, toAnySlice(corrections)); err != nil {
+			return nil, err
+		}
+		req.Request.SearchQuery = corrected
+		return next(ctx, req)
+	}
+}
+
+func toAnySlice(values []string) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
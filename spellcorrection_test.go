@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestSpellCorrector(t *testing.T) *SpellCorrector {
+	t.Helper()
+	sc := NewSpellCorrector()
+	if err := sc.LoadDictionary(strings.NewReader("shoes 100\nshows 5\nrunning\nred\nblue\n")); err != nil {
+		t.Fatalf("LoadDictionary failed: %v", err)
+	}
+	return sc
+}
+
+func TestSpellCorrectorFixesCommonTypos(t *testing.T) {
+	sc := newTestSpellCorrector(t)
+	tests := map[string]string{
+		"sheos":         "shoes",
+		"shoez":         "shoes",
+		"runing sheos":  "running shoes",
+		"red shoes":     "red shoes",
+		"bleu":          "blue",
+		"xylophonezzzz": "xylophonezzzz",
+	}
+	for query, want := range tests {
+		if got, _ := sc.Correct(query); got != want {
+			t.Errorf("Correct(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestSpellCorrectorLeavesShortAndNumericWordsAlone(t *testing.T) {
+	sc := NewSpellCorrector()
+	if err := sc.LoadDictionary(strings.NewReader("shoes\nshirt\nred\nxs\nfour\nsize\n")); err != nil {
+		t.Fatalf("LoadDictionary failed: %v", err)
+	}
+	for _, query := range []string{"42", "xl shirt", "rad shoes", "size 44", "ps5", "sh0es", "shoes 4xl"} {
+		if got, corrections := sc.Correct(query); got != query || corrections != nil {
+			t.Errorf("Correct(%q) = %q with corrections %v, want it unchanged", query, got, corrections)
+		}
+	}
+	if got, _ := sc.Correct("sizr 44"); got != "size 44" {
+		t.Errorf("Correct(%q) = %q, want %q", "sizr 44", got, "size 44")
+	}
+}
+
+func TestSpellCorrectorPrefersFrequentWords(t *testing.T) {
+	// "shoas" is one edit from both "shoes" and "shows".
+	got, corrections := newTestSpellCorrector(t).Correct("shoas")
+	if got != "shoes" || !reflect.DeepEqual(corrections, []string{"shoas->shoes"}) {
+		t.Errorf("got %q with corrections %v, want the more frequent shoes", got, corrections)
+	}
+}
+
+func TestSpellCorrectorRejectsInvalidFrequency(t *testing.T) {
+	if err := NewSpellCorrector().LoadDictionary(strings.NewReader("shoes many\n")); err == nil {
+		t.Error("LoadDictionary accepted a non-numeric frequency")
+	}
+}
+
+func TestSpellCorrectionPreservesOriginalQuery(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithSpellCorrector(newTestSpellCorrector(t)))
+
+	if _, err := c.Deliver(context.Background(), newQueryRequest(t, "runing sheos", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	sent := api.lastRequest(t)
+	if got := sent.GetSearchQuery(); got != "running shoes" {
+		t.Errorf("got search query %q, want running shoes", got)
+	}
+	if got := getProperty(sent.GetProperties(), originalQueryPropertyKey).GetStringValue(); got != "runing sheos" {
+		t.Errorf("got original query %q, want runing sheos", got)
+	}
+	var corrections []string
+	for _, v := range getProperty(sent.GetProperties(), spellCorrectionsPropertyKey).GetListValue().GetValues() {
+		corrections = append(corrections, v.GetStringValue())
+	}
+	if want := []string{"runing->running", "sheos->shoes"}; !reflect.DeepEqual(corrections, want) {
+		t.Errorf("got corrections %v, want %v", corrections, want)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{{"", "", 0}, {"abc", "", 3}, {"kitten", "sitting", 3}, {"sheos", "shoes", 2}, {"héllo", "hello", 1}}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const sseEndpointSuffix = "/deliver/stream"
+
+const defaultSSEMaxReconnectDelay = 30 * time.Second
+const sseInitialReconnectDelay = 100 * time.Millisecond
+
+// maxSSEEventSize bounds one line of the event stream, which can hold a whole response.
+const maxSSEEventSize = 4 << 20
+
+// DeliverSSE streams re-ranked responses for req from the Delivery API's event stream until ctx is
+// done, reconnecting with exponential backoff when the stream ends. Errors are sent on the error
+// channel without stopping the stream; both channels are closed once ctx is done. Streamed
+// responses come straight from the Delivery API and are not logged by the SDK. Like Deliver, it
+// refuses opted-out users, only sending the error, encrypts and filters insertion properties, and
+// follows data residency.
+func (c *DeliveryClient) DeliverSSE(ctx context.Context, req *DeliveryRequest) (<-chan *DeliveryResponse, <-chan error) {
+	responses := make(chan *DeliveryResponse)
+	errs := make(chan error)
+	go func() {
+		defer close(responses)
+		defer close(errs)
+		api, req, err := c.prepareDirectCall(ctx, req)
+		if err != nil {
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+			}
+			return
+		}
+		delay := sseInitialReconnectDelay
+		for {
+			received, err := api.streamDelivery(ctx, req, func(resp *delivery.Response) bool {
+				if err := c.decryptStreamed(resp); err != nil {
+					select {
+					case errs <- err:
+						return true
+					case <-ctx.Done():
+						return false
+					}
+				}
+				select {
+				case responses <- &DeliveryResponse{DeliveryResponse: &client.DeliveryResponse{
+					Response:        resp,
+					ClientRequestID: req.Request.GetClientRequestId(),
+					ExecutionServer: delivery.ExecutionServer_API,
+				}}:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if received {
+				delay = sseInitialReconnectDelay
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+			delay = min(2*delay, c.sseMaxReconnectDelay)
+		}
+	}()
+	return responses, errs
+}
+
+// decryptStreamed decrypts the encrypted insertion properties of a streamed response in place.
+func (c *DeliveryClient) decryptStreamed(resp *delivery.Response) error {
+	if c.propertyEncryption == nil {
+		return nil
+	}
+	for _, ins := range resp.GetInsertion() {
+		if err := c.propertyEncryption.decrypt(ins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamDelivery reads one connection's events, passing each response to emit until it returns
+// false. It reports whether any response was received; a stream that ends cleanly returns no error.
+func (d *deliveryAPI) streamDelivery(ctx context.Context, req *DeliveryRequest, emit func(*delivery.Response) bool) (bool, error) {
+	requestBody, err := protojson.Marshal(req.Request)
+	if err != nil {
+		return false, fmt.Errorf("error marshaling delivery request: %v", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, d.sseHTTPEndpoint, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return false, fmt.Errorf("error creating HTTP request: %v", err)
+	}
+	d.setHeaders(httpReq, req.Headers)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	// The stream stays open indefinitely, so it can't use the client's per-call timeout.
+	streamClient := &http.Client{Transport: d.httpClient.Transport}
+	respHTTP, err := streamClient.Do(httpReq)
+	if err != nil {
+		return false, fmt.Errorf("error making HTTP request: %v", err)
+	}
+	defer respHTTP.Body.Close()
+	if respHTTP.StatusCode < 200 || respHTTP.StatusCode >= 300 {
+		return false, fmt.Errorf("failure calling Delivery API event stream; statusCode=%d", respHTTP.StatusCode)
+	}
+
+	received := false
+	scanner := bufio.NewScanner(respHTTP.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSEEventSize)
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			// Only data fields carry responses; event, id, retry and comments are ignored.
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data = append(data, strings.TrimPrefix(value, " "))
+			}
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		var resp delivery.Response
+		err := protojson.Unmarshal([]byte(strings.Join(data, "\n")), &resp)
+		data = nil
+		if err != nil {
+			return received, fmt.Errorf("error unmarshaling event: %v", err)
+		}
+		received = true
+		if !emit(&resp) {
+			return received, nil
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return received, fmt.Errorf("error reading event stream: %v", err)
+	}
+	return received, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// newSSEAPI streams a response per content ID, then, on the first connection only, a malformed
+// event, before ending the stream.
+func newSSEAPI(t *testing.T, contentIDs ...string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != sseEndpointSuffix || r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("got call to %s accepting %q, want an event stream", r.URL.Path, r.Header.Get("Accept"))
+			return
+		}
+		n := connections.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, id := range contentIDs {
+			body, _ := protojson.Marshal(rankedResponse(id))
+			fmt.Fprintf(w, ": keepalive\nevent: rerank\ndata: %s\n\n", body)
+			flusher.Flush()
+		}
+		if n == 1 {
+			fmt.Fprint(w, "data: {not json\n\n")
+			flusher.Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &connections
+}
+
+func TestDeliverSSEStreamsAndReconnects(t *testing.T) {
+	server, connections := newSSEAPI(t, "a", "b")
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithDisableUsageTracking(true).
+		WithSSEMaxReconnectDelay(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	responses, errs := c.DeliverSSE(ctx, newTestDeliveryRequest(t, "a", "b"))
+
+	var got []string
+	var errCount int
+	timeout := time.After(5 * time.Second)
+	for len(got) < 4 {
+		select {
+		case resp := <-responses:
+			got = append(got, resp.Response.GetInsertion()[0].GetContentId())
+		case <-errs:
+			errCount++
+		case <-timeout:
+			t.Fatalf("timed out after responses %v", got)
+		}
+	}
+	if fmt.Sprint(got) != "[a b a b]" {
+		t.Errorf("got responses %v, want [a b a b] across two connections", got)
+	}
+	if errCount != 1 {
+		t.Errorf("got %d errors, want 1 for the malformed event", errCount)
+	}
+	if connections.Load() < 2 {
+		t.Errorf("got %d connections, want a reconnect", connections.Load())
+	}
+
+	cancel()
+	closed := make(chan struct{})
+	go func() {
+		for range responses {
+		}
+		for range errs {
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("channels weren't closed after the context was canceled")
+	}
+}
+
+func TestDeliverSSEReportsHTTPErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	c := buildTestClient(t, NewDeliveryClientBuilder().WithDeliveryEndpoint(server.URL).WithDisableUsageTracking(true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, errs := c.DeliverSSE(ctx, newTestDeliveryRequest(t, "a"))
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("got a nil error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the error")
+	}
+}
+
+func TestDeliverSSEEncryptsAndFiltersProperties(t *testing.T) {
+	var sent atomic.Pointer[delivery.Request]
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req delivery.Request
+		body, _ := io.ReadAll(r.Body)
+		if err := protojson.Unmarshal(body, &req); err != nil {
+			t.Errorf("error parsing streamed request: %v", err)
+			return
+		}
+		sent.Store(&req)
+		resp := echoResponse(&req)
+		resp.Insertion[0].Properties = req.GetInsertion()[0].GetProperties()
+		data, _ := protojson.Marshal(resp)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}))
+	t.Cleanup(server.Close)
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithDisableUsageTracking(true).
+		WithDeniedPropertyKeys("email").
+		WithInsertionPropertyEncryption(newTestEncryptor(t, testEncryptionKey), []string{"customerId"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := newTestDeliveryRequest(t, "a")
+	setProperty(&req.Request.Insertion[0].Properties, "email", "a@example.com")
+	setProperty(&req.Request.Insertion[0].Properties, "customerId", "customer-123")
+	responses, errs := c.DeliverSSE(ctx, req)
+
+	var resp *DeliveryResponse
+	select {
+	case resp = <-responses:
+	case err := <-errs:
+		t.Fatalf("got stream error %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a streamed response")
+	}
+	props := sent.Load().GetInsertion()[0].GetProperties()
+	if getProperty(props, "email") != nil {
+		t.Error("sent the denied email property")
+	}
+	if got := getProperty(props, "customerId").GetStringValue(); got == "customer-123" || got == "" {
+		t.Errorf("customerId was sent as %q, want it encrypted", got)
+	}
+	if got := getProperty(resp.Response.GetInsertion()[0].GetProperties(), "customerId").GetStringValue(); got != "customer-123" {
+		t.Errorf("got streamed customerId %q, want it decrypted", got)
+	}
+}
+
+func TestDeliverSSERefusesOptedOutUsers(t *testing.T) {
+	server, connections := newSSEAPI(t, "a")
+	c := buildTestClient(t, NewDeliveryClientBuilder().
+		WithDeliveryEndpoint(server.URL).
+		WithDisableUsageTracking(true).
+		WithGDPROptOut(func(context.Context) bool { return true }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	responses, errs := c.DeliverSSE(ctx, newTestDeliveryRequest(t, "a"))
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errOptedOut) {
+			t.Errorf("got error %v, want the opt-out error", err)
+		}
+	case <-responses:
+		t.Fatal("streamed a response for an opted-out user")
+	}
+	if _, ok := <-responses; ok {
+		t.Error("streamed a response for an opted-out user")
+	}
+	if n := connections.Load(); n != 0 {
+		t.Errorf("got %d stream connections for an opted-out user, want 0", n)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const defaultStabilizationTopK = 10
+
+// stabilizedEntry is the order last served for a request.
+type stabilizedEntry struct {
+	positions  map[string]int
+	insertions []*delivery.Insertion
+	expiresAt  time.Time
+}
+
+// PositionStabilizer keeps positions from flickering when a user refreshes quickly. While the order
+// last served for a request is fresh, a new response whose top insertions are similar to it is put
+// back in that order.
+type PositionStabilizer struct {
+	threshold float64
+	ttl       time.Duration
+	topK      int
+	// optedOut users' orders aren't remembered.
+	optedOut func(ctx context.Context) bool
+
+	mu        sync.Mutex
+	entries   map[string]stabilizedEntry
+	lastSweep time.Time
+
+	stabilized atomic.Uint64
+}
+
+// NewPositionStabilizer is a factory method for PositionStabilizer. Responses are reordered when
+// their top-K Jaccard similarity to the last order served exceeds threshold.
+func NewPositionStabilizer(threshold float64, ttl time.Duration) *PositionStabilizer {
+	return &PositionStabilizer{
+		threshold: threshold,
+		ttl:       ttl,
+		topK:      defaultStabilizationTopK,
+		entries:   map[string]stabilizedEntry{},
+	}
+}
+
+// Stabilized returns how many responses were put back in the last order served.
+func (s *PositionStabilizer) Stabilized() uint64 {
+	return s.stabilized.Load()
+}
+
+// middleware reorders similar new responses to match the order last served to the same user for the
+// same request, by RequestFingerprint. The new response keeps its own request and insertion IDs, so
+// logged impressions and actions join to it. The order expires ttl after it was first served, so
+// stabilization never pins results for longer.
+func (s *PositionStabilizer) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		if s.optedOut != nil && s.optedOut(ctx) {
+			return next(ctx, req)
+		}
+		userID := requestUserID(req.Request)
+		key := userID + "/" + RequestFingerprint(req)
+		resp, err := next(ctx, req)
+		if err != nil || userID == "" {
+			return resp, err
+		}
+		if cached, ok := s.get(key); ok && JaccardSimilarity(cached.insertions, resp.Response.GetInsertion(), s.topK) > s.threshold {
+			s.stabilized.Add(1)
+			return reorderLike(resp, cached.positions), nil
+		}
+		s.put(key, resp)
+		return resp, nil
+	}
+}
+
+// reorderLike returns a copy of resp with the insertions in positions ordered as there, followed by
+// the others in their original order.
+func reorderLike(resp *DeliveryResponse, positions map[string]int) *DeliveryResponse {
+	resp = cloneResponse(resp)
+	insertions := resp.Response.GetInsertion()
+	sort.SliceStable(insertions, func(i, j int) bool {
+		pi, iok := positions[insertions[i].GetContentId()]
+		pj, jok := positions[insertions[j].GetContentId()]
+		if iok && jok {
+			return pi < pj
+		}
+		return iok && !jok
+	})
+	renumberPositions(insertions)
+	return resp
+}
+
+func (s *PositionStabilizer) get(key string) (stabilizedEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return stabilizedEntry{}, false
+	}
+	return entry, true
+}
+
+func (s *PositionStabilizer) put(key string, resp *DeliveryResponse) {
+	// Copied, since the caller may modify the response it was served.
+	insertions := cloneResponse(resp).Response.GetInsertion()
+	positions := make(map[string]int, len(insertions))
+	for i, ins := range insertions {
+		positions[ins.GetContentId()] = i
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Drop expired entries at most once per TTL to keep memory bounded.
+	if now.Sub(s.lastSweep) > s.ttl {
+		for key, entry := range s.entries {
+			if now.After(entry.expiresAt) {
+				delete(s.entries, key)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.entries[key] = stabilizedEntry{positions: positions, insertions: insertions, expiresAt: now.Add(s.ttl)}
+}
+
+// JaccardSimilarity compares the content IDs of the top k insertions of a and b, from 0 for
+// disjoint to 1 for the same set. Two empty lists are the same.
+func JaccardSimilarity(a, b []*delivery.Insertion, k int) float64 {
+	setA := topContentIDs(a, k)
+	setB := topContentIDs(b, k)
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+	intersection := 0
+	for id := range setA {
+		if setB[id] {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(setA)+len(setB)-intersection)
+}
+
+func topContentIDs(insertions []*delivery.Insertion, k int) map[string]bool {
+	if k < len(insertions) {
+		insertions = insertions[:k]
+	}
+	ids := make(map[string]bool, len(insertions))
+	for _, ins := range insertions {
+		ids[ins.GetContentId()] = true
+	}
+	return ids
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestJaccardSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		k    int
+		want float64
+	}{
+		{"same order", []string{"a", "b", "c"}, []string{"a", "b", "c"}, 10, 1},
+		{"same set", []string{"a", "b", "c"}, []string{"c", "a", "b"}, 10, 1},
+		{"disjoint", []string{"a", "b"}, []string{"c", "d"}, 10, 0},
+		{"overlap", []string{"a", "b", "c"}, []string{"b", "c", "d"}, 10, 0.5},
+		{"only top k", []string{"a", "b", "x"}, []string{"b", "a", "y"}, 2, 1},
+		{"both empty", nil, nil, 10, 1},
+		{"one empty", []string{"a"}, nil, 10, 0},
+	}
+	for _, tc := range tests {
+		if got := JaccardSimilarity(testInsertions(tc.a...), testInsertions(tc.b...), tc.k); got != tc.want {
+			t.Errorf("%s: JaccardSimilarity(%v, %v, %d) = %v, want %v", tc.name, tc.a, tc.b, tc.k, got, tc.want)
+		}
+	}
+}
+
+// newRankingSequenceAPI serves the rankings in order, repeating the last.
+func newRankingSequenceAPI(t *testing.T, rankings ...[]string) *fakeAPI {
+	t.Helper()
+	api := newFakeAPI(t)
+	var mu sync.Mutex
+	call := 0
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		mu.Lock()
+		ranking := rankings[min(call, len(rankings)-1)]
+		call++
+		mu.Unlock()
+		resp := rankedResponse(ranking...)
+		resp.RequestId = req.GetClientRequestId()
+		writeDeliveryResponse(t, w, resp)
+	})
+	return api
+}
+
+func TestSimilarResponseIsStabilized(t *testing.T) {
+	api := newRankingSequenceAPI(t, []string{"a", "b", "c", "d"}, []string{"b", "a", "c", "d"})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithPositionStabilization(0.8, time.Minute))
+
+	first, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a", "b", "c", "d"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, first, "a", "b", "c", "d")
+	req := newUserDeliveryRequest(t, "user-1", "a", "b", "c", "d")
+	second, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, second, "a", "b", "c", "d")
+	if second.Response.GetRequestId() != req.Request.GetClientRequestId() {
+		t.Errorf("got request ID %q, want the new response's %q so impressions join to it", second.Response.GetRequestId(), req.Request.GetClientRequestId())
+	}
+	for i, ins := range second.Response.GetInsertion() {
+		if ins.GetPosition() != uint64(i) {
+			t.Errorf("insertion %s has position %d, want %d", ins.GetContentId(), ins.GetPosition(), i)
+		}
+	}
+}
+
+func TestDifferentResponseBypassesStabilization(t *testing.T) {
+	api := newRankingSequenceAPI(t, []string{"a", "b", "c", "d"}, []string{"d", "e", "f", "a"}, []string{"e", "d", "f", "a"})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithPositionStabilization(0.8, time.Minute))
+	deliver := func() *DeliveryResponse {
+		t.Helper()
+		resp, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a", "b", "c", "d", "e", "f"))
+		if err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+		return resp
+	}
+
+	assertContentIDs(t, deliver(), "a", "b", "c", "d")
+	assertContentIDs(t, deliver(), "d", "e", "f", "a")
+	// The different response replaced the stabilized order.
+	assertContentIDs(t, deliver(), "d", "e", "f", "a")
+}
+
+func TestStabilizationIsPerUserAndRequest(t *testing.T) {
+	api := newRankingSequenceAPI(t, []string{"a", "b", "c"}, []string{"b", "a", "c"})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithPositionStabilization(0.8, time.Minute))
+
+	if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a", "b", "c")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	other, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "user-2", "a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, other, "b", "a", "c")
+	search := newUserDeliveryRequest(t, "user-1", "a", "b", "c")
+	search.Request.SearchQuery = "shoes"
+	otherRequest, err := c.Deliver(context.Background(), search)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, otherRequest, "b", "a", "c")
+}
+
+func TestStabilizedOrderExpires(t *testing.T) {
+	stabilizer := NewPositionStabilizer(0.8, 50*time.Millisecond)
+	ranking := []string{"a", "b", "c"}
+	deliver := stabilizer.middleware(func(context.Context, *DeliveryRequest) (*DeliveryResponse, error) {
+		return newResponseWithInsertions(testInsertions(ranking...)), nil
+	})
+	deliverIDs := func() []string {
+		t.Helper()
+		resp, err := deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a", "b", "c"))
+		if err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+		return insertionContentIDsOf(resp.Response.GetInsertion())
+	}
+
+	deliverIDs()
+	ranking = []string{"c", "b", "a"}
+	if got := deliverIDs(); got[0] != "a" {
+		t.Errorf("got %v within the TTL, want the stabilized a b c", got)
+	}
+	if stabilizer.Stabilized() != 1 {
+		t.Errorf("got %d stabilized responses, want 1", stabilizer.Stabilized())
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := deliverIDs(); got[0] != "c" {
+		t.Errorf("got %v after the TTL, want the new c b a", got)
+	}
+}
+
+func TestStabilizationSkipsOptedOutUsers(t *testing.T) {
+	s := NewPositionStabilizer(0.5, time.Minute)
+	s.optedOut = optedOutFromContext
+	deliver := s.middleware(func(_ context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		return newResponseWithInsertions(testInsertions("a", "b")), nil
+	})
+
+	ctx := context.WithValue(context.Background(), optedOutKey{}, true)
+	for i := 0; i < 2; i++ {
+		if _, err := deliver(ctx, newUserDeliveryRequest(t, "user-1", "a", "b")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) != 0 || s.Stabilized() != 0 {
+		t.Errorf("remembered %d orders of an opted-out user, want none", len(s.entries))
+	}
+}
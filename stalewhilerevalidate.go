@@ -0,0 +1,167 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultStaleMaxAgeMultiple = 10
+const defaultStaleMaxEntries = 10000
+
+// swrEntry is a cached response, when it goes stale and when it's too old to serve at all.
+type swrEntry struct {
+	fingerprint string
+	response    *DeliveryResponse
+	staleAt     time.Time
+	expiresAt   time.Time
+	refreshing  bool
+}
+
+// staleWhileRevalidateCache serves cached responses by request fingerprint. Responses older than
+// staleTTL are still served, but trigger a background refresh, until they are maxAge old. The
+// least recently used responses are evicted past maxEntries.
+type staleWhileRevalidateCache struct {
+	staleTTL         time.Duration
+	bgRefreshTimeout time.Duration
+	maxAge           time.Duration
+	maxEntries       int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	hits      atomic.Uint64
+	staleHits atomic.Uint64
+}
+
+// newStaleWhileRevalidateCache is a factory method for staleWhileRevalidateCache.
+func newStaleWhileRevalidateCache(staleTTL, bgRefreshTimeout, maxAge time.Duration, maxEntries int) *staleWhileRevalidateCache {
+	return &staleWhileRevalidateCache{
+		staleTTL:         staleTTL,
+		bgRefreshTimeout: bgRefreshTimeout,
+		maxAge:           maxAge,
+		maxEntries:       maxEntries,
+		entries:          map[string]*list.Element{},
+		lru:              list.New(),
+	}
+}
+
+// middleware returns the cached response if there is one, refreshing it in the background once it's
+// stale, and otherwise delivers and caches the response.
+func (s *staleWhileRevalidateCache) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		fingerprint := RequestFingerprint(req)
+		resp, stale, refresh := s.get(fingerprint)
+		if resp != nil {
+			if !stale {
+				s.hits.Add(1)
+				return resp, nil
+			}
+			s.staleHits.Add(1)
+			if refresh {
+				// Keep the context's values, e.g. the correlation ID, but not its deadline.
+				go s.refresh(context.WithoutCancel(ctx), next, cloneRequest(req), fingerprint)
+			}
+			return resp, nil
+		}
+
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if cacheable(resp) {
+			s.put(fingerprint, resp)
+		}
+		return resp, nil
+	}
+}
+
+// get returns the cached response, whether it's stale and whether the caller should refresh it.
+func (s *staleWhileRevalidateCache) get(fingerprint string) (*DeliveryResponse, bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[fingerprint]
+	if !ok {
+		return nil, false, false
+	}
+	entry := elem.Value.(*swrEntry)
+	now := time.Now()
+	if !now.Before(entry.expiresAt) {
+		s.lru.Remove(elem)
+		delete(s.entries, fingerprint)
+		return nil, false, false
+	}
+	s.lru.MoveToFront(elem)
+	if now.Before(entry.staleAt) {
+		return entry.response, false, false
+	}
+	// Only one refresh runs per fingerprint at a time.
+	refresh := !entry.refreshing
+	entry.refreshing = true
+	return entry.response, true, refresh
+}
+
+func (s *staleWhileRevalidateCache) put(fingerprint string, resp *DeliveryResponse) {
+	now := time.Now()
+	entry := &swrEntry{
+		fingerprint: fingerprint,
+		response:    resp,
+		staleAt:     now.Add(s.staleTTL),
+		expiresAt:   now.Add(s.maxAge),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[fingerprint]; ok {
+		elem.Value = entry
+		s.lru.MoveToFront(elem)
+		return
+	}
+	s.entries[fingerprint] = s.lru.PushFront(entry)
+	for s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*swrEntry).fingerprint)
+	}
+}
+
+// refresh replaces the cached response, keeping the stale one if delivery fails or falls back.
+func (s *staleWhileRevalidateCache) refresh(ctx context.Context, next deliverFunc, req *DeliveryRequest, fingerprint string) {
+	if s.bgRefreshTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.bgRefreshTimeout)
+		defer cancel()
+	}
+	resp, err := next(ctx, req)
+	if err == nil && cacheable(resp) {
+		s.put(fingerprint, resp)
+		return
+	}
+	if err != nil {
+		log.Printf("Error refreshing stale delivery response: %v\n", err)
+	}
+	s.mu.Lock()
+	if elem, ok := s.entries[fingerprint]; ok {
+		elem.Value.(*swrEntry).refreshing = false
+	}
+	s.mu.Unlock()
+}
+
+// CacheHits returns how many calls were served a fresh cached response.
+func (c *DeliveryClient) CacheHits() uint64 {
+	if c.staleWhileRevalidate == nil {
+		return 0
+	}
+	return c.staleWhileRevalidate.hits.Load()
+}
+
+// CacheStaleHits returns how many calls were served a stale cached response while it was refreshed.
+func (c *DeliveryClient) CacheStaleHits() uint64 {
+	if c.staleWhileRevalidate == nil {
+		return 0
+	}
+	return c.staleWhileRevalidate.staleHits.Load()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestStaleResponseIsServedWhileRefreshing(t *testing.T) {
+	api := newFakeAPI(t)
+	var version atomic.Int32
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		if version.Load() == 0 {
+			writeDeliveryResponse(t, w, rankedResponse("a", "b"))
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+		writeDeliveryResponse(t, w, rankedResponse("b", "a"))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithStaleWhileRevalidate(100*time.Millisecond, time.Second))
+	deliver := func() *DeliveryResponse {
+		t.Helper()
+		resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "b"))
+		if err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+		return resp
+	}
+
+	assertContentIDs(t, deliver(), "a", "b")
+	assertContentIDs(t, deliver(), "a", "b")
+	if c.CacheHits() != 1 || api.calls() != 1 {
+		t.Errorf("got %d cache hits and %d Delivery API calls, want 1 each", c.CacheHits(), api.calls())
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	version.Store(1)
+	start := time.Now()
+	assertContentIDs(t, deliver(), "a", "b")
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("stale hit took %v, want it served without waiting for the refresh", elapsed)
+	}
+	if c.CacheStaleHits() != 1 {
+		t.Errorf("got %d stale hits, want 1", c.CacheStaleHits())
+	}
+
+	waitFor(t, "the background refresh", func() bool {
+		cache := c.staleWhileRevalidate
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		elem, ok := cache.entries[RequestFingerprint(newTestDeliveryRequest(t, "a", "b"))]
+		return ok && !elem.Value.(*swrEntry).refreshing
+	})
+	assertContentIDs(t, deliver(), "b", "a")
+	if api.calls() != 2 {
+		t.Errorf("got %d Delivery API calls, want 2", api.calls())
+	}
+}
+
+func TestFallbackResponsesAreNotCached(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithStaleWhileRevalidate(time.Minute, time.Second))
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if api.calls() != 2 || c.CacheHits() != 0 {
+		t.Errorf("got %d Delivery API calls and %d cache hits, want the fallback not cached", api.calls(), c.CacheHits())
+	}
+}
+
+func TestStaleWhileRevalidateCacheBounds(t *testing.T) {
+	cache := newStaleWhileRevalidateCache(time.Millisecond, time.Second, 20*time.Millisecond, 2)
+	cache.put("a", &DeliveryResponse{})
+	cache.put("b", &DeliveryResponse{})
+	cache.get("a")
+	cache.put("c", &DeliveryResponse{})
+	if got, _, _ := cache.get("b"); got != nil {
+		t.Error("least recently used entry wasn't evicted")
+	}
+	if got, _, _ := cache.get("a"); got == nil {
+		t.Error("recently used entry was evicted")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if got, _, _ := cache.get("a"); got != nil {
+		t.Error("entry past its max age was served")
+	}
+}
+
+func TestStaleWhileRevalidateLimitsAreValidated(t *testing.T) {
+	_, err := newTestClientBuilder(t, newFakeAPI(t)).
+		WithStaleWhileRevalidate(time.Minute, time.Second).
+		WithStaleWhileRevalidateLimits(time.Second, 10).
+		Build()
+	if err == nil {
+		t.Error("Build succeeded with a max age shorter than the stale TTL")
+	}
+}
+
+func TestStaleWhileRevalidateIsPerUserAndOverride(t *testing.T) {
+	api := newFakeAPI(t)
+	api.setRespond(func(w http.ResponseWriter, req *delivery.Request) {
+		if req.GetUserInfo().GetUserId() == "user-2" {
+			writeDeliveryResponse(t, w, rankedResponse("b", "a"))
+			return
+		}
+		writeDeliveryResponse(t, w, rankedResponse("a", "b"))
+	})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithStaleWhileRevalidate(time.Minute, time.Second))
+	// Logged-in users may share an anonymous ID, e.g. on a shared device.
+	deliver := func(userID string, modelVersion string) *DeliveryResponse {
+		t.Helper()
+		req := buildTestRequest(t, NewDeliveryRequestBuilder(newUserDeliveryRequest(t, userID, "a", "b").Request))
+		req.Request.UserInfo.AnonUserId = "shared-device"
+		if modelVersion != "" {
+			req.Headers.Set(modelVersionHeader, modelVersion)
+		}
+		resp, err := c.Deliver(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+		return resp
+	}
+
+	assertContentIDs(t, deliver("user-1", ""), "a", "b")
+	assertContentIDs(t, deliver("user-2", ""), "b", "a")
+	deliver("user-1", "v2")
+	if c.CacheHits() != 0 || api.calls() != 3 {
+		t.Errorf("got %d cache hits and %d Delivery API calls, want each user and model version delivered", c.CacheHits(), api.calls())
+	}
+	assertContentIDs(t, deliver("user-1", ""), "a", "b")
+	if c.CacheHits() != 1 {
+		t.Errorf("got %d cache hits, want the repeated request cached", c.CacheHits())
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const rawQueryPropertyKey = "rawQuery"
+
+// defaultStopWords are common English words that carry no ranking signal.
+var defaultStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "by", "for", "from", "in", "is", "it", "of", "on",
+	"or", "that", "the", "this", "to", "was", "with",
+}
+
+// StopWordRemover drops words like "the" and "is" from search queries.
+type StopWordRemover struct {
+	stopWords map[string]bool
+}
+
+// NewStopWordRemover is a factory method for StopWordRemover. Without words it uses common
+// English stop words.
+func NewStopWordRemover(words []string) *StopWordRemover {
+	if len(words) == 0 {
+		words = defaultStopWords
+	}
+	stopWords := make(map[string]bool, len(words))
+	for _, word := range words {
+		stopWords[strings.ToLower(word)] = true
+	}
+	return &StopWordRemover{stopWords: stopWords}
+}
+
+// NewStopWordRemoverFromFile loads stop words from a file with one word per line.
+func NewStopWordRemoverFromFile(path string) (*StopWordRemover, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening stop words: %v", err)
+	}
+	defer f.Close()
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" {
+			words = append(words, word)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading stop words: %v", err)
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("no stop words in %s", path)
+	}
+	return NewStopWordRemover(words), nil
+}
+
+// Remove returns the query without its stop words, ignoring case. It is empty if every word is a
+// stop word.
+func (r *StopWordRemover) Remove(query string) string {
+	var kept []string
+	for _, word := range strings.Fields(query) {
+		if !r.stopWords[strings.ToLower(word)] {
+			kept = append(kept, word)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// stopWordRemoval strips stop words from the search query before sending.
+type stopWordRemoval struct {
+	remover *StopWordRemover
+}
+
+// middleware keeps the query as is if it is all stop words, since an empty query would turn a
+// search into a browse.
+func (s *stopWordRemoval) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		query := req.Request.GetSearchQuery()
+		cleaned := s.remover.Remove(query)
+		if cleaned == "" || cleaned == query {
+			return next(ctx, req)
+		}
+
+		req = cloneRequest(req)
+		if err := setProperty(&req.Request.Properties, rawQueryPropertyKey, query); err != nil {
+			return nil, err
+		}
+		req.Request.SearchQuery = cleaned
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveStopWords(t *testing.T) {
+	r := NewStopWordRemover(nil)
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"the best shoes for running", "best shoes running"},
+		{"The  Shoes", "Shoes"},
+		{"the and is", ""},
+		{"", ""},
+		{"boots", "boots"},
+	}
+	for _, tt := range tests {
+		if got := r.Remove(tt.query); got != tt.want {
+			t.Errorf("Remove(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestNewStopWordRemoverFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stopwords.txt")
+	if err := os.WriteFile(path, []byte("Der\n\n  die \ndas\n"), 0o644); err != nil {
+		t.Fatalf("error writing stop words: %v", err)
+	}
+	r, err := NewStopWordRemoverFromFile(path)
+	if err != nil {
+		t.Fatalf("NewStopWordRemoverFromFile failed: %v", err)
+	}
+	// Only the file's words are stop words.
+	if got := r.Remove("der Hund and die Katze"); got != "Hund and Katze" {
+		t.Errorf("got %q, want Hund and Katze", got)
+	}
+
+	empty := filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(empty, []byte("\n\n"), 0o644); err != nil {
+		t.Fatalf("error writing stop words: %v", err)
+	}
+	if _, err := NewStopWordRemoverFromFile(empty); err == nil {
+		t.Error("NewStopWordRemoverFromFile succeeded on a file without words")
+	}
+	if _, err := NewStopWordRemoverFromFile(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("NewStopWordRemoverFromFile succeeded on a missing file")
+	}
+}
+
+func TestStopWordRemovalRewritesSearchQuery(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithStopWordRemoval(NewStopWordRemover(nil)))
+
+	if _, err := c.Deliver(context.Background(), newQueryRequest(t, "the red shoes", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	sent := api.lastRequest(t)
+	if sent.GetSearchQuery() != "red shoes" {
+		t.Errorf("sent query %q, want red shoes", sent.GetSearchQuery())
+	}
+	if got := getProperty(sent.GetProperties(), rawQueryPropertyKey).GetStringValue(); got != "the red shoes" {
+		t.Errorf("sent raw query %q, want the red shoes", got)
+	}
+}
+
+func TestStopWordRemovalKeepsAllStopWordQueries(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithStopWordRemoval(NewStopWordRemover(nil)))
+
+	// An empty query would turn the search into a browse, so it's sent as is.
+	if _, err := c.Deliver(context.Background(), newQueryRequest(t, "the it", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	sent := api.lastRequest(t)
+	if sent.GetSearchQuery() != "the it" || getProperty(sent.GetProperties(), rawQueryPropertyKey) != nil {
+		t.Errorf("got query %q and raw query %v, want the it unchanged", sent.GetSearchQuery(), getProperty(sent.GetProperties(), rawQueryPropertyKey))
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import "github.com/promotedai/schema/generated/go/proto/delivery"
+
+const subscriptionPropertyKey = "subscription"
+
+const subscriptionBoost = 2.0
+const subscriptionBury = 0.5
+
+// SubscriptionContext describes the user's subscription, so subscribers see the items their
+// subscription covers first.
+type SubscriptionContext struct {
+	IsSubscriber       bool     `json:"isSubscriber"`
+	SubscriptionTier   string   `json:"subscriptionTier,omitempty"`
+	EligibleContentIDs []string `json:"eligibleContentIds,omitempty"`
+}
+
+// applySubscriptionBoosts boosts eligible insertions by 2 and buries the others by 0.5 for
+// subscribers, so applyBoostMultipliers ranks eligible ones above comparably ranked others.
+func applySubscriptionBoosts(insertions []*delivery.Insertion, sc SubscriptionContext) error {
+	if !sc.IsSubscriber {
+		return nil
+	}
+	eligible := make(map[string]bool, len(sc.EligibleContentIDs))
+	for _, id := range sc.EligibleContentIDs {
+		eligible[id] = true
+	}
+	for _, ins := range insertions {
+		multiplier := subscriptionBury
+		if eligible[ins.GetContentId()] {
+			multiplier = subscriptionBoost
+		}
+		if err := multiplyBoost(ins, multiplier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func deliverWithSubscription(t *testing.T, api *fakeAPI, sc SubscriptionContext, ids ...string) *DeliveryResponse {
+	t.Helper()
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, ids...).Request).WithSubscriptionContext(sc))
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	return resp
+}
+
+func TestSubscriptionEligibleItemsRankAboveIneligibleOnes(t *testing.T) {
+	api := newFakeAPI(t)
+	sc := SubscriptionContext{IsSubscriber: true, SubscriptionTier: "gold", EligibleContentIDs: []string{"c", "d"}}
+
+	// The fake API ranks the insertions in request order, so only the boosts move c and d up: c
+	// above a and b, and d above b, with which it was ranked two positions apart.
+	resp := deliverWithSubscription(t, api, sc, "a", "b", "c", "d")
+	assertContentIDs(t, resp, "c", "a", "d", "b")
+	if got := sentProperty(t, api, "c", boostMultiplierPropertyKey); got != 2.0 {
+		t.Errorf("got eligible multiplier %v, want 2", got)
+	}
+	if got := sentProperty(t, api, "a", boostMultiplierPropertyKey); got != 0.5 {
+		t.Errorf("got ineligible multiplier %v, want 0.5", got)
+	}
+
+	var sent SubscriptionContext
+	if err := getJSONProperty(api.lastRequest(t).GetProperties(), subscriptionPropertyKey, &sent); err != nil {
+		t.Fatalf("error reading subscription property: %v", err)
+	}
+	if !reflect.DeepEqual(sent, sc) {
+		t.Errorf("sent subscription %+v, want %+v", sent, sc)
+	}
+}
+
+func TestNonSubscribersAreNotBoosted(t *testing.T) {
+	api := newFakeAPI(t)
+	resp := deliverWithSubscription(t, api, SubscriptionContext{EligibleContentIDs: []string{"c"}}, "a", "b", "c")
+	assertContentIDs(t, resp, "a", "b", "c")
+	if got := sentProperty(t, api, "c", boostMultiplierPropertyKey); got != nil {
+		t.Errorf("got multiplier %v for a non-subscriber, want none", got)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const originalQueryPropertyKey = "originalQuery"
+
+// SynonymExpander expands search queries with their synonyms so equivalent queries rank alike.
+// Synonyms are bidirectional and matched against the whole query, ignoring case.
+type SynonymExpander struct {
+	mu       sync.RWMutex
+	synonyms map[string][]string
+}
+
+// NewSynonymExpander is a factory method for SynonymExpander.
+func NewSynonymExpander() *SynonymExpander {
+	return &SynonymExpander{synonyms: map[string][]string{}}
+}
+
+// Load adds each pair as synonyms of each other.
+func (e *SynonymExpander) Load(pairs [][2]string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, pair := range pairs {
+		a, b := normalizeQuery(pair[0]), normalizeQuery(pair[1])
+		if a == "" || b == "" {
+			return errors.New("synonyms must not be empty")
+		}
+		if a == b {
+			continue
+		}
+		e.addLocked(a, b)
+		e.addLocked(b, a)
+	}
+	return nil
+}
+
+// LoadSynonymsFromCSV adds synonym pairs from CSV rows of two columns.
+func (e *SynonymExpander) LoadSynonymsFromCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("error reading synonyms CSV: %v", err)
+	}
+	pairs := make([][2]string, len(records))
+	for i, record := range records {
+		pairs[i] = [2]string{record[0], record[1]}
+	}
+	return e.Load(pairs)
+}
+
+// Expand returns the query followed by its synonyms.
+func (e *SynonymExpander) Expand(query string) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]string{query}, e.synonyms[normalizeQuery(query)]...)
+}
+
+func (e *SynonymExpander) addLocked(term, synonym string) {
+	for _, s := range e.synonyms[term] {
+		if s == synonym {
+			return
+		}
+	}
+	e.synonyms[term] = append(e.synonyms[term], synonym)
+}
+
+func normalizeQuery(query string) string {
+	return strings.ToLower(strings.TrimSpace(query))
+}
+
+// synonymExpansion rewrites the search query to an OR of its synonyms before sending.
+type synonymExpansion struct {
+	expander *SynonymExpander
+}
+
+func (s *synonymExpansion) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		query := req.Request.GetSearchQuery()
+		expanded := s.expander.Expand(query)
+		if query == "" || len(expanded) == 1 {
+			return next(ctx, req)
+		}
+
+		req = cloneRequest(req)
+		if err := setOriginalQuery(req, query); err != nil {
+			return nil, err
+		}
+		req.Request.SearchQuery = strings.Join(expanded, " OR ")
+		return next(ctx, req)
+	}
+}
+
+// setOriginalQuery records the query the caller sent, unless an earlier rewrite already did.
+func setOriginalQuery(req *DeliveryRequest, query string) error {
+	if getProperty(req.Request.Properties, originalQueryPropertyKey) != nil {
+		return nil
+	}
+	return setProperty(&req.Request.Properties, originalQueryPropertyKey, query)
+}
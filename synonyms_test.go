@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newQueryRequest(t *testing.T, query string, contentIDs ...string) *DeliveryRequest {
+	t.Helper()
+	req := newTestDeliveryRequest(t, contentIDs...)
+	req.Request.SearchQuery = query
+	return req
+}
+
+func TestSynonymsAreBidirectional(t *testing.T) {
+	e := NewSynonymExpander()
+	if err := e.Load([][2]string{{"shoes", "sneakers"}, {"shoes", "trainers"}}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got, want := e.Expand("Shoes"), []string{"Shoes", "sneakers", "trainers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand(Shoes) = %v, want %v", got, want)
+	}
+	if got, want := e.Expand("sneakers"), []string{"sneakers", "shoes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand(sneakers) = %v, want %v", got, want)
+	}
+	if got := e.Expand("hats"); !reflect.DeepEqual(got, []string{"hats"}) {
+		t.Errorf("Expand(hats) = %v, want only the query", got)
+	}
+}
+
+func TestLoadSynonymsFromCSV(t *testing.T) {
+	e := NewSynonymExpander()
+	if err := e.LoadSynonymsFromCSV(strings.NewReader("shoes, sneakers\ntv,television\n")); err != nil {
+		t.Fatalf("LoadSynonymsFromCSV failed: %v", err)
+	}
+	if got, want := e.Expand("television"), []string{"television", "tv"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand(television) = %v, want %v", got, want)
+	}
+	if err := e.LoadSynonymsFromCSV(strings.NewReader("one,two,three\n")); err == nil {
+		t.Error("LoadSynonymsFromCSV accepted a row with three columns")
+	}
+	if err := e.Load([][2]string{{"shoes", " "}}); err == nil {
+		t.Error("Load accepted an empty synonym")
+	}
+}
+
+func TestSynonymExpansionPreservesOriginalQuery(t *testing.T) {
+	api := newFakeAPI(t)
+	e := NewSynonymExpander()
+	e.Load([][2]string{{"shoes", "sneakers"}})
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithSynonymExpander(e))
+
+	req := newQueryRequest(t, "shoes", "a")
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	sent := api.lastRequest(t)
+	if got := sent.GetSearchQuery(); got != "shoes OR sneakers" {
+		t.Errorf("got search query %q, want %q", got, "shoes OR sneakers")
+	}
+	if got := getProperty(sent.GetProperties(), originalQueryPropertyKey).GetStringValue(); got != "shoes" {
+		t.Errorf("got original query %q, want shoes", got)
+	}
+	if req.Request.GetSearchQuery() != "shoes" {
+		t.Errorf("the caller's query became %q", req.Request.GetSearchQuery())
+	}
+}
+
+func TestQueriesWithoutSynonymsAreUnchanged(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithSynonymExpander(NewSynonymExpander()))
+
+	if _, err := c.Deliver(context.Background(), newQueryRequest(t, "hats", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	sent := api.lastRequest(t)
+	if sent.GetSearchQuery() != "hats" || getProperty(sent.GetProperties(), originalQueryPropertyKey) != nil {
+		t.Errorf("got query %q and original query %v, want hats unchanged", sent.GetSearchQuery(), getProperty(sent.GetProperties(), originalQueryPropertyKey))
+	}
+}
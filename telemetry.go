@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const sdkModulePath = "github.com/promotedai/promoted-go-delivery-client"
+const telemetryFlushInterval = 24 * time.Hour
+const telemetryTimeout = 5 * time.Second
+
+// telemetryOptedOut disables telemetry for every client in the process.
+var telemetryOptedOut atomic.Bool
+
+// OptOutTelemetry disables telemetry for all clients, overriding WithTelemetry.
+func OptOutTelemetry() {
+	telemetryOptedOut.Store(true)
+}
+
+// telemetryPayload is the anonymized usage report. It must never contain user or request data.
+type telemetryPayload struct {
+	SDKVersion    string   `json:"sdkVersion"`
+	GoVersion     string   `json:"goVersion"`
+	Features      []string `json:"features"`
+	MeanLatencyMs float64  `json:"meanLatencyMs"`
+	RequestCount  uint64   `json:"requestCount"`
+}
+
+// telemetryReporter counts delivery calls and periodically posts usage stats.
+type telemetryReporter struct {
+	endpoint   string
+	httpClient *http.Client
+	features   []string
+
+	flushInterval time.Duration
+	requestCount  atomic.Uint64
+	latencyNanos  atomic.Int64
+	done          chan struct{}
+	wg            sync.WaitGroup
+	closeOnce     sync.Once
+}
+
+// newTelemetryReporter starts a reporter that flushes every flushInterval.
+func newTelemetryReporter(endpoint string, features []string, flushInterval time.Duration) *telemetryReporter {
+	t := &telemetryReporter{
+		endpoint:      endpoint,
+		httpClient:    &http.Client{Timeout: telemetryTimeout},
+		features:      features,
+		done:          make(chan struct{}),
+		flushInterval: flushInterval,
+	}
+	t.wg.Add(1)
+	go t.run()
+	return t
+}
+
+// middleware records the latency of every delivery call.
+func (t *telemetryReporter) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		start := time.Now()
+		resp, err := next(ctx, req)
+		t.requestCount.Add(1)
+		t.latencyNanos.Add(int64(time.Since(start)))
+		return resp, err
+	}
+}
+
+func (t *telemetryReporter) run() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.flush()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// payload builds the report and resets the counters for the next period.
+func (t *telemetryReporter) payload() *telemetryPayload {
+	count := t.requestCount.Swap(0)
+	latency := t.latencyNanos.Swap(0)
+	var meanLatencyMs float64
+	if count > 0 {
+		meanLatencyMs = float64(latency) / float64(count) / float64(time.Millisecond)
+	}
+	return &telemetryPayload{
+		SDKVersion:    sdkVersion(),
+		GoVersion:     runtime.Version(),
+		Features:      t.features,
+		MeanLatencyMs: meanLatencyMs,
+		RequestCount:  count,
+	}
+}
+
+func (t *telemetryReporter) flush() {
+	payload := t.payload()
+	if telemetryOptedOut.Load() {
+		return
+	}
+	if err := t.send(payload); err != nil {
+		log.Printf("Error sending telemetry: %v\n", err)
+	}
+}
+
+func (t *telemetryReporter) send(payload *telemetryPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling telemetry: %v", err)
+	}
+	resp, err := t.httpClient.Post(t.endpoint, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("error making HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failure sending telemetry; statusCode=%d", resp.StatusCode)
+	}
+	return nil
+}
+
+// close stops the background flush and sends a final report.
+func (t *telemetryReporter) close() {
+	t.closeOnce.Do(func() {
+		close(t.done)
+		t.wg.Wait()
+		t.flush()
+	})
+}
+
+// sdkVersion returns the delivery SDK module version this binary was built with.
+func sdkVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == sdkModulePath {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// enabledFeatures lists the optional features the builder turns on, by name only.
+func (b *DeliveryClientBuilder) enabledFeatures() []string {
+	var features []string
+	if b.useCaseValidation {
+		features = append(features, "use_case_validation")
+	}
+	if len(b.allowedPropertyKeys) > 0 {
+		features = append(features, "allowed_property_keys")
+	}
+	if len(b.deniedPropertyKeys) > 0 {
+		features = append(features, "denied_property_keys")
+	}
+	if b.shadowTrafficDeliveryRate > 0 {
+		features = append(features, "shadow_traffic")
+	}
+	if b.blockingShadowTraffic {
+		features = append(features, "blocking_shadow_traffic")
+	}
+	if b.performChecks {
+		features = append(features, "perform_checks")
+	}
+	if b.acceptsGzip {
+		features = append(features, "gzip")
+	}
+	if b.organizationID != "" {
+		features = append(features, "organization_id")
+	}
+	if b.correlationIDExtractor != nil {
+		features = append(features, "correlation_id")
+	}
+	if b.deduplicationWindow > 0 {
+		features = append(features, "deduplication")
+	}
+	if b.staleTTL > 0 {
+		features = append(features, "stale_while_revalidate")
+	}
+	if b.contentIDValidator != nil {
+		features = append(features, "content_id_validation")
+	}
+	if b.requiredAPIVersion != "" {
+		features = append(features, "required_api_version")
+	}
+	if b.batchingWindow > 0 {
+		features = append(features, "request_batching")
+	}
+	if b.adaptiveTimeoutCeiling > 0 {
+		features = append(features, "adaptive_timeout")
+	}
+	if b.rateLimitRPS > 0 {
+		features = append(features, "outbound_rate_limit")
+	}
+	if b.perUserRateLimitRPS > 0 {
+		features = append(features, "per_user_rate_limit")
+	}
+	if b.propertyEncryptor != nil && len(b.encryptedPropertyKeys) > 0 {
+		features = append(features, "property_encryption")
+	}
+	if b.userIDAnonymizer != nil || len(b.userIDAnonymizationPepper) > 0 {
+		features = append(features, "user_id_anonymization")
+	}
+	if b.gdprOptOut != nil {
+		features = append(features, "gdpr_opt_out")
+	}
+	if b.dataResidencyRouter != nil {
+		features = append(features, "data_residency")
+	}
+	if b.currencyNormalizer != nil {
+		features = append(features, "currency_normalization")
+	}
+	if b.categoryTaxonomy != nil {
+		features = append(features, "category_taxonomy")
+	}
+	if b.synonymExpander != nil {
+		features = append(features, "synonym_expansion")
+	}
+	if b.spellCorrector != nil {
+		features = append(features, "spell_correction")
+	}
+	if b.requestLinter != nil {
+		features = append(features, "request_linting")
+	}
+	if b.modelVersion != "" {
+		features = append(features, "model_version_pinning")
+	}
+	if b.stopWordRemover != nil {
+		features = append(features, "stop_word_removal")
+	}
+	if b.profiling {
+		features = append(features, "profiling")
+	}
+	if b.queryExpander != nil {
+		features = append(features, "query_expansion")
+	}
+	if b.impressionTracker != nil {
+		features = append(features, "impression_tracker")
+	}
+	if b.discountProperties != nil {
+		features = append(features, "discount_properties")
+	}
+	if b.priceTierKey != "" {
+		features = append(features, "price_tiers")
+	}
+	if b.inventoryChecker != nil {
+		features = append(features, "inventory_filter")
+	}
+	if b.fairnessConstraint != nil {
+		features = append(features, "fairness_constraint")
+	}
+	if b.diversityFilter != nil {
+		features = append(features, "diversity_filter")
+	}
+	if b.popularityProvider != nil {
+		features = append(features, "popularity_boost")
+	}
+	if len(b.temporalBoostRules) > 0 {
+		features = append(features, "temporal_boosts")
+	}
+	if b.contentStore != nil {
+		features = append(features, "content_store_enrichment")
+	}
+	if b.crossSellHook != nil {
+		features = append(features, "cross_sell")
+	}
+	if b.featureStore != nil {
+		features = append(features, "local_feature_store")
+	}
+	if b.confidenceThreshold > 0 {
+		features = append(features, "confidence_threshold")
+	}
+	if b.stabilizationTTL > 0 {
+		features = append(features, "position_stabilization")
+	}
+	if b.contentScorer != nil {
+		features = append(features, "content_scorer")
+	}
+	if b.priorityQueueSize > 0 {
+		features = append(features, "priority_queue")
+	}
+	if b.eventBus != nil {
+		features = append(features, "event_bus")
+	}
+	if b.budgetTracker != nil {
+		features = append(features, "budget_tracker")
+	}
+	if b.queryLogger != nil {
+		features = append(features, "query_logger")
+	}
+	if b.retryBackoff != nil {
+		features = append(features, "retry_backoff")
+	}
+	if b.abTestPlugin != nil {
+		features = append(features, "ab_test_plugin")
+	}
+	if b.localReRanker != nil {
+		features = append(features, "local_reranker")
+	}
+	if b.upSellHook != nil {
+		features = append(features, "up_sell")
+	}
+	if b.complementaryRecommender != nil {
+		features = append(features, "complementary_recommendations")
+	}
+	if b.recencyBooster != nil {
+		features = append(features, "recency_boost")
+	}
+	if b.offlineRankings != nil || b.offlineRankingsPath != "" {
+		features = append(features, "offline_mode")
+	}
+	return features
+}
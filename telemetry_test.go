@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestTelemetryNeverContainsUserData(t *testing.T) {
+	api := newFakeAPI(t)
+	telemetry, reports := newRecordingServer(t)
+	c, err := newTestClientBuilder(t, api).
+		WithTelemetry(true).
+		WithTelemetryEndpoint(telemetry.URL).
+		Build()
+	if err != nil {
+		t.Fatalf("error building client: %v", err)
+	}
+
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:        &common.UserInfo{UserId: "user-secret", AnonUserId: "anon-secret"},
+		ClientRequestId: "client-request-secret",
+		SearchQuery:     "query-secret",
+		Insertion:       testInsertions("content-secret"),
+	}))
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	c.Close()
+
+	bodies := reports()
+	if len(bodies) != 1 {
+		t.Fatalf("got %d telemetry reports on Close, want 1", len(bodies))
+	}
+	if strings.Contains(bodies[0], "secret") {
+		t.Errorf("telemetry report contains request data: %s", bodies[0])
+	}
+	var payload telemetryPayload
+	if err := json.Unmarshal([]byte(bodies[0]), &payload); err != nil {
+		t.Fatalf("error unmarshaling telemetry report: %v", err)
+	}
+	if payload.RequestCount != 1 || payload.GoVersion == "" {
+		t.Errorf("got request count %d and Go version %q, want 1 and a version", payload.RequestCount, payload.GoVersion)
+	}
+}
+
+func TestOptOutTelemetryOverridesClient(t *testing.T) {
+	OptOutTelemetry()
+	t.Cleanup(func() { telemetryOptedOut.Store(false) })
+
+	api := newFakeAPI(t)
+	telemetry, reports := newRecordingServer(t)
+	c, err := newTestClientBuilder(t, api).WithTelemetry(true).WithTelemetryEndpoint(telemetry.URL).Build()
+	if err != nil {
+		t.Fatalf("error building client: %v", err)
+	}
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	c.Close()
+
+	if got := len(reports()); got != 0 {
+		t.Errorf("got %d telemetry reports after opting out, want 0", got)
+	}
+}
+
+func TestTelemetryRequiresEndpoint(t *testing.T) {
+	if _, err := newTestClientBuilder(t, newFakeAPI(t)).WithTelemetry(true).Build(); err == nil {
+		t.Error("Build succeeded with telemetry enabled and no endpoint")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// boostMultiplierPropertyKey holds the product of the boosts applied to an insertion, e.g. by
+// temporal boost rules. applyBoostMultipliers reorders responses by it.
+const boostMultiplierPropertyKey = "boostMultiplier"
+
+// multiplyBoost multiplies the insertion's boostMultiplier property, which starts at 1, by m.
+func multiplyBoost(ins *delivery.Insertion, m float64) error {
+	if v := getProperty(ins.Properties, boostMultiplierPropertyKey); v != nil {
+		m *= v.GetNumberValue()
+	}
+	return setProperty(&ins.Properties, boostMultiplierPropertyKey, m)
+}
+
+// TemporalBoostRule boosts matching content for a fixed window, e.g. a flash sale.
+type TemporalBoostRule struct {
+	ContentIDPattern *regexp.Regexp
+	StartAt          time.Time
+	EndAt            time.Time
+	Multiplier       float64
+}
+
+// activeAt reports whether t is in [StartAt, EndAt).
+func (r TemporalBoostRule) activeAt(t time.Time) bool {
+	return !t.Before(r.StartAt) && t.Before(r.EndAt)
+}
+
+// temporalBoosts sets the boostMultiplier property of insertions matching active rules.
+type temporalBoosts struct {
+	rules []TemporalBoostRule
+	now   func() time.Time
+}
+
+func (b *temporalBoosts) active() []TemporalBoostRule {
+	now := b.now()
+	var active []TemporalBoostRule
+	for _, rule := range b.rules {
+		if rule.activeAt(now) {
+			active = append(active, rule)
+		}
+	}
+	return active
+}
+
+func (b *temporalBoosts) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		active := b.active()
+		if len(active) == 0 {
+			return next(ctx, req)
+		}
+
+		req = cloneRequest(req)
+		for _, ins := range req.Request.GetInsertion() {
+			multiplier, matched := 1.0, false
+			for _, rule := range active {
+				if rule.ContentIDPattern.MatchString(ins.GetContentId()) {
+					multiplier *= rule.Multiplier
+					matched = true
+				}
+			}
+			if !matched {
+				continue
+			}
+			if err := multiplyBoost(ins, multiplier); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// applyBoostMultipliers reorders each response by the boostMultiplier of its request insertions.
+// An insertion's score is its multiplier times a base score falling linearly from 1 at the top of
+// the response to 1/n at the bottom, so boosts move content from where the Delivery API or the SDK
+// ranked it rather than above everything unboosted: a 2x boost at most doubles an insertion's
+// distance from the bottom, and a multiplier of 0 buries it. Equal scores keep their order.
+func applyBoostMultipliers(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		multipliers := map[string]float64{}
+		for _, ins := range req.Request.GetInsertion() {
+			if v := getProperty(ins.Properties, boostMultiplierPropertyKey); v != nil {
+				multipliers[ins.GetContentId()] = v.GetNumberValue()
+			}
+		}
+		if len(multipliers) == 0 {
+			return resp, nil
+		}
+
+		resp = cloneResponse(resp)
+		insertions := resp.Response.GetInsertion()
+		n := float64(len(insertions))
+		scores := make(map[*delivery.Insertion]float64, len(insertions))
+		for i, ins := range insertions {
+			score := (n - float64(i)) / n
+			if m, ok := multipliers[ins.GetContentId()]; ok {
+				score *= m
+			}
+			scores[ins] = score
+		}
+		sort.SliceStable(insertions, func(i, j int) bool {
+			return scores[insertions[i]] > scores[insertions[j]]
+		})
+		renumberPositions(insertions)
+		return resp, nil
+	}
+}
+
+// ActiveTemporalBoosts returns the temporal boost rules in effect now.
+func (c *DeliveryClient) ActiveTemporalBoosts() []TemporalBoostRule {
+	if c.temporalBoosts == nil {
+		return nil
+	}
+	return c.temporalBoosts.active()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTemporalBoostAppliesOnlyWithinWindow(t *testing.T) {
+	saleStart := time.Date(2024, 11, 29, 0, 0, 0, 0, time.UTC)
+	sale := TemporalBoostRule{
+		ContentIDPattern: regexp.MustCompile(`^shoes-`),
+		StartAt:          saleStart,
+		EndAt:            saleStart.Add(24 * time.Hour),
+		Multiplier:       2,
+	}
+	tests := []struct {
+		name       string
+		now        time.Time
+		want       []string
+		wantActive int
+	}{
+		{"before", saleStart.Add(-time.Second), []string{"shirt", "shoes-1", "hat"}, 0},
+		{"start", saleStart, []string{"shoes-1", "shirt", "hat"}, 1},
+		{"during", saleStart.Add(12 * time.Hour), []string{"shoes-1", "shirt", "hat"}, 1},
+		{"end", sale.EndAt, []string{"shirt", "shoes-1", "hat"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t)
+			c := buildTestClient(t, newTestClientBuilder(t, api).WithTemporalBoostRules(sale))
+			c.temporalBoosts.now = func() time.Time { return tt.now }
+
+			resp, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "shirt", "shoes-1", "hat"))
+			if err != nil {
+				t.Fatalf("Deliver failed: %v", err)
+			}
+			assertContentIDs(t, resp, tt.want...)
+			if got := len(c.ActiveTemporalBoosts()); got != tt.wantActive {
+				t.Errorf("got %d active boosts, want %d", got, tt.wantActive)
+			}
+			wantMultiplier := any(nil)
+			if tt.wantActive > 0 {
+				wantMultiplier = 2.0
+			}
+			if got := sentProperty(t, api, "shoes-1", boostMultiplierPropertyKey); got != wantMultiplier {
+				t.Errorf("sent boost multiplier %v, want %v", got, wantMultiplier)
+			}
+			if got := sentProperty(t, api, "shirt", boostMultiplierPropertyKey); got != nil {
+				t.Errorf("sent boost multiplier %v for unmatched content, want none", got)
+			}
+		})
+	}
+}
+
+func TestOverlappingTemporalBoostsMultiply(t *testing.T) {
+	now := time.Now()
+	rule := func(pattern string, m float64) TemporalBoostRule {
+		return TemporalBoostRule{ContentIDPattern: regexp.MustCompile(pattern), StartAt: now.Add(-time.Hour), EndAt: now.Add(time.Hour), Multiplier: m}
+	}
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithTemporalBoostRules(rule(`^shoes-`, 2), rule(`-1$`, 1.5)))
+
+	if _, err := c.Deliver(context.Background(), newTestDeliveryRequest(t, "shoes-1", "shoes-2")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := sentProperty(t, api, "shoes-1", boostMultiplierPropertyKey); got != 3.0 {
+		t.Errorf("got boost multiplier %v, want 3", got)
+	}
+}
+
+func TestBoostMultipliersMoveContentFromItsRank(t *testing.T) {
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)))
+	req := newTestDeliveryRequest(t, "a", "b")
+	// The fake API ranks insertions in request order, giving base scores of 1, 0.8, 0.6, 0.4 and 0.2.
+	req.Request.Insertion = append(req.Request.Insertion,
+		insertionWithProperties(t, "c", map[string]any{boostMultiplierPropertyKey: 0.0}),
+		insertionWithProperties(t, "d", map[string]any{boostMultiplierPropertyKey: 3.0}),
+		insertionWithProperties(t, "e", map[string]any{boostMultiplierPropertyKey: 1.01}))
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	// A slight boost doesn't lift e above everything unboosted, a large one lifts d to the top and
+	// a multiplier of 0 buries c.
+	assertContentIDs(t, resp, "d", "a", "b", "e", "c")
+}
+
+func TestTemporalBoostRulesNeedPattern(t *testing.T) {
+	_, err := newTestClientBuilder(t, newFakeAPI(t)).WithTemporalBoostRules(TemporalBoostRule{Multiplier: 2}).Build()
+	if err == nil {
+		t.Error("Build succeeded with a rule without a ContentIDPattern")
+	}
+}
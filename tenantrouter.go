@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// TenantRouter routes delivery calls to a per-tenant client, falling back to a default client.
+type TenantRouter struct {
+	mu            sync.RWMutex
+	tenants       map[string]DeliveryClientInterface
+	defaultClient DeliveryClientInterface
+}
+
+// NewTenantRouter is a factory method for TenantRouter.
+func NewTenantRouter(tenants map[string]DeliveryClientInterface, defaultClient DeliveryClientInterface) *TenantRouter {
+	copied := make(map[string]DeliveryClientInterface, len(tenants))
+	for id, c := range tenants {
+		copied[id] = c
+	}
+	return &TenantRouter{
+		tenants:       copied,
+		defaultClient: defaultClient,
+	}
+}
+
+// Deliver sends the request with the tenant's client, or the default client for unknown tenants.
+func (r *TenantRouter) Deliver(ctx context.Context, tenantID string, req *DeliveryRequest) (*DeliveryResponse, error) {
+	r.mu.RLock()
+	c, ok := r.tenants[tenantID]
+	r.mu.RUnlock()
+	if !ok {
+		c = r.defaultClient
+	}
+	if c == nil {
+		return nil, fmt.Errorf("no delivery client for tenant %q", tenantID)
+	}
+	return c.Deliver(ctx, req)
+}
+
+// RegisterTenant adds or replaces the client for a tenant.
+func (r *TenantRouter) RegisterTenant(id string, c DeliveryClientInterface) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tenants[id] = c
+}
+
+// DeregisterTenant removes the client for a tenant so it falls back to the default client.
+func (r *TenantRouter) DeregisterTenant(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tenants, id)
+}
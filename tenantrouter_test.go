@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTenantRouterRoutesPerTenant(t *testing.T) {
+	acme := &fakeDeliveryClient{name: "acme"}
+	globex := &fakeDeliveryClient{name: "globex"}
+	fallback := &fakeDeliveryClient{name: "default"}
+	router := NewTenantRouter(map[string]DeliveryClientInterface{"acme": acme, "globex": globex}, fallback)
+
+	for tenantID, want := range map[string]string{"acme": "acme", "globex": "globex", "initech": "default"} {
+		resp, err := router.Deliver(context.Background(), tenantID, newTestDeliveryRequest(t, "a"))
+		if err != nil {
+			t.Fatalf("Deliver for %s failed: %v", tenantID, err)
+		}
+		if got := resp.Response.GetRequestId(); got != want {
+			t.Errorf("tenant %s was served by %s, want %s", tenantID, got, want)
+		}
+	}
+}
+
+func TestTenantRouterRegisterAndDeregister(t *testing.T) {
+	fallback := &fakeDeliveryClient{name: "default"}
+	router := NewTenantRouter(nil, fallback)
+	acme := &fakeDeliveryClient{name: "acme"}
+
+	router.RegisterTenant("acme", acme)
+	if _, err := router.Deliver(context.Background(), "acme", newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	router.DeregisterTenant("acme")
+	if _, err := router.Deliver(context.Background(), "acme", newTestDeliveryRequest(t, "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if acme.calls() != 1 || fallback.calls() != 1 {
+		t.Errorf("got %d acme and %d default calls, want 1 each", acme.calls(), fallback.calls())
+	}
+}
+
+func TestTenantRouterWithoutDefaultFailsForUnknownTenants(t *testing.T) {
+	router := NewTenantRouter(nil, nil)
+	if _, err := router.Deliver(context.Background(), "acme", newTestDeliveryRequest(t, "a")); err == nil {
+		t.Error("Deliver succeeded without a client for the tenant")
+	}
+}
+
+func TestTenantRouterDoesNotShareCallersMap(t *testing.T) {
+	tenants := map[string]DeliveryClientInterface{}
+	router := NewTenantRouter(tenants, &fakeDeliveryClient{name: "default"})
+	tenants["acme"] = &fakeDeliveryClient{name: "acme"}
+
+	resp, err := router.Deliver(context.Background(), "acme", newTestDeliveryRequest(t, "a"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if resp.Response.GetRequestId() != "default" {
+		t.Errorf("tenant added to the caller's map after construction was routed to %s", resp.Response.GetRequestId())
+	}
+}
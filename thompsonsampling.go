@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// ThompsonSamplingExplorer keeps a Beta(alpha, beta) click prior per content ID and ranks by sampling
+// from it, so uncertain items get explored in proportion to their chance of being the best.
+type ThompsonSamplingExplorer struct {
+	mu     sync.Mutex
+	rand   *rand.Rand
+	priors map[string][2]float64
+}
+
+// NewThompsonSamplingExplorer is a factory method for ThompsonSamplingExplorer. Unseen content
+// starts with a uniform Beta(1, 1) prior.
+func NewThompsonSamplingExplorer() *ThompsonSamplingExplorer {
+	return &ThompsonSamplingExplorer{
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		priors: map[string][2]float64{},
+	}
+}
+
+// SampleScore draws a click probability for the content ID from its Beta prior.
+func (e *ThompsonSamplingExplorer) SampleScore(contentID string) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	prior := e.priorLocked(contentID)
+	x := sampleGamma(e.rand, prior[0])
+	y := sampleGamma(e.rand, prior[1])
+	return x / (x + y)
+}
+
+// UpdatePrior records whether the content was clicked after being shown.
+func (e *ThompsonSamplingExplorer) UpdatePrior(contentID string, clicked bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	prior := e.priorLocked(contentID)
+	if clicked {
+		prior[0]++
+	} else {
+		prior[1]++
+	}
+	e.priors[contentID] = prior
+}
+
+// ExportPriors returns the (alpha, beta) parameters per content ID for persistence.
+func (e *ThompsonSamplingExplorer) ExportPriors() map[string][2]float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	priors := make(map[string][2]float64, len(e.priors))
+	for contentID, prior := range e.priors {
+		priors[contentID] = prior
+	}
+	return priors
+}
+
+// rank orders insertions by a fresh sample for each.
+func (e *ThompsonSamplingExplorer) rank(insertions []*delivery.Insertion) {
+	scores := make(map[*delivery.Insertion]float64, len(insertions))
+	for _, ins := range insertions {
+		scores[ins] = e.SampleScore(ins.GetContentId())
+	}
+	sort.SliceStable(insertions, func(i, j int) bool {
+		return scores[insertions[i]] > scores[insertions[j]]
+	})
+}
+
+// record treats any positive reward as a click.
+func (e *ThompsonSamplingExplorer) record(contentID string, reward float64) {
+	e.UpdatePrior(contentID, reward > 0)
+}
+
+func (e *ThompsonSamplingExplorer) priorLocked(contentID string) [2]float64 {
+	prior, ok := e.priors[contentID]
+	if !ok {
+		prior = [2]float64{1, 1}
+	}
+	return prior
+}
+
+// sampleGamma draws from Gamma(shape, 1) using Marsaglia and Tsang's method.
+func sampleGamma(r *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		// Boost to shape+1 and scale back down.
+		return sampleGamma(r, shape+1) * math.Pow(r.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := r.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
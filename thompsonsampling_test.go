@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestThompsonSamplingScoresHigherCTRHigher(t *testing.T) {
+	explorer := NewThompsonSamplingExplorer()
+	explorer.rand = rand.New(rand.NewSource(1))
+	outcomes := rand.New(rand.NewSource(2))
+	trueCTRs := map[string]float64{"low": 0.1, "high": 0.3}
+
+	for round := 0; round < 1000; round++ {
+		for contentID, ctr := range trueCTRs {
+			explorer.UpdatePrior(contentID, outcomes.Float64() < ctr)
+		}
+	}
+
+	// Under the null hypothesis that the explorer can't tell them apart, "high" wins half the draws.
+	const draws = 1000
+	wins := 0
+	for i := 0; i < draws; i++ {
+		if explorer.SampleScore("high") > explorer.SampleScore("low") {
+			wins++
+		}
+	}
+	z := (float64(wins) - draws/2) / math.Sqrt(draws/4)
+	if z < 1.645 {
+		t.Errorf("high CTR content won %d of %d draws (z=%.2f), want significantly more than half at p < 0.05", wins, draws, z)
+	}
+}
+
+func TestThompsonSamplingExportPriors(t *testing.T) {
+	explorer := NewThompsonSamplingExplorer()
+	explorer.UpdatePrior("a", true)
+	explorer.UpdatePrior("a", true)
+	explorer.UpdatePrior("a", false)
+
+	priors := explorer.ExportPriors()
+	if priors["a"] != [2]float64{3, 2} {
+		t.Errorf("got prior %v, want [3 2]", priors["a"])
+	}
+	if _, ok := priors["unseen"]; ok {
+		t.Error("got a prior for content that was never updated")
+	}
+}
+
+func TestThompsonSamplingScoresAreProbabilities(t *testing.T) {
+	explorer := NewThompsonSamplingExplorer()
+	explorer.UpdatePrior("a", false)
+	for i := 0; i < 100; i++ {
+		if score := explorer.SampleScore("a"); score < 0 || score > 1 {
+			t.Fatalf("got score %v outside [0, 1]", score)
+		}
+	}
+}
+
+func TestBanditWithThompsonSamplingRanksEveryResponse(t *testing.T) {
+	explorer := NewThompsonSamplingExplorer()
+	for i := 0; i < 200; i++ {
+		explorer.UpdatePrior("c", true)
+		explorer.UpdatePrior("a", false)
+	}
+	bandit := NewBanditDeliveryClient(&fakeDeliveryClient{name: "ranked"}, 0, 0).WithThompsonSamplingExplorer(explorer)
+
+	resp, err := bandit.Deliver(context.Background(), newTestDeliveryRequest(t, "a", "c"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "c", "a")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// UCBConfig configures UCBExplorer.
+type UCBConfig struct {
+	// Confidence scales the exploration bonus; 1 is standard UCB1. Values <= 0 default to 1.
+	Confidence float64
+}
+
+// ucbArm is the reward history of one content ID.
+type ucbArm struct {
+	pulls  int
+	reward float64
+}
+
+// UCBExplorer scores content with UCB1, mean + sqrt(2*ln(totalPulls)/pulls), favoring items whose
+// mean is uncertain because they have been pulled less.
+type UCBExplorer struct {
+	confidence float64
+
+	mu         sync.Mutex
+	arms       map[string]*ucbArm
+	totalPulls int
+}
+
+// NewUCBExplorer is a factory method for UCBExplorer.
+func NewUCBExplorer(config UCBConfig) *UCBExplorer {
+	confidence := config.Confidence
+	if confidence <= 0 {
+		confidence = 1
+	}
+	return &UCBExplorer{
+		confidence: confidence,
+		arms:       map[string]*ucbArm{},
+	}
+}
+
+// UCBScore returns the content's UCB1 score. Content that was never pulled scores +Inf so it is
+// tried first.
+func (e *UCBExplorer) UCBScore(contentID string, totalPulls int) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.scoreLocked(contentID, totalPulls)
+}
+
+// UpdateReward records one pull of the content and its reward.
+func (e *UCBExplorer) UpdateReward(contentID string, reward float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	arm, ok := e.arms[contentID]
+	if !ok {
+		arm = &ucbArm{}
+		e.arms[contentID] = arm
+	}
+	arm.pulls++
+	arm.reward += reward
+	e.totalPulls++
+}
+
+// UCBScores returns the UCB1 score of every content ID seen so far.
+func (e *UCBExplorer) UCBScores(totalPulls int) map[string]float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	scores := make(map[string]float64, len(e.arms))
+	for contentID := range e.arms {
+		scores[contentID] = e.scoreLocked(contentID, totalPulls)
+	}
+	return scores
+}
+
+// rank orders insertions by UCB1 score using the pulls recorded so far.
+func (e *UCBExplorer) rank(insertions []*delivery.Insertion) {
+	e.mu.Lock()
+	scores := make(map[*delivery.Insertion]float64, len(insertions))
+	for _, ins := range insertions {
+		scores[ins] = e.scoreLocked(ins.GetContentId(), e.totalPulls)
+	}
+	e.mu.Unlock()
+	sort.SliceStable(insertions, func(i, j int) bool {
+		return scores[insertions[i]] > scores[insertions[j]]
+	})
+}
+
+func (e *UCBExplorer) record(contentID string, reward float64) {
+	e.UpdateReward(contentID, reward)
+}
+
+func (e *UCBExplorer) scoreLocked(contentID string, totalPulls int) float64 {
+	arm, ok := e.arms[contentID]
+	if !ok || arm.pulls == 0 {
+		return math.Inf(1)
+	}
+	mean := arm.reward / float64(arm.pulls)
+	if totalPulls <= 1 {
+		return mean
+	}
+	return mean + e.confidence*math.Sqrt(2*math.Log(float64(totalPulls))/float64(arm.pulls))
+}
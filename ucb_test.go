@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestUCBPrefersUnderExploredItem(t *testing.T) {
+	explorer := NewUCBExplorer(UCBConfig{})
+	for i := 0; i < 10; i++ {
+		explorer.UpdateReward("popular", 0.5)
+	}
+	for i := 0; i < 2; i++ {
+		explorer.UpdateReward("rare", 0.5)
+	}
+
+	scores := explorer.UCBScores(12)
+	if scores["rare"] <= scores["popular"] {
+		t.Errorf("got scores %v, want the under-explored item to score higher", scores)
+	}
+
+	bandit := NewBanditDeliveryClient(&fakeDeliveryClient{name: "ranked"}, 0, 0).WithUCBExplorer(explorer)
+	resp, err := bandit.Deliver(context.Background(), newTestDeliveryRequest(t, "popular", "rare"))
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "rare", "popular")
+}
+
+func TestUCBScore(t *testing.T) {
+	explorer := NewUCBExplorer(UCBConfig{Confidence: 2})
+	explorer.UpdateReward("a", 1)
+	explorer.UpdateReward("a", 0)
+
+	want := 0.5 + 2*math.Sqrt(2*math.Log(10)/2)
+	if got := explorer.UCBScore("a", 10); math.Abs(got-want) > 1e-9 {
+		t.Errorf("got score %v, want %v", got, want)
+	}
+	if got := explorer.UCBScore("unseen", 10); !math.IsInf(got, 1) {
+		t.Errorf("got score %v for unseen content, want +Inf", got)
+	}
+}
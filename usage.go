@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// UsageTracker counts calls to builder methods, by name only, so deprecated methods can be
+// removed once nothing calls them. It never records the values passed.
+type UsageTracker struct {
+	counts sync.Map // method name -> *atomic.Int64
+}
+
+// requestBuilderUsage counts DeliveryRequestBuilder calls, which aren't tied to a client.
+var requestBuilderUsage = &UsageTracker{}
+
+// record counts a call to method. It is a no-op on a nil tracker.
+func (u *UsageTracker) record(method string) {
+	if u == nil {
+		return
+	}
+	count, ok := u.counts.Load(method)
+	if !ok {
+		count, _ = u.counts.LoadOrStore(method, &atomic.Int64{})
+	}
+	count.(*atomic.Int64).Add(1)
+}
+
+// Report returns the call count of each method called so far.
+func (u *UsageTracker) Report() map[string]int64 {
+	report := map[string]int64{}
+	if u == nil {
+		return report
+	}
+	u.counts.Range(func(method, count any) bool {
+		report[method.(string)] = count.(*atomic.Int64).Load()
+		return true
+	})
+	return report
+}
+
+// UsageReport returns the call counts of the builder methods used to build this client, plus those
+// of every DeliveryRequestBuilder in the process. It is nil if usage tracking is disabled.
+func (c *DeliveryClient) UsageReport() map[string]int64 {
+	if c.usage == nil {
+		return nil
+	}
+	report := c.usage.Report()
+	for method, count := range requestBuilderUsage.Report() {
+		report[method] += count
+	}
+	return report
+}
+
+// logUsageReport logs the usage report at debug level.
+func (c *DeliveryClient) logUsageReport() {
+	report := c.UsageReport()
+	if report == nil {
+		return
+	}
+	encoded, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	log.Printf("DEBUG: SDK usage report: %s\n", encoded)
+}
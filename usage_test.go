@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUsageReportCountsBuilderCalls(t *testing.T) {
+	before := requestBuilderUsage.Report()
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).
+		WithDisableUsageTracking(false).
+		WithDeliveryTimeoutMillis(100).
+		WithDeliveryTimeoutMillis(200).
+		WithAcceptsGzip(true))
+	buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).WithOnlyLog(true))
+
+	report := c.UsageReport()
+	for method, want := range map[string]int64{
+		"WithDeliveryTimeoutMillis": 2,
+		"WithAcceptsGzip":           1,
+		"WithDeliveryEndpoint":      1,
+		"WithOnlyLog":               before["WithOnlyLog"] + 1,
+	} {
+		if got := report[method]; got != want {
+			t.Errorf("got %d calls to %s, want %d", got, method, want)
+		}
+	}
+	if _, ok := report["WithTelemetry"]; ok {
+		t.Error("got calls to WithTelemetry, which wasn't called")
+	}
+}
+
+func TestUsageReportIsLoggedOnClose(t *testing.T) {
+	logs := captureLog(t)
+	c, err := newTestClientBuilder(t, newFakeAPI(t)).WithDisableUsageTracking(false).WithAcceptsGzip(true).Build()
+	if err != nil {
+		t.Fatalf("error building client: %v", err)
+	}
+	c.Close()
+	if got := logs.String(); !strings.Contains(got, "DEBUG: SDK usage report") || !strings.Contains(got, `"WithAcceptsGzip":1`) {
+		t.Errorf("got logs %q, want the usage report", got)
+	}
+}
+
+func TestUsageTrackingCanBeDisabled(t *testing.T) {
+	logs := captureLog(t)
+	c, err := newTestClientBuilder(t, newFakeAPI(t)).WithDisableUsageTracking(true).Build()
+	if err != nil {
+		t.Fatalf("error building client: %v", err)
+	}
+	if report := c.UsageReport(); report != nil {
+		t.Errorf("got usage report %v with tracking disabled, want none", report)
+	}
+	c.Close()
+	if strings.Contains(logs.String(), "usage report") {
+		t.Errorf("got logs %q, want no usage report", logs.String())
+	}
+}
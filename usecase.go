@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// ParseUseCase parses a use case name such as "SEARCH" or "feed", ignoring case.
+func ParseUseCase(s string) (delivery.UseCase, error) {
+	v, ok := delivery.UseCase_value[strings.ToUpper(strings.TrimSpace(s))]
+	if !ok {
+		return delivery.UseCase_UNKNOWN_USE_CASE, fmt.Errorf("unknown use case %q", s)
+	}
+	return delivery.UseCase(v), nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+const seedsPropertyKey = "seeds"
+
+// UseCaseValidator checks the request fields that each use case relies on. SEARCH requires a
+// SearchQuery, FEED shouldn't have one, and DISCOVER requires seed content IDs listed in the
+// request's "seeds" property.
+type UseCaseValidator struct{}
+
+// NewUseCaseValidator is a factory method for UseCaseValidator.
+func NewUseCaseValidator() *UseCaseValidator {
+	return &UseCaseValidator{}
+}
+
+// Validate returns the human-readable validation failures of req for its use case.
+func (v *UseCaseValidator) Validate(req *delivery.Request) []string {
+	var failures []string
+	for _, w := range v.check(req) {
+		failures = append(failures, w.Message)
+	}
+	return failures
+}
+
+// check returns the failures of req. Missing required fields are errors.
+func (v *UseCaseValidator) check(req *delivery.Request) []LintWarning {
+	useCase := req.GetUseCase()
+	switch useCase {
+	case delivery.UseCase_SEARCH:
+		if req.GetSearchQuery() == "" {
+			return []LintWarning{{Code: "SEARCH_WITHOUT_QUERY", Message: "SEARCH requests require a SearchQuery", Severity: LintSeverityError}}
+		}
+	case delivery.UseCase_FEED:
+		if req.GetSearchQuery() != "" {
+			return []LintWarning{{Code: "FEED_WITH_QUERY", Message: "FEED requests shouldn't have a SearchQuery", Severity: LintSeverityWarning}}
+		}
+	case delivery.UseCase_DISCOVER:
+		if !hasSeedContentID(req) {
+			return []LintWarning{{Code: "DISCOVER_WITHOUT_SEEDS", Message: `DISCOVER requests require at least one seed content ID in Properties["seeds"]`, Severity: LintSeverityError}}
+		}
+	}
+	return nil
+}
+
+// hasSeedContentID says whether the "seeds" property lists a non-empty content ID.
+func hasSeedContentID(req *delivery.Request) bool {
+	for _, seed := range getProperty(req.GetProperties(), seedsPropertyKey).GetListValue().GetValues() {
+		if seed.GetStringValue() != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// middleware rejects requests missing required fields and logs the other failures.
+func (v *UseCaseValidator) middleware(next deliverFunc) deliverFunc {
+	return func(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+		for _, w := range v.check(req.Request) {
+			if w.Severity == LintSeverityError {
+				return nil, fmt.Errorf("request failed use case validation %s: %s", w.Code, w.Message)
+			}
+			log.Printf("WARN: request use case validation %s: %s\n", w.Code, w.Message)
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// useCaseRequest returns a request for useCase with the search query and seeds, if set.
+func useCaseRequest(t *testing.T, useCase delivery.UseCase, query string, seeds ...any) *delivery.Request {
+	t.Helper()
+	req := &delivery.Request{
+		UserInfo:    &common.UserInfo{AnonUserId: "anon-1"},
+		UseCase:     useCase,
+		SearchQuery: query,
+		Insertion:   testInsertions("a", "b"),
+	}
+	if seeds != nil {
+		if err := setProperty(&req.Properties, seedsPropertyKey, seeds); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return req
+}
+
+func TestUseCaseValidatorValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		req     *delivery.Request
+		failure string
+	}{
+		{"search with query", useCaseRequest(t, delivery.UseCase_SEARCH, "shoes"), ""},
+		{"search without query", useCaseRequest(t, delivery.UseCase_SEARCH, ""), "SEARCH requests require a SearchQuery"},
+		{"feed without query", useCaseRequest(t, delivery.UseCase_FEED, ""), ""},
+		{"feed with query", useCaseRequest(t, delivery.UseCase_FEED, "shoes"), "FEED requests shouldn't have a SearchQuery"},
+		{"discover with seeds", useCaseRequest(t, delivery.UseCase_DISCOVER, "", "seed-1"), ""},
+		{"discover without seeds", useCaseRequest(t, delivery.UseCase_DISCOVER, ""), "DISCOVER requests require"},
+		{"discover with empty seeds", useCaseRequest(t, delivery.UseCase_DISCOVER, "", ""), "DISCOVER requests require"},
+		{"discover with non-string seeds", useCaseRequest(t, delivery.UseCase_DISCOVER, "", 1.0), "DISCOVER requests require"},
+		{"other use case", useCaseRequest(t, delivery.UseCase_CATEGORY_CONTENT, "shoes"), ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			failures := NewUseCaseValidator().Validate(tc.req)
+			if tc.failure == "" {
+				if len(failures) != 0 {
+					t.Errorf("got failures %q, want none", failures)
+				}
+				return
+			}
+			if len(failures) != 1 || !strings.HasPrefix(failures[0], tc.failure) {
+				t.Errorf("got failures %q, want %q", failures, tc.failure)
+			}
+		})
+	}
+}
+
+func TestUseCaseValidationRejectsMissingFields(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithUseCaseValidation(true))
+
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(useCaseRequest(t, delivery.UseCase_SEARCH, "")))
+	if _, err := c.Deliver(context.Background(), req); err == nil || !strings.Contains(err.Error(), "SEARCH_WITHOUT_QUERY") {
+		t.Errorf("got error %v, want SEARCH_WITHOUT_QUERY", err)
+	}
+	if got := api.calls(); got != 0 {
+		t.Errorf("rejected request called the Delivery API %d times", got)
+	}
+}
+
+func TestUseCaseValidationWarnsAndSends(t *testing.T) {
+	logs := captureLog(t)
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithUseCaseValidation(true))
+
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(useCaseRequest(t, delivery.UseCase_FEED, "shoes")))
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.calls(); got != 1 {
+		t.Errorf("got %d Delivery API calls, want 1", got)
+	}
+	if !strings.Contains(logs.String(), "WARN: request use case validation FEED_WITH_QUERY") {
+		t.Errorf("no FEED_WITH_QUERY warning in logs:\n%s", logs.String())
+	}
+}
+
+func TestUseCaseValidationIsOffByDefault(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(useCaseRequest(t, delivery.UseCase_DISCOVER, "")))
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.calls(); got != 1 {
+		t.Errorf("got %d Delivery API calls, want 1", got)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// UserIDAnonymizer maps raw user IDs to stable IDs that can't be reversed.
+type UserIDAnonymizer interface {
+	Anonymize(rawID string) string
+}
+
+// hmacUserIDAnonymizer hashes user IDs with HMAC-SHA256 under a secret pepper.
+type hmacUserIDAnonymizer struct {
+	pepper []byte
+}
+
+// NewHMACUserIDAnonymizer returns an anonymizer that keeps the first 16 hex characters of
+// HMAC-SHA256(pepper, rawID).
+func NewHMACUserIDAnonymizer(pepper []byte) UserIDAnonymizer {
+	return &hmacUserIDAnonymizer{pepper: append([]byte(nil), pepper...)}
+}
+
+func (a *hmacUserIDAnonymizer) Anonymize(rawID string) string {
+	mac := hmac.New(sha256.New, a.pepper)
+	mac.Write([]byte(rawID))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// anonymizeUserInfo replaces req's user IDs with anonymized ones in place, so callers pass a copy
+// of the caller's request.
+func anonymizeUserInfo(req *delivery.Request, anonymizer UserIDAnonymizer) {
+	userInfo := req.GetUserInfo()
+	if userInfo == nil {
+		return
+	}
+	if userInfo.UserId != "" {
+		userInfo.UserId = anonymizer.Anonymize(userInfo.UserId)
+	}
+	if userInfo.AnonUserId != "" {
+		userInfo.AnonUserId = anonymizer.Anonymize(userInfo.AnonUserId)
+	}
+}
+
+// AnonymizedUserID returns the ID that is logged in place of raw, or raw itself if no anonymizer
+// is configured.
+func (c *DeliveryClient) AnonymizedUserID(raw string) string {
+	if c.userIDAnonymizer == nil {
+		return raw
+	}
+	return c.userIDAnonymizer.Anonymize(raw)
+}
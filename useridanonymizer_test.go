@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+func TestHMACUserIDAnonymizerIsDeterministic(t *testing.T) {
+	a := NewHMACUserIDAnonymizer([]byte("pepper"))
+	if a.Anonymize("user-1") != a.Anonymize("user-1") {
+		t.Error("anonymizing the same ID twice gave different results")
+	}
+	if a.Anonymize("user-1") == a.Anonymize("user-2") {
+		t.Error("different IDs anonymized to the same value")
+	}
+	if a.Anonymize("user-1") == NewHMACUserIDAnonymizer([]byte("other")).Anonymize("user-1") {
+		t.Error("different peppers anonymized to the same value")
+	}
+}
+
+func TestHMACUserIDAnonymizerIsNotReversible(t *testing.T) {
+	got := NewHMACUserIDAnonymizer([]byte("pepper")).Anonymize("user-1")
+	if !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(got) {
+		t.Errorf("got %q, want 16 hex characters", got)
+	}
+	if strings.Contains(got, "user") {
+		t.Errorf("got %q, which leaks the raw ID", got)
+	}
+}
+
+func TestLoggedUserIDsAreAnonymized(t *testing.T) {
+	api := newFakeAPI(t)
+	metrics, logged := newRecordingServer(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).
+		WithMetricsEndpoint(metrics.URL).
+		WithUserIDAnonymizationPepper([]byte("pepper")))
+
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(&delivery.Request{
+		UserInfo:  &common.UserInfo{UserId: "raw-user", AnonUserId: "raw-anon"},
+		Insertion: testInsertions("a"),
+	}).WithOnlyLog(true))
+	if _, err := c.Deliver(context.Background(), req); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	waitFor(t, "the log request", func() bool { return len(logged()) > 0 })
+
+	body := logged()[0]
+	if strings.Contains(body, "raw-user") || strings.Contains(body, "raw-anon") {
+		t.Errorf("log request contains raw user IDs: %s", body)
+	}
+	if !strings.Contains(body, c.AnonymizedUserID("raw-user")) {
+		t.Errorf("log request doesn't contain the anonymized user ID: %s", body)
+	}
+	if got := req.Request.GetUserInfo().GetUserId(); got != "raw-user" {
+		t.Errorf("the caller's user ID became %q", got)
+	}
+}
+
+func TestDeliveryAPIGetsRawUserIDs(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api).WithUserIDAnonymizationPepper([]byte("pepper")))
+
+	if _, err := c.Deliver(context.Background(), newUserDeliveryRequest(t, "raw-user", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := api.lastRequest(t).GetUserInfo().GetUserId(); got != "raw-user" {
+		t.Errorf("the Delivery API got user ID %q, want the raw ID to personalize", got)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	client "github.com/promotedai/promoted-go-delivery-client/delivery"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/proto"
+)
+
+// cloneResponse returns a deep copy of resp that is safe to modify, e.g. when resp may be cached.
+func cloneResponse(resp *DeliveryResponse) *DeliveryResponse {
+	sdkResp := *resp.DeliveryResponse
+	sdkResp.Response = proto.Clone(resp.Response).(*delivery.Response)
+	cloned := *resp
+	cloned.DeliveryResponse = &sdkResp
+	return &cloned
+}
+
+// newSDKResponse serves req in its original order without calling the Delivery API or logging to
+// the Metrics API.
+func newSDKResponse(req *DeliveryRequest, fallbackReason string) (*DeliveryResponse, error) {
+	resp, err := client.NewSDKDelivery().RunDelivery(req.DeliveryRequest)
+	if err != nil {
+		return nil, err
+	}
+	return &DeliveryResponse{
+		DeliveryResponse: &client.DeliveryResponse{
+			Response:        resp,
+			ClientRequestID: req.Request.GetClientRequestId(),
+			ExecutionServer: delivery.ExecutionServer_SDK,
+		},
+		FallbackReason: fallbackReason,
+	}, nil
+}
+
+// cloneRequest returns a copy of req whose proto request is safe to modify, keeping its SDK options.
+func cloneRequest(req *DeliveryRequest) *DeliveryRequest {
+	sdkReq := *req.DeliveryRequest
+	sdkReq.Request = proto.Clone(req.Request).(*delivery.Request)
+	cloned := *req
+	cloned.DeliveryRequest = &sdkReq
+	return &cloned
+}
+
+// renumberPositions assigns consecutive positions to reordered insertions, starting from the
+// smallest position among them.
+func renumberPositions(insertions []*delivery.Insertion) {
+	var start uint64
+	found := false
+	for _, ins := range insertions {
+		if ins.Position != nil && (!found || *ins.Position < start) {
+			start = *ins.Position
+			found = true
+		}
+	}
+	for i, ins := range insertions {
+		position := start + uint64(i)
+		ins.Position = &position
+	}
+}
+
+// requestUserID is the logged-in user ID, or the anonymous one for logged-out users.
+func requestUserID(req *delivery.Request) string {
+	if userID := req.GetUserInfo().GetUserId(); userID != "" {
+		return userID
+	}
+	return req.GetUserInfo().GetAnonUserId()
+}
+
+// requestInsertionsByContentID indexes the request's insertions, e.g. to read the properties that
+// response insertions don't carry.
+func requestInsertionsByContentID(req *DeliveryRequest) map[string]*delivery.Insertion {
+	insertions := make(map[string]*delivery.Insertion, len(req.Request.GetInsertion()))
+	for _, ins := range req.Request.GetInsertion() {
+		insertions[ins.GetContentId()] = ins
+	}
+	return insertions
+}
+
+// cacheable says whether resp may be served again for other calls. SDK fallbacks aren't, so that a
+// transient Delivery API failure doesn't pin the unranked order.
+func cacheable(resp *DeliveryResponse) bool {
+	return resp.APIError == nil && resp.FallbackReason == ""
+}
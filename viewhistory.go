@@ -0,0 +1,148 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+)
+
+const viewHistoryPropertyKey = "viewHistory"
+
+const defaultMaxViewHistoryItems = 50
+const defaultMaxViewHistoryUsers = 10000
+
+// ViewedItem is one item the user viewed.
+type ViewedItem struct {
+	ContentID        string    `json:"contentId"`
+	ViewedAt         time.Time `json:"viewedAt"`
+	DwellTimeSeconds float64   `json:"dwellTimeSeconds"`
+}
+
+// ViewHistory is the user's recently viewed items, sent as session context.
+type ViewHistory struct {
+	Views []ViewedItem
+}
+
+// appendViewHistory adds views after any already in the viewHistory property.
+func appendViewHistory(props **common.Properties, views []ViewedItem) error {
+	var existing []ViewedItem
+	if err := getJSONProperty(*props, viewHistoryPropertyKey, &existing); err != nil {
+		return err
+	}
+	return setJSONProperty(props, viewHistoryPropertyKey, append(existing, views...))
+}
+
+// ViewHistoryTracker records the content each user was shown and sends it as view history with
+// that user's next request. The least recently seen users are forgotten past maxUsers.
+type ViewHistoryTracker struct {
+	client   DeliveryClientInterface
+	maxItems int
+	maxUsers int
+	// optedOut users' views are neither sent nor recorded.
+	optedOut func(ctx context.Context) bool
+
+	mu    sync.Mutex
+	users map[string]*list.Element
+	lru   *list.List
+}
+
+// userViews is the view history of one user.
+type userViews struct {
+	userID string
+	views  []ViewedItem
+}
+
+// NewViewHistoryTracker wraps c, keeping the 50 most recent views of each of the 10000 most recently
+// seen users by default.
+func NewViewHistoryTracker(c DeliveryClientInterface) *ViewHistoryTracker {
+	return &ViewHistoryTracker{
+		client:   c,
+		maxItems: defaultMaxViewHistoryItems,
+		maxUsers: defaultMaxViewHistoryUsers,
+		users:    map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// WithMaxViewHistoryItems keeps at most n views per user. Non-positive n keeps the default.
+func (t *ViewHistoryTracker) WithMaxViewHistoryItems(n int) *ViewHistoryTracker {
+	if n > 0 {
+		t.maxItems = n
+	}
+	return t
+}
+
+// WithMaxViewHistoryUsers keeps the views of at most n users. Non-positive n keeps the default.
+func (t *ViewHistoryTracker) WithMaxViewHistoryUsers(n int) *ViewHistoryTracker {
+	if n > 0 {
+		t.maxUsers = n
+	}
+	return t
+}
+
+// WithGDPROptOut stops tracking calls where fn reports the user opted out under GDPR, e.g. the
+// client's own WithGDPROptOut func.
+func (t *ViewHistoryTracker) WithGDPROptOut(fn func(ctx context.Context) bool) *ViewHistoryTracker {
+	t.optedOut = fn
+	return t
+}
+
+// Deliver attaches the user's tracked views to the request and records the response's content.
+// Requests without a user ID or from opted-out users aren't tracked.
+func (t *ViewHistoryTracker) Deliver(ctx context.Context, req *DeliveryRequest) (*DeliveryResponse, error) {
+	userID := requestUserID(req.Request)
+	if userID == "" || (t.optedOut != nil && t.optedOut(ctx)) {
+		return t.client.Deliver(ctx, req)
+	}
+	if views := t.History(userID).Views; len(views) > 0 {
+		req = cloneRequest(req)
+		if err := appendViewHistory(&req.Request.Properties, views); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.client.Deliver(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elem, ok := t.users[userID]
+	if ok {
+		t.lru.MoveToFront(elem)
+	} else {
+		elem = t.lru.PushFront(&userViews{userID: userID})
+		t.users[userID] = elem
+		for t.lru.Len() > t.maxUsers {
+			oldest := t.lru.Back()
+			t.lru.Remove(oldest)
+			delete(t.users, oldest.Value.(*userViews).userID)
+		}
+	}
+	entry := elem.Value.(*userViews)
+	views := entry.views
+	for _, ins := range resp.Response.GetInsertion() {
+		views = append(views, ViewedItem{ContentID: ins.GetContentId(), ViewedAt: now})
+	}
+	if len(views) > t.maxItems {
+		views = append([]ViewedItem(nil), views[len(views)-t.maxItems:]...)
+	}
+	entry.views = views
+	return resp, nil
+}
+
+// History returns the views tracked for the user, oldest first.
+func (t *ViewHistoryTracker) History(userID string) ViewHistory {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elem, ok := t.users[userID]
+	if !ok {
+		return ViewHistory{}
+	}
+	return ViewHistory{Views: append([]ViewedItem(nil), elem.Value.(*userViews).views...)}
+}
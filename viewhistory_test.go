@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// sentViewHistory returns the content IDs in the view history of the fake client's last request.
+func sentViewHistory(t *testing.T, f *fakeDeliveryClient) []string {
+	t.Helper()
+	f.mu.Lock()
+	req := f.requests[len(f.requests)-1]
+	f.mu.Unlock()
+	var views []ViewedItem
+	if err := getJSONProperty(req.Request.GetProperties(), viewHistoryPropertyKey, &views); err != nil {
+		t.Fatalf("error reading view history: %v", err)
+	}
+	ids := make([]string, len(views))
+	for i, v := range views {
+		ids[i] = v.ContentID
+	}
+	return ids
+}
+
+func TestViewHistoryAccumulatesAcrossCalls(t *testing.T) {
+	f := &fakeDeliveryClient{name: "ranked"}
+	tracker := NewViewHistoryTracker(f)
+
+	for _, ids := range [][]string{{"a", "b"}, {"c"}, {"d"}} {
+		if _, err := tracker.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", ids...)); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if got, want := sentViewHistory(t, f), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("third request carried views %v, want %v", got, want)
+	}
+	if got := len(tracker.History("user-1").Views); got != 4 {
+		t.Errorf("got %d tracked views, want 4", got)
+	}
+	if got := len(tracker.History("user-2").Views); got != 0 {
+		t.Errorf("got %d views for another user, want 0", got)
+	}
+}
+
+func TestViewHistoryIsCapped(t *testing.T) {
+	tracker := NewViewHistoryTracker(&fakeDeliveryClient{name: "ranked"}).WithMaxViewHistoryItems(3)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := tracker.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", id)); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	var got []string
+	for _, v := range tracker.History("user-1").Views {
+		got = append(got, v.ContentID)
+	}
+	if want := []string{"c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got views %v, want the 3 most recent %v", got, want)
+	}
+}
+
+func TestViewHistoryIgnoresNonPositiveCap(t *testing.T) {
+	tracker := NewViewHistoryTracker(&fakeDeliveryClient{name: "ranked"}).WithMaxViewHistoryItems(-1)
+	if _, err := tracker.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a", "b")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := len(tracker.History("user-1").Views); got != 2 {
+		t.Errorf("got %d views, want 2", got)
+	}
+}
+
+func TestViewHistoryEvictsLeastRecentUsers(t *testing.T) {
+	tracker := NewViewHistoryTracker(&fakeDeliveryClient{name: "ranked"}).WithMaxViewHistoryUsers(2)
+	for _, user := range []string{"user-1", "user-2", "user-1", "user-3"} {
+		if _, err := tracker.Deliver(context.Background(), newUserDeliveryRequest(t, user, "a")); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if len(tracker.History("user-2").Views) != 0 {
+		t.Error("least recently seen user wasn't evicted")
+	}
+	if len(tracker.History("user-1").Views) != 2 || len(tracker.History("user-3").Views) != 1 {
+		t.Error("recently seen users were evicted")
+	}
+}
+
+func TestViewHistoryIsGoroutineSafe(t *testing.T) {
+	tracker := NewViewHistoryTracker(&fakeDeliveryClient{name: "ranked"}).WithMaxViewHistoryItems(1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tracker.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a")); err != nil {
+				t.Errorf("Deliver failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := len(tracker.History("user-1").Views); got != 20 {
+		t.Errorf("got %d views from 20 concurrent calls, want 20", got)
+	}
+}
+
+func TestViewHistorySkipsOptedOutUsers(t *testing.T) {
+	f := &fakeDeliveryClient{name: "ranked"}
+	optedOut := true
+	tracker := NewViewHistoryTracker(f).WithGDPROptOut(func(context.Context) bool { return optedOut })
+
+	if _, err := tracker.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "a")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := len(tracker.History("user-1").Views); got != 0 {
+		t.Errorf("got %d views recorded for an opted-out user, want 0", got)
+	}
+
+	optedOut = false
+	if _, err := tracker.Deliver(context.Background(), newUserDeliveryRequest(t, "user-1", "b")); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if got := sentViewHistory(t, f); len(got) != 0 {
+		t.Errorf("sent views %v from while the user was opted out", got)
+	}
+}
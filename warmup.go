@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+)
+
+// Warmup checks that the Delivery API is reachable, e.g. so a server fails fast at startup. It sends
+// a minimal request, with no insertions or user data, to the health endpoint.
+func (c *DeliveryClient) Warmup(ctx context.Context) error {
+	request := &delivery.Request{
+		ClientInfo: &common.ClientInfo{
+			ClientType:  common.ClientInfo_PLATFORM_SERVER,
+			TrafficType: common.ClientInfo_PRODUCTION,
+		},
+	}
+	if err := c.deliveryAPI.runWarmup(ctx, request); err != nil {
+		return fmt.Errorf("error warming up Delivery API at %s: %v", c.deliveryAPI.healthHTTPEndpoint, err)
+	}
+	return nil
+}
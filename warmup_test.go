@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/promotedai/schema/generated/go/proto/common"
+	"github.com/promotedai/schema/generated/go/proto/delivery"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// newHealthServer answers the health endpoint with status after delay, returning the last warmup
+// request it got.
+func newHealthServer(t *testing.T, status int, delay time.Duration) (*httptest.Server, func() *delivery.Request) {
+	t.Helper()
+	var mu sync.Mutex
+	var last *delivery.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != healthEndpointSuffix {
+			t.Errorf("got request to %s, want only the health endpoint", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req delivery.Request
+		if err := protojson.Unmarshal(body, &req); err != nil {
+			t.Errorf("error unmarshaling warmup request: %v", err)
+		}
+		mu.Lock()
+		last = &req
+		mu.Unlock()
+		time.Sleep(delay)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() *delivery.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+func TestWarmupSucceedsOnHealthyAPI(t *testing.T) {
+	health, lastRequest := newHealthServer(t, http.StatusOK, 0)
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithDeliveryEndpoint(health.URL))
+
+	if err := c.Warmup(context.Background()); err != nil {
+		t.Fatalf("Warmup failed: %v", err)
+	}
+	req := lastRequest()
+	if req.GetClientInfo().GetClientType() != common.ClientInfo_PLATFORM_SERVER {
+		t.Errorf("got client info %v, want a platform server", req.GetClientInfo())
+	}
+	if len(req.GetInsertion()) != 0 || req.GetUserInfo() != nil {
+		t.Errorf("got warmup request %v, want client info only", req)
+	}
+}
+
+func TestWarmupFailsOnUnhealthyAPI(t *testing.T) {
+	health, _ := newHealthServer(t, http.StatusServiceUnavailable, 0)
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithDeliveryEndpoint(health.URL))
+
+	err := c.Warmup(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), health.URL) {
+		t.Errorf("got error %v, want one naming the endpoint and the 503", err)
+	}
+}
+
+func TestWarmupTimesOut(t *testing.T) {
+	health, _ := newHealthServer(t, http.StatusOK, 200*time.Millisecond)
+	c := buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).
+		WithDeliveryEndpoint(health.URL).
+		WithDeliveryTimeoutMillis(20))
+
+	if err := c.Warmup(context.Background()); err == nil {
+		t.Error("Warmup succeeded past the delivery timeout")
+	}
+}
+
+func TestWarmupOnBuild(t *testing.T) {
+	unhealthy, _ := newHealthServer(t, http.StatusServiceUnavailable, 0)
+	if _, err := newTestClientBuilder(t, newFakeAPI(t)).WithDeliveryEndpoint(unhealthy.URL).WithWarmupOnBuild(true).Build(); err == nil {
+		t.Error("Build succeeded with an unhealthy Delivery API")
+	}
+
+	healthy, lastRequest := newHealthServer(t, http.StatusOK, 0)
+	buildTestClient(t, newTestClientBuilder(t, newFakeAPI(t)).WithDeliveryEndpoint(healthy.URL).WithWarmupOnBuild(true))
+	if lastRequest() == nil {
+		t.Error("Build didn't warm up")
+	}
+}
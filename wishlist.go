@@ -0,0 +1,38 @@
+package main
+
+import "github.com/promotedai/schema/generated/go/proto/delivery"
+
+const wishlistPropertyKey = "wishlist"
+
+const defaultWishlistBoostMultiplier = 1.5
+const defaultMaxWishlistItems = 50
+
+// WishlistContext lists the content the user wishlisted, most relevant first.
+type WishlistContext struct {
+	WishlistedContentIDs []string
+}
+
+// applyWishlist sends the first maxItems wishlisted IDs in the wishlist request property and
+// boosts those insertions by multiplier.
+func applyWishlist(req *delivery.Request, wl WishlistContext, multiplier float64, maxItems int) error {
+	ids := wl.WishlistedContentIDs
+	if len(ids) > maxItems {
+		ids = ids[:maxItems]
+	}
+	if err := setJSONProperty(&req.Properties, wishlistPropertyKey, ids); err != nil {
+		return err
+	}
+	wishlisted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wishlisted[id] = true
+	}
+	for _, ins := range req.Insertion {
+		if !wishlisted[ins.GetContentId()] {
+			continue
+		}
+		if err := multiplyBoost(ins, multiplier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWishlistedItemsAreBoosted(t *testing.T) {
+	api := newFakeAPI(t)
+	c := buildTestClient(t, newTestClientBuilder(t, api))
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a", "b", "c").Request).
+		WithWishlistContext(WishlistContext{WishlistedContentIDs: []string{"c", "x"}}).
+		WithWishlistBoostMultiplier(4))
+
+	resp, err := c.Deliver(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	assertContentIDs(t, resp, "c", "a", "b")
+	if got := sentProperty(t, api, "c", boostMultiplierPropertyKey); got != 4.0 {
+		t.Errorf("sent boost multiplier %v for the wishlisted item, want 4", got)
+	}
+	if got := sentProperty(t, api, "a", boostMultiplierPropertyKey); got != nil {
+		t.Errorf("sent boost multiplier %v for an item not wishlisted, want none", got)
+	}
+}
+
+func TestWishlistBoostDefault(t *testing.T) {
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a").Request).
+		WithWishlistContext(WishlistContext{WishlistedContentIDs: []string{"a"}}))
+	if got := getProperty(req.Request.GetInsertion()[0].GetProperties(), boostMultiplierPropertyKey).GetNumberValue(); got != 1.5 {
+		t.Errorf("got boost multiplier %v, want the default 1.5", got)
+	}
+}
+
+func TestWishlistIsCapped(t *testing.T) {
+	req := buildTestRequest(t, NewDeliveryRequestBuilder(newTestDeliveryRequest(t, "a", "b", "c").Request).
+		WithWishlistContext(WishlistContext{WishlistedContentIDs: []string{"a", "b", "c"}}).
+		WithMaxWishlistItems(2))
+
+	var sent []string
+	if err := getJSONProperty(req.Request.GetProperties(), wishlistPropertyKey, &sent); err != nil {
+		t.Fatalf("error reading wishlist property: %v", err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent wishlist %v, want %v", sent, want)
+	}
+	// Items past the cap aren't boosted either.
+	if getProperty(req.Request.GetInsertion()[2].GetProperties(), boostMultiplierPropertyKey) != nil {
+		t.Error("boosted a wishlisted item past the cap")
+	}
+}